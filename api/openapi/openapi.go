@@ -0,0 +1,9 @@
+// Package openapi embeds the spec of the REST gateway generated from api/*.proto
+package openapi
+
+import _ "embed"
+
+// Spec in OpenAPI 2.0 JSON format
+//
+//go:embed gophkeeper.swagger.json
+var Spec []byte
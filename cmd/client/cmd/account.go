@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/client/pkg/export"
+	"gophkeeper/internal/client/pkg/output"
+	"gophkeeper/internal/client/pkg/render"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	accountCmd = &cobra.Command{
+		Use:   "account",
+		Short: "Account management",
+		Long:  `Choose one of the command to do with your account`,
+		Run: func(cmd *cobra.Command, args []string) {
+			checkErr(cmd.Help())
+		},
+	}
+	accountUsageCmd = &cobra.Command{
+		Use:   "usage",
+		Short: "Show storage usage",
+		Long:  `Allows you to see storage consumption against account limits`,
+		Run:   accountUsage,
+	}
+	accountDeleteCmd = &cobra.Command{
+		Use:   "delete",
+		Short: "Delete account with all secrets",
+		Long: `Permanently deletes your account, all secrets and sessions. Password is asked for interactively
+and account email has to be typed to confirm. Use --export to save an encrypted archive of all secrets first.`,
+		Run: accountDelete,
+	}
+	accountImportCmd = &cobra.Command{
+		Use:   "import FILE",
+		Short: "Import secrets from an exported archive",
+		Long: `Decrypts an archive saved by account delete --export and creates its secrets in the current account.
+Secrets with names already taken are skipped unless --overwrite is set.`,
+		Example: `  gkcli account import gophkeeper-export.json
+  gkcli account import gophkeeper-export.json --overwrite`,
+		Args: cobra.ExactArgs(1),
+		Run:  accountImport,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(accountCmd)
+	accountCmd.AddCommand(accountUsageCmd)
+	accountCmd.AddCommand(accountDeleteCmd)
+	accountCmd.AddCommand(accountImportCmd)
+	accountDeleteCmd.Flags().String("confirm", "", "account email to confirm deletion without a prompt")
+	accountDeleteCmd.Flags().String("export", "", "save secrets to a passphrase encrypted archive before deletion")
+	accountDeleteCmd.Flags().String("otp", "", "two-factor code from the authenticator app, asked for when required")
+	accountDeleteCmd.Flags().String("recovery-code", "", "one-time recovery code to use instead of a two-factor code")
+	accountImportCmd.Flags().Bool("overwrite", false, "replace secrets with names already taken")
+}
+
+func accountUsage(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	cl, stop := getKeeperClient()
+	defer stop()
+
+	resp, err := cl.GetUsage(ctx, &pb.GetUsageRequest{})
+	switch status.Code(err) {
+	case codes.OK:
+		// usage ok
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	default:
+		fail(err, "")
+	}
+
+	checkErr(out.Print(&usageView{
+		Secrets:       resp.GetSecrets(),
+		TotalSize:     resp.GetTotalSize(),
+		MaxSecrets:    resp.GetMaxSecrets(),
+		MaxTotalSize:  resp.GetMaxTotalSize(),
+		MaxSecretSize: resp.GetMaxSecretSize(),
+	}))
+}
+
+func accountDelete(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	email := authViper.GetString("email")
+	if email == "" || authToken() == "" {
+		checkErr(errors.New("not logged in"))
+	}
+
+	exportPath, err := cmd.Flags().GetString("export")
+	checkErr(err)
+	confirm, err := cmd.Flags().GetString("confirm")
+	checkErr(err)
+
+	if confirm == "" {
+		l.Warn().Str("email", email).Msg("Account and all secrets will be deleted permanently")
+		confirm, err = prompt("Type account email to confirm: ")
+		checkErr(err)
+	}
+	if confirm != email {
+		checkErr(errors.New("confirmation does not match account email"))
+	}
+
+	password, err := promptPassword("Password: ")
+	checkErr(err)
+
+	if exportPath != "" {
+		checkErr(exportAccount(ctx, email, exportPath))
+		l.Info().Str("path", exportPath).Msg("Secrets exported")
+	}
+
+	otp, recovery := twoFactorFlags(cmd)
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	req := &pb.DeleteAccountRequest{
+		OtpCode:      otp,
+		RecoveryCode: recovery,
+	}
+	doDelete := func() error {
+		// each proof is good for a single call
+		proof, err := srpProof(ctx, cl, email, password)
+		if err != nil {
+			return err
+		}
+		if proof == nil {
+			req.Password = password
+		}
+		req.SrpProof = proof
+		_, err = cl.DeleteAccount(ctx, req)
+		return err
+	}
+	err = doDelete()
+
+	if status.Code(err) == codes.PermissionDenied && output.Reason(err) == reasonTwoFactorRequired {
+		req.OtpCode, err = prompt("Two-factor code: ")
+		checkErr(err)
+		err = doDelete()
+	}
+
+	switch status.Code(err) {
+	case codes.OK:
+		// deleted
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	default:
+		fail(err, "")
+	}
+
+	checkErr(saveAuth("", ""))
+
+	l.Info().Msg("Account deleted")
+}
+
+// exportAccount saves all secrets to an encrypted archive
+func exportAccount(ctx context.Context, email, path string) error {
+	passphrase, err := promptPassword("Export passphrase: ")
+	if err != nil {
+		return err
+	}
+	repeated, err := promptPassword("Repeat export passphrase: ")
+	if err != nil {
+		return err
+	}
+	if passphrase == "" {
+		return errors.New("export passphrase is empty")
+	}
+	if passphrase != repeated {
+		return errors.New("passphrases do not match")
+	}
+
+	cl, stop := getKeeperClient()
+	defer stop()
+
+	a, err := archiveSecrets(ctx, cl, email)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := export.Write(&buf, a, passphrase, export.DefaultParams); err != nil {
+		return err
+	}
+
+	return render.WriteFile(path, buf.Bytes())
+}
+
+// archiveSecrets reads all secrets with their content
+func archiveSecrets(ctx context.Context, cl pb.KeeperClient, email string) (*export.Archive, error) {
+	list, err := cl.ListSecrets(ctx, &pb.ListSecretsRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	a := &export.Archive{
+		Account:    email,
+		ExportedAt: time.Now().UTC(),
+		Secrets:    make([]export.Secret, 0, len(list.GetSecrets())),
+	}
+	for _, d := range list.GetSecrets() {
+		resp, err := cl.ReadSecret(ctx, &pb.ReadSecretRequest{Name: d.GetName()})
+		if err != nil {
+			return nil, err
+		}
+		a.Secrets = append(a.Secrets, export.Secret{
+			Name:      resp.GetName(),
+			Type:      resp.GetType(),
+			CreatedAt: resp.GetCreatedAt().AsTime(),
+			Content:   resp.GetContent(),
+			Tags:      resp.GetTags(),
+		})
+	}
+
+	return a, nil
+}
+
+func accountImport(cmd *cobra.Command, args []string) {
+	overwrite, err := cmd.Flags().GetBool("overwrite")
+	checkErr(err)
+
+	f, err := os.Open(args[0])
+	checkErr(err)
+	defer func() {
+		_ = f.Close()
+	}()
+
+	passphrase, err := promptPassword("Export passphrase: ")
+	checkErr(err)
+
+	a, err := export.Read(f, passphrase)
+	if errors.Is(err, export.ErrDecrypt) {
+		fail(err, "Unable to decrypt archive")
+	}
+	checkErr(err)
+
+	ctx := context.Background()
+
+	cl, stop := getKeeperClient()
+	defer stop()
+
+	v, err := importSecrets(ctx, cl, a, overwrite)
+	switch status.Code(err) {
+	case codes.OK:
+		// import ok
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	default:
+		fail(err, "")
+	}
+
+	if !out.Structured() {
+		l.Info().Str("account", a.Account).Int("secrets", len(a.Secrets)).Int("skipped", v.count("skipped")).Msg("Secrets imported")
+	}
+	checkErr(out.Print(v))
+}
+
+// importSecrets of the archive, secrets with names already taken are skipped or replaced with overwrite
+func importSecrets(ctx context.Context, cl pb.KeeperClient, a *export.Archive, overwrite bool) (secretImportView, error) {
+	v := make(secretImportView, 0, len(a.Secrets))
+	for _, s := range a.Secrets {
+		res := "created"
+		_, err := cl.CreateSecret(ctx, &pb.CreateSecretRequest{
+			Name:    s.Name,
+			Type:    s.Type,
+			Content: s.Content,
+			Tags:    s.Tags,
+		})
+		if status.Code(err) == codes.AlreadyExists {
+			res = "skipped"
+			if overwrite {
+				res = "replaced"
+				_, err = cl.UpdateSecret(ctx, &pb.UpdateSecretRequest{
+					Name:    s.Name,
+					Type:    s.Type,
+					Content: s.Content,
+					Tags:    &pb.SecretTags{Tags: s.Tags},
+				})
+			} else {
+				err = nil
+			}
+		}
+		if err != nil {
+			l.Error().Str("name", s.Name).Msg("Unable to import secret")
+			return v, err
+		}
+		v = append(v, secretChangeView{Name: s.Name, Type: s.Type, Result: res})
+	}
+	return v, nil
+}
+
+func formatLimit(limit int64) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return strconv.FormatInt(limit, 10)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/client/pkg/export"
+	"testing"
+)
+
+// memKeeper is a keeper client storing secrets in memory
+type memKeeper struct {
+	pb.KeeperClient
+	secrets map[string]*pb.Secret
+}
+
+func (k *memKeeper) ListSecrets(context.Context, *pb.ListSecretsRequest, ...grpc.CallOption) (*pb.ListSecretsResponse, error) {
+	resp := &pb.ListSecretsResponse{}
+	for _, s := range k.secrets {
+		resp.Secrets = append(resp.Secrets, &pb.SecretDescription{Name: s.GetName(), Type: s.GetType(), Tags: s.GetTags()})
+	}
+	return resp, nil
+}
+
+func (k *memKeeper) ReadSecret(_ context.Context, in *pb.ReadSecretRequest, _ ...grpc.CallOption) (*pb.ReadSecretResponse, error) {
+	s, ok := k.secrets[in.GetName()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	return &pb.ReadSecretResponse{Name: s.GetName(), Type: s.GetType(), Content: s.GetContent(), Tags: s.GetTags(), CreatedAt: timestamppb.Now()}, nil
+}
+
+func (k *memKeeper) CreateSecret(_ context.Context, in *pb.CreateSecretRequest, _ ...grpc.CallOption) (*pb.CreateSecretResponse, error) {
+	if _, ok := k.secrets[in.GetName()]; ok {
+		return nil, status.Error(codes.AlreadyExists, "exists")
+	}
+	k.secrets[in.GetName()] = &pb.Secret{Name: in.GetName(), Type: in.GetType(), Content: in.GetContent(), Tags: in.GetTags()}
+	return &pb.CreateSecretResponse{Name: in.GetName(), Type: in.GetType()}, nil
+}
+
+func (k *memKeeper) UpdateSecret(_ context.Context, in *pb.UpdateSecretRequest, _ ...grpc.CallOption) (*pb.UpdateSecretResponse, error) {
+	s, ok := k.secrets[in.GetName()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	tags := s.GetTags()
+	if in.GetTags() != nil {
+		tags = in.GetTags().GetTags()
+	}
+	k.secrets[in.GetName()] = &pb.Secret{Name: in.GetName(), Type: in.GetType(), Content: in.GetContent(), Tags: tags}
+	return &pb.UpdateSecretResponse{Name: in.GetName(), Type: in.GetType()}, nil
+}
+
+func TestExportImport(t *testing.T) {
+	initLogger()
+	ctx := context.Background()
+
+	src := &memKeeper{secrets: map[string]*pb.Secret{
+		"mail": {Name: "mail", Type: "lp", Content: []byte(`{"login":"me","password":"secret"}`), Tags: []string{"personal"}},
+		"key":  {Name: "key", Type: "raw", Content: []byte{0, 1, 2}},
+	}}
+
+	a, err := archiveSecrets(ctx, src, "user@example.org")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, export.Write(&buf, a, "passphrase", export.Params{Time: 1, Memory: 1024, Threads: 1}))
+
+	got, err := export.Read(bytes.NewReader(buf.Bytes()), "passphrase")
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.org", got.Account)
+
+	dst := &memKeeper{secrets: map[string]*pb.Secret{
+		"key": {Name: "key", Type: "raw", Content: []byte("other")},
+	}}
+
+	// taken names are kept
+	v, err := importSecrets(ctx, dst, got, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, v.count("created"))
+	assert.Equal(t, 1, v.count("skipped"))
+	assert.Equal(t, src.secrets["mail"], dst.secrets["mail"])
+	assert.Equal(t, []byte("other"), dst.secrets["key"].GetContent())
+
+	v, err = importSecrets(ctx, dst, got, true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, v.count("replaced"))
+	assert.Equal(t, src.secrets, dst.secrets)
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/client/pkg/audit"
+	"gophkeeper/internal/client/pkg/resolver"
+	"gophkeeper/internal/client/pkg/secret"
+	"time"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Audit vault health",
+	Long: `Decodes login/password and card secrets locally and reports weak and reused passwords,
+secrets not changed for a long time and cards expiring soon. Secret values are never printed.`,
+	Run: runAudit,
+}
+
+var auditBreachesCmd = &cobra.Command{
+	Use:   "breaches",
+	Short: "Check passwords against a breached password corpus",
+	Long: `Checks SHA-1 hashes of login/password secrets against a locally downloaded HIBP-style corpus
+of "SHA1:COUNT" lines sorted by hash. The corpus is searched on disk, passwords never leave the machine.`,
+	Run: runAuditBreaches,
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.Flags().Int("stale-days", 365, "report secrets not changed for this number of days")
+	auditCmd.Flags().Int("expiry-days", 60, "report cards expiring within this number of days")
+	auditCmd.Flags().Float64("min-entropy", 50, "report passwords with lower estimated entropy in bits")
+
+	auditCmd.AddCommand(auditBreachesCmd)
+	auditBreachesCmd.Flags().String("corpus", "", "path to the sorted SHA-1 hash corpus, e.g. pwned-passwords-sha1-ordered-by-hash.txt")
+	checkErr(auditBreachesCmd.MarkFlagRequired("corpus"))
+}
+
+func runAudit(cmd *cobra.Command, args []string) {
+	opts := audit.DefaultOptions()
+
+	staleDays, err := cmd.Flags().GetInt("stale-days")
+	checkErr(err)
+	expiryDays, err := cmd.Flags().GetInt("expiry-days")
+	checkErr(err)
+	opts.MinEntropy, err = cmd.Flags().GetFloat64("min-entropy")
+	checkErr(err)
+	opts.StaleAfter = time.Duration(staleDays) * 24 * time.Hour
+	opts.ExpiringWithin = time.Duration(expiryDays) * 24 * time.Hour
+
+	cl, stop := getKeeperClient()
+	defer stop()
+
+	entries, err := auditEntries(context.Background(), cl)
+	checkErr(err)
+
+	checkErr(out.Print(audit.Run(entries, opts)))
+}
+
+func runAuditBreaches(cmd *cobra.Command, args []string) {
+	path, err := cmd.Flags().GetString("corpus")
+	checkErr(err)
+
+	cl, stop := getKeeperClient()
+	defer stop()
+
+	entries, err := auditEntries(context.Background(), cl)
+	checkErr(err)
+
+	corpus, f, err := audit.OpenCorpus(path)
+	checkErr(err)
+	// closed before checking errors, checkErr exits skipping deferred calls
+	r, err := audit.CheckBreaches(entries, corpus)
+	closeErr := f.Close()
+	checkErr(err)
+	checkErr(closeErr)
+
+	checkErr(out.Print(r))
+}
+
+// auditEntries reads secrets with auditable content, other secrets are checked for staleness only
+func auditEntries(ctx context.Context, cl pb.KeeperClient) ([]audit.Entry, error) {
+	resp, err := cl.ListSecrets(ctx, &pb.ListSecretsRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	r := resolver.New(cl)
+	entries := make([]audit.Entry, 0, len(resp.GetSecrets()))
+	for _, d := range resp.GetSecrets() {
+		e := audit.Entry{
+			Name:      d.GetName(),
+			ChangedAt: secretUpdatedAt(d),
+		}
+
+		switch d.GetType() {
+		case secret.TypeLoginPassword, secret.TypeCard:
+			if e.Secret, err = r.Secret(ctx, d.GetName()); err != nil {
+				return nil, err
+			}
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
@@ -2,17 +2,23 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
 	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/client/pkg/output"
 	"gophkeeper/pkg/logger"
 	"os"
+	"sync"
 )
 
+// authMu guards authViper, tokens may be refreshed by concurrent calls
+var authMu sync.Mutex
+
 // authCmd represents the auth command
 var authCmd = &cobra.Command{
 	Use:   "auth",
@@ -42,7 +48,7 @@ var authLoginCmd = &cobra.Command{
 var authForgetCmd = &cobra.Command{
 	Use:   "forget",
 	Short: "Forget current authorization",
-	Long:  `Allows you to forget current authorization`,
+	Long:  `Allows you to forget current authorization and revoke its session on the server`,
 	Run:   forgetAuth,
 }
 
@@ -51,6 +57,19 @@ func init() {
 	authCmd.AddCommand(authRegisterCmd)
 	authCmd.AddCommand(authLoginCmd)
 	authCmd.AddCommand(authForgetCmd)
+
+	for _, c := range []*cobra.Command{authRegisterCmd, authLoginCmd} {
+		c.Flags().String("device", defaultDevice(), "device name shown in the sessions list")
+	}
+}
+
+// defaultDevice name is the host name
+func defaultDevice() string {
+	h, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return h
 }
 
 func register(cmd *cobra.Command, args []string) {
@@ -60,25 +79,30 @@ func register(cmd *cobra.Command, args []string) {
 	cl, stop := getUserClient()
 	defer stop()
 
-	resp, err := cl.Register(ctx, &pb.RegisterRequest{
+	device, err := cmd.Flags().GetString("device")
+	checkErr(err)
+
+	v, err := newSRPVerifier(password)
+	checkErr(err)
+
+	resp, err := cl.RegisterSRP(ctx, &pb.RegisterSRPRequest{
 		Email:    email,
-		Password: password,
+		Verifier: v,
+		Device:   device,
 	})
 
 	switch status.Code(err) {
 	case codes.OK:
 		// register ok
-	case codes.InvalidArgument:
-		fallthrough
 	default:
-		l.Fatal().Msg(err.Error())
+		fail(err, "")
 	}
 
 	authViper.Set("email", email)
-	authViper.Set("token", resp.GetToken())
-	checkErr(authViper.WriteConfig())
+	checkErr(saveAuth(resp.GetToken(), resp.GetRefreshToken()))
+	markSRPEnrolled(email)
 
-	l.Info().Msg("Auth saved")
+	l.Info().Msg("Auth saved, check your mailbox to verify email")
 }
 
 func login(cmd *cobra.Command, args []string) {
@@ -88,25 +112,73 @@ func login(cmd *cobra.Command, args []string) {
 	cl, stop := getUserClient()
 	defer stop()
 
-	resp, err := cl.Login(ctx, &pb.LoginRequest{
-		Email:    email,
-		Password: password,
-	})
+	device, err := cmd.Flags().GetString("device")
+	checkErr(err)
+
+	otp, recovery := twoFactorFlags(cmd)
+
+	req := &pb.LoginRequest{
+		Email:        email,
+		Password:     password,
+		Device:       device,
+		OtpCode:      otp,
+		RecoveryCode: recovery,
+	}
+	// accounts with a password log in with it once more and switch to SRP
+	enroll := false
+	doLogin := func() (*pb.LoginResponse, error) {
+		if enroll {
+			return cl.Login(ctx, req)
+		}
+		resp, err := srpLogin(ctx, cl, req)
+		if notEnrolled(err) {
+			if err := legacyPassword(email); err != nil {
+				return nil, err
+			}
+			enroll = true
+			return cl.Login(ctx, req)
+		}
+		return resp, err
+	}
+	resp, err := doLogin()
+
+	if status.Code(err) == codes.Unauthenticated && output.Reason(err) == reasonTwoFactorRequired {
+		req.OtpCode, err = prompt("Two-factor code: ")
+		checkErr(err)
+		resp, err = doLogin()
+	}
 
 	switch status.Code(err) {
 	case codes.OK:
 		// login ok
 	case codes.Unauthenticated:
-		l.Fatal().Msg("Auth error")
+		if output.Reason(err) == reasonTwoFactorInvalid {
+			fail(err, "")
+		}
+		fail(err, "Auth error")
+	case codes.FailedPrecondition:
+		if output.Reason(err) == reasonPasswordDisabled {
+			fail(err, "Server accepts SRP only, reset the password with `auth forgot` to switch to SRP")
+		}
+		fail(err, "")
 	default:
-		l.Fatal().Msg(err.Error())
+		fail(err, "")
 	}
 
 	authViper.Set("email", email)
-	authViper.Set("token", resp.GetToken())
-	checkErr(authViper.WriteConfig())
+	checkErr(saveAuth(resp.GetToken(), resp.GetRefreshToken()))
 
 	l.Info().Msg("Auth saved")
+	if enroll {
+		if err := enrollSRP(ctx, password); err != nil {
+			l.Warn().Err(err).Msg("Switching to SRP failed, it is retried on next login")
+		} else {
+			l.Info().Msg("Switched to SRP, the password is not sent to the server anymore")
+		}
+	}
+	if !resp.GetEmailVerified() {
+		l.Warn().Msg("Email is not verified, run `auth verify` to get a new verification mail")
+	}
 }
 
 func forgetAuth(cmd *cobra.Command, args []string) {
@@ -117,19 +189,97 @@ func forgetAuth(cmd *cobra.Command, args []string) {
 		os.Exit(0)
 	}
 
-	authViper.Set("token", "")
-	if err := authViper.WriteConfig(); err != nil {
-		l.Fatal().Err(err)
+	// revoke the session on the server too, local auth is forgotten anyway
+	cl, stop := getAuthUserClient()
+	defer stop()
+	if _, err := cl.Logout(context.Background(), &pb.LogoutRequest{}); err != nil {
+		l.Warn().Err(err).Msg("Server logout failed")
 	}
 
+	checkErr(saveAuth("", ""))
+
 	l.Info().Msg("Done")
 }
 
+// saveAuth tokens to the user config
+func saveAuth(token, refreshToken string) error {
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	authViper.Set("token", token)
+	authViper.Set("refresh_token", refreshToken)
+	return authViper.WriteConfig()
+}
+
+// authToken is the current access token, an API token from the environment takes precedence
+func authToken() string {
+	if tk := viper.GetString("api_token"); tk != "" {
+		return tk
+	}
+
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	return authViper.GetString("token")
+}
+
+// refreshAuth exchanges the stored refresh token for new tokens, used is the access token rejected by the server.
+// Token is not refreshed again if it was already changed by a concurrent call.
+func refreshAuth(ctx context.Context, used string) (string, error) {
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	if tk := authViper.GetString("token"); tk != used {
+		return tk, nil
+	}
+
+	rt := authViper.GetString("refresh_token")
+	if rt == "" {
+		return "", errors.New("no refresh token, login again")
+	}
+
+	cl, stop := getUserClient()
+	defer stop()
+
+	resp, err := cl.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: rt})
+	if err != nil {
+		return "", fmt.Errorf("refresh token: %w", err)
+	}
+
+	authViper.Set("token", resp.GetToken())
+	authViper.Set("refresh_token", resp.GetRefreshToken())
+	if err := authViper.WriteConfig(); err != nil {
+		return "", fmt.Errorf("save auth: %w", err)
+	}
+
+	l.Debug().Msg("Auth token refreshed")
+
+	return resp.GetToken(), nil
+}
+
 func getUserClient() (pb.UserClient, func()) {
 	// real client for mocked service
 	conn, err := grpc.Dial(
 		viper.GetString("server_addr"),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		transportCredentials(),
+	)
+	checkErr(err)
+
+	stop := func() {
+		_ = conn.Close()
+	}
+
+	cl := pb.NewUserClient(conn)
+
+	return cl, stop
+}
+
+// getAuthUserClient for user methods requiring auth
+func getAuthUserClient() (pb.UserClient, func()) {
+	conn, err := grpc.Dial(
+		viper.GetString("server_addr"),
+		transportCredentials(),
+		grpc.WithUnaryInterceptor(clientAuthInterceptor),
 	)
 	checkErr(err)
 
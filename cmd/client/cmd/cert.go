@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"os"
+)
+
+var (
+	certificateCmd = &cobra.Command{
+		Use:     "certificate",
+		Aliases: []string{"certs"},
+		Short:   "Manage client certificates",
+		Long: `Client certificates authenticate calls without login, the server accepts only certificates
+signed by its trusted CA whose subject names your account or your service account
+and whose public key is registered for that account.
+A certificate names your account by an email SAN or a common name equal to your email,
+and a service account by the URI SAN urn:gophkeeper:service-account:ID.
+A renewed certificate with the same key keeps working, a new key has to be registered again.`,
+	}
+	certificateRegisterCmd = &cobra.Command{
+		Use:   "register NAME [FILE]",
+		Short: "Register a client certificate",
+		Long: `Registers the public key of a PEM certificate, the --cert one is used without FILE.
+The certificate subject must name your account, or the service account with --service-account.
+With --service-account calls with the certificate are limited by the account grants.`,
+		Example: `  gkcli certs register laptop ~/.gophkeeper/client.pem
+  gkcli certs register ci ci.pem --service-account 5b0c...`,
+		Args: cobra.RangeArgs(1, 2),
+		Run:  registerCertificate,
+	}
+	certificateListCmd = &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List client certificates",
+		Run:     listCertificates,
+	}
+	certificateRemoveCmd = &cobra.Command{
+		Use:   "rm ID",
+		Short: "Remove a client certificate",
+		Long:  `Removes a client certificate of yours or of your service account, its key is rejected immediately`,
+		Args:  cobra.ExactArgs(1),
+		Run:   removeCertificate,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(certificateCmd)
+	certificateCmd.AddCommand(certificateRegisterCmd)
+	certificateCmd.AddCommand(certificateListCmd)
+	certificateCmd.AddCommand(certificateRemoveCmd)
+
+	certificateRegisterCmd.Flags().String("service-account", "", "register for the service account with this id")
+	certificateListCmd.Flags().String("service-account", "", "list certificates of the service account with this id")
+}
+
+func registerCertificate(cmd *cobra.Command, args []string) {
+	said, err := cmd.Flags().GetString("service-account")
+	checkErr(err)
+
+	file := viper.GetString("tls_cert")
+	if len(args) > 1 {
+		file = args[1]
+	}
+	if file == "" {
+		checkErr(errors.New("certificate file is required"))
+	}
+	pem, err := os.ReadFile(file)
+	checkErr(err)
+
+	ctx := context.Background()
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	resp, err := cl.RegisterClientCertificate(ctx, &pb.RegisterClientCertificateRequest{
+		Name:             args[0],
+		Certificate:      pem,
+		ServiceAccountId: said,
+	})
+	switch status.Code(err) {
+	case codes.OK:
+		// registered
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	case codes.PermissionDenied:
+		fail(err, "API tokens can not register certificates, log in")
+	case codes.InvalidArgument:
+		fail(err, "Invalid certificate")
+	case codes.AlreadyExists:
+		fail(err, "Certificate key is already registered")
+	case codes.NotFound:
+		fail(err, "Service account not found")
+	case codes.Unimplemented:
+		fail(err, "Server does not support client certificates")
+	default:
+		fail(err, "")
+	}
+
+	if !out.Structured() {
+		l.Info().
+			Str("id", resp.GetCertificate().GetId()).
+			Str("fingerprint", resp.GetCertificate().GetFingerprint()).
+			Msg("Client certificate registered")
+		return
+	}
+	checkErr(out.Print(newClientCertificateListView([]*pb.ClientCertificate{resp.GetCertificate()})))
+}
+
+func listCertificates(cmd *cobra.Command, args []string) {
+	said, err := cmd.Flags().GetString("service-account")
+	checkErr(err)
+
+	ctx := context.Background()
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	resp, err := cl.ListClientCertificates(ctx, &pb.ListClientCertificatesRequest{ServiceAccountId: said})
+	switch status.Code(err) {
+	case codes.OK:
+		// list ok
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	case codes.PermissionDenied:
+		fail(err, "API tokens can not list certificates, log in")
+	case codes.InvalidArgument, codes.NotFound:
+		fail(err, "Service account not found")
+	case codes.Unimplemented:
+		fail(err, "Server does not support client certificates")
+	default:
+		fail(err, "")
+	}
+
+	checkErr(out.Print(newClientCertificateListView(resp.GetCertificates())))
+}
+
+func removeCertificate(cmd *cobra.Command, args []string) {
+	id := args[0]
+	ctx := context.Background()
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	_, err := cl.DeleteClientCertificate(ctx, &pb.DeleteClientCertificateRequest{Id: id})
+	switch status.Code(err) {
+	case codes.OK:
+		// remove ok
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	case codes.PermissionDenied:
+		fail(err, "API tokens can not remove certificates, log in")
+	case codes.InvalidArgument, codes.NotFound:
+		fail(err, "Certificate not found")
+	case codes.Unimplemented:
+		fail(err, "Server does not support client certificates")
+	default:
+		fail(err, "")
+	}
+
+	if !out.Structured() {
+		l.Info().Str("id", id).Msg("Client certificate removed")
+		return
+	}
+	checkErr(out.Print(&sessionRevokeView{Revoked: 1}))
+}
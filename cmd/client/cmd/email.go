@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+)
+
+var authVerifyCmd = &cobra.Command{
+	Use:   "verify [token]",
+	Short: "Verify account email",
+	Long: `Verifies your email with a token from the verification mail.
+Without a token a new verification mail is sent, previous tokens stop working.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  verifyEmail,
+}
+
+var authForgotCmd = &cobra.Command{
+	Use:   "forgot [email]",
+	Short: "Request a password reset mail",
+	Long:  `Sends a mail with a password reset token if there is an account with the email`,
+	Args:  cobra.ExactArgs(1),
+	Run:   forgotPassword,
+}
+
+var authResetCmd = &cobra.Command{
+	Use:   "reset [token]",
+	Short: "Reset password with a token from the mail",
+	Long: `Allows you to set a new password with a token from the password reset mail, it is asked for interactively.
+All sessions are logged out, login again afterwards.`,
+	Args: cobra.ExactArgs(1),
+	Run:  resetPassword,
+}
+
+func init() {
+	authCmd.AddCommand(authVerifyCmd)
+	authCmd.AddCommand(authForgotCmd)
+	authCmd.AddCommand(authResetCmd)
+}
+
+func verifyEmail(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		cl, stop := getAuthUserClient()
+		defer stop()
+
+		_, err := cl.SendVerificationEmail(ctx, &pb.SendVerificationEmailRequest{})
+		switch status.Code(err) {
+		case codes.OK:
+			// sent
+		case codes.Unauthenticated:
+			fail(err, "Auth error")
+		default:
+			fail(err, "")
+		}
+
+		l.Info().Str("email", authViper.GetString("email")).Msg("Verification mail sent")
+		return
+	}
+
+	cl, stop := getUserClient()
+	defer stop()
+
+	_, err := cl.VerifyEmail(ctx, &pb.VerifyEmailRequest{Token: args[0]})
+	switch status.Code(err) {
+	case codes.OK:
+		// verified
+	default:
+		fail(err, "")
+	}
+
+	l.Info().Msg("Email verified")
+}
+
+func forgotPassword(cmd *cobra.Command, args []string) {
+	cl, stop := getUserClient()
+	defer stop()
+
+	_, err := cl.RequestPasswordReset(context.Background(), &pb.RequestPasswordResetRequest{Email: args[0]})
+	switch status.Code(err) {
+	case codes.OK:
+		// requested
+	default:
+		fail(err, "")
+	}
+
+	l.Info().Str("email", args[0]).Msg("If there is an account with this email, a password reset mail is sent")
+}
+
+func resetPassword(cmd *cobra.Command, args []string) {
+	password, err := promptPassword("New password: ")
+	checkErr(err)
+	repeated, err := promptPassword("Repeat new password: ")
+	checkErr(err)
+
+	if password == "" {
+		checkErr(errors.New("new password is empty"))
+	}
+	if password != repeated {
+		checkErr(errors.New("passwords do not match"))
+	}
+
+	v, err := newSRPVerifier(password)
+	checkErr(err)
+
+	cl, stop := getUserClient()
+	defer stop()
+
+	resp, err := cl.ResetPassword(context.Background(), &pb.ResetPasswordRequest{
+		Token:          args[0],
+		NewSrpVerifier: v,
+	})
+	switch status.Code(err) {
+	case codes.OK:
+		// reset
+	default:
+		fail(err, "")
+	}
+
+	l.Info().Int64("revoked_sessions", resp.GetRevokedSessions()).Msg("Password changed, login again")
+}
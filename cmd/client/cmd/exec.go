@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"github.com/spf13/cobra"
+	"gophkeeper/internal/client/pkg/resolver"
+	"os"
+	"os/exec"
+	"os/signal"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec [flags] -- command [args...]",
+	Short: "Run a command with secrets in its environment",
+	Long: `Resolves secret references and passes them to the environment of the command only.
+References are "name" for single field secrets or "name:field", e.g.
+
+  gkcli exec --env DB_PASS=prod/db:password -- ./migrate
+
+Mapping file contains one VAR=name[:field] per line, --env flags take precedence over it.
+Signals are forwarded to the command and its exit code is returned.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  execCommand,
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+	execCmd.Flags().SetInterspersed(false)
+	execCmd.Flags().StringArrayP("env", "e", nil, "environment variable mapping VAR=name[:field]")
+	execCmd.Flags().StringP("mapping", "m", "", "file with VAR=name[:field] mappings")
+}
+
+func execCommand(cmd *cobra.Command, args []string) {
+	var mappings []resolver.EnvMapping
+
+	mappingFile, err := cmd.Flags().GetString("mapping")
+	checkErr(err)
+	if mappingFile != "" {
+		f, err := os.Open(mappingFile)
+		checkErr(err)
+		mm, err := resolver.ReadEnvMappings(f)
+		_ = f.Close()
+		checkErr(err)
+		mappings = append(mappings, mm...)
+	}
+
+	envs, err := cmd.Flags().GetStringArray("env")
+	checkErr(err)
+	for _, e := range envs {
+		m, err := resolver.ParseEnvMapping(e)
+		checkErr(err)
+		mappings = append(mappings, m)
+	}
+
+	cl, stop := getKeeperClient()
+	r := resolver.New(cl)
+	ctx := context.Background()
+
+	// later values override earlier ones for duplicate names
+	env := os.Environ()
+	for _, m := range mappings {
+		v, err := r.Resolve(ctx, m.Ref)
+		if err != nil {
+			stop()
+			fail(err, "")
+		}
+		env = append(env, m.Name+"="+v)
+	}
+	stop()
+
+	c := exec.Command(args[0], args[1:]...)
+	c.Env = env
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	checkErr(c.Start())
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, forwardedSignals...)
+	go func() {
+		for sig := range sigs {
+			_ = c.Process.Signal(sig)
+		}
+	}()
+
+	err = c.Wait()
+	signal.Stop(sigs)
+	close(sigs)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitCode(exitErr.ProcessState))
+	}
+	checkErr(err)
+}
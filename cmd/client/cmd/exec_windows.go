@@ -0,0 +1,16 @@
+//go:build windows
+
+package cmd
+
+import (
+	"os"
+)
+
+var forwardedSignals = []os.Signal{
+	os.Interrupt,
+}
+
+// exitCode of a finished process
+func exitCode(ps *os.ProcessState) int {
+	return ps.ExitCode()
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/pkg/version"
+)
+
+var infoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show server version and features",
+	Long:  `Allows you to see the server version, its enabled features and the client version, no login is needed`,
+	Args:  cobra.NoArgs,
+	Run:   serverInfo,
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+}
+
+func serverInfo(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	conn, err := grpc.Dial(
+		viper.GetString("server_addr"),
+		transportCredentials(),
+	)
+	checkErr(err)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	resp, err := pb.NewInfoClient(conn).ServerInfo(ctx, &pb.ServerInfoRequest{})
+	checkErr(err)
+
+	checkErr(out.Print(&serverInfoView{
+		Server:        viper.GetString("server_addr"),
+		Version:       resp.GetVersion(),
+		Branch:        resp.GetBranch(),
+		Revision:      resp.GetRevision(),
+		BuildDate:     resp.GetBuildDate(),
+		GoVersion:     resp.GetGoVersion(),
+		Features:      resp.GetFeatures(),
+		ClientVersion: version.Version,
+	}))
+}
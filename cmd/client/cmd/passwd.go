@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/client/pkg/output"
+)
+
+var authPasswdCmd = &cobra.Command{
+	Use:   "passwd",
+	Short: "Change account password",
+	Long: `Allows you to change your password, it is asked for interactively.
+All other sessions are logged out and your API tokens are revoked, tokens of your
+service accounts are kept. The current session stays valid.`,
+	Run: changePassword,
+}
+
+func init() {
+	authCmd.AddCommand(authPasswdCmd)
+	authPasswdCmd.Flags().String("otp", "", "two-factor code from the authenticator app, asked for when required")
+	authPasswdCmd.Flags().String("recovery-code", "", "one-time recovery code to use instead of a two-factor code")
+}
+
+func changePassword(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	current, err := promptPassword("Current password: ")
+	checkErr(err)
+	password, err := promptPassword("New password: ")
+	checkErr(err)
+	repeated, err := promptPassword("Repeat new password: ")
+	checkErr(err)
+
+	if password == "" {
+		checkErr(errors.New("new password is empty"))
+	}
+	if password != repeated {
+		checkErr(errors.New("passwords do not match"))
+	}
+
+	otp, recovery := twoFactorFlags(cmd)
+
+	// the new password is never sent, accounts still having a password switch to SRP
+	v, err := newSRPVerifier(password)
+	checkErr(err)
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	req := &pb.ChangePasswordRequest{
+		NewSrpVerifier: v,
+		OtpCode:        otp,
+		RecoveryCode:   recovery,
+	}
+	doChange := func() (*pb.ChangePasswordResponse, error) {
+		// each proof is good for a single call
+		proof, err := srpProof(ctx, cl, authViper.GetString("email"), current)
+		if err != nil {
+			return nil, err
+		}
+		if proof == nil {
+			req.CurrentPassword = current
+		}
+		req.CurrentSrpProof = proof
+		return cl.ChangePassword(ctx, req)
+	}
+	resp, err := doChange()
+
+	if status.Code(err) == codes.PermissionDenied && output.Reason(err) == reasonTwoFactorRequired {
+		req.OtpCode, err = prompt("Two-factor code: ")
+		checkErr(err)
+		resp, err = doChange()
+	}
+
+	switch status.Code(err) {
+	case codes.OK:
+		// changed
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	default:
+		fail(err, "")
+	}
+
+	markSRPEnrolled(authViper.GetString("email"))
+
+	l.Info().
+		Int64("revoked_sessions", resp.GetRevokedSessions()).
+		Int64("revoked_api_tokens", resp.GetRevokedApiTokens()).
+		Msg("Password changed")
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"strings"
+)
+
+// stdin is shared by prompts, so buffered input is not lost between them
+var stdin = bufio.NewReader(os.Stdin)
+
+// prompt reads a line from stdin, label goes to stderr to keep stdout parsable
+func prompt(label string) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", fmt.Errorf("read input: %w", err)
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+// promptPassword reads a line without echo if stdin is a terminal
+func promptPassword(label string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return prompt(label)
+	}
+
+	fmt.Fprint(os.Stderr, label)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"github.com/spf13/cobra"
+	"gophkeeper/internal/client/pkg/render"
+	"gophkeeper/internal/client/pkg/resolver"
+	"os"
+)
+
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Render config template with secrets",
+	Long: `Renders Go text/template file with secret references, e.g.
+
+  password = {{ secret "prod/db" "password" }}
+  key = {{ secretRaw "tls-key" }}
+
+Each secret is fetched once. Output file is written with 0600 permissions
+and left untouched if any secret or field is missing.`,
+	Run: renderTemplate,
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+	renderCmd.Flags().StringP("input", "i", "", "template file")
+	checkErr(renderCmd.MarkFlagRequired("input"))
+	// shadows global output format flag, render produces plain text only
+	renderCmd.Flags().StringP("output", "o", "-", "output file, - for stdout")
+}
+
+func renderTemplate(cmd *cobra.Command, args []string) {
+	in, err := cmd.Flags().GetString("input")
+	checkErr(err)
+	outFile, err := cmd.Flags().GetString("output")
+	checkErr(err)
+
+	text, err := os.ReadFile(in)
+	checkErr(err)
+
+	cl, stop := getKeeperClient()
+	defer stop()
+
+	var buf bytes.Buffer
+	if err := render.Render(context.Background(), resolver.New(cl), &buf, in, string(text)); err != nil {
+		fail(err, "")
+	}
+
+	if outFile == "-" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		checkErr(err)
+		return
+	}
+
+	checkErr(render.WriteFile(outFile, buf.Bytes()))
+	l.Info().Str("file", outFile).Msg("Rendered")
+}
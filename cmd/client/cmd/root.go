@@ -6,6 +6,8 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"gophkeeper/internal/client/pkg/output"
+	"gophkeeper/internal/client/pkg/tofu"
 	"gophkeeper/pkg/logger"
 	"gophkeeper/pkg/userconfig"
 	"gophkeeper/pkg/version"
@@ -21,6 +23,7 @@ const (
 var (
 	authViper *viper.Viper
 	l         *logger.Logger
+	out       *output.Printer
 )
 
 var rootCmd = &cobra.Command{
@@ -44,11 +47,20 @@ func init() {
 	cobra.OnInitialize(initDotEnv)
 	cobra.OnInitialize(initConfig)
 	cobra.OnInitialize(initLogger)
+	cobra.OnInitialize(initOutput)
 	cobra.OnInitialize(initAuth)
+	cobra.OnInitialize(initTLS)
 
 	//rootCmd.CompletionOptions.DisableDefaultCmd = true
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "set high log verbosity")
 	rootCmd.PersistentFlags().StringP("server", "s", "localhost:50051", "remote server address and port")
+	rootCmd.PersistentFlags().StringP("output", "o", "table", "output format: table, json, yaml or env")
+	rootCmd.PersistentFlags().String("cert", "", "client certificate file in PEM format")
+	rootCmd.PersistentFlags().String("key", "", "client certificate key file in PEM format")
+	rootCmd.PersistentFlags().String("ca", "", "server CA file in PEM format, enables TLS")
+	rootCmd.PersistentFlags().Bool("tls", false, "use TLS, certificates not trusted by the system are pinned once confirmed")
+	rootCmd.PersistentFlags().String("fingerprint", "", "expected fingerprint of the server certificate to pin without a prompt")
+	rootCmd.PersistentFlags().Bool("legacy-password", false, "send the password of an account using SRP to a server asking for it")
 }
 
 func initDotEnv() {
@@ -60,15 +72,27 @@ func initDotEnv() {
 func initConfig() {
 	viper.SetDefault("server_addr", "localhost:50051")
 	viper.SetDefault("log_verbose", 0)
+	viper.SetDefault("output", "table")
 
 	checkErr(viper.BindPFlag("log_verbose", rootCmd.PersistentFlags().Lookup("verbose")))
 	checkErr(viper.BindPFlag("server_addr", rootCmd.PersistentFlags().Lookup("server")))
+	checkErr(viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output")))
+	checkErr(viper.BindPFlag("tls_cert", rootCmd.PersistentFlags().Lookup("cert")))
+	checkErr(viper.BindPFlag("tls_key", rootCmd.PersistentFlags().Lookup("key")))
+	checkErr(viper.BindPFlag("tls_ca", rootCmd.PersistentFlags().Lookup("ca")))
+	checkErr(viper.BindPFlag("tls", rootCmd.PersistentFlags().Lookup("tls")))
+	checkErr(viper.BindPFlag("tls_fingerprint", rootCmd.PersistentFlags().Lookup("fingerprint")))
+	checkErr(viper.BindPFlag("legacy_password", rootCmd.PersistentFlags().Lookup("legacy-password")))
+	// API token for automation, used instead of the logged-in session
+	checkErr(viper.BindEnv("api_token", "GKCLI_TOKEN"))
 }
 
 func initAuth() {
 	uc, err := userconfig.New(appName, "toml")
 	checkErr(err)
 	authViper = uc.Viper("auth")
+	tlsViper = uc.Viper("tls")
+	pins = tofu.NewStore(uc.Path("known_servers"))
 
 	l.Debug().
 		Str("email", authViper.GetString("email")).
@@ -77,6 +101,7 @@ func initAuth() {
 
 func initLogger() {
 	logger.NewGlobal(logger.Config{
+		Out:        os.Stderr,
 		Pretty:     true,
 		Verbose:    viper.GetBool("log_verbose"),
 		TimeFormat: time.Kitchen,
@@ -84,9 +109,30 @@ func initLogger() {
 	l = logger.Global()
 }
 
+func initOutput() {
+	p, err := output.New(viper.GetString("output"))
+	checkErr(err)
+	out = p
+}
+
+// checkErr fails if err is not nil
 func checkErr(err error) {
 	if err == nil {
 		return
 	}
-	logger.Global().Fatal().Msg(err.Error())
+	fail(err, "")
+}
+
+// fail reports err in the selected output format and exits with a code matching its gRPC status,
+// msg overrides the error message if not empty
+func fail(err error, msg string) {
+	if out == nil {
+		if msg == "" {
+			msg = err.Error()
+		}
+		logger.Global().Fatal().Msg(msg)
+	}
+
+	out.Error(err, msg)
+	os.Exit(output.ExitCode(err))
 }
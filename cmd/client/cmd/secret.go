@@ -1,22 +1,19 @@
 package cmd
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	pb "gophkeeper/api/proto"
 	"gophkeeper/internal/client/pkg/secret"
 	"io/ioutil"
 	"os"
-	"strings"
-	"text/template"
 )
 
 var (
@@ -31,13 +28,14 @@ var (
 	secretListCmd = &cobra.Command{
 		Use:   "ls",
 		Short: "List own secrets",
-		Long:  `Allows you to list own secrets stored on server`,
+		Long:  `Allows you to list own secrets stored on server, --tag shows only secrets having all given tags`,
 		Run:   secretList,
 	}
 	secretCreateCmd = &cobra.Command{
 		Use:   "create",
 		Short: "Create secret",
-		Long:  `Allows you to create secret`,
+		Long: `Allows you to create secret.
+Tags given with --tag label the secret for filters and API token scopes like "read#ci".`,
 		Run: func(cmd *cobra.Command, args []string) {
 			checkErr(cmd.Help())
 		},
@@ -65,8 +63,10 @@ var (
 	secretReadCmd = &cobra.Command{
 		Use:   "read",
 		Short: "Read secret",
-		Long:  `Allows you to read secret`,
-		Run:   readSecret,
+		Long: `Allows you to read secret.
+Use --field to print a single field value without trailing newline, e.g. for piping,
+and --fields to list fields available for the secret.`,
+		Run: readSecret,
 	}
 	secretRemoveCmd = &cobra.Command{
 		Use:   "rm",
@@ -74,22 +74,37 @@ var (
 		Long:  `Allows you to remove secret`,
 		Run:   removeSecret,
 	}
+	secretTagCmd = &cobra.Command{
+		Use:   "tag [tag]...",
+		Short: "Set secret tags",
+		Long: `Allows you to replace tags of the secret, it is untagged when no tags are given.
+Tags label secrets for filters and API token scopes like "read#ci".`,
+		Run: tagSecret,
+	}
 )
 
 func init() {
 	rootCmd.AddCommand(secretCmd)
 
 	secretCmd.AddCommand(secretListCmd)
+	secretListCmd.Flags().StringSliceP("tag", "t", nil, "show only secrets having the tag, can be repeated")
 
 	secretCmd.AddCommand(secretReadCmd)
 	secretReadCmd.PersistentFlags().StringP("name", "n", "", "secret name")
 	checkErr(secretReadCmd.MarkPersistentFlagRequired("name"))
+	secretReadCmd.Flags().StringP("field", "f", "", "print only value of this field")
+	secretReadCmd.Flags().Bool("fields", false, "list available fields")
 
 	secretCmd.AddCommand(secretRemoveCmd)
 	secretRemoveCmd.PersistentFlags().StringP("name", "n", "", "secret name")
 	checkErr(secretRemoveCmd.MarkPersistentFlagRequired("name"))
 
+	secretCmd.AddCommand(secretTagCmd)
+	secretTagCmd.Flags().StringP("name", "n", "", "secret name")
+	checkErr(secretTagCmd.MarkFlagRequired("name"))
+
 	secretCmd.AddCommand(secretCreateCmd)
+	secretCreateCmd.PersistentFlags().StringSliceP("tag", "t", nil, "tag the secret, can be repeated")
 
 	secretCreateCmd.AddCommand(secretCreateRawCmd)
 	secretCreateRawCmd.Flags().StringP("name", "n", "", "secret name")
@@ -118,13 +133,68 @@ func readSecret(cmd *cobra.Command, args []string) {
 		Name: name,
 	})
 	switch status.Code(err) {
-	case codes.NotFound:
-		l.Fatal().Msg("Secret not found")
 	case codes.OK:
-		s, err := secret.Read(resp.Type, resp.Content)
-		checkErr(err)
-		fmt.Print(s.Print())
+		// read ok
+	case codes.NotFound:
+		fail(err, "Secret not found")
+	default:
+		fail(err, "")
 	}
+
+	sec, err := secret.Read(resp.Type, resp.Content)
+	checkErr(err)
+
+	listFields, err := cmd.Flags().GetBool("fields")
+	checkErr(err)
+	field, err := cmd.Flags().GetString("field")
+	checkErr(err)
+
+	if listFields {
+		printFields(sec)
+		return
+	}
+
+	if field != "" {
+		printField(resp.GetName(), field, sec)
+		return
+	}
+
+	if !out.Structured() {
+		fmt.Print(sec.Print())
+		return
+	}
+	checkErr(out.Print(newSecretView(resp.GetName(), resp.GetTags(), sec)))
+}
+
+func printFields(s secret.Secret) {
+	ff := s.Fields()
+	names := make([]string, 0, len(ff))
+	for _, f := range ff {
+		names = append(names, f.Name)
+	}
+
+	if !out.Structured() {
+		for _, n := range names {
+			fmt.Println(n)
+		}
+		return
+	}
+	checkErr(out.Print(names))
+}
+
+func printField(name, field string, s secret.Secret) {
+	v, err := secret.Value(s, field)
+	checkErr(err)
+
+	if !out.Structured() {
+		fmt.Print(v)
+		return
+	}
+	checkErr(out.Print(&fieldView{
+		Name:  name,
+		Field: field,
+		Value: v,
+	}))
 }
 
 func removeSecret(cmd *cobra.Command, args []string) {
@@ -142,21 +212,33 @@ func removeSecret(cmd *cobra.Command, args []string) {
 		Name: name,
 	})
 	switch status.Code(err) {
-	case codes.NotFound:
-		l.Fatal().Msg("Secret not found")
 	case codes.OK:
+		// remove ok
+	case codes.NotFound:
+		fail(err, "Secret not found")
+	default:
+		fail(err, "")
+	}
+
+	if !out.Structured() {
 		l.Info().Msg("Secret removed successfully")
+		return
 	}
+	checkErr(out.Print(&secretChangeView{
+		Name:   name,
+		Result: "removed",
+	}))
 }
 
-func createGenericSecret(n string, s secret.Secret) {
+func createGenericSecret(cmd *cobra.Command, n string, s secret.Secret) {
+	tags, err := cmd.Flags().GetStringSlice("tag")
+	checkErr(err)
+
 	data, err := s.Encode()
-	if err != nil {
-		l.Fatal().Err(err).Send()
-	}
+	checkErr(err)
 
 	if len(data) == 0 {
-		l.Fatal().Msg("Unable to create empty secret")
+		fail(errors.New("empty secret"), "Unable to create empty secret")
 	}
 
 	cl, stop := getKeeperClient()
@@ -165,24 +247,35 @@ func createGenericSecret(n string, s secret.Secret) {
 	ctx := context.Background()
 
 	if n == "" {
-		l.Fatal().Msg("Please specify secret name")
+		fail(errors.New("empty name"), "Please specify secret name")
 	}
 
-	_, err = cl.CreateSecret(ctx, &pb.CreateSecretRequest{
+	resp, err := cl.CreateSecret(ctx, &pb.CreateSecretRequest{
 		Type:    s.Type(),
 		Name:    n,
 		Content: data,
+		Tags:    tags,
 	})
 	switch status.Code(err) {
-	case codes.AlreadyExists:
-		l.Fatal().Msg("Secret already exists")
 	case codes.OK:
-		l.Info().Msg("Secret created successfully")
+		// create ok
+	case codes.AlreadyExists:
+		fail(err, "Secret already exists")
 	case codes.Unauthenticated:
-		l.Fatal().Msg("Auth error")
+		fail(err, "Auth error")
 	default:
-		l.Fatal().Msg(err.Error())
+		fail(err, "")
+	}
+
+	if !out.Structured() {
+		l.Info().Msg("Secret created successfully")
+		return
 	}
+	checkErr(out.Print(&secretChangeView{
+		Name:   resp.GetName(),
+		Type:   resp.GetType(),
+		Result: "created",
+	}))
 }
 
 func createRawSecret(cmd *cobra.Command, args []string) {
@@ -212,7 +305,7 @@ func createRawSecret(cmd *cobra.Command, args []string) {
 
 	s := secret.Raw(data)
 
-	createGenericSecret(name, &s)
+	createGenericSecret(cmd, name, &s)
 }
 
 func createLoginPasswordSecret(cmd *cobra.Command, args []string) {
@@ -224,7 +317,7 @@ func createLoginPasswordSecret(cmd *cobra.Command, args []string) {
 	name, err := cmd.Flags().GetString("name")
 	checkErr(err)
 
-	createGenericSecret(name, in)
+	createGenericSecret(cmd, name, in)
 }
 
 func createCardSecret(cmd *cobra.Command, args []string) {
@@ -238,7 +331,7 @@ func createCardSecret(cmd *cobra.Command, args []string) {
 	name, err := cmd.Flags().GetString("name")
 	checkErr(err)
 
-	createGenericSecret(name, &in)
+	createGenericSecret(cmd, name, &in)
 }
 
 func secretList(cmd *cobra.Command, args []string) {
@@ -247,29 +340,66 @@ func secretList(cmd *cobra.Command, args []string) {
 	cl, stop := getKeeperClient()
 	defer stop()
 
+	tags, err := cmd.Flags().GetStringSlice("tag")
+	checkErr(err)
+
 	resp, err := cl.ListSecrets(ctx, &pb.ListSecretsRequest{})
-	if err != nil {
-		checkErr(err)
+	checkErr(err)
+
+	ss := make([]*pb.SecretDescription, 0, len(resp.GetSecrets()))
+	for _, s := range resp.GetSecrets() {
+		if secret.HasTags(s.GetTags(), tags...) {
+			ss = append(ss, s)
+		}
+	}
+
+	checkErr(out.Print(newSecretListView(ss)))
+}
+
+// tagSecret replaces tags keeping the content, the secret is read to update it as a whole
+func tagSecret(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	name, err := cmd.Flags().GetString("name")
+	checkErr(err)
+
+	cl, stop := getKeeperClient()
+	defer stop()
+
+	resp, err := cl.ReadSecret(ctx, &pb.ReadSecretRequest{Name: name})
+	switch status.Code(err) {
+	case codes.OK:
+		// read ok
+	case codes.NotFound:
+		fail(err, "Secret not found")
+	default:
+		fail(err, "")
 	}
 
-	var tmpl = `
-Name		Type
-{{range .}}{{.Name}}			{{.Type}}
-{{end}}
-`
-	t := template.Must(template.New("secret").Parse(tmpl))
-	var buf bytes.Buffer
-	if err := t.ExecuteTemplate(&buf, "secret", resp.Secrets); err != nil {
-		checkErr(err)
+	_, err = cl.UpdateSecret(ctx, &pb.UpdateSecretRequest{
+		Name:    name,
+		Type:    resp.GetType(),
+		Content: resp.GetContent(),
+		Tags:    &pb.SecretTags{Tags: args},
+	})
+	checkErr(err)
+
+	if !out.Structured() {
+		l.Info().Msg("Secret tags set successfully")
+		return
 	}
-	fmt.Println(strings.TrimSpace(buf.String()))
+	checkErr(out.Print(&secretChangeView{
+		Name:   name,
+		Type:   resp.GetType(),
+		Result: "tagged",
+	}))
 }
 
 func getKeeperClient() (pb.KeeperClient, func()) {
 	// real client for mocked service
 	conn, err := grpc.Dial(
 		viper.GetString("server_addr"),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		transportCredentials(),
 		grpc.WithUnaryInterceptor(clientAuthInterceptor),
 	)
 	checkErr(err)
@@ -292,7 +422,27 @@ func clientAuthInterceptor(
 	invoker grpc.UnaryInvoker,
 	opts ...grpc.CallOption,
 ) error {
-	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "bearer "+authViper.GetString("token"))
-	err := invoker(ctx, method, req, reply, cc, opts...)
-	return err
+	tk := authToken()
+	if tk == "" {
+		// client certificate auth, nothing to refresh
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	err := invoker(metadata.AppendToOutgoingContext(ctx, "authorization", "bearer "+tk), method, req, reply, cc, opts...)
+	if status.Code(err) != codes.Unauthenticated {
+		return err
+	}
+	// API tokens are long-lived and can not be refreshed
+	if viper.GetString("api_token") != "" {
+		return err
+	}
+
+	// access token is short-lived, try to refresh it once
+	tk, rerr := refreshAuth(ctx, tk)
+	if rerr != nil {
+		l.Debug().Err(rerr).Msg("Auth refresh failed")
+		return err
+	}
+
+	return invoker(metadata.AppendToOutgoingContext(ctx, "authorization", "bearer "+tk), method, req, reply, cc, opts...)
 }
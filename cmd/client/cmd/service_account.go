@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"time"
+)
+
+var (
+	serviceAccountCmd = &cobra.Command{
+		Use:     "service-account",
+		Aliases: []string{"sa"},
+		Short:   "Manage service accounts",
+		Long: `Service accounts are machine identities for automation owned by you. They can not log in
+and use API tokens only. Grants of a service account limit its tokens to your secrets, they use
+the same syntax as token scopes and take effect immediately when changed. Without grants
+a service account can not access anything.`,
+	}
+	serviceAccountCreateCmd = &cobra.Command{
+		Use:     "create NAME",
+		Short:   "Create a service account",
+		Example: `  gkcli sa create deployer --grant read:ci/ --grant write:ci/artifacts/`,
+		Args:    cobra.ExactArgs(1),
+		Run:     createServiceAccount,
+	}
+	serviceAccountListCmd = &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List service accounts",
+		Run:     listServiceAccounts,
+	}
+	serviceAccountGrantCmd = &cobra.Command{
+		Use:   "set-grants ID [GRANT...]",
+		Short: "Replace grants of a service account",
+		Long:  `Replaces grants of a service account, no grants deny its tokens everything`,
+		Args:  cobra.MinimumNArgs(1),
+		Run:   setServiceAccountGrants,
+	}
+	serviceAccountRemoveCmd = &cobra.Command{
+		Use:   "rm ID",
+		Short: "Delete a service account",
+		Long:  `Deletes a service account, its tokens stop working immediately`,
+		Args:  cobra.ExactArgs(1),
+		Run:   removeServiceAccount,
+	}
+	serviceAccountTokenCmd = &cobra.Command{
+		Use:   "token",
+		Short: "Manage API tokens of a service account",
+		Long:  `Manage API tokens of a service account, revoke them with the token revoke command`,
+	}
+	serviceAccountTokenCreateCmd = &cobra.Command{
+		Use:   "create ID NAME",
+		Short: "Create an API token of a service account",
+		Long: `Creates an API token of a service account, it is limited by both its scopes and the account grants.
+The token is shown once, store it right away.`,
+		Example: `  gkcli sa token create 5b0c... github-actions --expires 2160h`,
+		Args:    cobra.ExactArgs(2),
+		Run:     createServiceAccountToken,
+	}
+	serviceAccountTokenListCmd = &cobra.Command{
+		Use:     "ls ID",
+		Aliases: []string{"list"},
+		Short:   "List API tokens of a service account",
+		Args:    cobra.ExactArgs(1),
+		Run:     listServiceAccountTokens,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(serviceAccountCmd)
+	serviceAccountCmd.AddCommand(serviceAccountCreateCmd)
+	serviceAccountCmd.AddCommand(serviceAccountListCmd)
+	serviceAccountCmd.AddCommand(serviceAccountGrantCmd)
+	serviceAccountCmd.AddCommand(serviceAccountRemoveCmd)
+	serviceAccountCmd.AddCommand(serviceAccountTokenCmd)
+	serviceAccountTokenCmd.AddCommand(serviceAccountTokenCreateCmd)
+	serviceAccountTokenCmd.AddCommand(serviceAccountTokenListCmd)
+
+	serviceAccountCreateCmd.Flags().StringSlice("grant", nil, "grant of the account, repeat or separate by commas")
+
+	// grants limit the account anyway, so its tokens get full scopes by default
+	serviceAccountTokenCreateCmd.Flags().StringSlice("scope", []string{"read", "write"}, "scope of the token, repeat or separate by commas")
+	serviceAccountTokenCreateCmd.Flags().Duration("expires", 30*24*time.Hour, "lifetime of the token, at most a year")
+}
+
+func createServiceAccount(cmd *cobra.Command, args []string) {
+	grants, err := cmd.Flags().GetStringSlice("grant")
+	checkErr(err)
+
+	ctx := context.Background()
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	resp, err := cl.CreateServiceAccount(ctx, &pb.CreateServiceAccountRequest{
+		Name:   args[0],
+		Grants: grants,
+	})
+	switch status.Code(err) {
+	case codes.OK:
+		// created
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	case codes.PermissionDenied:
+		fail(err, "API tokens can not manage service accounts, log in")
+	case codes.InvalidArgument:
+		fail(err, "Invalid service account")
+	case codes.AlreadyExists:
+		fail(err, "Service account with this name already exists")
+	default:
+		fail(err, "")
+	}
+
+	checkErr(out.Print(newServiceAccountListView([]*pb.ServiceAccount{resp.GetServiceAccount()})))
+}
+
+func listServiceAccounts(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	resp, err := cl.ListServiceAccounts(ctx, &pb.ListServiceAccountsRequest{})
+	switch status.Code(err) {
+	case codes.OK:
+		// list ok
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	case codes.PermissionDenied:
+		fail(err, "API tokens can not manage service accounts, log in")
+	default:
+		fail(err, "")
+	}
+
+	checkErr(out.Print(newServiceAccountListView(resp.GetServiceAccounts())))
+}
+
+func setServiceAccountGrants(cmd *cobra.Command, args []string) {
+	id := args[0]
+	ctx := context.Background()
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	_, err := cl.UpdateServiceAccountGrants(ctx, &pb.UpdateServiceAccountGrantsRequest{
+		Id:     id,
+		Grants: args[1:],
+	})
+	switch status.Code(err) {
+	case codes.OK:
+		// updated
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	case codes.PermissionDenied:
+		fail(err, "API tokens can not manage service accounts, log in")
+	case codes.InvalidArgument:
+		fail(err, "Invalid grants")
+	case codes.NotFound:
+		fail(err, "Service account not found")
+	default:
+		fail(err, "")
+	}
+
+	l.Info().Str("id", id).Strs("grants", args[1:]).Msg("Service account grants updated")
+}
+
+func removeServiceAccount(cmd *cobra.Command, args []string) {
+	id := args[0]
+	ctx := context.Background()
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	_, err := cl.DeleteServiceAccount(ctx, &pb.DeleteServiceAccountRequest{Id: id})
+	switch status.Code(err) {
+	case codes.OK:
+		// deleted
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	case codes.PermissionDenied:
+		fail(err, "API tokens can not manage service accounts, log in")
+	case codes.InvalidArgument, codes.NotFound:
+		fail(err, "Service account not found")
+	default:
+		fail(err, "")
+	}
+
+	l.Info().Str("id", id).Msg("Service account deleted")
+}
+
+func createServiceAccountToken(cmd *cobra.Command, args []string) {
+	createToken(cmd, args[0], args[1])
+}
+
+func listServiceAccountTokens(cmd *cobra.Command, args []string) {
+	listTokens(args[0])
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+)
+
+var (
+	authSessionsCmd = &cobra.Command{
+		Use:   "sessions",
+		Short: "List logged-in devices",
+		Long:  `Allows you to see sessions of your account, the current one is marked`,
+		Run:   listSessions,
+	}
+	authSessionsRevokeCmd = &cobra.Command{
+		Use:   "revoke [id]",
+		Short: "Revoke a session",
+		Long:  `Allows you to log out a device, its tokens stop working immediately`,
+		Args:  cobra.ExactArgs(1),
+		Run:   revokeSession,
+	}
+	authSessionsRevokeOthersCmd = &cobra.Command{
+		Use:   "revoke-others",
+		Short: "Revoke all other sessions",
+		Long:  `Allows you to log out all devices except the current one`,
+		Run:   revokeOtherSessions,
+	}
+)
+
+func init() {
+	authCmd.AddCommand(authSessionsCmd)
+	authSessionsCmd.AddCommand(authSessionsRevokeCmd)
+	authSessionsCmd.AddCommand(authSessionsRevokeOthersCmd)
+}
+
+func listSessions(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	resp, err := cl.ListSessions(ctx, &pb.ListSessionsRequest{})
+	switch status.Code(err) {
+	case codes.OK:
+		// list ok
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	default:
+		fail(err, "")
+	}
+
+	checkErr(out.Print(newSessionListView(resp.GetSessions())))
+}
+
+func revokeSession(cmd *cobra.Command, args []string) {
+	id := args[0]
+	ctx := context.Background()
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	_, err := cl.RevokeSession(ctx, &pb.RevokeSessionRequest{Id: id})
+	switch status.Code(err) {
+	case codes.OK:
+		// revoke ok
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	case codes.NotFound:
+		fail(err, "Session not found")
+	default:
+		fail(err, "")
+	}
+
+	if !out.Structured() {
+		l.Info().Str("id", id).Msg("Session revoked")
+		return
+	}
+	checkErr(out.Print(&sessionRevokeView{Revoked: 1}))
+}
+
+func revokeOtherSessions(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	resp, err := cl.RevokeAllOtherSessions(ctx, &pb.RevokeAllOtherSessionsRequest{})
+	switch status.Code(err) {
+	case codes.OK:
+		// revoke ok
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	default:
+		fail(err, "")
+	}
+
+	if !out.Structured() {
+		l.Info().Int64("revoked", resp.GetRevoked()).Msg("Other sessions revoked")
+		return
+	}
+	checkErr(out.Print(&sessionRevokeView{Revoked: resp.GetRevoked()}))
+}
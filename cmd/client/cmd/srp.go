@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/client/pkg/output"
+	"gophkeeper/pkg/srp"
+	"strings"
+)
+
+const (
+	// reasonSRPNotEnrolled is returned by the server for accounts still having a password
+	reasonSRPNotEnrolled = "SRP_NOT_ENROLLED"
+	// reasonPasswordDisabled is returned by servers accepting SRP only
+	reasonPasswordDisabled = "PASSWORD_DISABLED"
+
+	// srpEnrolledKey of the auth config lists accounts known to use SRP, as "<server> <email>"
+	srpEnrolledKey = "srp_enrolled"
+)
+
+// errPasswordRefused when a server asks for the password of an account known to use SRP,
+// a server which lost or hides the verifier would learn the password otherwise
+var errPasswordRefused = errors.New("the account uses SRP, refusing to send the password, " +
+	"pass --legacy-password if the server was restored from a backup")
+
+// notEnrolled accounts log in with the password once to switch to SRP
+func notEnrolled(err error) bool {
+	return status.Code(err) == codes.FailedPrecondition && output.Reason(err) == reasonSRPNotEnrolled
+}
+
+// srpAccount identifies the account on the current server
+func srpAccount(email string) string {
+	return viper.GetString("server_addr") + " " + strings.ToLower(email)
+}
+
+// srpEnrolled reports if the account logged in with SRP from this client before
+func srpEnrolled(email string) bool {
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	account := srpAccount(email)
+	for _, a := range authViper.GetStringSlice(srpEnrolledKey) {
+		if a == account {
+			return true
+		}
+	}
+	return false
+}
+
+// markSRPEnrolled account, so its password is not sent to the server anymore
+func markSRPEnrolled(email string) {
+	if srpEnrolled(email) {
+		return
+	}
+
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	authViper.Set(srpEnrolledKey, append(authViper.GetStringSlice(srpEnrolledKey), srpAccount(email)))
+	if err := authViper.WriteConfig(); err != nil {
+		l.Warn().Err(err).Msg("SRP enrollment is not saved")
+	}
+}
+
+// legacyPassword may be sent for the account unless it is known to use SRP and --legacy-password is not set
+func legacyPassword(email string) error {
+	if srpEnrolled(email) && !viper.GetBool("legacy_password") {
+		return errPasswordRefused
+	}
+	return nil
+}
+
+// newSRPVerifier of the password, the server stores it instead of the password
+func newSRPVerifier(password string) (*pb.SRPVerifier, error) {
+	salt, verifier, err := srp.NewVerifier(password)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SRPVerifier{Salt: salt, Verifier: verifier}, nil
+}
+
+// srpStart begins a handshake returning the client proof, the client checks the server proof later
+func srpStart(ctx context.Context, cl pb.UserClient, email, password string) (*srp.Client, *pb.SRPProof, error) {
+	c, err := srp.NewClient(email, password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start, err := cl.StartSRPLogin(ctx, &pb.StartSRPLoginRequest{
+		Email:        email,
+		ClientPublic: c.Public(),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m1, err := c.Proof(start.GetSalt(), start.GetServerPublic())
+	if err != nil {
+		return nil, nil, fmt.Errorf("srp: %w", err)
+	}
+
+	return c, &pb.SRPProof{HandshakeId: start.GetHandshakeId(), ClientProof: m1}, nil
+}
+
+// srpLogin proves the password without sending it, the server proves it knows the verifier too
+func srpLogin(ctx context.Context, cl pb.UserClient, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	c, proof, err := srpStart(ctx, cl, req.GetEmail(), req.GetPassword())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cl.FinishSRPLogin(ctx, &pb.FinishSRPLoginRequest{
+		HandshakeId:  proof.GetHandshakeId(),
+		ClientProof:  proof.GetClientProof(),
+		Device:       req.GetDevice(),
+		OtpCode:      req.GetOtpCode(),
+		RecoveryCode: req.GetRecoveryCode(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.VerifyServer(resp.GetServerProof()); err != nil {
+		return nil, fmt.Errorf("srp server: %w", err)
+	}
+	markSRPEnrolled(req.GetEmail())
+
+	return &pb.LoginResponse{
+		Token:         resp.GetToken(),
+		RefreshToken:  resp.GetRefreshToken(),
+		EmailVerified: resp.GetEmailVerified(),
+	}, nil
+}
+
+// srpProof of the password for operations asking it again, nil if the account is not enrolled yet
+// and its password may be sent instead
+func srpProof(ctx context.Context, cl pb.UserClient, email, password string) (*pb.SRPProof, error) {
+	_, proof, err := srpStart(ctx, cl, email, password)
+	if notEnrolled(err) {
+		return nil, legacyPassword(email)
+	}
+	return proof, err
+}
+
+// enrollSRP replaces the password of the logged-in account with a verifier
+func enrollSRP(ctx context.Context, password string) error {
+	v, err := newSRPVerifier(password)
+	if err != nil {
+		return err
+	}
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	_, err = cl.EnrollSRP(ctx, &pb.EnrollSRPRequest{
+		Password: password,
+		Verifier: v,
+	})
+	if err != nil {
+		return err
+	}
+	markSRPEnrolled(authViper.GetString("email"))
+	return nil
+}
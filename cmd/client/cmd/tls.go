@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"gophkeeper/internal/client/pkg/tofu"
+	"gophkeeper/pkg/tlscert"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// tlsViper keeps certificate settings next to the auth of the user config
+	tlsViper *viper.Viper
+	// pins of server certificates trusted on first use
+	pins *tofu.Store
+)
+
+var authCertCmd = &cobra.Command{
+	Use:   "cert",
+	Short: "Save client certificate settings",
+	Long: `Saves --cert, --key and --ca given to the command, so they are used by default.
+A client certificate authenticates calls without login when the server trusts its CA
+and its key is registered with the certificate register command.
+Run without flags to show saved settings.`,
+	Args: cobra.NoArgs,
+	Run:  authCert,
+}
+
+var authTrustCmd = &cobra.Command{
+	Use:   "trust",
+	Short: "Pin the server certificate",
+	Long: `Shows the certificate of the server and pins it once confirmed, so TLS is used without a CA file.
+Certificates not trusted by the system are also pinned on the first call with --tls once confirmed
+at the prompt or matched by --fingerprint, without a terminal the call fails instead.
+Compare the fingerprint with one logged by the server on start, a changed certificate is rejected
+until its pin is forgotten with --forget.`,
+	Args: cobra.NoArgs,
+	Run:  authTrust,
+}
+
+func init() {
+	authCmd.AddCommand(authCertCmd)
+	authCmd.AddCommand(authTrustCmd)
+
+	authCertCmd.Flags().Bool("clear", false, "forget saved settings")
+	authTrustCmd.Flags().Bool("forget", false, "forget the pinned certificate")
+}
+
+// initTLS loads saved certificate settings as defaults of the flags
+func initTLS() {
+	for _, k := range []string{"cert", "key", "ca"} {
+		viper.SetDefault("tls_"+k, tlsViper.GetString(k))
+	}
+}
+
+func authTrust(cmd *cobra.Command, args []string) {
+	server := viper.GetString("server_addr")
+
+	forget, err := cmd.Flags().GetBool("forget")
+	checkErr(err)
+	if forget {
+		ok, err := pins.Delete(server)
+		checkErr(err)
+		if ok {
+			l.Info().Str("server", server).Msg("Pinned certificate forgotten")
+		} else {
+			l.Info().Str("server", server).Msg("No pinned certificate")
+		}
+		return
+	}
+
+	cert := fetchConnection(server).PeerCertificates[0]
+	fp := tlscert.Fingerprint(cert.Raw)
+	pinned, err := pins.Get(server)
+	checkErr(err)
+
+	v := &serverCertView{
+		Server:      server,
+		Subject:     cert.Subject.String(),
+		Fingerprint: fp,
+		ExpiresAt:   cert.NotAfter,
+		Pinned:      pinned == fp,
+	}
+	if v.Pinned {
+		checkErr(out.Print(v))
+		return
+	}
+	if pinned != "" {
+		l.Warn().Str("pinned", pinned).Msg("Server certificate changed")
+	}
+
+	expected := viper.GetString("tls_fingerprint")
+	if expected == "" {
+		checkErr(out.Print(v))
+		answer, err := prompt("Trust this certificate? (yes/no): ")
+		checkErr(err)
+		if answer != "yes" {
+			checkErr(errors.New("certificate not trusted"))
+		}
+	} else if !strings.EqualFold(expected, fp) {
+		checkErr(fmt.Errorf("certificate fingerprint is %s, expected %s", fp, expected))
+	}
+
+	checkErr(pins.Put(server, fp))
+	v.Pinned = true
+	if expected != "" {
+		checkErr(out.Print(v))
+		return
+	}
+	l.Info().Str("server", server).Msg("Certificate pinned")
+}
+
+// fetchConnection state with certificates presented by the server, they are not verified
+func fetchConnection(server string) tls.ConnectionState {
+	host, _, err := net.SplitHostPort(server)
+	checkErr(err)
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", server, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: host,
+		// the certificate is shown to the user to decide
+		InsecureSkipVerify: true,
+	})
+	checkErr(err)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	cs := conn.ConnectionState()
+	cs.ServerName = host
+	return cs
+}
+
+// pinOnFirstUse checks the certificate of the server before the first connection to it,
+// so an untrusted one is confirmed outside of the handshake and never pinned silently
+func pinOnFirstUse(server string) {
+	checkErr(pins.VerifyConnection(server, nil, confirmPin(server))(fetchConnection(server)))
+}
+
+// confirmPin of the server certificate: it has to match --fingerprint or be accepted at the prompt,
+// without a terminal to ask it is rejected
+func confirmPin(server string) func(fingerprint string) error {
+	return func(fp string) error {
+		if expected := viper.GetString("tls_fingerprint"); expected != "" {
+			if !strings.EqualFold(expected, fp) {
+				return fmt.Errorf("certificate fingerprint is %s, expected %s", fp, expected)
+			}
+			return nil
+		}
+
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("certificate %s of %s is not trusted, pin it with 'auth trust' or pass --fingerprint", fp, server)
+		}
+
+		l.Warn().Str("server", server).Str("fingerprint", fp).
+			Msg("Server certificate is not trusted, compare the fingerprint with the server log")
+		answer, err := prompt("Trust this certificate? (yes/no): ")
+		if err != nil {
+			return err
+		}
+		if answer != "yes" {
+			return errors.New("certificate not trusted")
+		}
+		return nil
+	}
+}
+
+func authCert(cmd *cobra.Command, args []string) {
+	forget, err := cmd.Flags().GetBool("clear")
+	checkErr(err)
+
+	changed := false
+	for _, k := range []string{"cert", "key", "ca"} {
+		v := ""
+		if !forget {
+			f := cmd.Flags().Lookup(k)
+			if !f.Changed {
+				continue
+			}
+			v, err = filepath.Abs(f.Value.String())
+			checkErr(err)
+		}
+		tlsViper.Set(k, v)
+		changed = true
+	}
+
+	if changed {
+		checkErr(tlsViper.WriteConfig())
+		if forget {
+			l.Info().Msg("Certificate settings forgotten")
+		} else {
+			l.Info().Msg("Certificate settings saved")
+		}
+		return
+	}
+
+	checkErr(out.Print(&tlsSettingsView{
+		Cert: tlsViper.GetString("cert"),
+		Key:  tlsViper.GetString("key"),
+		CA:   tlsViper.GetString("ca"),
+	}))
+}
+
+// transportCredentials of server connections, TLS is used with --tls, a CA, a client certificate
+// or a pinned server certificate. Without a CA certificates not trusted by the system are pinned on first use
+// with --tls once confirmed or checked against the pin, otherwise they are verified by the system roots.
+func transportCredentials() grpc.DialOption {
+	cert, key, ca := viper.GetString("tls_cert"), viper.GetString("tls_key"), viper.GetString("tls_ca")
+	server := viper.GetString("server_addr")
+	pinned, err := pins.Get(server)
+	checkErr(err)
+	if cert == "" && ca == "" && pinned == "" && !viper.GetBool("tls") {
+		return grpc.WithTransportCredentials(insecure.NewCredentials())
+	}
+
+	var c *tls.Config
+	if ca != "" {
+		pem, err := os.ReadFile(ca)
+		checkErr(err)
+		c = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    x509.NewCertPool(),
+		}
+		if !c.RootCAs.AppendCertsFromPEM(pem) {
+			checkErr(fmt.Errorf("no certificates in %s", ca))
+		}
+	} else if pinned != "" || viper.GetBool("tls") {
+		if pinned == "" {
+			pinOnFirstUse(server)
+		}
+		// a certificate changed since the check is rejected
+		c = pins.Config(server, nil, nil)
+	} else {
+		// a client certificate alone does not opt in to trust on first use
+		c = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	if cert != "" {
+		if key == "" {
+			checkErr(errors.New("client certificate requires --key"))
+		}
+		kp, err := tls.LoadX509KeyPair(cert, key)
+		checkErr(err)
+		c.Certificates = []tls.Certificate{kp}
+	}
+
+	return grpc.WithTransportCredentials(credentials.NewTLS(c))
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	pb "gophkeeper/api/proto"
+	"time"
+)
+
+var (
+	tokenCmd = &cobra.Command{
+		Use:   "token",
+		Short: "Manage API tokens",
+		Long: `API tokens give CI jobs and scripts access to secrets limited by scopes, without your password.
+Pass a token in the GKCLI_TOKEN environment variable, it is used instead of the logged-in session.
+API tokens can not manage the account, e.g. create other tokens.`,
+	}
+	tokenCreateCmd = &cobra.Command{
+		Use:   "create NAME",
+		Short: "Create an API token",
+		Long: `Creates an API token limited to scopes:
+  read           list and read all secrets
+  write          create and delete all secrets
+  read:PREFIX    read secrets with names starting with PREFIX, listing shows them only
+  write:PREFIX   create and delete secrets with names starting with PREFIX
+  read#TAG       read secrets tagged TAG, listing shows them only
+  write#TAG      create and delete secrets tagged TAG, they can not be untagged with it
+The token is shown once, store it right away.`,
+		Example: `  gkcli token create deploy --scope read:ci/ --expires 2160h
+  gkcli token create release --scope read#release
+  eval "$(gkcli token create nightly --scope read -o env)"`,
+		Args: cobra.ExactArgs(1),
+		Run:  createAPIToken,
+	}
+	tokenListCmd = &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List API tokens",
+		Long:    `Shows unexpired API tokens with their scopes and last use`,
+		Run:     listAPITokens,
+	}
+	tokenRevokeCmd = &cobra.Command{
+		Use:   "revoke ID",
+		Short: "Revoke an API token",
+		Long:  `Revokes an API token of yours or of your service account, it stops working immediately`,
+		Args:  cobra.ExactArgs(1),
+		Run:   revokeAPIToken,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(tokenCmd)
+	tokenCmd.AddCommand(tokenCreateCmd)
+	tokenCmd.AddCommand(tokenListCmd)
+	tokenCmd.AddCommand(tokenRevokeCmd)
+
+	tokenCreateCmd.Flags().StringSlice("scope", nil, "scope of the token, repeat or separate by commas")
+	tokenCreateCmd.Flags().Duration("expires", 30*24*time.Hour, "lifetime of the token, at most a year")
+	checkErr(tokenCreateCmd.MarkFlagRequired("scope"))
+}
+
+func createAPIToken(cmd *cobra.Command, args []string) {
+	createToken(cmd, "", args[0])
+}
+
+// createToken of the user or its service account if said is not empty
+func createToken(cmd *cobra.Command, said, name string) {
+	scopes, err := cmd.Flags().GetStringSlice("scope")
+	checkErr(err)
+	expires, err := cmd.Flags().GetDuration("expires")
+	checkErr(err)
+
+	ctx := context.Background()
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	resp, err := cl.CreateAPIToken(ctx, &pb.CreateAPITokenRequest{
+		Name:             name,
+		Scopes:           scopes,
+		Lifetime:         durationpb.New(expires),
+		ServiceAccountId: said,
+	})
+	switch status.Code(err) {
+	case codes.OK:
+		// created
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	case codes.PermissionDenied:
+		fail(err, "API tokens can not create tokens, log in")
+	case codes.InvalidArgument:
+		fail(err, "Invalid token")
+	case codes.AlreadyExists:
+		fail(err, "Token with this name already exists")
+	case codes.NotFound:
+		fail(err, "Service account not found")
+	default:
+		fail(err, "")
+	}
+
+	if !out.Structured() {
+		l.Info().
+			Str("id", resp.GetInfo().GetId()).
+			Time("expires", resp.GetInfo().GetExpiresAt().AsTime()).
+			Msg("API token created, store it now, it is not shown again")
+	}
+	checkErr(out.Print(&apiTokenCreateView{
+		apiTokenView: newAPITokenView(resp.GetInfo()),
+		Token:        resp.GetToken(),
+	}))
+}
+
+func listAPITokens(cmd *cobra.Command, args []string) {
+	listTokens("")
+}
+
+// listTokens of the user or its service account if said is not empty
+func listTokens(said string) {
+	ctx := context.Background()
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	resp, err := cl.ListAPITokens(ctx, &pb.ListAPITokensRequest{ServiceAccountId: said})
+	switch status.Code(err) {
+	case codes.OK:
+		// list ok
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	case codes.PermissionDenied:
+		fail(err, "API tokens can not list tokens, log in")
+	case codes.InvalidArgument, codes.NotFound:
+		fail(err, "Service account not found")
+	default:
+		fail(err, "")
+	}
+
+	checkErr(out.Print(newAPITokenListView(resp.GetTokens())))
+}
+
+func revokeAPIToken(cmd *cobra.Command, args []string) {
+	id := args[0]
+	ctx := context.Background()
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	_, err := cl.RevokeAPIToken(ctx, &pb.RevokeAPITokenRequest{Id: id})
+	switch status.Code(err) {
+	case codes.OK:
+		// revoke ok
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	case codes.PermissionDenied:
+		fail(err, "API tokens can not revoke tokens, log in")
+	case codes.InvalidArgument, codes.NotFound:
+		fail(err, "Token not found")
+	default:
+		fail(err, "")
+	}
+
+	if !out.Structured() {
+		l.Info().Str("id", id).Msg("API token revoked")
+		return
+	}
+	checkErr(out.Print(&sessionRevokeView{Revoked: 1}))
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"gophkeeper/internal/client/pkg/tui"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Browse secrets interactively",
+	Long:  `Opens a full-screen terminal interface to search, view, create, edit and remove secrets`,
+	Run:   runTUI,
+}
+
+func init() {
+	rootCmd.AddCommand(tuiCmd)
+}
+
+func runTUI(cmd *cobra.Command, args []string) {
+	cl, stop := getKeeperClient()
+	defer stop()
+
+	checkErr(tui.New(cl).Run())
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"os"
+)
+
+// reasons set by the server in ErrorInfo of two-factor errors
+const (
+	reasonTwoFactorRequired = "TWO_FACTOR_REQUIRED"
+	reasonTwoFactorInvalid  = "TWO_FACTOR_INVALID"
+)
+
+var (
+	auth2FACmd = &cobra.Command{
+		Use:   "2fa",
+		Short: "Two-factor authentication",
+		Long:  `Choose one of the command to do with two-factor authentication`,
+		Run: func(cmd *cobra.Command, args []string) {
+			checkErr(cmd.Help())
+		},
+	}
+	auth2FAEnableCmd = &cobra.Command{
+		Use:   "enable [password]",
+		Short: "Enable two-factor authentication",
+		Long: `Allows you to enroll an authenticator app with your password: add the shown secret or otpauth URI
+to the app and enter the code it generates. Store printed recovery codes safely, each of them can be used
+once instead of a code if the app is lost.`,
+		Args: cobra.ExactArgs(1),
+		Run:  enable2FA,
+	}
+	auth2FADisableCmd = &cobra.Command{
+		Use:   "disable [password]",
+		Short: "Disable two-factor authentication",
+		Long:  `Allows you to disable two-factor authentication with your password and a code or a recovery code`,
+		Args:  cobra.ExactArgs(1),
+		Run:   disable2FA,
+	}
+)
+
+func init() {
+	authCmd.AddCommand(auth2FACmd)
+	auth2FACmd.AddCommand(auth2FAEnableCmd)
+	auth2FACmd.AddCommand(auth2FADisableCmd)
+
+	for _, c := range []*cobra.Command{authLoginCmd, auth2FADisableCmd} {
+		c.Flags().String("otp", "", "two-factor code from the authenticator app, asked for when required")
+		c.Flags().String("recovery-code", "", "one-time recovery code to use instead of a two-factor code")
+	}
+}
+
+func enable2FA(cmd *cobra.Command, args []string) {
+	password := args[0]
+	ctx := context.Background()
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	req := &pb.EnableTwoFactorRequest{}
+	proof, err := srpProof(ctx, cl, authViper.GetString("email"), password)
+	checkErr(err)
+	if proof == nil {
+		req.Password = password
+	}
+	req.SrpProof = proof
+
+	resp, err := cl.EnableTwoFactor(ctx, req)
+	switch status.Code(err) {
+	case codes.OK:
+		// enrollment started
+	case codes.Unauthenticated, codes.PermissionDenied:
+		fail(err, "Auth error")
+	default:
+		fail(err, "")
+	}
+
+	fmt.Fprintf(os.Stderr, "Add to your authenticator app\n  secret: %s\n  uri:    %s\n", resp.GetSecret(), resp.GetOtpauthUri())
+
+	code, err := prompt("Code from the app: ")
+	checkErr(err)
+
+	confirm, err := cl.ConfirmTwoFactor(ctx, &pb.ConfirmTwoFactorRequest{OtpCode: code})
+	switch status.Code(err) {
+	case codes.OK:
+		// enabled
+	case codes.InvalidArgument:
+		fail(err, "Invalid code, run enable again")
+	default:
+		fail(err, "")
+	}
+
+	if !out.Structured() {
+		l.Info().Msg("Two-factor authentication enabled, store recovery codes safely")
+	}
+	checkErr(out.Print(&recoveryCodesView{Codes: confirm.GetRecoveryCodes()}))
+}
+
+func disable2FA(cmd *cobra.Command, args []string) {
+	password := args[0]
+	ctx := context.Background()
+
+	otp, recovery := twoFactorFlags(cmd)
+	if otp == "" && recovery == "" {
+		var err error
+		otp, err = prompt("Two-factor code: ")
+		checkErr(err)
+	}
+
+	cl, stop := getAuthUserClient()
+	defer stop()
+
+	req := &pb.DisableTwoFactorRequest{
+		OtpCode:      otp,
+		RecoveryCode: recovery,
+	}
+	proof, err := srpProof(ctx, cl, authViper.GetString("email"), password)
+	checkErr(err)
+	if proof == nil {
+		req.Password = password
+	}
+	req.SrpProof = proof
+
+	_, err = cl.DisableTwoFactor(ctx, req)
+	switch status.Code(err) {
+	case codes.OK:
+		// disabled
+	case codes.Unauthenticated:
+		fail(err, "Auth error")
+	default:
+		fail(err, "")
+	}
+
+	l.Info().Msg("Two-factor authentication disabled")
+}
+
+func twoFactorFlags(cmd *cobra.Command) (string, string) {
+	otp, err := cmd.Flags().GetString("otp")
+	checkErr(err)
+	recovery, err := cmd.Flags().GetString("recovery-code")
+	checkErr(err)
+	return otp, recovery
+}
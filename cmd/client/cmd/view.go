@@ -0,0 +1,443 @@
+package cmd
+
+import (
+	"fmt"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/client/pkg/output"
+	"gophkeeper/internal/client/pkg/secret"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// secretDescriptionView is a secret without content
+type secretDescriptionView struct {
+	Name      string    `json:"name" yaml:"name"`
+	Type      string    `json:"type" yaml:"type"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+	Tags      []string  `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// secretListView output of the ls command
+type secretListView []secretDescriptionView
+
+func newSecretListView(ss []*pb.SecretDescription) secretListView {
+	v := make(secretListView, 0, len(ss))
+	for _, s := range ss {
+		v = append(v, secretDescriptionView{
+			Name:      s.GetName(),
+			Type:      s.GetType(),
+			CreatedAt: s.GetCreatedAt().AsTime(),
+			UpdatedAt: secretUpdatedAt(s),
+			Tags:      s.GetTags(),
+		})
+	}
+	return v
+}
+
+func (v secretListView) Table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(v))
+	for _, s := range v {
+		rows = append(rows, []string{
+			s.Name,
+			s.Type,
+			s.CreatedAt.Local().Format(time.RFC822),
+			s.UpdatedAt.Local().Format(time.RFC822),
+			strings.Join(s.Tags, ","),
+		})
+	}
+	return []string{"NAME", "TYPE", "CREATED", "UPDATED", "TAGS"}, rows
+}
+
+func (v secretListView) Env() []output.EnvVar {
+	vars := make([]output.EnvVar, 0, len(v)*2)
+	for i, s := range v {
+		vars = append(vars,
+			output.EnvVar{Name: fmt.Sprintf("SECRET_%d_NAME", i), Value: s.Name},
+			output.EnvVar{Name: fmt.Sprintf("SECRET_%d_TYPE", i), Value: s.Type},
+		)
+	}
+	return vars
+}
+
+// secretView output of the read command
+type secretView struct {
+	Name   string            `json:"name" yaml:"name"`
+	Type   string            `json:"type" yaml:"type"`
+	Tags   []string          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Fields map[string]string `json:"fields" yaml:"fields"`
+
+	fields []secret.Field
+}
+
+func newSecretView(name string, tags []string, s secret.Secret) *secretView {
+	v := &secretView{
+		Name:   name,
+		Type:   s.Type(),
+		Tags:   tags,
+		Fields: make(map[string]string),
+		fields: s.Fields(),
+	}
+	for _, f := range v.fields {
+		v.Fields[f.Name] = f.Value
+	}
+	return v
+}
+
+func (v *secretView) Table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(v.fields))
+	for _, f := range v.fields {
+		rows = append(rows, []string{f.Name, f.Value})
+	}
+	return []string{"FIELD", "VALUE"}, rows
+}
+
+// Env contains secret fields only, so output can be sourced by shell
+func (v *secretView) Env() []output.EnvVar {
+	vars := make([]output.EnvVar, 0, len(v.fields))
+	for _, f := range v.fields {
+		vars = append(vars, output.EnvVar{Name: f.Name, Value: f.Value})
+	}
+	return vars
+}
+
+// fieldView output of the read command for a single field
+type fieldView struct {
+	Name  string `json:"name" yaml:"name"`
+	Field string `json:"field" yaml:"field"`
+	Value string `json:"value" yaml:"value"`
+}
+
+func (v *fieldView) Env() []output.EnvVar {
+	return []output.EnvVar{{Name: v.Field, Value: v.Value}}
+}
+
+// secretChangeView output of the create and rm commands
+type secretChangeView struct {
+	Name   string `json:"name" yaml:"name"`
+	Type   string `json:"type,omitempty" yaml:"type,omitempty"`
+	Result string `json:"result" yaml:"result"`
+}
+
+func (v *secretChangeView) Table() ([]string, [][]string) {
+	return []string{"NAME", "TYPE", "RESULT"}, [][]string{{v.Name, v.Type, v.Result}}
+}
+
+// secretImportView output of the account import command
+type secretImportView []secretChangeView
+
+func (v secretImportView) Table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(v))
+	for _, s := range v {
+		rows = append(rows, []string{s.Name, s.Type, s.Result})
+	}
+	return []string{"NAME", "TYPE", "RESULT"}, rows
+}
+
+// count of secrets with the result
+func (v secretImportView) count(result string) int {
+	n := 0
+	for _, s := range v {
+		if s.Result == result {
+			n++
+		}
+	}
+	return n
+}
+
+// usageView output of the account usage command, zero limit means unlimited
+type usageView struct {
+	Secrets       int64 `json:"secrets" yaml:"secrets"`
+	TotalSize     int64 `json:"total_size" yaml:"total_size"`
+	MaxSecrets    int64 `json:"max_secrets" yaml:"max_secrets"`
+	MaxTotalSize  int64 `json:"max_total_size" yaml:"max_total_size"`
+	MaxSecretSize int64 `json:"max_secret_size" yaml:"max_secret_size"`
+}
+
+func (v *usageView) Table() ([]string, [][]string) {
+	return []string{"RESOURCE", "USED", "LIMIT"}, [][]string{
+		{"secrets", fmt.Sprint(v.Secrets), formatLimit(v.MaxSecrets)},
+		{"total size, bytes", fmt.Sprint(v.TotalSize), formatLimit(v.MaxTotalSize)},
+		{"secret size, bytes", "", formatLimit(v.MaxSecretSize)},
+	}
+}
+
+// sessionView is a logged-in device
+type sessionView struct {
+	ID         string    `json:"id" yaml:"id"`
+	Device     string    `json:"device" yaml:"device"`
+	IP         string    `json:"ip" yaml:"ip"`
+	CreatedAt  time.Time `json:"created_at" yaml:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at" yaml:"last_used_at"`
+	Current    bool      `json:"current" yaml:"current"`
+}
+
+// sessionListView output of the auth sessions command
+type sessionListView []sessionView
+
+func newSessionListView(ss []*pb.Session) sessionListView {
+	v := make(sessionListView, 0, len(ss))
+	for _, s := range ss {
+		v = append(v, sessionView{
+			ID:         s.GetId(),
+			Device:     s.GetDevice(),
+			IP:         s.GetIp(),
+			CreatedAt:  s.GetCreatedAt().AsTime(),
+			LastUsedAt: s.GetLastUsedAt().AsTime(),
+			Current:    s.GetCurrent(),
+		})
+	}
+	return v
+}
+
+func (v sessionListView) Table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(v))
+	for _, s := range v {
+		current := ""
+		if s.Current {
+			current = "*"
+		}
+		rows = append(rows, []string{
+			s.ID,
+			s.Device,
+			s.IP,
+			s.CreatedAt.Local().Format(time.RFC822),
+			s.LastUsedAt.Local().Format(time.RFC822),
+			current,
+		})
+	}
+	return []string{"ID", "DEVICE", "IP", "CREATED", "LAST USED", "CURRENT"}, rows
+}
+
+// sessionRevokeView output of the session revoke commands
+type sessionRevokeView struct {
+	Revoked int64 `json:"revoked" yaml:"revoked"`
+}
+
+// recoveryCodesView output of the auth 2fa enable command
+type recoveryCodesView struct {
+	Codes []string `json:"recovery_codes" yaml:"recovery_codes"`
+}
+
+func (v *recoveryCodesView) Table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(v.Codes))
+	for _, c := range v.Codes {
+		rows = append(rows, []string{c})
+	}
+	return []string{"RECOVERY CODE"}, rows
+}
+
+// apiTokenView is an API token without its value
+type apiTokenView struct {
+	ID         string     `json:"id" yaml:"id"`
+	Name       string     `json:"name" yaml:"name"`
+	Scopes     []string   `json:"scopes" yaml:"scopes"`
+	CreatedAt  time.Time  `json:"created_at" yaml:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at" yaml:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" yaml:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty" yaml:"last_used_ip,omitempty"`
+	// ServiceAccountID is set for tokens of a service account
+	ServiceAccountID string `json:"service_account_id,omitempty" yaml:"service_account_id,omitempty"`
+}
+
+func newAPITokenView(t *pb.APIToken) apiTokenView {
+	v := apiTokenView{
+		ID:         t.GetId(),
+		Name:       t.GetName(),
+		Scopes:     t.GetScopes(),
+		CreatedAt:  t.GetCreatedAt().AsTime(),
+		ExpiresAt:  t.GetExpiresAt().AsTime(),
+		LastUsedIP: t.GetLastUsedIp(),
+
+		ServiceAccountID: t.GetServiceAccountId(),
+	}
+	if t.GetLastUsedAt() != nil {
+		at := t.GetLastUsedAt().AsTime()
+		v.LastUsedAt = &at
+	}
+	return v
+}
+
+// apiTokenListView output of the token ls command
+type apiTokenListView []apiTokenView
+
+func newAPITokenListView(tt []*pb.APIToken) apiTokenListView {
+	v := make(apiTokenListView, 0, len(tt))
+	for _, t := range tt {
+		v = append(v, newAPITokenView(t))
+	}
+	return v
+}
+
+func (v apiTokenListView) Table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(v))
+	for _, t := range v {
+		lastUsed := "never"
+		if t.LastUsedAt != nil {
+			lastUsed = t.LastUsedAt.Local().Format(time.RFC822) + " from " + t.LastUsedIP
+		}
+		rows = append(rows, []string{
+			t.ID,
+			t.Name,
+			strings.Join(t.Scopes, ","),
+			t.CreatedAt.Local().Format(time.RFC822),
+			t.ExpiresAt.Local().Format(time.RFC822),
+			lastUsed,
+		})
+	}
+	return []string{"ID", "NAME", "SCOPES", "CREATED", "EXPIRES", "LAST USED"}, rows
+}
+
+// clientCertificateView is a registered client certificate
+type clientCertificateView struct {
+	ID          string    `json:"id" yaml:"id"`
+	Name        string    `json:"name" yaml:"name"`
+	Fingerprint string    `json:"fingerprint" yaml:"fingerprint"`
+	CreatedAt   time.Time `json:"created_at" yaml:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at" yaml:"expires_at"`
+	// ServiceAccountID is set for certificates of a service account
+	ServiceAccountID string `json:"service_account_id,omitempty" yaml:"service_account_id,omitempty"`
+}
+
+// clientCertificateListView output of the certificate commands
+type clientCertificateListView []clientCertificateView
+
+func newClientCertificateListView(cc []*pb.ClientCertificate) clientCertificateListView {
+	v := make(clientCertificateListView, 0, len(cc))
+	for _, c := range cc {
+		v = append(v, clientCertificateView{
+			ID:               c.GetId(),
+			Name:             c.GetName(),
+			Fingerprint:      c.GetFingerprint(),
+			CreatedAt:        c.GetCreatedAt().AsTime(),
+			ExpiresAt:        c.GetExpiresAt().AsTime(),
+			ServiceAccountID: c.GetServiceAccountId(),
+		})
+	}
+	return v
+}
+
+func (v clientCertificateListView) Table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(v))
+	for _, c := range v {
+		rows = append(rows, []string{
+			c.ID,
+			c.Name,
+			c.Fingerprint,
+			c.CreatedAt.Local().Format(time.RFC822),
+			c.ExpiresAt.Local().Format(time.RFC822),
+		})
+	}
+	return []string{"ID", "NAME", "FINGERPRINT", "CREATED", "EXPIRES"}, rows
+}
+
+// apiTokenCreateView output of the token create command, the only time the token is shown
+type apiTokenCreateView struct {
+	apiTokenView `yaml:",inline"`
+	Token        string `json:"token" yaml:"token"`
+}
+
+func (v *apiTokenCreateView) Table() ([]string, [][]string) {
+	return []string{"TOKEN"}, [][]string{{v.Token}}
+}
+
+// Env can be sourced by shell to use the token right away
+func (v *apiTokenCreateView) Env() []output.EnvVar {
+	return []output.EnvVar{{Name: "GKCLI_TOKEN", Value: v.Token}}
+}
+
+// serviceAccountView is a machine identity of the user
+type serviceAccountView struct {
+	ID        string    `json:"id" yaml:"id"`
+	Name      string    `json:"name" yaml:"name"`
+	Grants    []string  `json:"grants" yaml:"grants"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+}
+
+// serviceAccountListView output of the service-account ls and create commands
+type serviceAccountListView []serviceAccountView
+
+func newServiceAccountListView(ss []*pb.ServiceAccount) serviceAccountListView {
+	v := make(serviceAccountListView, 0, len(ss))
+	for _, s := range ss {
+		v = append(v, serviceAccountView{
+			ID:        s.GetId(),
+			Name:      s.GetName(),
+			Grants:    s.GetGrants(),
+			CreatedAt: s.GetCreatedAt().AsTime(),
+		})
+	}
+	return v
+}
+
+func (v serviceAccountListView) Table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(v))
+	for _, s := range v {
+		grants := strings.Join(s.Grants, ",")
+		if grants == "" {
+			grants = "none"
+		}
+		rows = append(rows, []string{s.ID, s.Name, grants, s.CreatedAt.Local().Format(time.RFC822)})
+	}
+	return []string{"ID", "NAME", "GRANTS", "CREATED"}, rows
+}
+
+// tlsSettingsView output of the auth cert command
+type tlsSettingsView struct {
+	Cert string `json:"cert" yaml:"cert"`
+	Key  string `json:"key" yaml:"key"`
+	CA   string `json:"ca" yaml:"ca"`
+}
+
+func (v *tlsSettingsView) Table() ([]string, [][]string) {
+	return []string{"CERT", "KEY", "CA"}, [][]string{{v.Cert, v.Key, v.CA}}
+}
+
+// serverCertView output of the auth trust command
+type serverCertView struct {
+	Server      string    `json:"server" yaml:"server"`
+	Subject     string    `json:"subject" yaml:"subject"`
+	Fingerprint string    `json:"fingerprint" yaml:"fingerprint"`
+	ExpiresAt   time.Time `json:"expires_at" yaml:"expires_at"`
+	Pinned      bool      `json:"pinned" yaml:"pinned"`
+}
+
+func (v *serverCertView) Table() ([]string, [][]string) {
+	return []string{"SERVER", "SUBJECT", "FINGERPRINT", "EXPIRES", "PINNED"}, [][]string{{
+		v.Server, v.Subject, v.Fingerprint, v.ExpiresAt.Local().Format(time.RFC822), strconv.FormatBool(v.Pinned),
+	}}
+}
+
+// serverInfoView output of the info command
+type serverInfoView struct {
+	Server        string   `json:"server" yaml:"server"`
+	Version       string   `json:"version" yaml:"version"`
+	Branch        string   `json:"branch" yaml:"branch"`
+	Revision      string   `json:"revision" yaml:"revision"`
+	BuildDate     string   `json:"build_date" yaml:"build_date"`
+	GoVersion     string   `json:"go_version" yaml:"go_version"`
+	Features      []string `json:"features" yaml:"features"`
+	ClientVersion string   `json:"client_version" yaml:"client_version"`
+}
+
+func (v *serverInfoView) Table() ([]string, [][]string) {
+	return []string{"PROPERTY", "VALUE"}, [][]string{
+		{"server", v.Server},
+		{"version", v.Version},
+		{"branch", v.Branch},
+		{"revision", v.Revision},
+		{"build date", v.BuildDate},
+		{"go version", v.GoVersion},
+		{"features", strings.Join(v.Features, ", ")},
+		{"client version", v.ClientVersion},
+	}
+}
+
+// secretUpdatedAt is the time of the last change, servers without updated_at set created_at on updates
+func secretUpdatedAt(d *pb.SecretDescription) time.Time {
+	if d.GetUpdatedAt() == nil {
+		return d.GetCreatedAt().AsTime()
+	}
+	return d.GetUpdatedAt().AsTime()
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"gophkeeper/internal/server/grpcservice"
+	"gophkeeper/pkg/logger"
+	"gophkeeper/pkg/token"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Token signing keys",
+	Long:  `Choose one of the command to do with token signing keys in security.keys_dir`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.CheckErr(cmd.Help())
+	},
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List signing keys",
+	Long: `Lists active, published and retired keys. Published keys verify tokens and wait for activation,
+retired keys only verify tokens issued before rotation`,
+	Run: keysList,
+}
+
+var keysPublishCmd = &cobra.Command{
+	Use:     "publish",
+	Aliases: []string{"rotate"},
+	Short:   "Generate a new signing key verifying tokens only",
+	Long: `Generates a new key that verifies tokens but does not sign them yet, the first step of rotation.
+Servers check the key dir every 10 seconds and reload it once modified. When every server sharing the dir has the new key,
+activate it with "keys activate", so tokens it signs are accepted by all of them.
+The first key of the dir is activated right away.`,
+	Run: keysPublish,
+}
+
+var keysActivateCmd = &cobra.Command{
+	Use:   "activate [kid]",
+	Short: "Sign tokens with a published key",
+	Long: `Makes a published key active, the second step of rotation. The previous active key is retired:
+its private part is deleted and tokens it signed stay valid till they expire. Servers check the key dir
+every 10 seconds and reload it once modified, no restart is needed.`,
+	Args: cobra.ExactArgs(1),
+	Run:  keysActivate,
+}
+
+var keysRemoveCmd = &cobra.Command{
+	Use:   "remove [kid]",
+	Short: "Remove a retired key",
+	Long: `Removes a retired or published key, tokens it signed become invalid.
+A key retired less than a year ago is kept, as API tokens it signed may be still valid, unless --force is set.`,
+	Args: cobra.ExactArgs(1),
+	Run:  keysRemove,
+}
+
+func init() {
+	rootCmd.AddCommand(keysCmd)
+	keysCmd.AddCommand(keysListCmd)
+	keysCmd.AddCommand(keysPublishCmd)
+	keysCmd.AddCommand(keysActivateCmd)
+	keysCmd.AddCommand(keysRemoveCmd)
+
+	keysPublishCmd.Flags().String("alg", token.AlgEdDSA, fmt.Sprintf("signing algorithm: %s or %s", token.AlgEdDSA, token.AlgRS256))
+	keysRemoveCmd.Flags().Bool("force", false, "remove a key retired less than a year ago, tokens it signed stop working")
+}
+
+func keyDir() *token.KeyDir {
+	if cfg.Security.KeysDir == "" {
+		logger.CheckErr(errors.New("security.keys_dir is not set"))
+	}
+	return token.NewKeyDir(cfg.Security.KeysDir)
+}
+
+func keysList(cmd *cobra.Command, args []string) {
+	infos, err := keyDir().List()
+	logger.CheckErr(err)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tALG\tSTATUS\tCREATED\tRETIRED")
+	for _, k := range infos {
+		st := "published"
+		switch {
+		case k.Active:
+			st = "active"
+		case k.Retired:
+			st = "retired"
+		}
+		retired := ""
+		if !k.RetiredAt.IsZero() {
+			retired = k.RetiredAt.Local().Format(time.RFC822)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", k.ID, k.Algorithm, st, k.CreatedAt.Local().Format(time.RFC822), retired)
+	}
+	logger.CheckErr(w.Flush())
+}
+
+func keysPublish(cmd *cobra.Command, args []string) {
+	alg, err := cmd.Flags().GetString("alg")
+	logger.CheckErr(err)
+
+	d := keyDir()
+	kid, err := d.Publish(alg)
+	logger.CheckErr(err)
+
+	infos, err := d.List()
+	logger.CheckErr(err)
+	for _, k := range infos {
+		if k.ID == kid && k.Active {
+			logger.Global().Info().Str("kid", kid).Str("alg", alg).Msg("First key generated and activated")
+			return
+		}
+	}
+
+	logger.Global().Info().Str("kid", kid).Str("alg", alg).
+		Msg("Key published, activate it once every server has loaded it")
+}
+
+func keysActivate(cmd *cobra.Command, args []string) {
+	logger.CheckErr(keyDir().Activate(args[0]))
+
+	logger.Global().Info().Str("kid", args[0]).Msg("Key activated, the previous one is retired")
+}
+
+func keysRemove(cmd *cobra.Command, args []string) {
+	force, err := cmd.Flags().GetBool("force")
+	logger.CheckErr(err)
+
+	// API tokens live the longest of tokens signed by keys
+	keep := grpcservice.MaxAPITokenLifetime
+	if force {
+		keep = 0
+	}
+	logger.CheckErr(keyDir().Remove(args[0], keep))
+
+	logger.Global().Info().Str("kid", args[0]).Msg("Key removed")
+}
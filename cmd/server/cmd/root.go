@@ -55,6 +55,13 @@ func initConfig() {
 	var defaultConfig = []byte(`
 [grpc]
 listen_addr="localhost:50051"
+tls_cert_file=""
+tls_key_file=""
+tls_self_signed=0
+client_ca_file=""
+reflection=0
+[http]
+listen_addr=""
 [db]
 dsn=""
 [log]
@@ -62,6 +69,40 @@ verbose=0
 pretty=0
 [security]
 secret_key="CHANGE_ME"
+keys_dir=""
+access_token_lifetime="15m"
+refresh_token_lifetime="720h"
+srp_only=0
+[secrets]
+e2e=0
+max_size=1048576
+[quota]
+max_secrets=0
+max_total_size=0
+[mail]
+driver="log"
+log_tokens=0
+from="gophkeeper@localhost"
+file_path="mail.mbox"
+smtp_addr="localhost:1025"
+smtp_username=""
+smtp_password=""
+[throttle]
+enabled=1
+[throttle.email]
+free_attempts=5
+base_delay="1s"
+max_delay="5m"
+lockout_attempts=20
+lockout_duration="1h"
+reset_after="1h"
+[throttle.ip]
+free_attempts=20
+base_delay="1s"
+max_delay="5m"
+lockout_attempts=100
+lockout_duration="1h"
+reset_after="1h"
 `)
 	logger.CheckErr(viper.ReadConfig(bytes.NewBuffer(defaultConfig)))
 
@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gophkeeper/internal/client/pkg/secret"
+)
+
+const day = 24 * time.Hour
+
+// Entry is a decoded secret to audit, Secret may be nil for types checked for staleness only
+type Entry struct {
+	Name      string
+	ChangedAt time.Time
+	Secret    secret.Secret
+}
+
+type Options struct {
+	// Now is the audit time
+	Now time.Time
+	// MinEntropy of a password in bits, weaker ones are reported
+	MinEntropy float64
+	// StaleAfter is the max age of a secret since last change
+	StaleAfter time.Duration
+	// ExpiringWithin is the period to report cards expiring in
+	ExpiringWithin time.Duration
+}
+
+// DefaultOptions for an audit at the current time
+func DefaultOptions() Options {
+	return Options{
+		Now:            time.Now(),
+		MinEntropy:     50,
+		StaleAfter:     365 * day,
+		ExpiringWithin: 60 * day,
+	}
+}
+
+type WeakPassword struct {
+	Name    string   `json:"name" yaml:"name"`
+	Entropy float64  `json:"entropy" yaml:"entropy"`
+	Reasons []string `json:"reasons" yaml:"reasons"`
+}
+
+type ReusedPassword struct {
+	Names []string `json:"names" yaml:"names"`
+}
+
+type StaleSecret struct {
+	Name      string    `json:"name" yaml:"name"`
+	ChangedAt time.Time `json:"changed_at" yaml:"changed_at"`
+	AgeDays   int       `json:"age_days" yaml:"age_days"`
+}
+
+type ExpiringCard struct {
+	Name      string    `json:"name" yaml:"name"`
+	Expires   string    `json:"expires" yaml:"expires"`
+	ExpiresAt time.Time `json:"expires_at" yaml:"expires_at"`
+	Expired   bool      `json:"expired" yaml:"expired"`
+}
+
+// Report of the vault health, never contains secret values
+type Report struct {
+	Audited  int              `json:"audited" yaml:"audited"`
+	Weak     []WeakPassword   `json:"weak" yaml:"weak"`
+	Reused   []ReusedPassword `json:"reused" yaml:"reused"`
+	Stale    []StaleSecret    `json:"stale" yaml:"stale"`
+	Expiring []ExpiringCard   `json:"expiring" yaml:"expiring"`
+}
+
+// Run audit of the entries
+func Run(entries []Entry, opts Options) *Report {
+	r := &Report{
+		Audited:  len(entries),
+		Weak:     []WeakPassword{},
+		Reused:   []ReusedPassword{},
+		Stale:    []StaleSecret{},
+		Expiring: []ExpiringCard{},
+	}
+
+	byPassword := make(map[string][]string)
+
+	for _, e := range entries {
+		if opts.StaleAfter > 0 && !e.ChangedAt.IsZero() && opts.Now.Sub(e.ChangedAt) > opts.StaleAfter {
+			r.Stale = append(r.Stale, StaleSecret{
+				Name:      e.Name,
+				ChangedAt: e.ChangedAt,
+				AgeDays:   int(opts.Now.Sub(e.ChangedAt) / day),
+			})
+		}
+
+		switch s := e.Secret.(type) {
+		case *secret.LoginPassword:
+			if st := PasswordStrength(s.Password, s.Login); st.Entropy < opts.MinEntropy {
+				r.Weak = append(r.Weak, WeakPassword{
+					Name:    e.Name,
+					Entropy: float64(int(st.Entropy*10)) / 10,
+					Reasons: append([]string{}, st.Reasons...),
+				})
+			}
+			if s.Password != "" {
+				byPassword[s.Password] = append(byPassword[s.Password], e.Name)
+			}
+		case *secret.Card:
+			exp, err := CardExpiry(s.Expires)
+			if err != nil {
+				r.Expiring = append(r.Expiring, ExpiringCard{Name: e.Name, Expires: s.Expires})
+				continue
+			}
+			if exp.Sub(opts.Now) <= opts.ExpiringWithin {
+				r.Expiring = append(r.Expiring, ExpiringCard{
+					Name:      e.Name,
+					Expires:   s.Expires,
+					ExpiresAt: exp,
+					Expired:   !exp.After(opts.Now),
+				})
+			}
+		}
+	}
+
+	for _, names := range byPassword {
+		if len(names) > 1 {
+			sort.Strings(names)
+			r.Reused = append(r.Reused, ReusedPassword{Names: names})
+		}
+	}
+	sort.Slice(r.Reused, func(i, j int) bool {
+		return r.Reused[i].Names[0] < r.Reused[j].Names[0]
+	})
+
+	return r
+}
+
+// CardExpiry parses MM/YY or MM/YYYY, card is valid till the end of the month
+func CardExpiry(s string) (time.Time, error) {
+	for _, layout := range []string{"01/06", "01/2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.AddDate(0, 1, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid card expiry %q, expected MM/YY or MM/YYYY", s)
+}
+
+// Table representation of the report, one issue per row
+func (r *Report) Table() ([]string, [][]string) {
+	var rows [][]string
+
+	for _, w := range r.Weak {
+		rows = append(rows, []string{"weak", w.Name, fmt.Sprintf("~%.0f bits %v", w.Entropy, w.Reasons)})
+	}
+	for _, u := range r.Reused {
+		for _, n := range u.Names {
+			rows = append(rows, []string{"reused", n, fmt.Sprintf("same password in %d secrets %v", len(u.Names), u.Names)})
+		}
+	}
+	for _, s := range r.Stale {
+		rows = append(rows, []string{"stale", s.Name, fmt.Sprintf("not changed for %d days", s.AgeDays)})
+	}
+	for _, c := range r.Expiring {
+		switch {
+		case c.ExpiresAt.IsZero():
+			rows = append(rows, []string{"card", c.Name, fmt.Sprintf("unknown expiry %q", c.Expires)})
+		case c.Expired:
+			rows = append(rows, []string{"card", c.Name, "expired " + c.Expires})
+		default:
+			rows = append(rows, []string{"card", c.Name, "expires " + c.Expires})
+		}
+	}
+
+	return []string{"ISSUE", "SECRET", "DETAILS"}, rows
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gophkeeper/internal/client/pkg/secret"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	tests := []struct {
+		password string
+		login    string
+		weak     bool
+	}{
+		{password: "", weak: true},
+		{password: "password", weak: true},
+		{password: "Qwerty123", weak: true},
+		{password: "abcdefgh12345", weak: true},
+		{password: "aaaaaaaaaaaaaaaa", weak: true},
+		{password: "johnsmith1984!", login: "johnsmith", weak: true},
+		{password: "x7#Lp9!qR2@vM4zK", weak: false},
+		{password: "correct horse battery staple", weak: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			st := PasswordStrength(tt.password, tt.login)
+			assert.Equal(t, tt.weak, st.Entropy < 50, "entropy %.1f, reasons %v", st.Entropy, st.Reasons)
+		})
+	}
+}
+
+func TestCardExpiry(t *testing.T) {
+	exp, err := CardExpiry("12/30")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC), exp)
+
+	exp, err = CardExpiry("02/2027")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC), exp)
+
+	_, err = CardExpiry("2027-02")
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	opts := DefaultOptions()
+	opts.Now = now
+
+	strong := "x7#Lp9!qR2@vM4zK"
+	r := Run([]Entry{
+		{Name: "mail", ChangedAt: now, Secret: &secret.LoginPassword{Login: "me", Password: "password"}},
+		{Name: "bank", ChangedAt: now, Secret: &secret.LoginPassword{Login: "me", Password: strong}},
+		{Name: "shop", ChangedAt: now, Secret: &secret.LoginPassword{Login: "me", Password: strong}},
+		{Name: "old", ChangedAt: now.AddDate(-2, 0, 0)},
+		{Name: "visa", ChangedAt: now, Secret: &secret.Card{Expires: "11/26"}},
+		{Name: "amex", ChangedAt: now, Secret: &secret.Card{Expires: "09/26"}},
+		{Name: "mc", ChangedAt: now, Secret: &secret.Card{Expires: "12/30"}},
+	}, opts)
+
+	assert.Equal(t, 7, r.Audited)
+	if assert.Len(t, r.Weak, 1) {
+		assert.Equal(t, "mail", r.Weak[0].Name)
+	}
+	assert.Equal(t, []ReusedPassword{{Names: []string{"bank", "shop"}}}, r.Reused)
+	if assert.Len(t, r.Stale, 1) {
+		assert.Equal(t, "old", r.Stale[0].Name)
+		assert.Equal(t, 730, r.Stale[0].AgeDays)
+	}
+	if assert.Len(t, r.Expiring, 2) {
+		assert.Equal(t, "visa", r.Expiring[0].Name)
+		assert.False(t, r.Expiring[0].Expired)
+		assert.Equal(t, "amex", r.Expiring[1].Name)
+		assert.True(t, r.Expiring[1].Expired)
+	}
+}
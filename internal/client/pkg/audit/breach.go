@@ -0,0 +1,193 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha1" //nolint:gosec // HIBP corpus is keyed by SHA-1
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gophkeeper/internal/client/pkg/secret"
+)
+
+const corpusChunk = 256
+
+// Corpus of breached password hashes in the HIBP format: "SHA1HEX:COUNT" lines sorted by hash.
+// Lookups binary search the file on disk, so it is never loaded into memory.
+type Corpus struct {
+	r    io.ReaderAt
+	size int64
+}
+
+// NewCorpus over a sorted hash list of a given size
+func NewCorpus(r io.ReaderAt, size int64) *Corpus {
+	return &Corpus{
+		r:    r,
+		size: size,
+	}
+}
+
+// OpenCorpus file, caller closes returned file when done
+func OpenCorpus(path string) (*Corpus, *os.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open corpus: %w", err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, fmt.Errorf("stat corpus: %w", err)
+	}
+
+	return NewCorpus(f, fi.Size()), f, nil
+}
+
+// PasswordHash in the corpus format
+func PasswordHash(password string) string {
+	sum := sha1.Sum([]byte(password)) //nolint:gosec // HIBP corpus is keyed by SHA-1
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// Lookup a hash, returns occurrence count if found
+func (c *Corpus) Lookup(hash string) (int64, bool, error) {
+	key := []byte(strings.ToUpper(hash))
+
+	// invariant: the line with the key, if any, starts within [lo, hi)
+	lo, hi := int64(0), c.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		start, line, err := c.lineAfter(mid)
+		if err != nil {
+			return 0, false, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+
+		h, count := splitCorpusLine(line)
+		switch cmp := bytes.Compare(key, bytes.ToUpper(h)); {
+		case cmp == 0:
+			return count, true, nil
+		case cmp < 0:
+			hi = mid
+		default:
+			lo = start + int64(len(line)) + 1
+		}
+	}
+
+	return 0, false, nil
+}
+
+// lineAfter returns the first line starting at or after offset, start is c.size if there is none
+func (c *Corpus) lineAfter(offset int64) (int64, []byte, error) {
+	start := offset
+	if offset > 0 {
+		// skip the rest of a line containing offset-1
+		pos := offset - 1
+		for {
+			chunk, err := c.read(pos)
+			if err != nil {
+				return 0, nil, err
+			}
+			if i := bytes.IndexByte(chunk, '\n'); i >= 0 {
+				start = pos + int64(i) + 1
+				break
+			}
+			pos += int64(len(chunk))
+			if pos >= c.size {
+				return c.size, nil, nil
+			}
+		}
+	}
+	if start >= c.size {
+		return c.size, nil, nil
+	}
+
+	var line []byte
+	for pos := start; pos < c.size; {
+		chunk, err := c.read(pos)
+		if err != nil {
+			return 0, nil, err
+		}
+		if i := bytes.IndexByte(chunk, '\n'); i >= 0 {
+			return start, append(line, chunk[:i]...), nil
+		}
+		line = append(line, chunk...)
+		pos += int64(len(chunk))
+	}
+
+	return start, line, nil
+}
+
+func (c *Corpus) read(pos int64) ([]byte, error) {
+	buf := make([]byte, corpusChunk)
+	n, err := c.r.ReadAt(buf, pos)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("read corpus: %w", err)
+	}
+	return buf[:n], nil
+}
+
+func splitCorpusLine(line []byte) ([]byte, int64) {
+	line = bytes.TrimRight(line, "\r")
+	h, cnt, _ := bytes.Cut(line, []byte(":"))
+	count, _ := strconv.ParseInt(string(cnt), 10, 64)
+	return h, count
+}
+
+type Breach struct {
+	Name  string `json:"name" yaml:"name"`
+	Count int64  `json:"count" yaml:"count"`
+}
+
+// BreachReport lists secrets with passwords found in the corpus
+type BreachReport struct {
+	Checked  int      `json:"checked" yaml:"checked"`
+	Breached []Breach `json:"breached" yaml:"breached"`
+}
+
+// CheckBreaches of login/password entries, other entries are skipped
+func CheckBreaches(entries []Entry, c *Corpus) (*BreachReport, error) {
+	r := &BreachReport{
+		Breached: []Breach{},
+	}
+
+	for _, e := range entries {
+		lp, ok := e.Secret.(*secret.LoginPassword)
+		if !ok || lp.Password == "" {
+			continue
+		}
+		r.Checked++
+
+		count, found, err := c.Lookup(PasswordHash(lp.Password))
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			r.Breached = append(r.Breached, Breach{Name: e.Name, Count: count})
+		}
+	}
+
+	sort.SliceStable(r.Breached, func(i, j int) bool {
+		return r.Breached[i].Count > r.Breached[j].Count
+	})
+
+	return r, nil
+}
+
+// Table representation of the report
+func (r *BreachReport) Table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(r.Breached))
+	for _, b := range r.Breached {
+		rows = append(rows, []string{b.Name, strconv.FormatInt(b.Count, 10)})
+	}
+	return []string{"SECRET", "SEEN IN BREACHES"}, rows
+}
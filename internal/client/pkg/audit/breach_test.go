@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gophkeeper/internal/client/pkg/secret"
+)
+
+func testCorpus(t *testing.T, passwords map[string]int64, eol string) *Corpus {
+	t.Helper()
+
+	lines := make([]string, 0, len(passwords))
+	for p, n := range passwords {
+		lines = append(lines, fmt.Sprintf("%s:%d", PasswordHash(p), n))
+	}
+	sort.Strings(lines)
+
+	data := strings.Join(lines, eol) + eol
+	return NewCorpus(strings.NewReader(data), int64(len(data)))
+}
+
+func TestPasswordHash(t *testing.T) {
+	assert.Equal(t, "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8", PasswordHash("password"))
+}
+
+func TestCorpus_Lookup(t *testing.T) {
+	passwords := make(map[string]int64)
+	for i := 0; i < 1000; i++ {
+		passwords[fmt.Sprintf("password%d", i)] = int64(i + 1)
+	}
+
+	for _, eol := range []string{"\n", "\r\n"} {
+		c := testCorpus(t, passwords, eol)
+
+		for p, n := range passwords {
+			count, found, err := c.Lookup(PasswordHash(p))
+			require.NoError(t, err)
+			require.True(t, found, p)
+			require.Equal(t, n, count, p)
+		}
+
+		for i := 1000; i < 1100; i++ {
+			_, found, err := c.Lookup(PasswordHash(fmt.Sprintf("password%d", i)))
+			require.NoError(t, err)
+			require.False(t, found)
+		}
+
+		// lowercase lookups and bounds
+		_, found, err := c.Lookup(strings.ToLower(PasswordHash("password0")))
+		require.NoError(t, err)
+		assert.True(t, found)
+		_, found, err = c.Lookup(strings.Repeat("0", 40))
+		require.NoError(t, err)
+		assert.False(t, found)
+		_, found, err = c.Lookup(strings.Repeat("F", 40))
+		require.NoError(t, err)
+		assert.False(t, found)
+	}
+}
+
+func TestCorpus_Empty(t *testing.T) {
+	_, found, err := NewCorpus(strings.NewReader(""), 0).Lookup(PasswordHash("password"))
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestOpenCorpus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	require.NoError(t, os.WriteFile(path, []byte(PasswordHash("password")+":42\n"), 0600))
+
+	c, f, err := OpenCorpus(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	count, found, err := c.Lookup(PasswordHash("password"))
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(42), count)
+
+	_, _, err = OpenCorpus(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
+
+func TestCheckBreaches(t *testing.T) {
+	c := testCorpus(t, map[string]int64{"password": 10, "qwerty": 100, "letmein": 1}, "\n")
+
+	r, err := CheckBreaches([]Entry{
+		{Name: "mail", Secret: &secret.LoginPassword{Login: "me", Password: "password"}},
+		{Name: "shop", Secret: &secret.LoginPassword{Login: "me", Password: "qwerty"}},
+		{Name: "bank", Secret: &secret.LoginPassword{Login: "me", Password: "x7#Lp9!qR2@vM4zK"}},
+		{Name: "empty", Secret: &secret.LoginPassword{Login: "me"}},
+		{Name: "visa", Secret: &secret.Card{Expires: "12/30"}},
+		{Name: "raw"},
+	}, c)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, r.Checked)
+	assert.Equal(t, []Breach{{Name: "shop", Count: 100}, {Name: "mail", Count: 10}}, r.Breached)
+}
@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonPasswords are rejected regardless of their entropy estimate
+var commonPasswords = map[string]struct{}{
+	"123456": {}, "123456789": {}, "12345678": {}, "12345": {}, "1234567": {}, "1234567890": {},
+	"password": {}, "password1": {}, "password123": {}, "passw0rd": {}, "p@ssw0rd": {},
+	"qwerty": {}, "qwerty123": {}, "qwertyuiop": {}, "asdfgh": {}, "zxcvbnm": {}, "1q2w3e4r": {},
+	"111111": {}, "000000": {}, "123123": {}, "654321": {}, "abc123": {}, "iloveyou": {},
+	"admin": {}, "admin123": {}, "root": {}, "toor": {}, "letmein": {}, "welcome": {},
+	"monkey": {}, "dragon": {}, "football": {}, "baseball": {}, "master": {}, "sunshine": {},
+	"princess": {}, "shadow": {}, "superman": {}, "trustno1": {}, "secret": {}, "changeme": {},
+}
+
+// keyboardRows used to detect keyboard walks like "qwerty" or "asdf", digits are covered by sequences
+var keyboardRows = []string{
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+}
+
+// Strength of a password
+type Strength struct {
+	// Entropy estimate in bits after pattern penalties
+	Entropy float64
+	// Reasons why the password is considered weak
+	Reasons []string
+}
+
+// PasswordStrength estimates password entropy from its character classes and length,
+// known patterns reduce the estimate. Login is checked to not be a part of the password.
+func PasswordStrength(password, login string) Strength {
+	var st Strength
+
+	if password == "" {
+		st.Reasons = append(st.Reasons, "empty password")
+		return st
+	}
+
+	lower := strings.ToLower(password)
+	if _, ok := commonPasswords[lower]; ok {
+		st.Reasons = append(st.Reasons, "common password")
+		return st
+	}
+
+	runes := []rune(password)
+	length := float64(len(runes))
+	st.Entropy = length * math.Log2(float64(charsetSize(password)))
+
+	if len(runes) < 8 {
+		st.Reasons = append(st.Reasons, "shorter than 8 characters")
+	}
+
+	// every patterned rune adds almost nothing to the entropy
+	if n := repeatedRunes(lower); n > 0 {
+		st.Entropy -= float64(n) * st.Entropy / length
+		st.Reasons = append(st.Reasons, "repeated characters")
+	}
+	if n := sequentialRunes(lower); n > 0 {
+		st.Entropy -= float64(n) * st.Entropy / length
+		st.Reasons = append(st.Reasons, "sequential characters")
+	}
+	if n := keyboardWalk(lower); n > 0 {
+		st.Entropy -= float64(n) * st.Entropy / length
+		st.Reasons = append(st.Reasons, "keyboard pattern")
+	}
+
+	if l := strings.ToLower(login); len(l) >= 3 && strings.Contains(lower, l) {
+		st.Entropy /= 2
+		st.Reasons = append(st.Reasons, "contains login")
+	}
+
+	if st.Entropy < 0 {
+		st.Entropy = 0
+	}
+
+	return st
+}
+
+func charsetSize(s string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range s {
+		switch {
+		case r > unicode.MaxASCII:
+			other = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 100
+	}
+	return size
+}
+
+// repeatedRunes counts runes equal to the previous one
+func repeatedRunes(s string) int {
+	n := 0
+	rr := []rune(s)
+	for i := 1; i < len(rr); i++ {
+		if rr[i] == rr[i-1] {
+			n++
+		}
+	}
+	return n
+}
+
+// sequentialRunes counts runes continuing ascending or descending sequence of 3 or more like "abc" or "321"
+func sequentialRunes(s string) int {
+	n := 0
+	rr := []rune(s)
+	for i := 2; i < len(rr); i++ {
+		d1, d2 := rr[i]-rr[i-1], rr[i-1]-rr[i-2]
+		if d1 == d2 && (d1 == 1 || d1 == -1) {
+			n++
+		}
+	}
+	return n
+}
+
+// keyboardWalk counts runes continuing walks of 3 or more adjacent keys on a keyboard row
+func keyboardWalk(s string) int {
+	n := 0
+	for i := 2; i < len(s); i++ {
+		for _, row := range keyboardRows {
+			if strings.Contains(row, s[i-2:i+1]) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,192 @@
+// Package export writes secrets to a passphrase encrypted archive.
+// Archive is JSON with argon2id parameters and AES-256-GCM sealed list of secrets.
+package export
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	Version = 1
+	KDF     = "argon2id"
+
+	saltSize = 16
+	keySize  = 32
+
+	// bounds of the key derivation parameters accepted from an archive,
+	// so a crafted header can not exhaust memory or stall the import
+	maxTime    = 16
+	maxMemory  = 1024 * 1024
+	maxThreads = 16
+)
+
+var ErrDecrypt = errors.New("wrong passphrase or corrupted archive")
+
+// Secret is an exported secret with its raw content
+type Secret struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Content   []byte    `json:"content"`
+	Tags      []string  `json:"tags,omitempty"`
+}
+
+// Archive is the decrypted export content
+type Archive struct {
+	Account    string    `json:"account"`
+	ExportedAt time.Time `json:"exported_at"`
+	Secrets    []Secret  `json:"secrets"`
+}
+
+// envelope is the stored form of an archive
+type envelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt"`
+	Time       uint32 `json:"time"`
+	Memory     uint32 `json:"memory"`
+	Threads    uint8  `json:"threads"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Params of the key derivation, defaults follow RFC 9106 recommendations for memory constrained environments
+type Params struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+var DefaultParams = Params{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+// validate parameters against the bounds, argon2 needs at least 8 KiB of memory per thread
+func (p Params) validate() error {
+	if p.Time < 1 || p.Time > maxTime {
+		return fmt.Errorf("argon2 time %d is out of 1..%d", p.Time, maxTime)
+	}
+	if p.Threads < 1 || p.Threads > maxThreads {
+		return fmt.Errorf("argon2 threads %d is out of 1..%d", p.Threads, maxThreads)
+	}
+	if p.Memory < 8*uint32(p.Threads) || p.Memory > maxMemory {
+		return fmt.Errorf("argon2 memory %d KiB is out of %d..%d", p.Memory, 8*uint32(p.Threads), maxMemory)
+	}
+	return nil
+}
+
+// Write archive encrypted with the passphrase
+func Write(w io.Writer, a *Archive, passphrase string, p Params) error {
+	if passphrase == "" {
+		return errors.New("empty passphrase")
+	}
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	plain, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("marshal archive: %w", err)
+	}
+
+	env := &envelope{
+		Version: Version,
+		KDF:     KDF,
+		Salt:    make([]byte, saltSize),
+		Time:    p.Time,
+		Memory:  p.Memory,
+		Threads: p.Threads,
+	}
+	if _, err := rand.Read(env.Salt); err != nil {
+		return fmt.Errorf("generate salt: %w", err)
+	}
+
+	aead, err := env.aead(passphrase)
+	if err != nil {
+		return err
+	}
+
+	env.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return fmt.Errorf("generate nonce: %w", err)
+	}
+	env.Ciphertext = aead.Seal(nil, env.Nonce, plain, env.additionalData())
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(env); err != nil {
+		return fmt.Errorf("write archive: %w", err)
+	}
+
+	return nil
+}
+
+// Read archive encrypted with the passphrase
+func Read(r io.Reader, passphrase string) (*Archive, error) {
+	env := &envelope{}
+	if err := json.NewDecoder(r).Decode(env); err != nil {
+		return nil, fmt.Errorf("read archive: %w", err)
+	}
+	if env.Version != Version || env.KDF != KDF {
+		return nil, fmt.Errorf("unsupported archive version %d with %q", env.Version, env.KDF)
+	}
+	p := Params{Time: env.Time, Memory: env.Memory, Threads: env.Threads}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid archive: %w", err)
+	}
+	if len(env.Salt) != saltSize {
+		return nil, errors.New("invalid archive: wrong salt size")
+	}
+
+	aead, err := env.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	plain, err := aead.Open(nil, env.Nonce, env.Ciphertext, env.additionalData())
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	a := &Archive{}
+	if err := json.Unmarshal(plain, a); err != nil {
+		return nil, fmt.Errorf("unmarshal archive: %w", err)
+	}
+
+	return a, nil
+}
+
+func (e *envelope) aead(passphrase string) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), e.Salt, e.Time, e.Memory, e.Threads, keySize)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("gcm: %w", err)
+	}
+
+	return aead, nil
+}
+
+// additionalData binds the ciphertext to the key derivation parameters
+func (e *envelope) additionalData() []byte {
+	return []byte(fmt.Sprintf("%d:%s:%d:%d:%d", e.Version, e.KDF, e.Time, e.Memory, e.Threads))
+}
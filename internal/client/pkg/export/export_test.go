@@ -0,0 +1,74 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testParams keep tests fast
+var testParams = Params{Time: 1, Memory: 1024, Threads: 1}
+
+func TestWriteRead(t *testing.T) {
+	a := &Archive{
+		Account:    "user@example.org",
+		ExportedAt: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		Secrets: []Secret{
+			{Name: "mail", Type: "lp", CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Content: []byte(`{"login":"me"}`)},
+			{Name: "key", Type: "raw", Content: []byte{0, 1, 2}},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, a, "passphrase", testParams))
+	assert.NotContains(t, buf.String(), "mail")
+
+	got, err := Read(bytes.NewReader(buf.Bytes()), "passphrase")
+	require.NoError(t, err)
+	assert.Equal(t, a, got)
+
+	_, err = Read(bytes.NewReader(buf.Bytes()), "wrong")
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	// tampered parameters are detected
+	env := &envelope{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), env))
+	env.Time = 2
+	tampered, err := json.Marshal(env)
+	require.NoError(t, err)
+	_, err = Read(bytes.NewReader(tampered), "passphrase")
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestRead_Params(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, &Archive{}, "passphrase", testParams))
+
+	for name, tamper := range map[string]func(*envelope){
+		"no threads":   func(e *envelope) { e.Threads = 0 },
+		"many threads": func(e *envelope) { e.Threads = 255 },
+		"no time":      func(e *envelope) { e.Time = 0 },
+		"long time":    func(e *envelope) { e.Time = 1 << 20 },
+		"huge memory":  func(e *envelope) { e.Memory = 1<<32 - 1 },
+		"tiny memory":  func(e *envelope) { e.Memory = 1 },
+		"short salt":   func(e *envelope) { e.Salt = e.Salt[:4] },
+	} {
+		env := &envelope{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), env))
+		tamper(env)
+		data, err := json.Marshal(env)
+		require.NoError(t, err)
+
+		_, err = Read(bytes.NewReader(data), "passphrase")
+		assert.ErrorContains(t, err, "invalid archive", name)
+	}
+}
+
+func TestWrite_Invalid(t *testing.T) {
+	assert.Error(t, Write(&bytes.Buffer{}, &Archive{}, "", testParams))
+	assert.Error(t, Write(&bytes.Buffer{}, &Archive{}, "passphrase", Params{Time: 1, Memory: 1024}))
+}
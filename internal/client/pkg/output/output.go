@@ -0,0 +1,358 @@
+// Package output renders command results and errors in a machine-readable format.
+//
+// Commands exit with a code derived from the gRPC status of the error:
+//
+//	0       success
+//	1       local error (bad flags, files, etc.)
+//	10 + N  server error with gRPC status code N, e.g. 15 for NotFound, 26 for Unauthenticated
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	FormatTable Format = "table"
+	FormatJSON  Format = "json"
+	FormatYAML  Format = "yaml"
+	FormatEnv   Format = "env"
+)
+
+const (
+	ExitOK        = 0
+	ExitLocal     = 1
+	exitGRPCShift = 10
+)
+
+var envNameRegexp = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+// Tabler is implemented by values with own table representation
+type Tabler interface {
+	Table() (header []string, rows [][]string)
+}
+
+// EnvVar is a single variable of env output
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// Enver is implemented by values with own env representation
+type Enver interface {
+	Env() []EnvVar
+}
+
+type Printer struct {
+	format Format
+	out    io.Writer
+	errOut io.Writer
+}
+
+// New printer for a named format
+func New(format string) (*Printer, error) {
+	f := Format(format)
+	switch f {
+	case FormatTable, FormatJSON, FormatYAML, FormatEnv:
+	default:
+		return nil, fmt.Errorf("unknown output format %q, use one of: table, json, yaml, env", format)
+	}
+
+	return &Printer{
+		format: f,
+		out:    os.Stdout,
+		errOut: os.Stderr,
+	}, nil
+}
+
+// Format of the printer
+func (p *Printer) Format() Format {
+	return p.format
+}
+
+// Structured reports if output is meant to be parsed by programs
+func (p *Printer) Structured() bool {
+	return p.format != FormatTable
+}
+
+// Print a value in the printer format
+func (p *Printer) Print(v interface{}) error {
+	switch p.format {
+	case FormatJSON:
+		enc := json.NewEncoder(p.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case FormatYAML:
+		enc := yaml.NewEncoder(p.out)
+		defer func() {
+			_ = enc.Close()
+		}()
+		return enc.Encode(v)
+	case FormatEnv:
+		return p.printEnv(v)
+	default:
+		return p.printTable(v)
+	}
+}
+
+func (p *Printer) printTable(v interface{}) error {
+	t, ok := v.(Tabler)
+	if !ok {
+		enc := yaml.NewEncoder(p.out)
+		defer func() {
+			_ = enc.Close()
+		}()
+		return enc.Encode(v)
+	}
+
+	header, rows := t.Table()
+	w := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	if len(header) > 0 {
+		_, _ = fmt.Fprintln(w, strings.Join(header, "\t"))
+	}
+	for _, r := range rows {
+		_, _ = fmt.Fprintln(w, strings.Join(r, "\t"))
+	}
+
+	return w.Flush()
+}
+
+func (p *Printer) printEnv(v interface{}) error {
+	var vars []EnvVar
+	if e, ok := v.(Enver); ok {
+		vars = e.Env()
+	} else {
+		var err error
+		if vars, err = flatten(v); err != nil {
+			return err
+		}
+	}
+
+	for _, ev := range vars {
+		if _, err := fmt.Fprintf(p.out, "%s=%s\n", EnvName(ev.Name), quote(ev.Value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ErrorView is the structured representation of a failed command
+type ErrorView struct {
+	Error ErrorDetails `json:"error" yaml:"error"`
+}
+
+type ErrorDetails struct {
+	Code     string `json:"code" yaml:"code"`
+	ExitCode int    `json:"exit_code" yaml:"exit_code"`
+	Message  string `json:"message" yaml:"message"`
+	// Reason is a constant cause of the error set by the server, scripts may match on it
+	Reason   string            `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	// Violations of request fields or quotas
+	Violations []Violation `json:"violations,omitempty" yaml:"violations,omitempty"`
+	// RetryAfter in seconds when the command may succeed if repeated
+	RetryAfter int64 `json:"retry_after,omitempty" yaml:"retry_after,omitempty"`
+}
+
+// Violation is a request field or a quota subject the server rejected
+type Violation struct {
+	Subject     string `json:"subject" yaml:"subject"`
+	Description string `json:"description" yaml:"description"`
+}
+
+// Error prints err to the error output, msg overrides the error message if not empty
+func (p *Printer) Error(err error, msg string) {
+	if msg == "" {
+		msg = status.Convert(err).Message()
+	}
+
+	v := ErrorView{
+		Error: ErrorDetails{
+			Code:     Code(err),
+			ExitCode: ExitCode(err),
+			Message:  msg,
+		},
+	}
+	v.Error.addDetails(err)
+
+	switch p.format {
+	case FormatJSON:
+		_ = json.NewEncoder(p.errOut).Encode(v)
+	case FormatYAML:
+		_ = yaml.NewEncoder(p.errOut).Encode(v)
+	case FormatEnv:
+		for _, ev := range v.Error.Env() {
+			_, _ = fmt.Fprintf(p.errOut, "%s=%s\n", ev.Name, quote(ev.Value))
+		}
+	default:
+		_, _ = fmt.Fprintln(p.errOut, "Error: "+msg)
+		for _, vi := range v.Error.Violations {
+			_, _ = fmt.Fprintf(p.errOut, "  %s: %s\n", vi.Subject, vi.Description)
+		}
+		if v.Error.RetryAfter > 0 {
+			_, _ = fmt.Fprintf(p.errOut, "Retry in %s\n", time.Duration(v.Error.RetryAfter)*time.Second)
+		}
+	}
+}
+
+// Env representation of the error, violations are numbered from zero
+func (d ErrorDetails) Env() []EnvVar {
+	vars := []EnvVar{
+		{Name: "ERROR_CODE", Value: d.Code},
+		{Name: "ERROR_MESSAGE", Value: d.Message},
+	}
+	if d.Reason != "" {
+		vars = append(vars, EnvVar{Name: "ERROR_REASON", Value: d.Reason})
+	}
+	for i, vi := range d.Violations {
+		vars = append(vars,
+			EnvVar{Name: fmt.Sprintf("ERROR_VIOLATION_%d_SUBJECT", i), Value: vi.Subject},
+			EnvVar{Name: fmt.Sprintf("ERROR_VIOLATION_%d_DESCRIPTION", i), Value: vi.Description},
+		)
+	}
+	if d.RetryAfter > 0 {
+		vars = append(vars, EnvVar{Name: "ERROR_RETRY_AFTER", Value: fmt.Sprint(d.RetryAfter)})
+	}
+	return vars
+}
+
+// addDetails of the server error: ErrorInfo, BadRequest, QuotaFailure and RetryInfo
+func (d *ErrorDetails) addDetails(err error) {
+	s, ok := grpcStatus(err)
+	if !ok {
+		return
+	}
+
+	for _, detail := range s.Details() {
+		switch t := detail.(type) {
+		case *errdetails.ErrorInfo:
+			d.Reason = t.GetReason()
+			d.Metadata = t.GetMetadata()
+		case *errdetails.BadRequest:
+			for _, fv := range t.GetFieldViolations() {
+				d.Violations = append(d.Violations, Violation{Subject: fv.GetField(), Description: fv.GetDescription()})
+			}
+		case *errdetails.QuotaFailure:
+			for _, qv := range t.GetViolations() {
+				d.Violations = append(d.Violations, Violation{Subject: qv.GetSubject(), Description: qv.GetDescription()})
+			}
+		case *errdetails.RetryInfo:
+			// rounded up, so retrying right after the delay does not fail again
+			delay := t.GetRetryDelay().AsDuration()
+			d.RetryAfter = int64((delay + time.Second - 1) / time.Second)
+		}
+	}
+}
+
+// Reason from ErrorInfo details of a server error, empty if not set
+func Reason(err error) string {
+	var d ErrorDetails
+	d.addDetails(err)
+	return d.Reason
+}
+
+// grpcStatus of the error or of any error it wraps
+func grpcStatus(err error) (*status.Status, bool) {
+	var se interface {
+		GRPCStatus() *status.Status
+	}
+	if errors.As(err, &se) {
+		return se.GRPCStatus(), true
+	}
+	return nil, false
+}
+
+// Code name of the error, "Local" for errors not coming from the server
+func Code(err error) string {
+	if s, ok := grpcStatus(err); ok {
+		return s.Code().String()
+	}
+	return "Local"
+}
+
+// ExitCode of the process for a given error
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	if s, ok := grpcStatus(err); ok && s.Code() != codes.OK {
+		return exitGRPCShift + int(s.Code())
+	}
+	return ExitLocal
+}
+
+// EnvName converts arbitrary name to a valid environment variable name
+func EnvName(name string) string {
+	return strings.Trim(envNameRegexp.ReplaceAllString(strings.ToUpper(name), "_"), "_")
+}
+
+// quote value for POSIX shells
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// flatten arbitrary value to env variables using its json representation
+func flatten(v interface{}) ([]EnvVar, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+
+	var res []EnvVar
+	var walk func(prefix string, n interface{})
+	walk = func(prefix string, n interface{}) {
+		switch t := n.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(t))
+			for k := range t {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(join(prefix, k), t[k])
+			}
+		case []interface{}:
+			for i, e := range t {
+				walk(join(prefix, fmt.Sprint(i)), e)
+			}
+		case nil:
+			res = append(res, EnvVar{Name: prefix})
+		default:
+			res = append(res, EnvVar{Name: prefix, Value: fmt.Sprint(t)})
+		}
+	}
+	walk("", tree)
+
+	return res, nil
+}
+
+func join(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
@@ -0,0 +1,109 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type testView struct {
+	Name  string `json:"name" yaml:"name"`
+	Count int    `json:"count" yaml:"count"`
+}
+
+func (v testView) Table() ([]string, [][]string) {
+	return []string{"NAME", "COUNT"}, [][]string{{v.Name, "1000000"}}
+}
+
+func TestPrinter_Print(t *testing.T) {
+	v := testView{Name: "it's", Count: 1000000}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"json", "{\n  \"name\": \"it's\",\n  \"count\": 1000000\n}\n"},
+		{"yaml", "name: it's\ncount: 1000000\n"},
+		{"env", "COUNT='1000000'\nNAME='it'\\''s'\n"},
+		{"table", "NAME  COUNT\nit's  1000000\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			p, err := New(tt.format)
+			assert.NoError(t, err)
+
+			var buf bytes.Buffer
+			p.out = &buf
+			assert.NoError(t, p.Print(v))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+
+	_, err := New("xml")
+	assert.Error(t, err)
+}
+
+func TestPrinter_Error(t *testing.T) {
+	p, err := New("json")
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	p.errOut = &buf
+	p.Error(status.Error(codes.NotFound, "not found"), "Secret not found")
+	assert.JSONEq(t, `{"error":{"code":"NotFound","exit_code":15,"message":"Secret not found"}}`, buf.String())
+}
+
+func TestPrinter_ErrorDetails(t *testing.T) {
+	st, err := status.New(codes.ResourceExhausted, "too many attempts").WithDetails(
+		&errdetails.ErrorInfo{Reason: "TOO_MANY_ATTEMPTS", Domain: "gophkeeper"},
+		&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{Subject: "user:1", Description: "limit reached"}}},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(1500 * time.Millisecond)},
+	)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"json", `{"error":{"code":"ResourceExhausted","exit_code":18,"message":"too many attempts","reason":"TOO_MANY_ATTEMPTS",` +
+			`"violations":[{"subject":"user:1","description":"limit reached"}],"retry_after":2}}` + "\n"},
+		{"env", "ERROR_CODE='ResourceExhausted'\nERROR_MESSAGE='too many attempts'\nERROR_REASON='TOO_MANY_ATTEMPTS'\n" +
+			"ERROR_VIOLATION_0_SUBJECT='user:1'\nERROR_VIOLATION_0_DESCRIPTION='limit reached'\nERROR_RETRY_AFTER='2'\n"},
+		{"table", "Error: too many attempts\n  user:1: limit reached\nRetry in 2s\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			p, err := New(tt.format)
+			assert.NoError(t, err)
+
+			var buf bytes.Buffer
+			p.errOut = &buf
+			p.Error(st.Err(), "")
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+
+	assert.Equal(t, "TOO_MANY_ATTEMPTS", Reason(fmt.Errorf("wrapped: %w", st.Err())))
+	assert.Empty(t, Reason(errors.New("local")))
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, ExitOK, ExitCode(nil))
+	assert.Equal(t, ExitLocal, ExitCode(errors.New("local")))
+	assert.Equal(t, 15, ExitCode(status.Error(codes.NotFound, "")))
+	assert.Equal(t, 26, ExitCode(status.Error(codes.Unauthenticated, "")))
+	assert.Equal(t, 15, ExitCode(fmt.Errorf("wrapped: %w", status.Error(codes.NotFound, ""))))
+}
+
+func TestEnvName(t *testing.T) {
+	assert.Equal(t, "DB_PASSWORD", EnvName("db-password"))
+	assert.Equal(t, "PROD_DB", EnvName("/prod/db/"))
+}
@@ -0,0 +1,86 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gophkeeper/internal/client/pkg/resolver"
+	"gophkeeper/internal/client/pkg/secret"
+)
+
+// FileMode of rendered files, they contain secrets
+const FileMode = 0600
+
+// Funcs available in templates:
+//
+//	{{ secret "prod/db" "password" }}  field of a secret, field may be omitted for single field secrets
+//	{{ secretRaw "tls-key" }}          content of a raw secret
+func Funcs(ctx context.Context, r *resolver.Resolver) template.FuncMap {
+	return template.FuncMap{
+		"secret": func(name string, field ...string) (string, error) {
+			if len(field) > 1 {
+				return "", fmt.Errorf("secret %q: expected at most one field, got %d", name, len(field))
+			}
+			return r.Resolve(ctx, secret.Ref{Name: name, Field: strings.Join(field, "")})
+		},
+		"secretRaw": func(name string) (string, error) {
+			s, err := r.Secret(ctx, name)
+			if err != nil {
+				return "", err
+			}
+			raw, ok := s.(*secret.Raw)
+			if !ok {
+				return "", fmt.Errorf("secret %q: type %s is not raw, use secret with one of fields: %s",
+					name, s.Type(), secret.FieldNames(s))
+			}
+			return string(*raw), nil
+		},
+	}
+}
+
+// Render template text to w resolving secret references
+func Render(ctx context.Context, r *resolver.Resolver, w io.Writer, name, text string) error {
+	t, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(Funcs(ctx, r)).
+		Parse(text)
+	if err != nil {
+		return fmt.Errorf("parse: %w", err)
+	}
+
+	return t.Execute(w, nil)
+}
+
+// WriteFile atomically with FileMode permissions, partial output never reaches the destination
+func WriteFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temp: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+
+	if err := f.Chmod(FileMode); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("chmod: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("rename: %w", err)
+	}
+
+	return nil
+}
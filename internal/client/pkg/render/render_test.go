@@ -0,0 +1,109 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/client/pkg/resolver"
+	"gophkeeper/internal/client/pkg/secret"
+)
+
+type fakeKeeper struct {
+	pb.KeeperClient
+
+	reads int
+}
+
+func (k *fakeKeeper) ReadSecret(ctx context.Context, in *pb.ReadSecretRequest, opts ...grpc.CallOption) (*pb.ReadSecretResponse, error) {
+	k.reads++
+	switch in.GetName() {
+	case "prod/db":
+		return &pb.ReadSecretResponse{
+			Name:    "prod/db",
+			Type:    secret.TypeLoginPassword,
+			Content: []byte(`{"login":"user","password":"pass"}`),
+		}, nil
+	case "tls-key":
+		return &pb.ReadSecretResponse{
+			Name:    "tls-key",
+			Type:    secret.TypeRaw,
+			Content: []byte(`"S0VZ"`),
+		}, nil
+	}
+	return nil, status.Error(codes.NotFound, "not found")
+}
+
+func TestRender(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "fields and raw",
+			text: `{{ secret "prod/db" "login" }}:{{ secret "prod/db" "password" }} {{ secretRaw "tls-key" }} {{ secret "tls-key" }}`,
+			want: "user:pass KEY KEY",
+		},
+		{
+			name:    "missing secret",
+			text:    `{{ secret "missing" "password" }}`,
+			wantErr: true,
+		},
+		{
+			name:    "missing field",
+			text:    `{{ secret "prod/db" "cvv" }}`,
+			wantErr: true,
+		},
+		{
+			name:    "raw of structured secret",
+			text:    `{{ secretRaw "prod/db" }}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Render(ctx, resolver.New(&fakeKeeper{}), &buf, "test", tt.text)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestRender_FetchesOnce(t *testing.T) {
+	k := &fakeKeeper{}
+	err := Render(context.Background(), resolver.New(k), &bytes.Buffer{}, "test",
+		`{{ secret "prod/db" "login" }}{{ secret "prod/db" "password" }}`)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, k.reads)
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.conf")
+	assert.NoError(t, os.WriteFile(path, []byte("old"), 0644))
+
+	assert.NoError(t, WriteFile(path, []byte("new")))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+
+	fi, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(FileMode), fi.Mode().Perm())
+}
@@ -0,0 +1,59 @@
+package resolver
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gophkeeper/internal/client/pkg/secret"
+)
+
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EnvMapping binds an environment variable to a secret reference
+type EnvMapping struct {
+	Name string
+	Ref  secret.Ref
+}
+
+// ParseEnvMapping from a "VAR=name[:field]" string
+func ParseEnvMapping(s string) (EnvMapping, error) {
+	name, ref, ok := strings.Cut(s, "=")
+	name, ref = strings.TrimSpace(name), strings.TrimSpace(ref)
+	if !ok || !envNameRegexp.MatchString(name) {
+		return EnvMapping{}, fmt.Errorf("invalid env mapping %q, expected VAR=name[:field]", s)
+	}
+
+	r, err := secret.ParseRef(ref)
+	if err != nil {
+		return EnvMapping{}, err
+	}
+
+	return EnvMapping{Name: name, Ref: r}, nil
+}
+
+// ReadEnvMappings of a file with one VAR=name[:field] per line, empty lines and # comments are skipped
+func ReadEnvMappings(r io.Reader) ([]EnvMapping, error) {
+	var res []EnvMapping
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		m, err := ParseEnvMapping(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		res = append(res, m)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	return res, nil
+}
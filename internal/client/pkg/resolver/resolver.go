@@ -0,0 +1,72 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/client/pkg/secret"
+)
+
+// Error of a secret reading, keeps server status so callers can tell NotFound from others
+type Error struct {
+	status *status.Status
+}
+
+func (e *Error) Error() string {
+	return e.status.Message()
+}
+
+func (e *Error) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// Resolver reads referenced secrets from the server, each secret is fetched once
+type Resolver struct {
+	keeper  pb.KeeperClient
+	secrets map[string]secret.Secret
+}
+
+func New(cl pb.KeeperClient) *Resolver {
+	return &Resolver{
+		keeper:  cl,
+		secrets: make(map[string]secret.Secret),
+	}
+}
+
+// Secret by name, server errors are returned as *Error
+func (r *Resolver) Secret(ctx context.Context, name string) (secret.Secret, error) {
+	if s, ok := r.secrets[name]; ok {
+		return s, nil
+	}
+
+	resp, err := r.keeper.ReadSecret(ctx, &pb.ReadSecretRequest{Name: name})
+	if err != nil {
+		st := status.Convert(err)
+		return nil, &Error{status: status.Newf(st.Code(), "secret %q: %s", name, st.Message())}
+	}
+
+	s, err := secret.Read(resp.GetType(), resp.GetContent())
+	if err != nil {
+		return nil, fmt.Errorf("secret %q: decode: %w", name, err)
+	}
+
+	r.secrets[name] = s
+	return s, nil
+}
+
+// Resolve referenced value
+func (r *Resolver) Resolve(ctx context.Context, ref secret.Ref) (string, error) {
+	s, err := r.Secret(ctx, ref.Name)
+	if err != nil {
+		return "", err
+	}
+
+	v, err := secret.Value(s, ref.Field)
+	if err != nil {
+		return "", fmt.Errorf("secret %q: %w", ref.Name, err)
+	}
+
+	return v, nil
+}
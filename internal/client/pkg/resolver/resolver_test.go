@@ -0,0 +1,74 @@
+package resolver
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/client/pkg/secret"
+)
+
+type fakeKeeper struct {
+	pb.KeeperClient
+
+	reads int
+}
+
+func (k *fakeKeeper) ReadSecret(ctx context.Context, in *pb.ReadSecretRequest, opts ...grpc.CallOption) (*pb.ReadSecretResponse, error) {
+	k.reads++
+	if in.GetName() != "prod/db" {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	return &pb.ReadSecretResponse{
+		Name:    "prod/db",
+		Type:    secret.TypeLoginPassword,
+		Content: []byte(`{"login":"user","password":"pass"}`),
+	}, nil
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	ctx := context.Background()
+	k := &fakeKeeper{}
+	r := New(k)
+
+	v, err := r.Resolve(ctx, secret.Ref{Name: "prod/db", Field: "login"})
+	assert.NoError(t, err)
+	assert.Equal(t, "user", v)
+
+	v, err = r.Resolve(ctx, secret.Ref{Name: "prod/db", Field: "password"})
+	assert.NoError(t, err)
+	assert.Equal(t, "pass", v)
+	assert.Equal(t, 1, k.reads)
+
+	_, err = r.Resolve(ctx, secret.Ref{Name: "prod/db", Field: "cvv"})
+	assert.Error(t, err)
+
+	_, err = r.Resolve(ctx, secret.Ref{Name: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestReadEnvMappings(t *testing.T) {
+	mm, err := ReadEnvMappings(strings.NewReader(`
+# database
+DB_USER = prod/db:login
+DB_PASS=prod/db:password
+
+TLS_KEY=tls-key
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []EnvMapping{
+		{Name: "DB_USER", Ref: secret.Ref{Name: "prod/db", Field: "login"}},
+		{Name: "DB_PASS", Ref: secret.Ref{Name: "prod/db", Field: "password"}},
+		{Name: "TLS_KEY", Ref: secret.Ref{Name: "tls-key"}},
+	}, mm)
+
+	_, err = ReadEnvMappings(strings.NewReader("1DB=prod/db"))
+	assert.Error(t, err)
+	_, err = ReadEnvMappings(strings.NewReader("DB_PASS"))
+	assert.Error(t, err)
+}
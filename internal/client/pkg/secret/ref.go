@@ -0,0 +1,65 @@
+package secret
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RefSeparator splits secret name and field name in a reference
+const RefSeparator = ":"
+
+// Ref references a whole secret or a single field of it as "name[:field]"
+type Ref struct {
+	Name  string
+	Field string
+}
+
+// ParseRef from a "name[:field]" string
+func ParseRef(s string) (Ref, error) {
+	name, field, _ := strings.Cut(s, RefSeparator)
+	if name == "" {
+		return Ref{}, fmt.Errorf("invalid secret reference %q: empty name", s)
+	}
+	if strings.Contains(field, RefSeparator) {
+		return Ref{}, fmt.Errorf("invalid secret reference %q: too many separators", s)
+	}
+
+	return Ref{Name: name, Field: field}, nil
+}
+
+func (r Ref) String() string {
+	if r.Field == "" {
+		return r.Name
+	}
+	return r.Name + RefSeparator + r.Field
+}
+
+// Value of a named field, empty name is allowed for secrets having a single field only
+func Value(s Secret, field string) (string, error) {
+	ff := s.Fields()
+
+	if field == "" {
+		if len(ff) == 1 {
+			return ff[0].Value, nil
+		}
+		return "", fmt.Errorf("secret of type %s has several fields, specify one of: %s", s.Type(), FieldNames(s))
+	}
+
+	for _, f := range ff {
+		if f.Name == field {
+			return f.Value, nil
+		}
+	}
+
+	return "", fmt.Errorf("secret of type %s has no field %q, available: %s", s.Type(), field, FieldNames(s))
+}
+
+// FieldNames of a secret joined with comma
+func FieldNames(s Secret) string {
+	ff := s.Fields()
+	names := make([]string, 0, len(ff))
+	for _, f := range ff {
+		names = append(names, f.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,51 @@
+package secret
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Ref
+		wantErr bool
+	}{
+		{in: "prod/db", want: Ref{Name: "prod/db"}},
+		{in: "prod/db:password", want: Ref{Name: "prod/db", Field: "password"}},
+		{in: ":password", wantErr: true},
+		{in: "prod/db:pass:word", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRef(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.in, got.String())
+		})
+	}
+}
+
+func TestValue(t *testing.T) {
+	lp := &LoginPassword{Login: "user", Password: "pass"}
+	raw := Raw("content")
+
+	v, err := Value(lp, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "pass", v)
+
+	_, err = Value(lp, "")
+	assert.Error(t, err)
+
+	_, err = Value(lp, "cvv")
+	assert.Error(t, err)
+
+	v, err = Value(&raw, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "content", v)
+}
@@ -26,6 +26,14 @@ type Secret interface {
 	Encode() ([]byte, error)
 	Decode([]byte) error
 	Print() string
+	Fields() []Field
+}
+
+// Field is a single named value of a secret
+type Field struct {
+	Name      string
+	Value     string
+	Sensitive bool
 }
 
 func Read(t string, data []byte) (Secret, error) {
@@ -83,6 +91,15 @@ Holder:       {{.Holder}}
 	return strings.TrimSpace(buf.String()) + "\n"
 }
 
+func (s *Card) Fields() []Field {
+	return []Field{
+		{Name: "number", Value: s.Number, Sensitive: true},
+		{Name: "expires", Value: s.Expires},
+		{Name: "cvv", Value: s.CVV, Sensitive: true},
+		{Name: "holder", Value: s.Holder},
+	}
+}
+
 type LoginPassword struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
@@ -113,6 +130,13 @@ Password:     {{.Password}}
 	return strings.TrimSpace(buf.String()) + "\n"
 }
 
+func (s *LoginPassword) Fields() []Field {
+	return []Field{
+		{Name: "login", Value: s.Login},
+		{Name: "password", Value: s.Password, Sensitive: true},
+	}
+}
+
 type Raw []byte
 
 func (s *Raw) Type() string {
@@ -130,3 +154,26 @@ func (s *Raw) Decode(bytes []byte) error {
 func (s *Raw) Print() string {
 	return fmt.Sprint(string([]byte(*s)))
 }
+
+func (s *Raw) Fields() []Field {
+	return []Field{
+		{Name: "content", Value: string(*s), Sensitive: true},
+	}
+}
+
+// HasTags reports if tags of a secret include all wanted ones
+func HasTags(tags []string, want ...string) bool {
+	for _, w := range want {
+		found := false
+		for _, t := range tags {
+			if t == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
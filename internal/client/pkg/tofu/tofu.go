@@ -0,0 +1,189 @@
+// Package tofu pins server certificates on first use, so self-signed servers are trusted
+// without a CA file while a replaced certificate is still detected.
+package tofu
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"gophkeeper/pkg/tlscert"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MismatchError is returned when the server presents a certificate other than the pinned one
+type MismatchError struct {
+	Server    string
+	Pinned    string
+	Presented string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("certificate of %s changed: pinned %s, presented %s", e.Server, e.Pinned, e.Presented)
+}
+
+// UntrustedError is returned for a certificate neither trusted by the roots nor pinned, unless pinning is confirmed
+type UntrustedError struct {
+	Server    string
+	Presented string
+}
+
+func (e *UntrustedError) Error() string {
+	return fmt.Sprintf("certificate %s of %s is not trusted", e.Presented, e.Server)
+}
+
+// Store keeps pins in a file, a line per server: "<address> <fingerprint>"
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewStore(path string) *Store {
+	return &Store{
+		path: path,
+	}
+}
+
+// Get fingerprint pinned for the server, empty if not pinned
+func (s *Store) Get(server string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pins, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	return pins[server], nil
+}
+
+// Put pin of the server replacing the previous one
+func (s *Store) Put(server, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pins, err := s.read()
+	if err != nil {
+		return err
+	}
+	pins[server] = fingerprint
+	return s.write(pins)
+}
+
+// Delete pin of the server, reports if it was pinned
+func (s *Store) Delete(server string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pins, err := s.read()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := pins[server]; !ok {
+		return false, nil
+	}
+	delete(pins, server)
+	return true, s.write(pins)
+}
+
+// VerifyConnection builds tls.Config callback for the server address: certificates valid for the roots pass,
+// others have to match the pin. Unpinned ones are pinned once confirm accepts their fingerprint,
+// nil confirm rejects them with UntrustedError. Nil roots are the system ones.
+func (s *Store) VerifyConnection(server string, roots *x509.CertPool, confirm func(fingerprint string) error) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("no server certificate")
+		}
+		leaf := cs.PeerCertificates[0]
+
+		opts := x509.VerifyOptions{
+			Roots:         roots,
+			DNSName:       cs.ServerName,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, c := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(c)
+		}
+		if _, err := leaf.Verify(opts); err == nil {
+			return nil
+		}
+
+		fp := tlscert.Fingerprint(leaf.Raw)
+		pinned, err := s.Get(server)
+		if err != nil {
+			return fmt.Errorf("read pins: %w", err)
+		}
+		switch pinned {
+		case fp:
+			return nil
+		case "":
+			if confirm == nil {
+				return &UntrustedError{Server: server, Presented: fp}
+			}
+			if err := confirm(fp); err != nil {
+				return err
+			}
+			if err := s.Put(server, fp); err != nil {
+				return fmt.Errorf("pin certificate: %w", err)
+			}
+			return nil
+		default:
+			return &MismatchError{Server: server, Pinned: pinned, Presented: fp}
+		}
+	}
+}
+
+// Config of TLS connections to the server verified by VerifyConnection
+func (s *Store) Config(server string, roots *x509.CertPool, confirm func(fingerprint string) error) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// the chain is verified by VerifyConnection, falling back to the pin
+		InsecureSkipVerify: true,
+		VerifyConnection:   s.VerifyConnection(server, roots, confirm),
+	}
+}
+
+func (s *Store) read() (map[string]string, error) {
+	pins := make(map[string]string)
+
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return pins, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		pins[fields[0]] = fields[1]
+	}
+
+	return pins, sc.Err()
+}
+
+func (s *Store) write(pins map[string]string) error {
+	servers := make([]string, 0, len(pins))
+	for k := range pins {
+		servers = append(servers, k)
+	}
+	sort.Strings(servers)
+
+	var b strings.Builder
+	for _, k := range servers {
+		b.WriteString(k + " " + pins[k] + "\n")
+	}
+
+	return os.WriteFile(s.path, []byte(b.String()), 0600)
+}
@@ -0,0 +1,83 @@
+package tofu
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gophkeeper/pkg/tlscert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func selfSigned(t *testing.T) *x509.Certificate {
+	certPEM, _, err := tlscert.SelfSigned([]string{"localhost"}, time.Hour)
+	require.NoError(t, err)
+	b, _ := pem.Decode(certPEM)
+	c, err := x509.ParseCertificate(b.Bytes)
+	require.NoError(t, err)
+	return c
+}
+
+func TestStore_VerifyConnection(t *testing.T) {
+	s := NewStore(filepath.Join(t.TempDir(), "known_servers"))
+	first, second := selfSigned(t), selfSigned(t)
+	state := func(c *x509.Certificate) tls.ConnectionState {
+		return tls.ConnectionState{ServerName: "localhost", PeerCertificates: []*x509.Certificate{c}}
+	}
+
+	// unconfirmed certificates are not pinned
+	var ue *UntrustedError
+	require.True(t, errors.As(s.VerifyConnection("localhost:50051", x509.NewCertPool(), nil)(state(first)), &ue))
+	assert.Equal(t, tlscert.Fingerprint(first.Raw), ue.Presented)
+	denied := errors.New("denied")
+	assert.ErrorIs(t, s.VerifyConnection("localhost:50051", x509.NewCertPool(), func(string) error {
+		return denied
+	})(state(first)), denied)
+	fp, err := s.Get("localhost:50051")
+	require.NoError(t, err)
+	assert.Empty(t, fp)
+
+	var pinned string
+	verify := s.VerifyConnection("localhost:50051", x509.NewCertPool(), func(fp string) error {
+		pinned = fp
+		return nil
+	})
+
+	// first use pins the confirmed certificate
+	require.NoError(t, verify(state(first)))
+	assert.Equal(t, tlscert.Fingerprint(first.Raw), pinned)
+	fp, err = s.Get("localhost:50051")
+	require.NoError(t, err)
+	assert.Equal(t, pinned, fp)
+
+	pinned = ""
+	assert.NoError(t, verify(state(first)))
+	assert.Empty(t, pinned)
+
+	// replaced certificate is rejected
+	err = verify(state(second))
+	var me *MismatchError
+	require.True(t, errors.As(err, &me))
+	assert.Equal(t, tlscert.Fingerprint(first.Raw), me.Pinned)
+	assert.Equal(t, tlscert.Fingerprint(second.Raw), me.Presented)
+
+	// unless trusted by the roots
+	roots := x509.NewCertPool()
+	roots.AddCert(second)
+	assert.NoError(t, s.VerifyConnection("localhost:50051", roots, nil)(state(second)))
+
+	ok, err := s.Delete("localhost:50051")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, verify(state(second)))
+	assert.Equal(t, tlscert.Fingerprint(second.Raw), pinned)
+
+	// pins are per server
+	fp, err = s.Get("example.org:50051")
+	require.NoError(t, err)
+	assert.Empty(t, fp)
+}
@@ -0,0 +1,118 @@
+package tui
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/client/pkg/secret"
+)
+
+const (
+	typePrefix = "type:"
+	tagPrefix  = "tag:"
+)
+
+// query parsed from the search input, e.g. "type:lp tag:ci prod db"
+type query struct {
+	pattern string
+	typ     string
+	// tags the secret has to have all of
+	tags []string
+}
+
+func parseQuery(s string) query {
+	var q query
+	var words []string
+
+	for _, w := range strings.Fields(s) {
+		if strings.HasPrefix(w, typePrefix) {
+			q.typ = strings.TrimPrefix(w, typePrefix)
+			continue
+		}
+		if strings.HasPrefix(w, tagPrefix) {
+			q.tags = append(q.tags, strings.TrimPrefix(w, tagPrefix))
+			continue
+		}
+		words = append(words, w)
+	}
+	q.pattern = strings.Join(words, " ")
+
+	return q
+}
+
+// filter secrets matching the query, best fuzzy matches go first
+func filter(secrets []*pb.SecretDescription, q query) []*pb.SecretDescription {
+	type match struct {
+		s     *pb.SecretDescription
+		score int
+	}
+
+	var mm []match
+	for _, s := range secrets {
+		if q.typ != "" && s.GetType() != q.typ {
+			continue
+		}
+		if !secret.HasTags(s.GetTags(), q.tags...) {
+			continue
+		}
+		score, ok := fuzzyScore(q.pattern, s.GetName())
+		if !ok {
+			continue
+		}
+		mm = append(mm, match{s: s, score: score})
+	}
+
+	sort.SliceStable(mm, func(i, j int) bool {
+		return mm[i].score > mm[j].score
+	})
+
+	res := make([]*pb.SecretDescription, 0, len(mm))
+	for _, m := range mm {
+		res = append(res, m.s)
+	}
+
+	return res
+}
+
+// fuzzyScore reports if all pattern runes appear in s in the same order ignoring case.
+// Consecutive runes and runes at the start of words give a higher score.
+func fuzzyScore(pattern, s string) (int, bool) {
+	p := []rune(strings.ToLower(strings.ReplaceAll(pattern, " ", "")))
+	if len(p) == 0 {
+		return 0, true
+	}
+
+	score, pi := 0, 0
+	prevMatched := false
+	prev := rune(0)
+	for i, r := range []rune(strings.ToLower(s)) {
+		if pi == len(p) {
+			break
+		}
+		if r != p[pi] {
+			prevMatched = false
+			prev = r
+			continue
+		}
+
+		score++
+		if prevMatched {
+			score += 2
+		}
+		if i == 0 || !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+			score += 3
+		}
+
+		pi++
+		prevMatched = true
+		prev = r
+	}
+
+	if pi < len(p) {
+		return 0, false
+	}
+
+	return score, true
+}
@@ -0,0 +1,56 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	pb "gophkeeper/api/proto"
+)
+
+func TestParseQuery(t *testing.T) {
+	assert.Equal(t, query{pattern: "prod db", typ: "lp"}, parseQuery(" prod type:lp  db "))
+	assert.Equal(t, query{pattern: "db", tags: []string{"ci", "prod"}}, parseQuery("tag:ci db tag:prod"))
+	assert.Equal(t, query{}, parseQuery(""))
+}
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		ok      bool
+	}{
+		{"", "anything", true},
+		{"pdb", "prod/db", true},
+		{"PDB", "prod/db", true},
+		{"prod db", "prod/db", true},
+		{"dbp", "prod/db", false},
+		{"prodx", "prod/db", false},
+	}
+	for _, tt := range tests {
+		_, ok := fuzzyScore(tt.pattern, tt.s)
+		assert.Equal(t, tt.ok, ok, "%q in %q", tt.pattern, tt.s)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	secrets := []*pb.SecretDescription{
+		{Name: "backup/pdb", Type: "raw"},
+		{Name: "prod/db", Type: "lp", Tags: []string{"ci", "prod"}},
+		{Name: "personal/card", Type: "card"},
+	}
+
+	names := func(ss []*pb.SecretDescription) []string {
+		var res []string
+		for _, s := range ss {
+			res = append(res, s.GetName())
+		}
+		return res
+	}
+
+	assert.Equal(t, []string{"backup/pdb", "prod/db", "personal/card"}, names(filter(secrets, query{})))
+	assert.Equal(t, []string{"prod/db", "backup/pdb"}, names(filter(secrets, query{pattern: "pdb"})))
+	assert.Equal(t, []string{"prod/db"}, names(filter(secrets, query{pattern: "pdb", typ: "lp"})))
+	assert.Empty(t, filter(secrets, query{typ: "unknown"}))
+	assert.Equal(t, []string{"prod/db"}, names(filter(secrets, query{tags: []string{"prod", "ci"}})))
+	assert.Empty(t, filter(secrets, query{tags: []string{"prod", "personal"}}))
+}
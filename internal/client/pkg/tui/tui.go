@@ -0,0 +1,563 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/atotto/clipboard"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/client/pkg/secret"
+)
+
+const (
+	pageMain  = "main"
+	pageModal = "modal"
+
+	mask = "********"
+
+	flashDuration = 3 * time.Second
+
+	helpText = "[yellow]/[white] search  [yellow]t[white] type  [yellow]n[white] new  [yellow]e[white] edit  " +
+		"[yellow]d[white] delete  [yellow]r[white] reveal  [yellow]c[white] copy  [yellow]F5[white] reload  [yellow]q[white] quit"
+)
+
+var filterTypes = []string{"", secret.TypeLoginPassword, secret.TypeCard, secret.TypeRaw}
+
+// App is a full-screen terminal interface to the vault
+type App struct {
+	keeper  pb.KeeperClient
+	timeout time.Duration
+
+	app    *tview.Application
+	pages  *tview.Pages
+	search *tview.InputField
+	list   *tview.List
+	detail *tview.TextView
+	status *tview.TextView
+
+	secrets    []*pb.SecretDescription
+	visible    []*pb.SecretDescription
+	typeFilter int
+	current    *entry
+	revealed   bool
+	// loading is the name of the secret being loaded, loadSeq identifies the last load
+	// and cancelLoad stops it once another secret is selected
+	loading    string
+	loadSeq    int
+	cancelLoad context.CancelFunc
+	// flashSeq identifies the last flash message
+	flashSeq int
+}
+
+// entry is a secret loaded from the server
+type entry struct {
+	name   string
+	tags   []string
+	secret secret.Secret
+}
+
+// New constructor
+func New(cl pb.KeeperClient) *App {
+	a := &App{
+		keeper:  cl,
+		timeout: 10 * time.Second,
+		app:     tview.NewApplication(),
+		pages:   tview.NewPages(),
+		search:  tview.NewInputField(),
+		list:    tview.NewList(),
+		detail:  tview.NewTextView(),
+		status:  tview.NewTextView(),
+	}
+
+	a.search.
+		SetLabel("Search: ").
+		SetPlaceholder("fuzzy name, type:lp, tag:ci").
+		SetChangedFunc(func(string) { a.applyFilter() }).
+		SetDoneFunc(func(tcell.Key) { a.app.SetFocus(a.list) })
+
+	a.list.
+		ShowSecondaryText(false).
+		SetChangedFunc(func(i int, _ string, _ string, _ rune) { a.showItem(i) }).
+		SetInputCapture(a.listKeys)
+	a.list.SetBorder(true).SetTitle(" Secrets ")
+
+	a.detail.SetDynamicColors(true).SetWordWrap(true)
+	a.detail.SetBorder(true).SetTitle(" Details ")
+
+	a.status.SetDynamicColors(true).SetText(helpText)
+
+	body := tview.NewFlex().
+		AddItem(a.list, 0, 1, true).
+		AddItem(a.detail, 0, 2, false)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(a.search, 1, 0, false).
+		AddItem(body, 0, 1, true).
+		AddItem(a.status, 1, 0, false)
+
+	a.pages.AddPage(pageMain, layout, true, true)
+	a.app.SetRoot(a.pages, true).SetFocus(a.list)
+
+	return a
+}
+
+// Run the interface until user quits
+func (a *App) Run() error {
+	a.reload()
+	return a.app.Run()
+}
+
+func (a *App) listKeys(ev *tcell.EventKey) *tcell.EventKey {
+	switch ev.Key() {
+	case tcell.KeyF5:
+		a.reload()
+		return nil
+	case tcell.KeyRune:
+	default:
+		return ev
+	}
+
+	switch ev.Rune() {
+	case 'q':
+		a.app.Stop()
+	case '/':
+		a.app.SetFocus(a.search)
+	case 't':
+		a.typeFilter = (a.typeFilter + 1) % len(filterTypes)
+		a.applyFilter()
+	case 'n':
+		a.showCreate()
+	case 'e':
+		a.showEdit()
+	case 'd':
+		a.showDelete()
+	case 'r':
+		a.revealed = !a.revealed
+		a.renderDetail()
+	case 'c':
+		a.showCopy()
+	default:
+		return ev
+	}
+
+	return nil
+}
+
+func (a *App) ctx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), a.timeout)
+}
+
+// reload secrets list from the server
+func (a *App) reload() {
+	ctx, cancel := a.ctx()
+	defer cancel()
+
+	resp, err := a.keeper.ListSecrets(ctx, &pb.ListSecretsRequest{})
+	if err != nil {
+		a.flash(err)
+		return
+	}
+
+	a.secrets = resp.GetSecrets()
+	a.applyFilter()
+}
+
+func (a *App) applyFilter() {
+	q := parseQuery(a.search.GetText())
+	if t := filterTypes[a.typeFilter]; t != "" {
+		q.typ = t
+	}
+	a.visible = filter(a.secrets, q)
+
+	var ff []string
+	if q.typ != "" {
+		ff = append(ff, q.typ)
+	}
+	for _, t := range q.tags {
+		ff = append(ff, "#"+t)
+	}
+	title := " Secrets "
+	if len(ff) > 0 {
+		title = fmt.Sprintf(" Secrets (%s) ", strings.Join(ff, " "))
+	}
+	a.list.SetTitle(title)
+
+	a.list.Clear()
+	for _, s := range a.visible {
+		a.list.AddItem(s.GetName(), s.GetType(), 0, nil)
+	}
+	a.showSelected()
+}
+
+func (a *App) selected() *pb.SecretDescription {
+	return a.item(a.list.GetCurrentItem())
+}
+
+func (a *App) item(i int) *pb.SecretDescription {
+	if i < 0 || i >= len(a.visible) {
+		return nil
+	}
+	return a.visible[i]
+}
+
+// showSelected renders currently selected secret
+func (a *App) showSelected() {
+	a.showItem(a.list.GetCurrentItem())
+}
+
+// showItem renders the secret at the list index, it is loaded in background,
+// so moving through the list does not wait for the server.
+// The list reports changes before its current item is updated, so the index is passed along.
+func (a *App) showItem(i int) {
+	a.revealed = false
+
+	d := a.item(i)
+	if d == nil {
+		a.stopLoad()
+		a.current = nil
+		a.renderDetail()
+		return
+	}
+
+	if a.current != nil && a.current.name == d.GetName() {
+		a.renderDetail()
+		return
+	}
+	if a.current == nil && a.loading == d.GetName() {
+		return
+	}
+
+	a.stopLoad()
+	a.current = nil
+	a.detail.SetText("[gray]Loading...")
+
+	ctx, cancel := a.ctx()
+	a.loading = d.GetName()
+	a.loadSeq++
+	a.cancelLoad = cancel
+	seq := a.loadSeq
+
+	go func(name string) {
+		defer cancel()
+
+		e, err := a.load(ctx, name)
+		a.app.QueueUpdateDraw(func() {
+			// results of loads replaced by a later selection are dropped
+			if a.loadSeq != seq {
+				return
+			}
+			a.loading = ""
+			a.cancelLoad = nil
+			a.current = e
+			a.renderDetail()
+			if err != nil {
+				a.flash(err)
+			}
+		})
+	}(d.GetName())
+}
+
+// stopLoad cancels the pending load if any, called from the event loop only
+func (a *App) stopLoad() {
+	if a.cancelLoad != nil {
+		a.cancelLoad()
+	}
+	a.loading = ""
+	a.loadSeq++
+	a.cancelLoad = nil
+}
+
+// load a secret from the server, safe to call outside the event loop
+func (a *App) load(ctx context.Context, name string) (*entry, error) {
+	resp, err := a.keeper.ReadSecret(ctx, &pb.ReadSecretRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := secret.Read(resp.GetType(), resp.GetContent())
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry{name: resp.GetName(), tags: resp.GetTags(), secret: s}, nil
+}
+
+func (a *App) renderDetail() {
+	if a.current == nil {
+		a.detail.SetText("")
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[yellow]Name:[white]   %s\n", tview.Escape(a.current.name))
+	fmt.Fprintf(&b, "[yellow]Type:[white]   %s\n", a.current.secret.Type())
+	if len(a.current.tags) > 0 {
+		fmt.Fprintf(&b, "[yellow]Tags:[white]   %s\n", tview.Escape(strings.Join(a.current.tags, ", ")))
+	}
+	b.WriteString("\n")
+	for _, f := range a.current.secret.Fields() {
+		v := f.Value
+		if f.Sensitive && !a.revealed {
+			v = mask
+		}
+		fmt.Fprintf(&b, "[yellow]%s:[white]\n%s\n\n", f.Name, tview.Escape(v))
+	}
+
+	a.detail.SetText(b.String()).ScrollToBeginning()
+}
+
+// flash a message in the status line for a few seconds, called from the event loop only.
+// The reset is queued to the event loop as well and skipped if a newer message is shown.
+func (a *App) flash(msg interface{}) {
+	text := fmt.Sprint(msg)
+	if err, ok := msg.(error); ok {
+		text = "[red]" + tview.Escape(status.Convert(err).Message())
+	}
+	a.status.SetText(text)
+
+	a.flashSeq++
+	seq := a.flashSeq
+	time.AfterFunc(flashDuration, func() {
+		a.app.QueueUpdateDraw(func() {
+			if a.flashSeq == seq {
+				a.status.SetText(helpText)
+			}
+		})
+	})
+}
+
+func (a *App) showModal(p tview.Primitive) {
+	a.pages.AddPage(pageModal, p, true, true)
+	a.app.SetFocus(p)
+}
+
+func (a *App) closeModal() {
+	a.pages.RemovePage(pageModal)
+	a.app.SetFocus(a.list)
+}
+
+func (a *App) showDelete() {
+	d := a.selected()
+	if d == nil {
+		return
+	}
+
+	m := tview.NewModal().
+		SetText(fmt.Sprintf("Delete secret %q?", d.GetName())).
+		AddButtons([]string{"Delete", "Cancel"}).
+		SetDoneFunc(func(i int, label string) {
+			a.closeModal()
+			if label != "Delete" {
+				return
+			}
+
+			ctx, cancel := a.ctx()
+			defer cancel()
+
+			if _, err := a.keeper.DeleteSecret(ctx, &pb.DeleteSecretRequest{Name: d.GetName()}); err != nil {
+				a.flash(err)
+				return
+			}
+			a.current = nil
+			a.reload()
+			a.flash("Secret removed")
+		})
+
+	a.showModal(m)
+}
+
+func (a *App) showCopy() {
+	if a.current == nil {
+		return
+	}
+
+	l := tview.NewList().ShowSecondaryText(false)
+	l.SetBorder(true).SetTitle(" Copy field ")
+	for _, f := range a.current.secret.Fields() {
+		f := f
+		l.AddItem(f.Name, "", 0, func() {
+			a.closeModal()
+			if err := clipboard.WriteAll(f.Value); err != nil {
+				a.flash(err)
+				return
+			}
+			a.flash(fmt.Sprintf("Copied %s to clipboard", f.Name))
+		})
+	}
+	l.SetDoneFunc(a.closeModal)
+
+	a.showModal(centered(l, 40, len(a.current.secret.Fields())+2))
+}
+
+func (a *App) showCreate() {
+	m := tview.NewModal().
+		SetText("Choose secret type").
+		AddButtons([]string{"Login/password", "Card", "Raw", "Cancel"}).
+		SetDoneFunc(func(i int, label string) {
+			a.closeModal()
+
+			var s secret.Secret
+			switch label {
+			case "Login/password":
+				s = &secret.LoginPassword{}
+			case "Card":
+				s = &secret.Card{}
+			case "Raw":
+				s = &secret.Raw{}
+			default:
+				return
+			}
+
+			a.showForm(" New secret ", "", nil, s, a.create)
+		})
+
+	a.showModal(m)
+}
+
+// showEdit opens a form for the selected secret, it is updated in place,
+// so a failed save keeps the previous version
+func (a *App) showEdit() {
+	if a.current == nil {
+		return
+	}
+	old := a.current
+
+	a.showForm(" Edit secret ", old.name, old.tags, old.secret, func(name string, tags []string, s secret.Secret) error {
+		data, err := s.Encode()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := a.ctx()
+		defer cancel()
+
+		_, err = a.keeper.UpdateSecret(ctx, &pb.UpdateSecretRequest{
+			Name:    old.name,
+			NewName: name,
+			Type:    s.Type(),
+			Content: data,
+			Tags:    &pb.SecretTags{Tags: tags},
+		})
+
+		return err
+	})
+}
+
+func (a *App) create(name string, tags []string, s secret.Secret) error {
+	data, err := s.Encode()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := a.ctx()
+	defer cancel()
+
+	_, err = a.keeper.CreateSecret(ctx, &pb.CreateSecretRequest{
+		Name:    name,
+		Type:    s.Type(),
+		Content: data,
+		Tags:    tags,
+	})
+
+	return err
+}
+
+func (a *App) showForm(
+	title, name string,
+	tags []string,
+	s secret.Secret,
+	save func(name string, tags []string, s secret.Secret) error,
+) {
+	f := tview.NewForm()
+	f.SetBorder(true).SetTitle(title)
+
+	f.AddInputField("Name", name, 40, nil, nil)
+	f.AddInputField("Tags", strings.Join(tags, ", "), 40, nil, nil)
+	switch v := s.(type) {
+	case *secret.LoginPassword:
+		f.AddInputField("Login", v.Login, 40, nil, nil)
+		f.AddPasswordField("Password", v.Password, 40, '*', nil)
+	case *secret.Card:
+		f.AddInputField("Number", v.Number, 24, nil, nil)
+		f.AddInputField("Expires", v.Expires, 8, nil, nil)
+		f.AddPasswordField("CVV", v.CVV, 5, '*', nil)
+		f.AddInputField("Holder", v.Holder, 40, nil, nil)
+	case *secret.Raw:
+		f.AddTextArea("Content", string(*v), 60, 10, 0, nil)
+	}
+
+	f.AddButton("Save", func() {
+		name := formText(f, "Name")
+		var ns secret.Secret
+		switch s.(type) {
+		case *secret.LoginPassword:
+			ns = &secret.LoginPassword{
+				Login:    formText(f, "Login"),
+				Password: formText(f, "Password"),
+			}
+		case *secret.Card:
+			ns = &secret.Card{
+				Number:  formText(f, "Number"),
+				Expires: formText(f, "Expires"),
+				CVV:     formText(f, "CVV"),
+				Holder:  formText(f, "Holder"),
+			}
+		case *secret.Raw:
+			r := secret.Raw(formText(f, "Content"))
+			ns = &r
+		}
+
+		if err := save(name, parseTags(formText(f, "Tags")), ns); err != nil {
+			a.flash(err)
+			return
+		}
+
+		a.closeModal()
+		a.current = nil
+		a.reload()
+		a.flash("Secret saved")
+	})
+	f.AddButton("Cancel", a.closeModal)
+	f.SetCancelFunc(a.closeModal)
+
+	height := f.GetFormItemCount()*2 + 3
+	if _, ok := s.(*secret.Raw); ok {
+		height += 10
+	}
+
+	a.showModal(centered(f, 70, height))
+}
+
+func formText(f *tview.Form, label string) string {
+	switch v := f.GetFormItemByLabel(label).(type) {
+	case *tview.InputField:
+		return v.GetText()
+	case *tview.TextArea:
+		return v.GetText()
+	}
+	return ""
+}
+
+// parseTags separated by commas or spaces
+func parseTags(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// centered wraps primitive to be shown in the middle of the screen
+func centered(p tview.Primitive, width, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)
+}
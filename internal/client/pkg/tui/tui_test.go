@@ -0,0 +1,95 @@
+package tui
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/client/pkg/secret"
+)
+
+// slowKeeper answers ReadSecret once the name is released
+type slowKeeper struct {
+	pb.KeeperClient
+	release map[string]chan struct{}
+}
+
+func (k *slowKeeper) ListSecrets(context.Context, *pb.ListSecretsRequest, ...grpc.CallOption) (*pb.ListSecretsResponse, error) {
+	return &pb.ListSecretsResponse{Secrets: []*pb.SecretDescription{
+		{Name: "a", Type: secret.TypeRaw},
+		{Name: "b", Type: secret.TypeRaw},
+	}}, nil
+}
+
+func (k *slowKeeper) ReadSecret(ctx context.Context, in *pb.ReadSecretRequest, _ ...grpc.CallOption) (*pb.ReadSecretResponse, error) {
+	select {
+	case <-k.release[in.GetName()]:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	r := secret.Raw(in.GetName())
+	data, err := r.Encode()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ReadSecretResponse{Name: in.GetName(), Type: secret.TypeRaw, Content: data}, nil
+}
+
+// onLoop runs f on the event loop and waits for it
+func onLoop(t *testing.T, a *App, f func()) {
+	t.Helper()
+
+	done := make(chan struct{})
+	a.app.QueueUpdate(func() {
+		f()
+		close(done)
+	})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.FailNow(t, "event loop is blocked")
+	}
+}
+
+func TestApp_ShowSelected(t *testing.T) {
+	k := &slowKeeper{release: map[string]chan struct{}{
+		"a": make(chan struct{}),
+		"b": make(chan struct{}),
+	}}
+
+	screen := tcell.NewSimulationScreen("")
+	require.NoError(t, screen.Init())
+
+	a := New(k)
+	a.app.SetScreen(screen)
+	go func() {
+		_ = a.Run()
+	}()
+	defer a.app.Stop()
+
+	current := func() string {
+		var name string
+		onLoop(t, a, func() {
+			if a.current != nil {
+				name = a.current.name
+			}
+		})
+		return name
+	}
+
+	// the first secret is selected after the reload and loads without blocking the loop
+	assert.Empty(t, current())
+
+	// the pending load of a is dropped once b is selected
+	onLoop(t, a, func() { a.list.SetCurrentItem(1) })
+	close(k.release["a"])
+	assert.Empty(t, current())
+
+	close(k.release["b"])
+	assert.Eventually(t, func() bool { return current() == "b" }, time.Second, 10*time.Millisecond)
+}
@@ -1,23 +1,45 @@
 package app
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"errors"
 	"fmt"
 	_ "github.com/lib/pq"
+	pb "gophkeeper/api/proto"
 	"gophkeeper/internal/server/config"
+	"gophkeeper/internal/server/gateway"
 	"gophkeeper/internal/server/grpcservice"
+	"gophkeeper/internal/server/mailer"
 	"gophkeeper/internal/server/migrate"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/internal/server/secrettype"
 	"gophkeeper/internal/server/storage/postgres"
+	"gophkeeper/internal/server/throttle"
 	"gophkeeper/pkg/grpcserver"
 	"gophkeeper/pkg/logger"
+	"gophkeeper/pkg/tlscert"
 	"gophkeeper/pkg/token"
+	"net"
+	"os"
+	"time"
 )
 
+// keysReloadInterval between checks of the keys dir, rotated keys are used within it
+const keysReloadInterval = 10 * time.Second
+
 type App struct {
 	config config.Config
 	logger logger.Logger
 	stop   chan struct{}
 	server *grpcserver.Server
+	health *grpcservice.Health
+	// keys is nil when tokens are signed with the secret key
+	keys *token.KeyReloader
+	// gateway is nil when disabled
+	gateway *gateway.Server
 }
 
 func New(cfg config.Config) (*App, error) {
@@ -37,7 +59,7 @@ func New(cfg config.Config) (*App, error) {
 		return nil, fmt.Errorf("migrate up: %w", err)
 	}
 
-	tm, err := token.NewJWT(cfg.Security.SecretKey)
+	tm, keys, err := newTokenManager(cfg.Security, l)
 	if err != nil {
 		return nil, fmt.Errorf("token manager: %w", err)
 	}
@@ -47,36 +69,345 @@ func New(cfg config.Config) (*App, error) {
 		return nil, fmt.Errorf("user repository: %w", err)
 	}
 
+	sessions, err := postgres.NewSessionRepository(db)
+	if err != nil {
+		return nil, fmt.Errorf("session repository: %w", err)
+	}
+
+	refreshTokens, err := postgres.NewRefreshTokenRepository(db)
+	if err != nil {
+		return nil, fmt.Errorf("refresh token repository: %w", err)
+	}
+
+	twoFactor, err := postgres.NewTwoFactorRepository(db)
+	if err != nil {
+		return nil, fmt.Errorf("two-factor repository: %w", err)
+	}
+
+	userTokens, err := postgres.NewUserTokenRepository(db)
+	if err != nil {
+		return nil, fmt.Errorf("user token repository: %w", err)
+	}
+
+	apiTokens, err := postgres.NewAPITokenRepository(db)
+	if err != nil {
+		return nil, fmt.Errorf("api token repository: %w", err)
+	}
+
+	serviceAccounts, err := postgres.NewServiceAccountRepository(db)
+	if err != nil {
+		return nil, fmt.Errorf("service account repository: %w", err)
+	}
+
+	certificates, err := postgres.NewClientCertificateRepository(db)
+	if err != nil {
+		return nil, fmt.Errorf("client certificate repository: %w", err)
+	}
+
+	m, err := newMailer(cfg.Mail, l)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: %w", err)
+	}
+
 	secrets, err := postgres.NewSecretRepository(db)
 	if err != nil {
 		return nil, fmt.Errorf("user repository: %w", err)
 	}
 
-	as := grpcservice.NewUser(users, tm)
-	ks := grpcservice.NewKeeper(secrets)
+	userOpts := []grpcservice.UserOption{
+		grpcservice.WithAccessTokenLifetime(cfg.Security.AccessTokenLifetime),
+		grpcservice.WithRefreshTokenLifetime(cfg.Security.RefreshTokenLifetime),
+		grpcservice.WithMailer(m),
+		grpcservice.WithCertificates(certificates),
+		grpcservice.WithSRPSecret(cfg.Security.SecretKey),
+	}
+	if cfg.Security.SRPOnly {
+		userOpts = append(userOpts, grpcservice.WithSRPOnly())
+	}
+	if cfg.Throttle.Enabled {
+		userOpts = append(userOpts, grpcservice.WithThrottle(
+			throttle.New(cfg.Throttle.Email),
+			throttle.New(cfg.Throttle.IP),
+		))
+	}
+
+	as := grpcservice.NewUser(
+		users,
+		sessions,
+		refreshTokens,
+		twoFactor,
+		userTokens,
+		apiTokens,
+		serviceAccounts,
+		tm,
+		userOpts...,
+	)
+	typeOpts := []secrettype.RegistryOption{secrettype.WithMaxSize(cfg.Secrets.MaxSize)}
+	if cfg.Secrets.E2E {
+		typeOpts = append(typeOpts, secrettype.WithoutSchemas())
+	}
+
+	ks := grpcservice.NewKeeper(
+		secrets,
+		grpcservice.WithTypeRegistry(secrettype.NewRegistry(typeOpts...)),
+		grpcservice.WithQuota(model.Quota{
+			MaxSecrets:   cfg.Quota.MaxSecrets,
+			MaxTotalSize: cfg.Quota.MaxTotalSize,
+		}),
+	)
 
-	s := grpcserver.New(
+	tlsConfig, err := newTLSConfig(cfg.GRPC, l)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+
+	var authOpts []grpcservice.AuthOption
+	if tlsConfig != nil && tlsConfig.ClientCAs != nil {
+		authOpts = append(authOpts, grpcservice.WithClientCertificates(certificates, users))
+	}
+
+	health := grpcservice.NewHealth(
+		grpcservice.WithHealthCheck("db", db.PingContext),
+		grpcservice.WithHealthCheck("migrations", func(context.Context) error {
+			return migrate.Check(db)
+		}),
+		grpcservice.WithHealthServices(
+			pb.Keeper_ServiceDesc.ServiceName,
+			pb.User_ServiceDesc.ServiceName,
+			pb.Info_ServiceDesc.ServiceName,
+		),
+	)
+
+	services := []grpcserver.Service{as, ks, health, grpcservice.NewInfo(serverFeatures(cfg, tlsConfig)...)}
+	if cfg.GRPC.Reflection {
+		services = append(services, grpcservice.NewReflection())
+	}
+
+	serverOpts := []grpcserver.ServerOption{
 		grpcserver.WithListenAddr(cfg.GRPC.ListenAddr),
-		grpcserver.WithServices(as, ks),
+		grpcserver.WithServices(services...),
 		grpcserver.WithUnaryInterceptors(grpcservice.BuildUnaryInterceptors()...),
-		grpcserver.WithAuthFunc(grpcservice.BuildAuthFunc(tm)),
-	)
+		grpcserver.WithStreamInterceptors(grpcservice.BuildStreamInterceptors()...),
+		grpcserver.WithAuthFunc(grpcservice.BuildAuthFunc(tm, sessions, apiTokens, serviceAccounts, authOpts...)),
+	}
+	if tlsConfig != nil {
+		serverOpts = append(serverOpts, grpcserver.WithTLSConfig(tlsConfig))
+	} else {
+		l.Warn().Msg("GRPC is served without TLS, set grpc.tls_cert_file and grpc.tls_key_file for production")
+	}
+
+	s := grpcserver.New(serverOpts...)
 
 	if err := s.Start(); err != nil {
 		return nil, fmt.Errorf("grpc: %w", err)
 	}
+	health.Start()
+	if keys != nil {
+		keys.Start(keysReloadInterval)
+	}
 
 	a := &App{
 		config: cfg,
 		logger: l,
 		stop:   make(chan struct{}),
 		server: s,
+		health: health,
+		keys:   keys,
+	}
+
+	if cfg.HTTP.ListenAddr != "" {
+		if a.gateway, err = newGateway(cfg.HTTP, s, tlsConfig); err != nil {
+			if keys != nil {
+				keys.Stop()
+			}
+			health.Stop()
+			s.Stop()
+			return nil, fmt.Errorf("gateway: %w", err)
+		}
 	}
 
 	return a, nil
 }
 
+// newTokenManager with the key reloader to start when keys dir is set
+func newTokenManager(cfg config.SecurityConfig, l logger.Logger) (*token.JWT, *token.KeyReloader, error) {
+	if cfg.KeysDir == "" {
+		if cfg.SecretKey == "CHANGE_ME" {
+			l.Warn().Msg("Tokens are signed with the default secret key, set security.keys_dir or security.secret_key")
+		}
+		tm, err := token.NewJWT(cfg.SecretKey)
+		return tm, nil, err
+	}
+
+	// HS256 tokens issued before keys were set up are still accepted unless the secret is the default one
+	legacy := cfg.SecretKey
+	if legacy == "CHANGE_ME" {
+		// the secret still derives SRP salts of unknown emails
+		l.Warn().Msg("Default secret key is used, set security.secret_key")
+		legacy = ""
+	}
+
+	// keys are reloaded once the dir is modified, so rotation needs no restart
+	r, err := token.NewKeyReloader(token.NewKeyDir(cfg.KeysDir))
+	if err != nil {
+		return nil, nil, fmt.Errorf("load keys: %w", err)
+	}
+	active := r.KeySet().Active()
+	l.Info().Str("kid", active.ID).Str("alg", active.Method.Alg()).Msg("Signing key loaded")
+
+	tm, err := token.NewJWT(legacy, token.WithKeyReloader(r))
+	return tm, r, err
+}
+
+// newTLSConfig of the server, nil when TLS is not configured.
+// The certificate is reloaded once its files change, so renewals need no restart.
+// Client certificates are optional, so password logins keep working with client CA set.
+func newTLSConfig(cfg config.GRPCConfig, l logger.Logger) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" {
+		if cfg.ClientCAFile != "" {
+			return nil, errors.New("client certificates require tls_cert_file")
+		}
+		if cfg.TLSSelfSigned {
+			return nil, errors.New("self-signed certificate requires tls_cert_file and tls_key_file")
+		}
+		return nil, nil
+	}
+
+	if cfg.TLSSelfSigned {
+		created, err := tlscert.EnsureSelfSigned(cfg.TLSCertFile, cfg.TLSKeyFile, certificateHosts(cfg.ListenAddr))
+		if err != nil {
+			return nil, fmt.Errorf("self-signed certificate: %w", err)
+		}
+		if created {
+			l.Warn().Str("path", cfg.TLSCertFile).Msg("Self-signed certificate generated, use a CA signed one for production")
+		}
+	}
+
+	r, err := tlscert.NewReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	// logged so users can compare it with one shown by clients on first use
+	l.Info().Str("fingerprint", tlscert.Fingerprint(r.Certificate().Certificate[0])).Msg("TLS certificate loaded")
+
+	c := &tls.Config{
+		GetCertificate: r.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+	if cfg.ClientCAFile == "" {
+		return c, nil
+	}
+
+	pem, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read client ca: %w", err)
+	}
+	c.ClientCAs = x509.NewCertPool()
+	if !c.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", cfg.ClientCAFile)
+	}
+	c.ClientAuth = tls.VerifyClientCertIfGiven
+
+	return c, nil
+}
+
+// certificateHosts of a self-signed certificate: loopback, the hostname and the listen address host
+func certificateHosts(listenAddr string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if h, err := os.Hostname(); err == nil {
+		hosts = append(hosts, h)
+	}
+	if h, _, err := net.SplitHostPort(listenAddr); err == nil && h != "" && h != "localhost" {
+		if ip := net.ParseIP(h); ip == nil || !ip.IsUnspecified() && !ip.IsLoopback() {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// newGateway started in front of the server, client certificates are not used by REST clients
+func newGateway(cfg config.HTTPConfig, s *grpcserver.Server, tlsConfig *tls.Config) (*gateway.Server, error) {
+	conn, err := s.DialInProcess(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+
+	opts := []gateway.Option{gateway.WithListenAddr(cfg.ListenAddr)}
+	if tlsConfig != nil {
+		c := tlsConfig.Clone()
+		c.ClientCAs = nil
+		c.ClientAuth = tls.NoClientCert
+		opts = append(opts, gateway.WithTLSConfig(c))
+	}
+
+	gw := gateway.New(conn, opts...)
+	if err := gw.Start(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return gw, nil
+}
+
+// serverFeatures reported by the Info service, the ones always enabled are listed for older clients
+func serverFeatures(cfg config.Config, tlsConfig *tls.Config) []string {
+	features := []string{
+		grpcservice.FeatureTwoFactor,
+		grpcservice.FeatureSRP,
+		grpcservice.FeatureAPITokens,
+		grpcservice.FeatureServiceAccounts,
+		grpcservice.FeatureEmailVerification,
+	}
+	if cfg.Security.SRPOnly {
+		features = append(features, grpcservice.FeatureSRPOnly)
+	}
+	if cfg.Throttle.Enabled {
+		features = append(features, grpcservice.FeatureThrottle)
+	}
+	if tlsConfig != nil {
+		features = append(features, grpcservice.FeatureTLS)
+		if tlsConfig.ClientCAs != nil {
+			features = append(features, grpcservice.FeatureClientCertificates)
+		}
+	}
+	if cfg.Secrets.E2E {
+		features = append(features, grpcservice.FeatureE2E)
+	}
+	if cfg.HTTP.ListenAddr != "" {
+		features = append(features, grpcservice.FeatureGateway)
+	}
+	if cfg.GRPC.Reflection {
+		features = append(features, grpcservice.FeatureReflection)
+	}
+	return features
+}
+
+func newMailer(cfg config.MailConfig, l logger.Logger) (mailer.Mailer, error) {
+	switch cfg.Driver {
+	case "log":
+		l.Warn().Msg("Mails are written to the log, configure mail.driver for production")
+		if cfg.LogTokens {
+			l.Warn().Msg("Mailed tokens are written to the log, do not enable mail.log_tokens in production")
+			return mailer.NewLog(l, mailer.WithSecrets()), nil
+		}
+		return mailer.NewLog(l), nil
+	case "file":
+		return mailer.NewFile(cfg.FilePath, cfg.From), nil
+	case "smtp":
+		return mailer.NewSMTP(cfg.SMTPAddr, cfg.From, mailer.WithPlainAuth(cfg.SMTPUsername, cfg.SMTPPassword)), nil
+	default:
+		return nil, fmt.Errorf("unknown driver %q", cfg.Driver)
+	}
+}
+
 func (a *App) Stop() {
 	close(a.stop)
+	if a.keys != nil {
+		a.keys.Stop()
+	}
+	a.health.Stop()
+	if a.gateway != nil {
+		a.gateway.Stop()
+	}
 	a.server.Stop()
 }
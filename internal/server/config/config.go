@@ -1,18 +1,41 @@
 package config
 
 import (
+	"gophkeeper/internal/server/throttle"
 	"gophkeeper/pkg/logger"
+	"time"
 )
 
 type Config struct {
 	GRPC     GRPCConfig     `mapstructure:"grpc"`
+	HTTP     HTTPConfig     `mapstructure:"http"`
 	DB       DatabaseConfig `mapstructure:"db"`
 	Security SecurityConfig `mapstructure:"security"`
+	Secrets  SecretsConfig  `mapstructure:"secrets"`
+	Quota    QuotaConfig    `mapstructure:"quota"`
+	Mail     MailConfig     `mapstructure:"mail"`
+	Throttle ThrottleConfig `mapstructure:"throttle"`
 	Logger   logger.Config  `mapstructure:"log"`
 }
 
 type GRPCConfig struct {
 	ListenAddr string `mapstructure:"listen_addr"`
+	// TLSCertFile and TLSKeyFile in PEM format enable TLS, plaintext is served when not set
+	TLSCertFile string `mapstructure:"tls_cert_file"`
+	TLSKeyFile  string `mapstructure:"tls_key_file"`
+	// TLSSelfSigned generates a self-signed certificate at TLSCertFile and TLSKeyFile if missing,
+	// clients pin it on first use or trust it with --ca
+	TLSSelfSigned bool `mapstructure:"tls_self_signed"`
+	// ClientCAFile in PEM format enables client certificate auth, requires TLS
+	ClientCAFile string `mapstructure:"client_ca_file"`
+	// Reflection serves the API description for grpcurl and alike
+	Reflection bool `mapstructure:"reflection"`
+}
+
+// HTTPConfig of the REST gateway, it uses TLS settings of GRPCConfig except client certificates
+type HTTPConfig struct {
+	// ListenAddr of the gateway, it is disabled when empty
+	ListenAddr string `mapstructure:"listen_addr"`
 }
 
 type DatabaseConfig struct {
@@ -20,5 +43,49 @@ type DatabaseConfig struct {
 }
 
 type SecurityConfig struct {
+	// SecretKey signs tokens with HS256 when KeysDir is not set, otherwise it verifies HS256 tokens issued before
 	SecretKey string `mapstructure:"secret_key"`
+	// KeysDir with asymmetric signing keys managed by the keys command, reloaded once it is modified
+	KeysDir string `mapstructure:"keys_dir"`
+	// AccessTokenLifetime is a lifetime of issued JWT, keep it short as it can not be revoked
+	AccessTokenLifetime time.Duration `mapstructure:"access_token_lifetime"`
+	// RefreshTokenLifetime is a lifetime of single use tokens to get a new JWT without login
+	RefreshTokenLifetime time.Duration `mapstructure:"refresh_token_lifetime"`
+	// SRPOnly rejects passwords sent in plain text, users log in with SRP only
+	SRPOnly bool `mapstructure:"srp_only"`
+}
+
+type SecretsConfig struct {
+	// E2E is set when clients encrypt secret content, so it can not be checked against type schemas
+	E2E bool `mapstructure:"e2e"`
+	// MaxSize of a single secret content in bytes
+	MaxSize int `mapstructure:"max_size"`
+}
+
+// QuotaConfig limits storage usage per user, zero means unlimited.
+// The size of a single secret is limited by SecretsConfig.MaxSize.
+type QuotaConfig struct {
+	MaxSecrets   int64 `mapstructure:"max_secrets"`
+	MaxTotalSize int64 `mapstructure:"max_total_size"`
+}
+
+// MailConfig of verification and password reset mails delivery
+type MailConfig struct {
+	// Driver is one of: log (development only), file (mbox) or smtp
+	Driver string `mapstructure:"driver"`
+	// LogTokens exposes mailed tokens in the log driver output instead of redacting them, development only
+	LogTokens bool   `mapstructure:"log_tokens"`
+	From      string `mapstructure:"from"`
+	// FilePath of the mbox file for the file driver
+	FilePath     string `mapstructure:"file_path"`
+	SMTPAddr     string `mapstructure:"smtp_addr"`
+	SMTPUsername string `mapstructure:"smtp_username"`
+	SMTPPassword string `mapstructure:"smtp_password"`
+}
+
+// ThrottleConfig of login attempts and password reset requests, IP policy applies to registrations too
+type ThrottleConfig struct {
+	Enabled bool            `mapstructure:"enabled"`
+	Email   throttle.Policy `mapstructure:"email"`
+	IP      throttle.Policy `mapstructure:"ip"`
 }
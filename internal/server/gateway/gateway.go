@@ -0,0 +1,140 @@
+// Package gateway serves REST/JSON calls of api/*.proto HTTP rules by the gRPC services,
+// calls go through the in-process connection, so auth and interceptors are the same.
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"gophkeeper/api/openapi"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/pkg/logger"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// SpecPath of the OpenAPI document
+	SpecPath = "/openapi.json"
+
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 10 * time.Second
+)
+
+type Server struct {
+	listenAddr string
+	tlsConfig  *tls.Config
+
+	conn   *grpc.ClientConn
+	logger *logger.Logger
+	server *http.Server
+}
+
+type Option func(*Server)
+
+func WithListenAddr(a string) Option {
+	return func(s *Server) {
+		s.listenAddr = a
+	}
+}
+
+// WithTLSConfig serves HTTPS instead of plain HTTP
+func WithTLSConfig(c *tls.Config) Option {
+	return func(s *Server) {
+		s.tlsConfig = c
+	}
+}
+
+// New gateway to the services of the connection, see grpcserver.DialInProcess
+func New(conn *grpc.ClientConn, opts ...Option) *Server {
+	s := &Server{
+		conn:   conn,
+		logger: logger.Global(),
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	return s
+}
+
+func (s *Server) ListenAddr() string {
+	return s.listenAddr
+}
+
+// Handler of the REST routes, the OpenAPI document and /healthz backed by grpc.health.v1
+func Handler(ctx context.Context, conn *grpc.ClientConn) (http.Handler, error) {
+	gw := runtime.NewServeMux(runtime.WithHealthzEndpoint(healthgrpc.NewHealthClient(conn)))
+	if err := pb.RegisterInfoHandler(ctx, gw, conn); err != nil {
+		return nil, fmt.Errorf("register info: %w", err)
+	}
+	if err := pb.RegisterKeeperHandler(ctx, gw, conn); err != nil {
+		return nil, fmt.Errorf("register keeper: %w", err)
+	}
+	if err := pb.RegisterUserHandler(ctx, gw, conn); err != nil {
+		return nil, fmt.Errorf("register user: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", gw)
+	mux.HandleFunc(SpecPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(openapi.Spec)
+	})
+
+	return mux, nil
+}
+
+func (s *Server) Start() error {
+	h, err := Handler(context.Background(), s.conn)
+	if err != nil {
+		return err
+	}
+
+	lis, err := net.Listen("tcp", s.listenAddr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
+	// required for testing
+	s.listenAddr = lis.Addr().String()
+
+	s.server = &http.Server{
+		Handler:           h,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	if s.tlsConfig != nil {
+		s.server.TLSConfig = s.tlsConfig
+		lis = tls.NewListener(lis, s.tlsConfig)
+	}
+
+	go func() {
+		s.logger.Info().Str("host", s.listenAddr).Bool("tls", s.tlsConfig != nil).Msg("Listening incoming HTTP connections")
+		if err := s.server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Fatal().Err(err).Send()
+		}
+	}()
+
+	return nil
+}
+
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.logger.Error().Err(err).Msg("HTTP shutdown")
+	}
+	_ = s.conn.Close()
+}
@@ -0,0 +1,116 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/v2/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gophkeeper/internal/server/grpcservice"
+	"gophkeeper/internal/server/model"
+	storagemock "gophkeeper/internal/server/storage/mock"
+	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/grpcserver"
+	"gophkeeper/pkg/usercontext"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid := uuid.New()
+	secrets := storagemock.NewMockSecretRepository(ctrl)
+	secrets.EXPECT().List(gomock.Any(), uid).Return([]*model.Secret{
+		{Name: "prod/db", Type: "raw", CreatedAt: time.Now()},
+	}, nil)
+	secrets.EXPECT().ReadByName(gomock.Any(), uid, "prod/db").Return(&model.Secret{
+		Name: "prod/db", Type: "raw", Content: []byte("secret"),
+	}, nil)
+	secrets.EXPECT().ReadByName(gomock.Any(), uid, "missing").Return(nil, apperr.ErrNotFound)
+
+	// the same bearer token auth as gRPC calls
+	var auth grpcauth.AuthFunc = func(ctx context.Context) (context.Context, error) {
+		tok, err := grpcauth.AuthFromMD(ctx, "bearer")
+		if err != nil {
+			return nil, err
+		}
+		if tok != "good" {
+			return nil, status.Error(codes.Unauthenticated, "invalid auth token")
+		}
+		return usercontext.WritePrincipal(ctx, usercontext.Principal{UserID: uid}), nil
+	}
+
+	s := grpcserver.New(
+		grpcserver.WithListenAddr("localhost:0"),
+		grpcserver.WithServices(grpcservice.NewKeeper(secrets)),
+		grpcserver.WithUnaryInterceptors(grpcservice.ErrorUnaryInterceptor()),
+		grpcserver.WithAuthFunc(auth),
+	)
+	require.NoError(t, s.Start())
+	defer s.Stop()
+
+	conn, err := s.DialInProcess(context.Background())
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	h, err := Handler(context.Background(), conn)
+	require.NoError(t, err)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	get := func(path, token string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	code, body := get("/v1/secrets", "good")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"name":"prod/db"`)
+
+	// names with slashes are matched as a whole
+	code, body = get("/v1/secrets/prod/db", "good")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"content":"c2VjcmV0"`)
+
+	code, _ = get("/v1/secrets/missing", "good")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = get("/v1/secrets", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = get("/v1/secrets", "bad")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, body = get(SpecPath, "")
+	assert.Equal(t, http.StatusOK, code)
+	var spec struct {
+		Swagger string                 `json:"swagger"`
+		Paths   map[string]interface{} `json:"paths"`
+	}
+	require.NoError(t, json.NewDecoder(strings.NewReader(body)).Decode(&spec))
+	assert.Equal(t, "2.0", spec.Swagger)
+	assert.Contains(t, spec.Paths, "/v1/secrets")
+	assert.Contains(t, spec.Paths, "/v1/auth/login")
+}
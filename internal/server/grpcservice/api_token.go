@@ -0,0 +1,158 @@
+package grpcservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/logger"
+	"gophkeeper/pkg/scope"
+	"time"
+)
+
+const (
+	DefaultAPITokenLifetime = time.Hour * 24 * 30
+	MaxAPITokenLifetime     = time.Hour * 24 * 365
+
+	// ReasonScopeDenied is set in ErrorInfo when an API token lacks the scope required by a call
+	ReasonScopeDenied = "SCOPE_DENIED"
+
+	maxAPITokenNameLength = 64
+)
+
+// CreateAPIToken for automation, the token is returned once and is valid till its expiration or revocation
+func (s User) CreateAPIToken(ctx context.Context, request *pb.CreateAPITokenRequest) (*pb.CreateAPITokenResponse, error) {
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name := request.GetName()
+	if name == "" || len(name) > maxAPITokenNameLength {
+		return nil, status.Errorf(codes.InvalidArgument, "token name must be 1 to %d bytes long", maxAPITokenNameLength)
+	}
+
+	scopes, err := scope.ParseSet(request.GetScopes())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	sa, err := s.ownServiceAccount(ctx, uid, request.GetServiceAccountId())
+	if err != nil {
+		return nil, err
+	}
+
+	lifetime := DefaultAPITokenLifetime
+	if request.GetLifetime() != nil {
+		lifetime = request.GetLifetime().AsDuration()
+	}
+	if lifetime <= 0 || lifetime > MaxAPITokenLifetime {
+		return nil, status.Errorf(codes.InvalidArgument, "token lifetime must be positive and at most %s", MaxAPITokenLifetime)
+	}
+
+	m, err := s.apiTokens.Create(ctx, &model.APIToken{
+		UserID:           uid,
+		ServiceAccountID: sa,
+		Name:             name,
+		Scopes:           scopes.Strings(),
+		ExpiresAt:        time.Now().Add(lifetime),
+	})
+	switch {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, apperr.ErrConflict):
+		return nil, status.Errorf(codes.AlreadyExists, "token %q already exists", name)
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	t, err := s.token.Issue(m, lifetime)
+	if err != nil {
+		// the token is unusable without being issued
+		if err := s.apiTokens.Delete(ctx, uid, m.ID); err != nil {
+			l := logger.Ctx(ctx)
+			l.Warn().Err(err).Msg("Unissued API token is not deleted")
+		}
+		return nil, status.Error(codes.Internal, fmt.Sprintf("issue api token: %v", err))
+	}
+
+	return &pb.CreateAPITokenResponse{
+		Token: t,
+		Info:  newAPITokenInfo(m),
+	}, nil
+}
+
+// ListAPITokens of the user or its service account, expired ones are omitted
+func (s User) ListAPITokens(ctx context.Context, request *pb.ListAPITokensRequest) (*pb.ListAPITokensResponse, error) {
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sa, err := s.ownServiceAccount(ctx, uid, request.GetServiceAccountId())
+	if err != nil {
+		return nil, err
+	}
+
+	mm, err := s.apiTokens.List(ctx, uid, sa)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &pb.ListAPITokensResponse{
+		Tokens: make([]*pb.APIToken, 0, len(mm)),
+	}
+	for _, m := range mm {
+		resp.Tokens = append(resp.Tokens, newAPITokenInfo(m))
+	}
+
+	return resp, nil
+}
+
+// RevokeAPIToken of the user or its service account, it stops working immediately
+func (s User) RevokeAPIToken(ctx context.Context, request *pb.RevokeAPITokenRequest) (*pb.RevokeAPITokenResponse, error) {
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(request.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid token id")
+	}
+
+	switch err := s.apiTokens.Delete(ctx, uid, id); {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, apperr.ErrNotFound):
+		return nil, status.Error(codes.NotFound, "token not found")
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.RevokeAPITokenResponse{}, nil
+}
+
+func newAPITokenInfo(m *model.APIToken) *pb.APIToken {
+	info := &pb.APIToken{
+		Id:         m.ID.String(),
+		Name:       m.Name,
+		Scopes:     m.Scopes,
+		CreatedAt:  timestamppb.New(m.CreatedAt),
+		ExpiresAt:  timestamppb.New(m.ExpiresAt),
+		LastUsedIp: m.LastUsedIP,
+	}
+	if !m.LastUsedAt.IsZero() {
+		info.LastUsedAt = timestamppb.New(m.LastUsedAt)
+	}
+	if m.ServiceAccountID != uuid.Nil {
+		info.ServiceAccountId = m.ServiceAccountID.String()
+	}
+	return info
+}
@@ -0,0 +1,177 @@
+package grpcservice
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/server/model"
+	storagemock "gophkeeper/internal/server/storage/mock"
+	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/scope"
+	"gophkeeper/pkg/token"
+	"gophkeeper/pkg/usercontext"
+	"testing"
+	"time"
+)
+
+func TestUser_CreateAPIToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid, tid := uuid.New(), uuid.New(), uuid.New()
+	ctx := usercontext.WriteSessionID(usercontext.WriteUID(context.Background(), uid), sid)
+
+	tm, err := token.NewJWT("secret")
+	require.NoError(t, err)
+
+	at := storagemock.NewMockAPITokenRepository(ctrl)
+	at.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, m *model.APIToken) (*model.APIToken, error) {
+			assert.Equal(t, uid, m.UserID)
+			assert.Equal(t, []string{"read:ci/", "write:ci/"}, m.Scopes)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), m.ExpiresAt, time.Minute)
+			m.ID = tid
+			return m, nil
+		})
+	at.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, apperr.ErrConflict)
+
+	svc := NewUser(
+		storagemock.NewMockUserRepository(ctrl),
+		storagemock.NewMockSessionRepository(ctrl),
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		storagemock.NewMockUserTokenRepository(ctrl),
+		at,
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		tm,
+	)
+
+	for _, req := range []*pb.CreateAPITokenRequest{
+		{Scopes: []string{"read"}},
+		{Name: "ci"},
+		{Name: "ci", Scopes: []string{"admin"}},
+		{Name: "ci", Scopes: []string{"read"}, Lifetime: durationpb.New(MaxAPITokenLifetime + time.Hour)},
+		{Name: "ci", Scopes: []string{"read"}, Lifetime: durationpb.New(-time.Hour)},
+	} {
+		_, err = svc.CreateAPIToken(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), req.String())
+	}
+
+	resp, err := svc.CreateAPIToken(ctx, &pb.CreateAPITokenRequest{
+		Name:     "ci",
+		Scopes:   []string{"write:ci/", "read:ci/", "write:ci/"},
+		Lifetime: durationpb.New(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, tid.String(), resp.GetInfo().GetId())
+	assert.Nil(t, resp.GetInfo().GetLastUsedAt())
+
+	id, err := tm.Decode(resp.GetToken())
+	require.NoError(t, err)
+	assert.Equal(t, uid.String(), id.Identity())
+	assert.Equal(t, tid.String(), id.(token.ScopedIdentity).TokenID())
+	assert.Equal(t, []string{"read:ci/", "write:ci/"}, id.(token.ScopedIdentity).TokenScopes())
+
+	_, err = svc.CreateAPIToken(ctx, &pb.CreateAPITokenRequest{Name: "ci", Scopes: []string{"read"}})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+}
+
+func TestUser_RevokeAPIToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid, tid := uuid.New(), uuid.New(), uuid.New()
+	ctx := usercontext.WriteSessionID(usercontext.WriteUID(context.Background(), uid), sid)
+
+	at := storagemock.NewMockAPITokenRepository(ctrl)
+	at.EXPECT().Delete(gomock.Any(), uid, tid).Return(nil)
+	at.EXPECT().Delete(gomock.Any(), uid, tid).Return(apperr.ErrNotFound)
+
+	svc := NewUser(
+		storagemock.NewMockUserRepository(ctrl),
+		storagemock.NewMockSessionRepository(ctrl),
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		storagemock.NewMockUserTokenRepository(ctrl),
+		at,
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		nil,
+	)
+
+	_, err := svc.RevokeAPIToken(ctx, &pb.RevokeAPITokenRequest{Id: "nope"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = svc.RevokeAPIToken(ctx, &pb.RevokeAPITokenRequest{Id: tid.String()})
+	assert.NoError(t, err)
+
+	_, err = svc.RevokeAPIToken(ctx, &pb.RevokeAPITokenRequest{Id: tid.String()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// api tokens can not manage the account
+	_, err = svc.ListAPITokens(usercontext.WriteUID(context.Background(), uid), &pb.ListAPITokensRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestBuildAuthFunc_APIToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, tid := uuid.New(), uuid.New()
+
+	tm, err := token.NewJWT("secret")
+	require.NoError(t, err)
+	tk, err := tm.Issue(&model.APIToken{ID: tid, UserID: uid, Scopes: []string{"read:ci/"}}, time.Minute)
+	require.NoError(t, err)
+	foreign, err := tm.Issue(&model.APIToken{ID: tid, UserID: uuid.New(), Scopes: []string{"read"}}, time.Minute)
+	require.NoError(t, err)
+
+	at := storagemock.NewMockAPITokenRepository(ctrl)
+	at.EXPECT().Touch(gomock.Any(), tid, "").Return(&model.APIToken{ID: tid, UserID: uid}, nil).Times(2)
+	at.EXPECT().Touch(gomock.Any(), tid, "").Return(nil, apperr.ErrNotFound)
+
+	sr := storagemock.NewMockSessionRepository(ctrl)
+	auth := BuildAuthFunc(tm, sr, at, storagemock.NewMockServiceAccountRepository(ctrl))
+	bearer := func(tk string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "bearer "+tk))
+	}
+
+	ctx, err := auth(bearer(tk))
+	require.NoError(t, err)
+	assert.Equal(t, uid, usercontext.ReadUID(ctx).UUID)
+	assert.False(t, usercontext.ReadSessionID(ctx).Valid)
+	p, ok := usercontext.ReadPrincipal(ctx)
+	require.True(t, ok)
+	assert.True(t, p.Scoped())
+	assert.True(t, p.Allows(scope.Read, "ci/deploy", nil))
+	assert.False(t, p.Allows(scope.Read, "prod/db", nil))
+
+	// token of another user with a stolen id
+	_, err = auth(bearer(foreign))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// revoked token
+	_, err = auth(bearer(tk))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// the account can not be managed with api tokens
+	svc := NewUser(
+		storagemock.NewMockUserRepository(ctrl),
+		sr,
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		storagemock.NewMockUserTokenRepository(ctrl),
+		at,
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		tm,
+	)
+	at.EXPECT().Touch(gomock.Any(), tid, "").Return(&model.APIToken{ID: tid, UserID: uid}, nil)
+	_, err = svc.AuthFuncOverride(bearer(tk), "/api.User/CreateAPIToken")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
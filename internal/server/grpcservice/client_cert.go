@@ -0,0 +1,337 @@
+package grpcservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/internal/server/storage"
+	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/usercontext"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const (
+	maxClientCertificateNameLength = 64
+
+	// ServiceAccountURNPrefix of the URI SAN naming the service account of a client certificate
+	ServiceAccountURNPrefix = "urn:gophkeeper:service-account:"
+)
+
+// certificateSubject is the account named by a client certificate, either a user email
+// or a service account id
+type certificateSubject struct {
+	email          string
+	serviceAccount uuid.UUID
+}
+
+// readCertificateSubject maps the service account URI SAN to the service account,
+// otherwise the email SAN or the subject common name to the user
+func readCertificateSubject(cert *x509.Certificate) (certificateSubject, error) {
+	for _, u := range cert.URIs {
+		s := u.String()
+		if !strings.HasPrefix(s, ServiceAccountURNPrefix) {
+			continue
+		}
+		id, err := uuid.Parse(strings.TrimPrefix(s, ServiceAccountURNPrefix))
+		if err != nil {
+			return certificateSubject{}, errors.New("invalid service account id in URI SAN")
+		}
+		return certificateSubject{serviceAccount: id}, nil
+	}
+
+	if len(cert.EmailAddresses) > 0 {
+		return certificateSubject{email: cert.EmailAddresses[0]}, nil
+	}
+	if a, err := mail.ParseAddress(cert.Subject.CommonName); err == nil && a.Address == cert.Subject.CommonName {
+		return certificateSubject{email: a.Address}, nil
+	}
+
+	return certificateSubject{}, errors.New("no email or service account URI SAN and no email common name")
+}
+
+// RegisterClientCertificate binds the certificate public key to the user or its service account,
+// only registered keys are accepted by the client certificate authentication
+func (s User) RegisterClientCertificate(
+	ctx context.Context,
+	request *pb.RegisterClientCertificateRequest,
+) (*pb.RegisterClientCertificateResponse, error) {
+	if s.certificates == nil {
+		return nil, status.Error(codes.Unimplemented, "client certificates are disabled")
+	}
+
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name := request.GetName()
+	if name == "" || len(name) > maxClientCertificateNameLength {
+		return nil, status.Errorf(codes.InvalidArgument, "certificate name must be 1 to %d bytes long", maxClientCertificateNameLength)
+	}
+
+	cert, err := parseClientCertificate(request.GetCertificate())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid certificate: %v", err)
+	}
+	if time.Now().After(cert.NotAfter) {
+		return nil, status.Error(codes.InvalidArgument, "certificate is expired")
+	}
+
+	sa, err := s.ownServiceAccount(ctx, uid, request.GetServiceAccountId())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCertificateSubject(ctx, uid, sa, cert); err != nil {
+		return nil, err
+	}
+
+	m, err := s.certificates.Create(ctx, &model.ClientCertificate{
+		UserID:           uid,
+		ServiceAccountID: sa,
+		Name:             name,
+		Fingerprint:      publicKeyFingerprint(cert),
+		ExpiresAt:        cert.NotAfter,
+	})
+	switch {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, apperr.ErrConflict):
+		return nil, status.Error(codes.AlreadyExists, "certificate key is already registered")
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.RegisterClientCertificateResponse{
+		Certificate: newClientCertificateInfo(m),
+	}, nil
+}
+
+// ListClientCertificates of the user or its service account
+func (s User) ListClientCertificates(
+	ctx context.Context,
+	request *pb.ListClientCertificatesRequest,
+) (*pb.ListClientCertificatesResponse, error) {
+	if s.certificates == nil {
+		return nil, status.Error(codes.Unimplemented, "client certificates are disabled")
+	}
+
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sa, err := s.ownServiceAccount(ctx, uid, request.GetServiceAccountId())
+	if err != nil {
+		return nil, err
+	}
+
+	mm, err := s.certificates.List(ctx, uid, sa)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &pb.ListClientCertificatesResponse{
+		Certificates: make([]*pb.ClientCertificate, 0, len(mm)),
+	}
+	for _, m := range mm {
+		resp.Certificates = append(resp.Certificates, newClientCertificateInfo(m))
+	}
+
+	return resp, nil
+}
+
+// DeleteClientCertificate of the user or its service account, its key is rejected immediately
+func (s User) DeleteClientCertificate(
+	ctx context.Context,
+	request *pb.DeleteClientCertificateRequest,
+) (*pb.DeleteClientCertificateResponse, error) {
+	if s.certificates == nil {
+		return nil, status.Error(codes.Unimplemented, "client certificates are disabled")
+	}
+
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(request.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid certificate id")
+	}
+
+	switch err := s.certificates.Delete(ctx, uid, id); {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, apperr.ErrNotFound):
+		return nil, status.Error(codes.NotFound, "certificate not found")
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.DeleteClientCertificateResponse{}, nil
+}
+
+// checkCertificateSubject is the account the certificate is registered for,
+// so the subject the certificate is authenticated by can not name someone else
+func (s User) checkCertificateSubject(ctx context.Context, uid, sa uuid.UUID, cert *x509.Certificate) error {
+	subj, err := readCertificateSubject(cert)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid certificate subject: %v", err)
+	}
+
+	if sa != uuid.Nil {
+		if subj.serviceAccount != sa {
+			return status.Errorf(codes.InvalidArgument, "certificate must have URI SAN %s%s", ServiceAccountURNPrefix, sa)
+		}
+		return nil
+	}
+
+	u, err := s.users.Read(ctx, uid)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if subj.serviceAccount != uuid.Nil || !strings.EqualFold(subj.email, u.Email) {
+		return status.Errorf(codes.InvalidArgument, "certificate must have email SAN or common name %s", u.Email)
+	}
+	return nil
+}
+
+// parseClientCertificate from a single PEM block
+func parseClientCertificate(data []byte) (*x509.Certificate, error) {
+	block, rest := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM certificate")
+	}
+	if next, _ := pem.Decode(rest); next != nil {
+		return nil, errors.New("more than one PEM block")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// publicKeyFingerprint is the SHA-256 of the certificate public key info, so renewals keep it
+func publicKeyFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "SHA256:" + hex.EncodeToString(sum[:])
+}
+
+func newClientCertificateInfo(m *model.ClientCertificate) *pb.ClientCertificate {
+	info := &pb.ClientCertificate{
+		Id:          m.ID.String(),
+		Name:        m.Name,
+		Fingerprint: m.Fingerprint,
+		CreatedAt:   timestamppb.New(m.CreatedAt),
+		ExpiresAt:   timestamppb.New(m.ExpiresAt),
+	}
+	if m.ServiceAccountID != uuid.Nil {
+		info.ServiceAccountId = m.ServiceAccountID.String()
+	}
+	return info
+}
+
+// peerCertificate is the leaf of the verified client certificate chain, if any
+func peerCertificate(ctx context.Context) *x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return info.State.VerifiedChains[0][0]
+}
+
+// certificatePrincipal maps the certificate subject to the user or the service account it names,
+// the certificate public key must also be registered for that account,
+// so a certificate signed by the trusted CA is not enough on its own.
+// Users get the vault access without a session, so account management still needs a login.
+func certificatePrincipal(
+	ctx context.Context,
+	certificates storage.ClientCertificateRepository,
+	users storage.UserRepository,
+	serviceAccounts storage.ServiceAccountRepository,
+	cert *x509.Certificate,
+) (usercontext.Principal, error) {
+	subj, err := readCertificateSubject(cert)
+	if err != nil {
+		return usercontext.Principal{}, status.Errorf(codes.Unauthenticated, "invalid client certificate subject: %v", err)
+	}
+
+	m, err := certificates.ReadByFingerprint(ctx, publicKeyFingerprint(cert))
+	switch {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, apperr.ErrNotFound):
+		return usercontext.Principal{}, status.Error(codes.Unauthenticated, "client certificate is not registered")
+	default:
+		return usercontext.Principal{}, status.Error(codes.Internal, err.Error())
+	}
+
+	if subj.serviceAccount != uuid.Nil {
+		if m.ServiceAccountID != subj.serviceAccount {
+			return usercontext.Principal{}, status.Error(codes.Unauthenticated, "client certificate is registered for another account")
+		}
+		return serviceAccountCertificatePrincipal(ctx, serviceAccounts, m)
+	}
+
+	u, err := users.ReadByEmail(ctx, subj.email)
+	switch {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, apperr.ErrNotFound):
+		return usercontext.Principal{}, status.Error(codes.Unauthenticated, "client certificate user not found")
+	default:
+		return usercontext.Principal{}, status.Error(codes.Internal, err.Error())
+	}
+	if m.ServiceAccountID != uuid.Nil || m.UserID != u.ID {
+		return usercontext.Principal{}, status.Error(codes.Unauthenticated, "client certificate is registered for another account")
+	}
+
+	return usercontext.Principal{
+		UserID: u.ID,
+	}, nil
+}
+
+// serviceAccountCertificatePrincipal acts on behalf of the service account owner limited by its grants
+func serviceAccountCertificatePrincipal(
+	ctx context.Context,
+	serviceAccounts storage.ServiceAccountRepository,
+	m *model.ClientCertificate,
+) (usercontext.Principal, error) {
+	sa, err := serviceAccounts.Read(ctx, m.ServiceAccountID)
+	switch {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, apperr.ErrNotFound):
+		return usercontext.Principal{}, status.Error(codes.Unauthenticated, "service account deleted")
+	default:
+		return usercontext.Principal{}, status.Error(codes.Internal, err.Error())
+	}
+	if sa.OwnerID != m.UserID {
+		return usercontext.Principal{}, status.Error(codes.Unauthenticated, "service account owner mismatch")
+	}
+
+	grants, err := parseGrants(sa.Grants)
+	if err != nil {
+		return usercontext.Principal{}, status.Error(codes.Internal, err.Error())
+	}
+
+	return usercontext.Principal{
+		UserID:           sa.OwnerID,
+		ServiceAccountID: sa.ID,
+		Grants:           grants,
+	}, nil
+}
@@ -0,0 +1,317 @@
+package grpcservice
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/server/model"
+	storagemock "gophkeeper/internal/server/storage/mock"
+	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/scope"
+	"gophkeeper/pkg/token"
+	"gophkeeper/pkg/usercontext"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func certContext(cert *x509.Certificate) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{
+				VerifiedChains: [][]*x509.Certificate{{cert}},
+			},
+		},
+	})
+}
+
+// testCertificate is a self-signed certificate with a fresh key valid till notAfter, with its PEM encoding,
+// a service account URN name goes to the URI SAN and any other name to the common name
+func testCertificate(t *testing.T, name string, notAfter time.Time) (*x509.Certificate, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    notAfter.Add(-time.Hour * 24 * 365),
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if strings.HasPrefix(name, ServiceAccountURNPrefix) {
+		u, err := url.Parse(name)
+		require.NoError(t, err)
+		tmpl.Subject = pkix.Name{CommonName: "service account"}
+		tmpl.URIs = []*url.URL{u}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestUser_RegisterClientCertificate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid, said, cid := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	ctx := usercontext.WriteSessionID(usercontext.WriteUID(context.Background(), uid), sid)
+
+	cert, certPEM := testCertificate(t, ServiceAccountURNPrefix+said.String(), time.Now().Add(time.Hour))
+	_, expiredPEM := testCertificate(t, "old", time.Now().Add(-time.Hour))
+	userCert, userPEM := testCertificate(t, "Alice@example.com", time.Now().Add(time.Hour))
+	_, otherPEM := testCertificate(t, "bob@example.com", time.Now().Add(time.Hour))
+	_, noSubjectPEM := testCertificate(t, "laptop", time.Now().Add(time.Hour))
+
+	sa := storagemock.NewMockServiceAccountRepository(ctrl)
+	sa.EXPECT().Read(gomock.Any(), said).Return(&model.ServiceAccount{ID: said, OwnerID: uid}, nil).Times(4)
+
+	users := storagemock.NewMockUserRepository(ctrl)
+	users.EXPECT().Read(gomock.Any(), uid).Return(&model.User{ID: uid, Email: "alice@example.com"}, nil).Times(3)
+
+	certs := storagemock.NewMockClientCertificateRepository(ctrl)
+	certs.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, m *model.ClientCertificate) (*model.ClientCertificate, error) {
+			assert.Equal(t, uid, m.UserID)
+			assert.Equal(t, said, m.ServiceAccountID)
+			assert.Equal(t, publicKeyFingerprint(cert), m.Fingerprint)
+			assert.Equal(t, cert.NotAfter, m.ExpiresAt)
+			m.ID = cid
+			return m, nil
+		})
+	certs.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, apperr.ErrConflict)
+	certs.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, m *model.ClientCertificate) (*model.ClientCertificate, error) {
+			assert.Equal(t, uid, m.UserID)
+			assert.Equal(t, uuid.Nil, m.ServiceAccountID)
+			assert.Equal(t, publicKeyFingerprint(userCert), m.Fingerprint)
+			return m, nil
+		})
+
+	newSvc := func(opts ...UserOption) *User {
+		return NewUser(
+			users,
+			storagemock.NewMockSessionRepository(ctrl),
+			storagemock.NewMockRefreshTokenRepository(ctrl),
+			storagemock.NewMockTwoFactorRepository(ctrl),
+			storagemock.NewMockUserTokenRepository(ctrl),
+			storagemock.NewMockAPITokenRepository(ctrl),
+			sa,
+			nil,
+			opts...,
+		)
+	}
+	svc := newSvc(WithCertificates(certs))
+
+	for _, req := range []*pb.RegisterClientCertificateRequest{
+		{Certificate: certPEM},
+		{Name: "ci"},
+		{Name: "ci", Certificate: []byte("not a certificate")},
+		{Name: "ci", Certificate: append(certPEM, certPEM...)},
+		{Name: "ci", Certificate: expiredPEM},
+	} {
+		_, err := svc.RegisterClientCertificate(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), req.GetName())
+	}
+
+	resp, err := svc.RegisterClientCertificate(ctx, &pb.RegisterClientCertificateRequest{
+		Name:             "ci",
+		Certificate:      certPEM,
+		ServiceAccountId: said.String(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, cid.String(), resp.GetCertificate().GetId())
+	assert.Equal(t, said.String(), resp.GetCertificate().GetServiceAccountId())
+
+	_, err = svc.RegisterClientCertificate(ctx, &pb.RegisterClientCertificateRequest{
+		Name:             "ci",
+		Certificate:      certPEM,
+		ServiceAccountId: said.String(),
+	})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	// the subject must name the account the key is registered for
+	for _, req := range []*pb.RegisterClientCertificateRequest{
+		{Name: "ci", Certificate: certPEM},
+		{Name: "ci", Certificate: otherPEM},
+		{Name: "ci", Certificate: noSubjectPEM},
+		{Name: "ci", Certificate: userPEM, ServiceAccountId: said.String()},
+		{Name: "ci", Certificate: noSubjectPEM, ServiceAccountId: said.String()},
+	} {
+		_, err := svc.RegisterClientCertificate(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+
+	_, err = svc.RegisterClientCertificate(ctx, &pb.RegisterClientCertificateRequest{
+		Name:        "laptop",
+		Certificate: userPEM,
+	})
+	require.NoError(t, err)
+
+	// certificates can not register more certificates
+	_, err = svc.RegisterClientCertificate(usercontext.WriteUID(context.Background(), uid), &pb.RegisterClientCertificateRequest{
+		Name:        "ci",
+		Certificate: certPEM,
+	})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = newSvc().RegisterClientCertificate(ctx, &pb.RegisterClientCertificateRequest{Name: "ci", Certificate: certPEM})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestUser_DeleteClientCertificate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid, cid := uuid.New(), uuid.New(), uuid.New()
+	ctx := usercontext.WriteSessionID(usercontext.WriteUID(context.Background(), uid), sid)
+
+	certs := storagemock.NewMockClientCertificateRepository(ctrl)
+	certs.EXPECT().List(gomock.Any(), uid, uuid.Nil).Return([]*model.ClientCertificate{{ID: cid, UserID: uid, Name: "laptop"}}, nil)
+	certs.EXPECT().Delete(gomock.Any(), uid, cid).Return(nil)
+	certs.EXPECT().Delete(gomock.Any(), uid, cid).Return(apperr.ErrNotFound)
+
+	svc := NewUser(
+		storagemock.NewMockUserRepository(ctrl),
+		storagemock.NewMockSessionRepository(ctrl),
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		storagemock.NewMockUserTokenRepository(ctrl),
+		storagemock.NewMockAPITokenRepository(ctrl),
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		nil,
+		WithCertificates(certs),
+	)
+
+	list, err := svc.ListClientCertificates(ctx, &pb.ListClientCertificatesRequest{})
+	require.NoError(t, err)
+	if assert.Len(t, list.GetCertificates(), 1) {
+		assert.Equal(t, "laptop", list.GetCertificates()[0].GetName())
+		assert.Empty(t, list.GetCertificates()[0].GetServiceAccountId())
+	}
+
+	_, err = svc.DeleteClientCertificate(ctx, &pb.DeleteClientCertificateRequest{Id: "nope"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = svc.DeleteClientCertificate(ctx, &pb.DeleteClientCertificateRequest{Id: cid.String()})
+	assert.NoError(t, err)
+
+	_, err = svc.DeleteClientCertificate(ctx, &pb.DeleteClientCertificateRequest{Id: cid.String()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestBuildAuthFunc_ClientCertificate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, said := uuid.New(), uuid.New()
+
+	tm, err := token.NewJWT("secret")
+	require.NoError(t, err)
+
+	saName := ServiceAccountURNPrefix + said.String()
+	userCert, _ := testCertificate(t, "alice@example.com", time.Now().Add(time.Hour))
+	saCert, _ := testCertificate(t, saName, time.Now().Add(time.Hour))
+	foreignCert, _ := testCertificate(t, saName, time.Now().Add(time.Hour))
+	unknownCert, _ := testCertificate(t, "bob@example.com", time.Now().Add(time.Hour))
+	noSubjectCert, _ := testCertificate(t, "ci", time.Now().Add(time.Hour))
+	userKeyAsSACert, _ := testCertificate(t, saName, time.Now().Add(time.Hour))
+	saKeyAsUserCert, _ := testCertificate(t, "alice@example.com", time.Now().Add(time.Hour))
+	otherUserCert, _ := testCertificate(t, "carol@example.com", time.Now().Add(time.Hour))
+	fp := func(c *x509.Certificate) string {
+		return publicKeyFingerprint(c)
+	}
+
+	certs := storagemock.NewMockClientCertificateRepository(ctrl)
+	certs.EXPECT().ReadByFingerprint(gomock.Any(), fp(userCert)).Return(&model.ClientCertificate{UserID: uid}, nil)
+	certs.EXPECT().ReadByFingerprint(gomock.Any(), fp(saCert)).Return(&model.ClientCertificate{UserID: uid, ServiceAccountID: said}, nil).Times(2)
+	certs.EXPECT().ReadByFingerprint(gomock.Any(), fp(foreignCert)).Return(&model.ClientCertificate{UserID: uuid.New(), ServiceAccountID: said}, nil)
+	certs.EXPECT().ReadByFingerprint(gomock.Any(), fp(unknownCert)).Return(nil, apperr.ErrNotFound)
+	certs.EXPECT().ReadByFingerprint(gomock.Any(), fp(userKeyAsSACert)).Return(&model.ClientCertificate{UserID: uid}, nil)
+	certs.EXPECT().ReadByFingerprint(gomock.Any(), fp(saKeyAsUserCert)).Return(&model.ClientCertificate{UserID: uid, ServiceAccountID: said}, nil)
+	certs.EXPECT().ReadByFingerprint(gomock.Any(), fp(otherUserCert)).Return(&model.ClientCertificate{UserID: uid}, nil)
+
+	users := storagemock.NewMockUserRepository(ctrl)
+	users.EXPECT().ReadByEmail(gomock.Any(), "alice@example.com").Return(&model.User{ID: uid, Email: "alice@example.com"}, nil).Times(2)
+	users.EXPECT().ReadByEmail(gomock.Any(), "carol@example.com").Return(&model.User{ID: uuid.New(), Email: "carol@example.com"}, nil)
+
+	sa := storagemock.NewMockServiceAccountRepository(ctrl)
+	gomock.InOrder(
+		sa.EXPECT().Read(gomock.Any(), said).Return(&model.ServiceAccount{ID: said, OwnerID: uid, Grants: []string{"read:ci/"}}, nil),
+		sa.EXPECT().Read(gomock.Any(), said).Return(&model.ServiceAccount{ID: said, OwnerID: uid, Grants: []string{"read:ci/"}}, nil),
+		sa.EXPECT().Read(gomock.Any(), said).Return(nil, apperr.ErrNotFound),
+	)
+
+	sessions := storagemock.NewMockSessionRepository(ctrl)
+	apiTokens := storagemock.NewMockAPITokenRepository(ctrl)
+	auth := BuildAuthFunc(tm, sessions, apiTokens, sa, WithClientCertificates(certs, users))
+
+	// registered key maps to the user with full vault access
+	ctx, err := auth(certContext(userCert))
+	require.NoError(t, err)
+	p, _ := usercontext.ReadPrincipal(ctx)
+	assert.Equal(t, uid, p.UserID)
+	assert.False(t, p.Scoped())
+	assert.True(t, p.Allows(scope.Write, "prod/db", nil))
+
+	// service account certificate is limited by the grants
+	ctx, err = auth(certContext(saCert))
+	require.NoError(t, err)
+	p, _ = usercontext.ReadPrincipal(ctx)
+	assert.Equal(t, uid, p.UserID)
+	assert.Equal(t, said, p.ServiceAccountID)
+	assert.True(t, p.Scoped())
+	assert.True(t, p.Allows(scope.Read, "ci/deploy", nil))
+	assert.False(t, p.Allows(scope.Read, "prod/db", nil))
+	assert.False(t, p.AllowsAny(scope.Write))
+
+	// registered by someone else than the service account owner
+	_, err = auth(certContext(foreignCert))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// deleted service account
+	_, err = auth(certContext(saCert))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// signed by the trusted CA but not registered
+	_, err = auth(certContext(unknownCert))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// the subject names no account
+	_, err = auth(certContext(noSubjectCert))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// the subject names another account than the key is registered for
+	for _, c := range []*x509.Certificate{userKeyAsSACert, saKeyAsUserCert, otherUserCert} {
+		_, err = auth(certContext(c))
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	}
+
+	// no certificate and no token
+	_, err = auth(context.Background())
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// certificates are ignored unless enabled
+	_, err = BuildAuthFunc(tm, sessions, apiTokens, sa)(certContext(userCert))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
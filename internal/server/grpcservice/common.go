@@ -2,24 +2,81 @@ package grpcservice
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	grpczerolog "github.com/grpc-ecosystem/go-grpc-middleware/providers/zerolog/v2"
 	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/v2/auth"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"gophkeeper/internal/server/secrettype"
+	"gophkeeper/internal/server/storage"
+	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/grpcserver"
 	"gophkeeper/pkg/logger"
+	"gophkeeper/pkg/scope"
 	"gophkeeper/pkg/token"
 	"gophkeeper/pkg/usercontext"
+	"net"
+	"strings"
+	"time"
 )
 
-func BuildAuthFunc(tok token.Manager) grpcauth.AuthFunc {
+// AuthOption configures BuildAuthFunc
+type AuthOption func(*authConfig)
+
+type authConfig struct {
+	certificates storage.ClientCertificateRepository
+	users        storage.UserRepository
+}
+
+// WithClientCertificates accepts verified client certificates of calls without a bearer token,
+// when their subject names an account their public key is registered for
+func WithClientCertificates(certificates storage.ClientCertificateRepository, users storage.UserRepository) AuthOption {
+	return func(c *authConfig) {
+		c.certificates = certificates
+		c.users = users
+	}
+}
+
+// BuildAuthFunc accepts access tokens of unexpired sessions and API tokens not revoked yet,
+// or verified client certificates when enabled
+func BuildAuthFunc(
+	tok token.Manager,
+	sessions storage.SessionRepository,
+	apiTokens storage.APITokenRepository,
+	serviceAccounts storage.ServiceAccountRepository,
+	opts ...AuthOption,
+) grpcauth.AuthFunc {
+	var cfg authConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+
 	return func(ctx context.Context) (context.Context, error) {
 		mdt, err := grpcauth.AuthFromMD(ctx, "bearer")
+		if err == nil && mdt == "" {
+			err = status.Error(codes.Unauthenticated, "empty auth token")
+		}
 		if err != nil {
-			return nil, err
+			if cfg.certificates == nil {
+				return nil, err
+			}
+			cert := peerCertificate(ctx)
+			if cert == nil {
+				return nil, err
+			}
+			p, err := certificatePrincipal(ctx, cfg.certificates, cfg.users, serviceAccounts, cert)
+			if err != nil {
+				return nil, err
+			}
+			return usercontext.WritePrincipal(ctx, p), nil
 		}
 
 		uid, err := tok.Decode(mdt)
@@ -32,14 +89,199 @@ func BuildAuthFunc(tok token.Manager) grpcauth.AuthFunc {
 			return nil, status.Errorf(codes.Unauthenticated, "invalid auth token: %v", err)
 		}
 
-		ctx = usercontext.WriteUID(ctx, u)
-		return ctx, nil
+		var p usercontext.Principal
+		if st, ok := uid.(token.ScopedIdentity); ok && st.TokenID() != "" {
+			p, err = apiTokenPrincipal(ctx, apiTokens, serviceAccounts, u, st)
+		} else {
+			p, err = sessionPrincipal(ctx, sessions, u, uid)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return usercontext.WritePrincipal(ctx, p), nil
+	}
+}
+
+// sessionPrincipal checks the session of an access token, so access tokens can be revoked
+func sessionPrincipal(
+	ctx context.Context,
+	sessions storage.SessionRepository,
+	u uuid.UUID,
+	uid token.Identity,
+) (usercontext.Principal, error) {
+	sid, ok := uid.(token.SessionIdentity)
+	if !ok || sid.Session() == "" {
+		return usercontext.Principal{}, status.Error(codes.Unauthenticated, "invalid auth token: no session")
+	}
+	sessionID, err := uuid.Parse(sid.Session())
+	if err != nil {
+		return usercontext.Principal{}, status.Errorf(codes.Unauthenticated, "invalid auth token: %v", err)
+	}
+
+	session, err := sessions.Touch(ctx, sessionID, peerIP(ctx))
+	switch {
+	case err == nil && session.UserID == u:
+		// all is ok
+	case err == nil, errors.Is(err, apperr.ErrNotFound):
+		return usercontext.Principal{}, status.Error(codes.Unauthenticated, "session expired or revoked")
+	default:
+		return usercontext.Principal{}, status.Error(codes.Internal, err.Error())
+	}
+
+	return usercontext.Principal{
+		UserID:    u,
+		SessionID: sessionID,
+	}, nil
+}
+
+// apiTokenPrincipal checks an API token is not revoked, its scopes are taken from the signed claims,
+// grants of a service account are read on each call, so they can be narrowed any time
+func apiTokenPrincipal(
+	ctx context.Context,
+	apiTokens storage.APITokenRepository,
+	serviceAccounts storage.ServiceAccountRepository,
+	u uuid.UUID,
+	st token.ScopedIdentity,
+) (usercontext.Principal, error) {
+	tokenID, err := uuid.Parse(st.TokenID())
+	if err != nil {
+		return usercontext.Principal{}, status.Errorf(codes.Unauthenticated, "invalid auth token: %v", err)
+	}
+	scopes, err := scope.ParseSet(st.TokenScopes())
+	if err != nil {
+		return usercontext.Principal{}, status.Errorf(codes.Unauthenticated, "invalid auth token: %v", err)
+	}
+
+	m, err := apiTokens.Touch(ctx, tokenID, peerIP(ctx))
+	switch {
+	case err == nil && m.UserID == u:
+		// all is ok
+	case err == nil, errors.Is(err, apperr.ErrNotFound):
+		return usercontext.Principal{}, status.Error(codes.Unauthenticated, "api token expired or revoked")
+	default:
+		return usercontext.Principal{}, status.Error(codes.Internal, err.Error())
+	}
+
+	p := usercontext.Principal{
+		UserID:  u,
+		TokenID: tokenID,
+		Scopes:  scopes,
+	}
+	if m.ServiceAccountID == uuid.Nil {
+		return p, nil
+	}
+
+	sa, err := serviceAccounts.Read(ctx, m.ServiceAccountID)
+	switch {
+	case err == nil && sa.OwnerID == u:
+		// all is ok
+	case err == nil, errors.Is(err, apperr.ErrNotFound):
+		return usercontext.Principal{}, status.Error(codes.Unauthenticated, "service account deleted")
+	default:
+		return usercontext.Principal{}, status.Error(codes.Internal, err.Error())
+	}
+
+	p.ServiceAccountID = sa.ID
+	if p.Grants, err = parseGrants(sa.Grants); err != nil {
+		return usercontext.Principal{}, status.Error(codes.Internal, err.Error())
+	}
+
+	return p, nil
+}
+
+// peerIP is the client address without port, calls of the in-process gateway take it from
+// the last X-Forwarded-For entry, the one added by the gateway, others can be set by clients
+func peerIP(ctx context.Context) string {
+	if grpcserver.InProcessPeer(ctx) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		fwd := md.Get("x-forwarded-for")
+		if len(fwd) == 0 {
+			return ""
+		}
+		ips := strings.Split(fwd[len(fwd)-1], ",")
+		return strings.TrimSpace(ips[len(ips)-1])
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// invalidSecretError builds InvalidArgument status with field violations attached
+func invalidSecretError(vv []secrettype.Violation) error {
+	e := &apperr.Error{Message: "invalid secret: " + vv[0].String()}
+	for _, v := range vv {
+		e.Violations = append(e.Violations, apperr.FieldViolation{Field: v.Field, Description: v.Description})
+	}
+	return newStatus(codes.InvalidArgument, e)
+}
+
+// reasonError builds status with error info, so clients can tell what to do next
+func reasonError(c codes.Code, reason, description string) error {
+	return newStatus(c, &apperr.Error{Message: description, Reason: reason})
+}
+
+// scopeError builds PermissionDenied status for an API token lacking the action scope
+func scopeError(action string) error {
+	return newStatus(codes.PermissionDenied, &apperr.Error{
+		Message:  "api token scopes do not allow to " + action + " the secret",
+		Reason:   ReasonScopeDenied,
+		Metadata: map[string]string{"action": action},
+	})
+}
+
+// throttledError builds ResourceExhausted status with retry info attached
+func throttledError(wait time.Duration) error {
+	wait = wait.Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+
+	return newStatus(codes.ResourceExhausted, &apperr.Error{
+		Message:    fmt.Sprintf("too many attempts, retry in %s", wait),
+		Reason:     ReasonTooManyAttempts,
+		RetryAfter: wait,
+	})
+}
+
+// statusReason from error info attached to the status, if any
+func statusReason(err error) string {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
 	}
+	return ""
 }
 
+// BuildUnaryInterceptors of the server, errors are mapped to statuses before logging,
+// panics are recovered before mapping, so they are reported as internal errors
 func BuildUnaryInterceptors() []grpc.UnaryServerInterceptor {
 	return []grpc.UnaryServerInterceptor{
-		logging.UnaryServerInterceptor(grpczerolog.InterceptorLogger(logger.Global().Logger)),
+		logging.UnaryServerInterceptor(grpczerolog.InterceptorLogger(logger.Global().Logger), logging.WithDecider(logDecider)),
+		ErrorUnaryInterceptor(),
 		recovery.UnaryServerInterceptor(),
 	}
 }
+
+// BuildStreamInterceptors of the server in the same order as unary ones
+func BuildStreamInterceptors() []grpc.StreamServerInterceptor {
+	return []grpc.StreamServerInterceptor{
+		logging.StreamServerInterceptor(grpczerolog.InterceptorLogger(logger.Global().Logger), logging.WithDecider(logDecider)),
+		ErrorStreamInterceptor(),
+		recovery.StreamServerInterceptor(),
+	}
+}
+
+// logDecider skips successful health checks, load balancers call them every few seconds
+func logDecider(method string, err error) bool {
+	return err != nil || !strings.HasPrefix(method, "/grpc.health.v1.Health/")
+}
@@ -0,0 +1,229 @@
+package grpcservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/server/mailer"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/logger"
+	"net/mail"
+	"time"
+)
+
+const (
+	verifyEmailTokenLifetime   = time.Hour * 48
+	resetPasswordTokenLifetime = time.Hour
+	maxEmailLength             = 254
+	// mailTimeout of mails sent in background
+	mailTimeout = time.Minute
+)
+
+// SendVerificationEmail to the authenticated user, previously sent tokens stop working
+func (s User) SendVerificationEmail(
+	ctx context.Context,
+	_ *pb.SendVerificationEmailRequest,
+) (*pb.SendVerificationEmailResponse, error) {
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.mailer == nil {
+		return nil, status.Error(codes.Unimplemented, "mail is not configured")
+	}
+
+	u, err := s.users.Read(ctx, uid)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if u.EmailVerified {
+		return nil, status.Error(codes.FailedPrecondition, "email is already verified")
+	}
+
+	if err := s.sendVerificationEmail(ctx, u); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.SendVerificationEmailResponse{}, nil
+}
+
+// VerifyEmail with a token from the verification mail
+func (s User) VerifyEmail(ctx context.Context, request *pb.VerifyEmailRequest) (*pb.VerifyEmailResponse, error) {
+	uid, err := s.useToken(ctx, model.UserTokenVerifyEmail, request.GetToken())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.users.VerifyEmail(ctx, uid); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.VerifyEmailResponse{}, nil
+}
+
+// RequestPasswordReset mails a reset token, unknown emails are not revealed
+func (s User) RequestPasswordReset(
+	ctx context.Context,
+	request *pb.RequestPasswordResetRequest,
+) (*pb.RequestPasswordResetResponse, error) {
+	if s.mailer == nil {
+		return nil, status.Error(codes.Unimplemented, "mail is not configured")
+	}
+
+	if err := s.throttleReset(ctx, request.GetEmail(), peerIP(ctx)); err != nil {
+		return nil, err
+	}
+
+	u, err := s.users.ReadByEmail(ctx, request.GetEmail())
+	switch {
+	case err == nil:
+		// send the token
+	case errors.Is(err, apperr.ErrNotFound):
+		return &pb.RequestPasswordResetResponse{}, nil
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// the token is stored and mailed in background, so known emails are answered as fast as unknown ones
+	l := logger.Ctx(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(l.WithContext(context.Background()), mailTimeout)
+		defer cancel()
+
+		if err := s.sendResetPasswordEmail(ctx, u); err != nil {
+			l.Warn().Err(err).Msg("Password reset mail is not sent")
+		}
+	}()
+
+	return &pb.RequestPasswordResetResponse{}, nil
+}
+
+// ResetPassword with a token from the reset mail, all sessions are revoked
+func (s User) ResetPassword(ctx context.Context, request *pb.ResetPasswordRequest) (*pb.ResetPasswordResponse, error) {
+	nv := request.GetNewSrpVerifier()
+	if err := s.validNewCredentials(request.GetNewPassword(), nv); err != nil {
+		return nil, err
+	}
+
+	// the token is consumed before the update, so concurrent resets with the same token can not both succeed,
+	// a new reset has to be requested if the update fails
+	uid, err := s.useToken(ctx, model.UserTokenResetPassword, request.GetToken())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.updateCredentials(ctx, uid, request.GetNewPassword(), nv); err != nil {
+		return nil, err
+	}
+
+	// reset mail proves the email is owned by the user
+	if err := s.users.VerifyEmail(ctx, uid); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	n, err := s.sessions.DeleteOthers(ctx, uid, uuid.Nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.ResetPasswordResponse{
+		RevokedSessions: n,
+	}, nil
+}
+
+func (s User) sendResetPasswordEmail(ctx context.Context, u *model.User) error {
+	token, expiresAt, err := s.createUserToken(ctx, u.ID, model.UserTokenResetPassword, resetPasswordTokenLifetime)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Reset your gophkeeper password",
+		Body: fmt.Sprintf(`Someone requested a password reset for your gophkeeper account.
+To set a new password run:
+
+    gkcli auth reset %s
+
+The token expires at %s. If it was not you, ignore this mail, your password stays the same.
+Two-factor authentication, if enabled, is still required to log in.
+`, token, expiresAt.UTC().Format(time.RFC1123)),
+		Secrets: []string{token},
+	})
+}
+
+func (s User) sendVerificationEmail(ctx context.Context, u *model.User) error {
+	token, expiresAt, err := s.createUserToken(ctx, u.ID, model.UserTokenVerifyEmail, verifyEmailTokenLifetime)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Verify your gophkeeper email",
+		Body: fmt.Sprintf(`Welcome to gophkeeper! To verify your email run:
+
+    gkcli auth verify %s
+
+The token expires at %s. If you did not register, ignore this mail.
+`, token, expiresAt.UTC().Format(time.RFC1123)),
+		Secrets: []string{token},
+	})
+}
+
+// createUserToken returns a new token to be mailed and its expiration time
+func (s User) createUserToken(
+	ctx context.Context,
+	uid uuid.UUID,
+	purpose string,
+	lifetime time.Duration,
+) (string, time.Time, error) {
+	t, err := randomToken()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("generate token: %w", err)
+	}
+
+	m, err := s.userTokens.Create(ctx, &model.UserToken{
+		UserID:    uid,
+		Purpose:   purpose,
+		TokenHash: hashToken(t),
+		ExpiresAt: time.Now().Add(lifetime),
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("store token: %w", err)
+	}
+
+	return t, m.ExpiresAt, nil
+}
+
+// useToken consumes a mailed token, returns its user
+func (s User) useToken(ctx context.Context, purpose string, token string) (uuid.UUID, error) {
+	if token == "" {
+		return uuid.UUID{}, status.Error(codes.InvalidArgument, "empty token")
+	}
+
+	m, err := s.userTokens.Use(ctx, purpose, hashToken(token))
+	switch {
+	case err == nil:
+		return m.UserID, nil
+	case errors.Is(err, apperr.ErrNotFound):
+		return uuid.UUID{}, status.Error(codes.InvalidArgument, "invalid or expired token")
+	default:
+		return uuid.UUID{}, status.Error(codes.Internal, err.Error())
+	}
+}
+
+// validEmail accepts a bare address only, display names and comments are rejected
+func validEmail(email string) bool {
+	if email == "" || len(email) > maxEmailLength {
+		return false
+	}
+	a, err := mail.ParseAddress(email)
+	return err == nil && a.Address == email
+}
@@ -0,0 +1,237 @@
+package grpcservice
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/server/mailer"
+	"gophkeeper/internal/server/model"
+	storagemock "gophkeeper/internal/server/storage/mock"
+	"gophkeeper/pkg/apperr"
+	tokenmock "gophkeeper/pkg/token/mock"
+	"gophkeeper/pkg/usercontext"
+)
+
+// testMailer collects sent messages
+type testMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *testMailer) Send(_ context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+var mailedToken = regexp.MustCompile(`gkcli auth \w+ (\S+)`)
+
+// last sent token, waits for mails sent in background
+func (m *testMailer) token(t *testing.T) string {
+	require.Eventually(t, func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return len(m.sent) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	match := mailedToken.FindStringSubmatch(m.sent[len(m.sent)-1].Body)
+	require.Len(t, match, 2)
+	return match[1]
+}
+
+// expectUserTokens stores created tokens, so Use finds them by hash
+func expectUserTokens(ut *storagemock.MockUserTokenRepository) {
+	var mu sync.Mutex
+	tokens := map[string]*model.UserToken{}
+	ut.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, m *model.UserToken) (*model.UserToken, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			tokens[m.TokenHash] = m
+			return m, nil
+		},
+	).AnyTimes()
+	ut.EXPECT().Use(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, purpose, hash string) (*model.UserToken, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			m, ok := tokens[hash]
+			if !ok || m.Purpose != purpose {
+				return nil, apperr.ErrNotFound
+			}
+			delete(tokens, hash)
+			return m, nil
+		},
+	).AnyTimes()
+}
+
+func TestUser_VerifyEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid := uuid.New(), uuid.New()
+	ctx := usercontext.WriteSessionID(usercontext.WriteUID(context.Background(), uid), sid)
+
+	u := storagemock.NewMockUserRepository(ctrl)
+	gomock.InOrder(
+		u.EXPECT().Read(gomock.Any(), uid).Return(&model.User{ID: uid, Email: "user@example.org"}, nil),
+		u.EXPECT().VerifyEmail(gomock.Any(), uid).Return(nil),
+		u.EXPECT().Read(gomock.Any(), uid).Return(&model.User{ID: uid, Email: "user@example.org", EmailVerified: true}, nil),
+	)
+
+	ut := storagemock.NewMockUserTokenRepository(ctrl)
+	expectUserTokens(ut)
+
+	m := &testMailer{}
+	svc := NewUser(
+		u,
+		storagemock.NewMockSessionRepository(ctrl),
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		ut,
+		storagemock.NewMockAPITokenRepository(ctrl),
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		tokenmock.NewMockManager(ctrl),
+		WithMailer(m),
+	)
+
+	_, err := svc.SendVerificationEmail(ctx, &pb.SendVerificationEmailRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.org", m.sent[0].To)
+	token := m.token(t)
+
+	_, err = svc.VerifyEmail(context.Background(), &pb.VerifyEmailRequest{Token: "wrong"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = svc.VerifyEmail(context.Background(), &pb.VerifyEmailRequest{Token: token})
+	assert.NoError(t, err)
+
+	// single use
+	_, err = svc.VerifyEmail(context.Background(), &pb.VerifyEmailRequest{Token: token})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = svc.SendVerificationEmail(ctx, &pb.SendVerificationEmailRequest{})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestUser_ResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid := uuid.New()
+
+	u := storagemock.NewMockUserRepository(ctrl)
+	u.EXPECT().ReadByEmail(gomock.Any(), "missing@example.org").Return(nil, apperr.ErrNotFound)
+	u.EXPECT().ReadByEmail(gomock.Any(), "user@example.org").Return(&model.User{ID: uid, Email: "user@example.org"}, nil).Times(2)
+	gomock.InOrder(
+		u.EXPECT().UpdatePassword(gomock.Any(), uid, "new").Return(errors.New("connection refused")),
+		u.EXPECT().UpdatePassword(gomock.Any(), uid, "new").Return(nil),
+	)
+	u.EXPECT().VerifyEmail(gomock.Any(), uid).Return(nil)
+
+	ut := storagemock.NewMockUserTokenRepository(ctrl)
+	expectUserTokens(ut)
+
+	sr := storagemock.NewMockSessionRepository(ctrl)
+	sr.EXPECT().DeleteOthers(gomock.Any(), uid, uuid.Nil).Return(int64(2), nil)
+
+	m := &testMailer{}
+	svc := NewUser(
+		u,
+		sr,
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		ut,
+		storagemock.NewMockAPITokenRepository(ctrl),
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		tokenmock.NewMockManager(ctrl),
+		WithMailer(m),
+	)
+
+	// unknown email is not revealed
+	_, err := svc.RequestPasswordReset(context.Background(), &pb.RequestPasswordResetRequest{Email: "missing@example.org"})
+	assert.NoError(t, err)
+	assert.Empty(t, m.sent)
+
+	_, err = svc.RequestPasswordReset(context.Background(), &pb.RequestPasswordResetRequest{Email: "user@example.org"})
+	require.NoError(t, err)
+	token := m.token(t)
+	assert.Equal(t, []string{token}, m.sent[0].Secrets)
+
+	_, err = svc.ResetPassword(context.Background(), &pb.ResetPasswordRequest{Token: token})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// the token is consumed even if the update fails
+	_, err = svc.ResetPassword(context.Background(), &pb.ResetPasswordRequest{Token: token, NewPassword: "new"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	_, err = svc.ResetPassword(context.Background(), &pb.ResetPasswordRequest{Token: token, NewPassword: "new"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	m.mu.Lock()
+	m.sent = nil
+	m.mu.Unlock()
+	_, err = svc.RequestPasswordReset(context.Background(), &pb.RequestPasswordResetRequest{Email: "user@example.org"})
+	require.NoError(t, err)
+	token = m.token(t)
+
+	resp, err := svc.ResetPassword(context.Background(), &pb.ResetPasswordRequest{Token: token, NewPassword: "new"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), resp.GetRevokedSessions())
+
+	_, err = svc.ResetPassword(context.Background(), &pb.ResetPasswordRequest{Token: token, NewPassword: "new"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUser_EmailWithoutMailer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := NewUser(
+		storagemock.NewMockUserRepository(ctrl),
+		storagemock.NewMockSessionRepository(ctrl),
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		storagemock.NewMockUserTokenRepository(ctrl),
+		storagemock.NewMockAPITokenRepository(ctrl),
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		tokenmock.NewMockManager(ctrl),
+	)
+
+	_, err := svc.RequestPasswordReset(context.Background(), &pb.RequestPasswordResetRequest{Email: "user@example.org"})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func Test_validEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"user@example.org", true},
+		{"first.last+tag@sub.example.org", true},
+		{"", false},
+		{"user", false},
+		{"user@", false},
+		{"User <user@example.org>", false},
+		{" user@example.org", false},
+		{"user@example.org\r\nBcc: evil@example.org", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			assert.Equal(t, tt.want, validEmail(tt.email))
+		})
+	}
+}
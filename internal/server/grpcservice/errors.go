@@ -0,0 +1,147 @@
+package grpcservice
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/logger"
+)
+
+// ErrorDomain of ErrorInfo details attached to the statuses
+const ErrorDomain = "gophkeeper"
+
+// internalMessage replaces messages of internal errors, they may reveal storage details
+const internalMessage = "internal error"
+
+// ErrorUnaryInterceptor converts handler errors to statuses, see toStatus
+func ErrorUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, toStatus(ctx, info.FullMethod, err)
+		}
+		return resp, nil
+	}
+}
+
+// ErrorStreamInterceptor converts handler errors to statuses, see toStatus
+func ErrorStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := handler(srv, ss); err != nil {
+			return toStatus(ss.Context(), info.FullMethod, err)
+		}
+		return nil
+	}
+}
+
+// toStatus maps apperr sentinels to status codes with details of apperr.Error attached.
+// Statuses are kept as is, except internal ones, their messages are logged and hidden from clients.
+func toStatus(ctx context.Context, method string, err error) error {
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.Internal, codes.Unknown:
+			logInternal(ctx, method, err)
+			return status.Error(codes.Internal, internalMessage)
+		default:
+			return err
+		}
+	}
+
+	c := errorCode(err)
+	if c == codes.Internal {
+		logInternal(ctx, method, err)
+		return status.Error(codes.Internal, internalMessage)
+	}
+
+	var ae *apperr.Error
+	if !errors.As(err, &ae) {
+		ae = &apperr.Error{Message: err.Error()}
+	}
+
+	return newStatus(c, ae)
+}
+
+// errorCode of the sentinel wrapped by err
+func errorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, apperr.ErrUnauthorized):
+		return codes.Unauthenticated
+	case errors.Is(err, apperr.ErrForbidden):
+		return codes.PermissionDenied
+	// not found and conflicts wrap invalid input, so are checked first
+	case errors.Is(err, apperr.ErrNotFound):
+		return codes.NotFound
+	case errors.Is(err, apperr.ErrConflict), errors.Is(err, apperr.ErrSoftConflict):
+		return codes.AlreadyExists
+	case errors.Is(err, apperr.ErrExhausted):
+		return codes.ResourceExhausted
+	case errors.Is(err, apperr.ErrPrecondition):
+		return codes.FailedPrecondition
+	case errors.Is(err, apperr.ErrInvalidInput):
+		return codes.InvalidArgument
+	default:
+		return codes.Internal
+	}
+}
+
+// newStatus with the message and details of the error: ErrorInfo for the reason,
+// QuotaFailure or BadRequest for the violations, RetryInfo for the delay
+func newStatus(c codes.Code, e *apperr.Error) error {
+	st := status.New(c, e.Error())
+	// details failing to marshal are skipped, the status is still valid
+	if e.Reason != "" {
+		if ds, err := st.WithDetails(&errdetails.ErrorInfo{
+			Reason:   e.Reason,
+			Domain:   ErrorDomain,
+			Metadata: e.Metadata,
+		}); err == nil {
+			st = ds
+		}
+	}
+	if len(e.Violations) > 0 && c == codes.ResourceExhausted {
+		qf := &errdetails.QuotaFailure{}
+		for _, v := range e.Violations {
+			qf.Violations = append(qf.Violations, &errdetails.QuotaFailure_Violation{
+				Subject:     v.Field,
+				Description: v.Description,
+			})
+		}
+		if ds, err := st.WithDetails(qf); err == nil {
+			st = ds
+		}
+	} else if len(e.Violations) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, v := range e.Violations {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Description,
+			})
+		}
+		if ds, err := st.WithDetails(br); err == nil {
+			st = ds
+		}
+	}
+	if e.RetryAfter > 0 {
+		if ds, err := st.WithDetails(&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(e.RetryAfter),
+		}); err == nil {
+			st = ds
+		}
+	}
+
+	return st.Err()
+}
+
+func logInternal(ctx context.Context, method string, err error) {
+	l := logger.Ctx(ctx)
+	l.Error().Err(err).Str("grpc.method", method).Msg("internal error")
+}
@@ -0,0 +1,95 @@
+package grpcservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/pkg/apperr"
+	"testing"
+	"time"
+)
+
+func TestErrorUnaryInterceptor(t *testing.T) {
+	interceptor := ErrorUnaryInterceptor()
+	call := func(err error) error {
+		_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test"},
+			func(context.Context, interface{}) (interface{}, error) {
+				return nil, err
+			},
+		)
+		return err
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		code codes.Code
+		msg  string
+	}{
+		{"not found", fmt.Errorf("read: %w", apperr.ErrNotFound), codes.NotFound, "read: not found: invalid input"},
+		{"conflict", apperr.ErrConflict, codes.AlreadyExists, "conflict: invalid input"},
+		{"invalid input", apperr.ErrInvalidInput, codes.InvalidArgument, "invalid input"},
+		{"unauthorized", apperr.ErrUnauthorized, codes.Unauthenticated, "unauthorized"},
+		{"forbidden", apperr.ErrForbidden, codes.PermissionDenied, "forbidden"},
+		{"precondition", apperr.ErrPrecondition, codes.FailedPrecondition, "failed precondition"},
+		{"deadline", context.DeadlineExceeded, codes.DeadlineExceeded, "context deadline exceeded"},
+		{"status", status.Error(codes.NotFound, "gone"), codes.NotFound, "gone"},
+		{"unknown error", errors.New("pq: connection refused"), codes.Internal, internalMessage},
+		{"internal status", status.Error(codes.Internal, "pq: connection refused"), codes.Internal, internalMessage},
+		{"empty error", &apperr.Error{}, codes.Internal, internalMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := status.Convert(call(tt.err))
+			assert.Equal(t, tt.code, st.Code())
+			assert.Equal(t, tt.msg, st.Message())
+		})
+	}
+
+	assert.NoError(t, call(nil))
+}
+
+func TestNewStatus(t *testing.T) {
+	err := newStatus(codes.InvalidArgument, &apperr.Error{
+		Err:        apperr.ErrInvalidInput,
+		Reason:     "INVALID_NAME",
+		Metadata:   map[string]string{"name": "a/"},
+		Violations: []apperr.FieldViolation{{Field: "name", Description: "must not end with /"}},
+		RetryAfter: time.Second,
+	})
+
+	st := status.Convert(err)
+	assert.Equal(t, "invalid input", st.Message())
+	require.Len(t, st.Details(), 3)
+
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	require.True(t, ok)
+	assert.Equal(t, "INVALID_NAME", info.GetReason())
+	assert.Equal(t, ErrorDomain, info.GetDomain())
+	assert.Equal(t, "a/", info.GetMetadata()["name"])
+
+	br, ok := st.Details()[1].(*errdetails.BadRequest)
+	require.True(t, ok)
+	assert.Equal(t, "name", br.GetFieldViolations()[0].GetField())
+
+	ri, ok := st.Details()[2].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.Equal(t, time.Second, ri.GetRetryDelay().AsDuration())
+
+	// violations of exhausted resources are quota failures
+	uid := uuid.New()
+	st = status.Convert(toStatus(context.Background(), "/test", model.QuotaError(uid, "limit reached")))
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	require.Len(t, st.Details(), 1)
+	qf, ok := st.Details()[0].(*errdetails.QuotaFailure)
+	require.True(t, ok)
+	assert.Equal(t, "user:"+uid.String(), qf.GetViolations()[0].GetSubject())
+}
@@ -0,0 +1,165 @@
+package grpcservice
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"gophkeeper/pkg/logger"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthInterval = 10 * time.Second
+	defaultHealthTimeout  = 5 * time.Second
+)
+
+// HealthCheck of a dependency, fails with an error when it is unavailable
+type HealthCheck func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// Health serves grpc.health.v1, the server and its services are serving while all checks pass.
+// Checks run periodically between Start and Stop, calls are answered from the last results.
+type Health struct {
+	server   *health.Server
+	checks   []namedCheck
+	services []string
+	interval time.Duration
+	timeout  time.Duration
+	logger   *logger.Logger
+
+	mu      sync.Mutex
+	failing error
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type HealthOption func(*Health)
+
+// WithHealthCheck adds a check, the name is logged with its errors
+func WithHealthCheck(name string, c HealthCheck) HealthOption {
+	return func(h *Health) {
+		h.checks = append(h.checks, namedCheck{name: name, check: c})
+	}
+}
+
+// WithHealthServices reports the status for the service names too, not only the whole server
+func WithHealthServices(names ...string) HealthOption {
+	return func(h *Health) {
+		h.services = append(h.services, names...)
+	}
+}
+
+// WithHealthInterval between the checks
+func WithHealthInterval(d time.Duration) HealthOption {
+	return func(h *Health) {
+		h.interval = d
+	}
+}
+
+func NewHealth(opts ...HealthOption) *Health {
+	h := &Health{
+		server:   health.NewServer(),
+		interval: defaultHealthInterval,
+		timeout:  defaultHealthTimeout,
+		logger:   logger.Global(),
+	}
+
+	for _, o := range opts {
+		o(h)
+	}
+
+	h.set(healthgrpc.HealthCheckResponse_NOT_SERVING)
+
+	return h
+}
+
+func (h *Health) RegisterService(r grpc.ServiceRegistrar) {
+	healthgrpc.RegisterHealthServer(r, healthServer{h.server})
+}
+
+// Check runs the checks and updates the status, the error is of the first failed check
+func (h *Health) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	var err error
+	for _, c := range h.checks {
+		if err = c.check(ctx); err != nil {
+			err = fmt.Errorf("%s: %w", c.name, err)
+			break
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch {
+	case err != nil && h.failing == nil:
+		h.logger.Warn().Err(err).Msg("Health check failed, not serving")
+	case err == nil && h.failing != nil:
+		h.logger.Info().Msg("Health checks passed, serving")
+	}
+	h.failing = err
+
+	if err != nil {
+		h.set(healthgrpc.HealthCheckResponse_NOT_SERVING)
+	} else {
+		h.set(healthgrpc.HealthCheckResponse_SERVING)
+	}
+
+	return err
+}
+
+// Start checks once and then periodically until Stop
+func (h *Health) Start() {
+	_ = h.Check(context.Background())
+
+	h.stop = make(chan struct{})
+	h.done = make(chan struct{})
+	go func() {
+		defer close(h.done)
+
+		t := time.NewTicker(h.interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-h.stop:
+				return
+			case <-t.C:
+				_ = h.Check(context.Background())
+			}
+		}
+	}()
+}
+
+// Stop the checks and report not serving, so load balancers drain the server before it stops
+func (h *Health) Stop() {
+	if h.stop != nil {
+		close(h.stop)
+		<-h.done
+	}
+	h.server.Shutdown()
+}
+
+func (h *Health) set(st healthgrpc.HealthCheckResponse_ServingStatus) {
+	h.server.SetServingStatus("", st)
+	for _, s := range h.services {
+		h.server.SetServingStatus(s, st)
+	}
+}
+
+// healthServer skips auth, load balancers have no tokens
+type healthServer struct {
+	*health.Server
+}
+
+func (healthServer) AuthFuncOverride(ctx context.Context, _ string) (context.Context, error) {
+	return ctx, nil
+}
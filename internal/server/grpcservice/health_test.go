@@ -0,0 +1,100 @@
+package grpcservice
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/pkg/grpcserver"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	ctx := context.Background()
+
+	var dbDown atomic.Value
+	dbDown.Store(false)
+	h := NewHealth(
+		WithHealthCheck("db", func(context.Context) error {
+			if dbDown.Load().(bool) {
+				return errors.New("connection refused")
+			}
+			return nil
+		}),
+		WithHealthServices(pb.Keeper_ServiceDesc.ServiceName),
+	)
+
+	// every call needs auth except the public ones
+	s := grpcserver.New(
+		grpcserver.WithListenAddr("localhost:0"),
+		grpcserver.WithServices(h, NewInfo(FeatureSRP, FeatureReflection), NewReflection()),
+		grpcserver.WithAuthFunc(func(ctx context.Context) (context.Context, error) {
+			return nil, status.Error(codes.Unauthenticated, "no token")
+		}),
+	)
+	require.NoError(t, s.Start())
+	defer s.Stop()
+
+	conn, err := s.DialInProcess(ctx)
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	cl := healthgrpc.NewHealthClient(conn)
+
+	check := func(service string) healthgrpc.HealthCheckResponse_ServingStatus {
+		resp, err := cl.Check(ctx, &healthgrpc.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		return resp.GetStatus()
+	}
+
+	// not serving until checked
+	assert.Equal(t, healthgrpc.HealthCheckResponse_NOT_SERVING, check(""))
+
+	h.Start()
+	assert.Equal(t, healthgrpc.HealthCheckResponse_SERVING, check(""))
+	assert.Equal(t, healthgrpc.HealthCheckResponse_SERVING, check(pb.Keeper_ServiceDesc.ServiceName))
+
+	dbDown.Store(true)
+	assert.EqualError(t, h.Check(ctx), "db: connection refused")
+	assert.Equal(t, healthgrpc.HealthCheckResponse_NOT_SERVING, check(""))
+	assert.Equal(t, healthgrpc.HealthCheckResponse_NOT_SERVING, check(pb.Keeper_ServiceDesc.ServiceName))
+
+	dbDown.Store(false)
+	assert.NoError(t, h.Check(ctx))
+	assert.Equal(t, healthgrpc.HealthCheckResponse_SERVING, check(""))
+
+	_, err = cl.Check(ctx, &healthgrpc.HealthCheckRequest{Service: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// stopping servers drain first
+	h.Stop()
+	assert.Equal(t, healthgrpc.HealthCheckResponse_NOT_SERVING, check(""))
+
+	info, err := pb.NewInfoClient(conn).ServerInfo(ctx, &pb.ServerInfoRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{FeatureSRP, FeatureReflection}, info.GetFeatures())
+
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	var services []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		services = append(services, s.GetName())
+	}
+	assert.ElementsMatch(t, []string{
+		healthgrpc.Health_ServiceDesc.ServiceName,
+		pb.Info_ServiceDesc.ServiceName,
+		rpb.ServerReflection_ServiceDesc.ServiceName,
+	}, services)
+}
@@ -0,0 +1,57 @@
+package grpcservice
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/pkg/version"
+)
+
+// Features reported by ServerInfo, clients check them before using optional calls
+const (
+	FeatureTwoFactor          = "two_factor"
+	FeatureSRP                = "srp"
+	FeatureSRPOnly            = "srp_only"
+	FeatureAPITokens          = "api_tokens"
+	FeatureServiceAccounts    = "service_accounts"
+	FeatureEmailVerification  = "email_verification"
+	FeatureThrottle           = "throttle"
+	FeatureTLS                = "tls"
+	FeatureClientCertificates = "client_certificates"
+	FeatureE2E                = "e2e"
+	FeatureGateway            = "gateway"
+	FeatureReflection         = "reflection"
+)
+
+// Info serves the server version and features, calls need no auth
+type Info struct {
+	pb.UnimplementedInfoServer
+
+	features []string
+}
+
+func NewInfo(features ...string) *Info {
+	return &Info{
+		features: features,
+	}
+}
+
+func (s *Info) RegisterService(r grpc.ServiceRegistrar) {
+	pb.RegisterInfoServer(r, s)
+}
+
+// AuthFuncOverride skips auth, so clients can adapt before logging in
+func (s *Info) AuthFuncOverride(ctx context.Context, _ string) (context.Context, error) {
+	return ctx, nil
+}
+
+func (s *Info) ServerInfo(context.Context, *pb.ServerInfoRequest) (*pb.ServerInfoResponse, error) {
+	return &pb.ServerInfoResponse{
+		Version:   version.Version,
+		Branch:    version.Branch,
+		Revision:  version.Revision,
+		BuildDate: version.BuildDate,
+		GoVersion: version.GoVersion,
+		Features:  s.features,
+	}, nil
+}
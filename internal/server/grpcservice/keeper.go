@@ -3,26 +3,57 @@ package grpcservice
 import (
 	"context"
 	"errors"
+	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	pb "gophkeeper/api/proto"
 	"gophkeeper/internal/server/model"
+	"gophkeeper/internal/server/secrettype"
 	"gophkeeper/internal/server/storage"
 	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/scope"
 	"gophkeeper/pkg/usercontext"
 )
 
+// Keeper serves secrets of the user, repository errors are returned as is
+// and mapped to statuses by ErrorUnaryInterceptor
 type Keeper struct {
 	pb.UnimplementedKeeperServer
 
 	secrets storage.SecretRepository
+	types   *secrettype.Registry
+	quota   model.Quota
 }
 
-func NewKeeper(s storage.SecretRepository) *Keeper {
-	return &Keeper{
+type KeeperOption func(*Keeper)
+
+// WithTypeRegistry overrides default registry of the secret types
+func WithTypeRegistry(r *secrettype.Registry) KeeperOption {
+	return func(k *Keeper) {
+		k.types = r
+	}
+}
+
+// WithQuota limits storage usage per user, it is enforced by the repository on create
+func WithQuota(q model.Quota) KeeperOption {
+	return func(k *Keeper) {
+		k.quota = q
+	}
+}
+
+func NewKeeper(s storage.SecretRepository, opts ...KeeperOption) *Keeper {
+	k := &Keeper{
 		secrets: s,
+		types:   secrettype.NewRegistry(),
+	}
+
+	for _, o := range opts {
+		o(k)
 	}
+
+	return k
 }
 
 func (s *Keeper) RegisterService(r grpc.ServiceRegistrar) {
@@ -35,17 +66,28 @@ func (s *Keeper) CreateSecret(ctx context.Context, request *pb.CreateSecretReque
 		return nil, status.Error(codes.Unauthenticated, apperr.ErrUnauthorized.Error())
 	}
 
+	tags, err := scope.NormalizeTags(request.GetTags())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := authorize(ctx, scope.Write, request.GetName(), tags); err != nil {
+		return nil, err
+	}
+
+	if vv := s.types.Validate(request.GetName(), request.GetType(), request.GetContent()); len(vv) > 0 {
+		return nil, invalidSecretError(vv)
+	}
+
 	m := &model.Secret{
 		UserID:  uid.UUID,
 		Name:    request.GetName(),
 		Type:    request.GetType(),
 		Content: request.GetContent(),
+		Tags:    tags,
 	}
-	if m, err := s.secrets.Create(ctx, uid.UUID, m); err != nil {
-		if errors.Is(err, apperr.ErrConflict) {
-			return nil, status.Error(codes.AlreadyExists, err.Error())
-		}
-		return nil, status.Error(codes.Internal, err.Error())
+	if m, err := s.secrets.Create(ctx, uid.UUID, m, s.quota); err != nil {
+		return nil, err
 	} else {
 		return &pb.CreateSecretResponse{
 			Name: m.Name,
@@ -54,37 +96,110 @@ func (s *Keeper) CreateSecret(ctx context.Context, request *pb.CreateSecretReque
 	}
 }
 
-func (s *Keeper) ReadSecret(ctx context.Context, request *pb.ReadSecretRequest) (*pb.ReadSecretResponse, error) {
+// UpdateSecret replaces the secret in place, so a failed update keeps the previous version
+func (s *Keeper) UpdateSecret(ctx context.Context, request *pb.UpdateSecretRequest) (*pb.UpdateSecretResponse, error) {
 	uid := usercontext.ReadUID(ctx)
 	if !uid.Valid {
 		return nil, status.Error(codes.Unauthenticated, apperr.ErrUnauthorized.Error())
 	}
 
-	if m, err := s.secrets.ReadByName(ctx, uid.UUID, request.GetName()); err != nil {
-		if errors.Is(err, apperr.ErrNotFound) {
-			return nil, status.Error(codes.NotFound, err.Error())
+	name := request.GetNewName()
+	if name == "" {
+		name = request.GetName()
+	}
+
+	// nil tags are kept
+	var tags []string
+	if request.GetTags() != nil {
+		var err error
+		if tags, err = scope.NormalizeTags(request.GetTags().GetTags()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	p, err := readPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.authorizeSecret(ctx, uid.UUID, scope.Write, request.GetName())
+	if err != nil {
+		return nil, err
+	}
+	// the renamed or retagged secret has to stay in scopes, kept tags are read to check it
+	if !p.Allows(scope.Write, name, tags) {
+		if tags != nil || !p.Tagged(scope.Write) {
+			return nil, scopeError(scope.Write)
+		}
+		if current == nil {
+			if current, err = s.secrets.ReadByName(ctx, uid.UUID, request.GetName()); err != nil {
+				return nil, err
+			}
 		}
-		return nil, status.Error(codes.Internal, err.Error())
+		if !p.Allows(scope.Write, name, current.Tags) {
+			return nil, scopeError(scope.Write)
+		}
+	}
+
+	if vv := s.types.Validate(name, request.GetType(), request.GetContent()); len(vv) > 0 {
+		return nil, invalidSecretError(vv)
+	}
+
+	m := &model.Secret{
+		UserID:  uid.UUID,
+		Name:    name,
+		Type:    request.GetType(),
+		Content: request.GetContent(),
+		Tags:    tags,
+	}
+	if m, err := s.secrets.Update(ctx, uid.UUID, request.GetName(), m, s.quota); err != nil {
+		return nil, err
 	} else {
-		return &pb.ReadSecretResponse{
-			Name:    m.Name,
-			Type:    m.Type,
-			Content: m.Content,
+		return &pb.UpdateSecretResponse{
+			Name: m.Name,
+			Type: m.Type,
 		}, nil
 	}
 }
 
+func (s *Keeper) ReadSecret(ctx context.Context, request *pb.ReadSecretRequest) (*pb.ReadSecretResponse, error) {
+	uid := usercontext.ReadUID(ctx)
+	if !uid.Valid {
+		return nil, status.Error(codes.Unauthenticated, apperr.ErrUnauthorized.Error())
+	}
+
+	m, err := s.authorizeSecret(ctx, uid.UUID, scope.Read, request.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	if m == nil {
+		if m, err = s.secrets.ReadByName(ctx, uid.UUID, request.GetName()); err != nil {
+			return nil, err
+		}
+	}
+
+	return &pb.ReadSecretResponse{
+		Name:      m.Name,
+		Type:      m.Type,
+		Content:   m.Content,
+		CreatedAt: timestamppb.New(m.CreatedAt),
+		UpdatedAt: timestamppb.New(m.UpdatedAt),
+		Tags:      m.Tags,
+	}, nil
+}
+
 func (s *Keeper) DeleteSecret(ctx context.Context, request *pb.DeleteSecretRequest) (*pb.DeleteSecretResponse, error) {
 	uid := usercontext.ReadUID(ctx)
 	if !uid.Valid {
 		return nil, status.Error(codes.Unauthenticated, apperr.ErrUnauthorized.Error())
 	}
 
+	if _, err := s.authorizeSecret(ctx, uid.UUID, scope.Write, request.GetName()); err != nil {
+		return nil, err
+	}
+
 	if err := s.secrets.DeleteByName(ctx, uid.UUID, request.GetName()); err != nil {
-		if errors.Is(err, apperr.ErrNotFound) {
-			return nil, status.Error(codes.NotFound, err.Error())
-		}
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, err
 	} else {
 		return &pb.DeleteSecretResponse{}, nil
 	}
@@ -96,18 +211,99 @@ func (s *Keeper) ListSecrets(ctx context.Context, request *pb.ListSecretsRequest
 		return nil, status.Error(codes.Unauthenticated, apperr.ErrUnauthorized.Error())
 	}
 
+	p, err := readPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !p.AllowsAny(scope.Read) {
+		return nil, scopeError(scope.Read)
+	}
+
 	mm, err := s.secrets.List(ctx, uid.UUID)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, err
 	}
 
 	resp := &pb.ListSecretsResponse{}
 	for _, m := range mm {
+		// secrets out of API token scopes are not revealed
+		if !p.Allows(scope.Read, m.Name, m.Tags) {
+			continue
+		}
 		resp.Secrets = append(resp.Secrets, &pb.SecretDescription{
-			Name: m.Name,
-			Type: m.Type,
+			Name:      m.Name,
+			Type:      m.Type,
+			CreatedAt: timestamppb.New(m.CreatedAt),
+			UpdatedAt: timestamppb.New(m.UpdatedAt),
+			Tags:      m.Tags,
 		})
 	}
 
 	return resp, nil
 }
+
+func (s *Keeper) GetUsage(ctx context.Context, request *pb.GetUsageRequest) (*pb.GetUsageResponse, error) {
+	uid := usercontext.ReadUID(ctx)
+	if !uid.Valid {
+		return nil, status.Error(codes.Unauthenticated, apperr.ErrUnauthorized.Error())
+	}
+
+	p, err := readPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !p.AllowsAny(scope.Read) {
+		return nil, scopeError(scope.Read)
+	}
+
+	u, err := s.secrets.Usage(ctx, uid.UUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetUsageResponse{
+		Secrets:       u.Secrets,
+		TotalSize:     u.TotalSize,
+		MaxSecrets:    s.quota.MaxSecrets,
+		MaxTotalSize:  s.quota.MaxTotalSize,
+		MaxSecretSize: int64(s.types.Limit()),
+	}, nil
+}
+
+// authorizeSecret action on the stored secret, its tags are read only if a tag scope may allow it,
+// the read secret is returned then. A missing secret out of scopes is reported as forbidden,
+// so its existence is not revealed.
+func (s *Keeper) authorizeSecret(ctx context.Context, uid uuid.UUID, action, name string) (*model.Secret, error) {
+	p, err := readPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.Allows(action, name, nil) {
+		return nil, nil
+	}
+	if !p.Tagged(action) {
+		return nil, scopeError(action)
+	}
+
+	m, err := s.secrets.ReadByName(ctx, uid, name)
+	switch {
+	case err == nil && p.Allows(action, name, m.Tags):
+		return m, nil
+	case err == nil, errors.Is(err, apperr.ErrNotFound):
+		return nil, scopeError(action)
+	default:
+		return nil, err
+	}
+}
+
+// authorize action on the secret with the name and tags, API tokens are limited to their scopes while sessions are not
+func authorize(ctx context.Context, action, name string, tags []string) error {
+	p, err := readPrincipal(ctx)
+	if err != nil {
+		return err
+	}
+	if !p.Allows(action, name, tags) {
+		return scopeError(action)
+	}
+	return nil
+}
@@ -4,14 +4,22 @@ import (
 	"context"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
+	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/v2/auth"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	pb "gophkeeper/api/proto"
 	"gophkeeper/internal/server/model"
+	"gophkeeper/internal/server/secrettype"
 	"gophkeeper/internal/server/storage"
 	storagemock "gophkeeper/internal/server/storage/mock"
+	"gophkeeper/pkg/apperr"
 	"gophkeeper/pkg/grpcserver"
+	"gophkeeper/pkg/scope"
 	"gophkeeper/pkg/usercontext"
 	"log"
 	"testing"
@@ -41,6 +49,34 @@ func TestIntegrationKeeper_Create(t *testing.T) {
 	t.Log("Done integration testing")
 }
 
+func TestIntegrationKeeper_CreateInvalid(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cl, stop := getTestClient(t, ctrl)
+	defer stop()
+
+	_, err := cl.CreateSecret(ctx, &pb.CreateSecretRequest{
+		Name:    "",
+		Type:    "lp",
+		Content: []byte(`{"login":"user"}`),
+	})
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	if assert.Len(t, st.Details(), 1) {
+		br, ok := st.Details()[0].(*errdetails.BadRequest)
+		if assert.True(t, ok) {
+			require.Len(t, br.GetFieldViolations(), 2)
+			assert.Equal(t, "name", br.GetFieldViolations()[0].GetField())
+			assert.Equal(t, "content.password", br.GetFieldViolations()[1].GetField())
+		}
+	}
+
+	t.Log("Done integration testing")
+}
+
 func TestIntegrationKeeper_ReadByName(t *testing.T) {
 	ctx := context.Background()
 
@@ -60,6 +96,38 @@ func TestIntegrationKeeper_ReadByName(t *testing.T) {
 	t.Log("Done integration testing")
 }
 
+func TestIntegrationKeeper_Update(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cl, stop := getTestClient(t, ctrl)
+	defer stop()
+
+	resp, err := cl.UpdateSecret(ctx, &pb.UpdateSecretRequest{
+		Name:    "secret1",
+		NewName: "secret2",
+		Type:    "raw",
+		Content: []byte("keepitsecret"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "secret2", resp.GetName())
+
+	_, err = cl.UpdateSecret(ctx, &pb.UpdateSecretRequest{
+		Name:    "missing",
+		Type:    "raw",
+		Content: []byte("keepitsecret"),
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = cl.UpdateSecret(ctx, &pb.UpdateSecretRequest{
+		Name: "secret1",
+		Type: "lp",
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
 func TestIntegrationKeeper_DeleteByName(t *testing.T) {
 	ctx := context.Background()
 
@@ -95,14 +163,254 @@ func TestIntegrationKeeper_List(t *testing.T) {
 	t.Log("Done integration testing")
 }
 
-func getTestClient(t *testing.T, ctrl *gomock.Controller) (pb.KeeperClient, func()) {
+func TestIntegrationKeeper_Quota(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name     string
+		quota    model.Quota
+		wantCode codes.Code
+	}{
+		{
+			name:     "unlimited",
+			quota:    model.Quota{},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "within limits",
+			quota:    model.Quota{MaxSecrets: 2, MaxTotalSize: 24},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "secrets count exceeded",
+			quota:    model.Quota{MaxSecrets: 1},
+			wantCode: codes.ResourceExhausted,
+		},
+		{
+			name:     "total size exceeded",
+			quota:    model.Quota{MaxTotalSize: 23},
+			wantCode: codes.ResourceExhausted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl, stop := getTestClient(t, ctrl, WithQuota(tt.quota))
+			defer stop()
+
+			_, err := cl.CreateSecret(ctx, &pb.CreateSecretRequest{
+				Name:    "secret1",
+				Type:    "raw",
+				Content: []byte("keepitsecret"),
+			})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+func TestIntegrationKeeper_GetUsage(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cl, stop := getTestClient(t, ctrl, WithQuota(model.Quota{MaxSecrets: 10}))
+	defer stop()
+
+	resp, err := cl.GetUsage(ctx, &pb.GetUsageRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), resp.GetSecrets())
+	assert.Equal(t, int64(12), resp.GetTotalSize())
+	assert.Equal(t, int64(10), resp.GetMaxSecrets())
+	assert.Equal(t, int64(0), resp.GetMaxTotalSize())
+	assert.Equal(t, int64(secrettype.DefaultMaxSize), resp.GetMaxSecretSize())
+
+	t.Log("Done integration testing")
+}
+
+func TestIntegrationKeeper_Scopes(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	scopes, err := scope.ParseSet([]string{"read:secret", "write:ci/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := func(ctx context.Context) (context.Context, error) {
+		return usercontext.WritePrincipal(ctx, usercontext.Principal{
+			UserID:  okUserID,
+			TokenID: uuid.New(),
+			Scopes:  scopes,
+		}), nil
+	}
+
+	cl, stop := getTestClientWithAuth(t, ctrl, auth)
+	defer stop()
+
+	_, err = cl.ReadSecret(ctx, &pb.ReadSecretRequest{Name: "secret1"})
+	assert.NoError(t, err)
+
+	list, err := cl.ListSecrets(ctx, &pb.ListSecretsRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, list.GetSecrets(), 1)
+
+	_, err = cl.CreateSecret(ctx, &pb.CreateSecretRequest{
+		Name:    "secret1",
+		Type:    "raw",
+		Content: []byte("keepitsecret"),
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, ReasonScopeDenied, statusReason(err))
+
+	_, err = cl.DeleteSecret(ctx, &pb.DeleteSecretRequest{Name: "secret1"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// secrets out of read scopes are hidden
+	scopes, err = scope.ParseSet([]string{"read:ci/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err = cl.ListSecrets(ctx, &pb.ListSecretsRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, list.GetSecrets())
+
+	_, err = cl.ReadSecret(ctx, &pb.ReadSecretRequest{Name: "secret1"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// write only token can not read anything
+	scopes, err = scope.ParseSet([]string{"write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cl.ListSecrets(ctx, &pb.ListSecretsRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = cl.GetUsage(ctx, &pb.GetUsageRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestKeeper_TagScopes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	scopes, err := scope.ParseSet([]string{"read#ci", "write#ci"})
+	require.NoError(t, err)
+	ctx := usercontext.WritePrincipal(context.Background(), usercontext.Principal{
+		UserID:  okUserID,
+		TokenID: uuid.New(),
+		Scopes:  scopes,
+	})
+
+	tagged := &model.Secret{Name: "deploy", Type: "raw", Content: []byte("key"), Tags: []string{"ci"}}
+	other := &model.Secret{Name: "db", Type: "raw", Content: []byte("password")}
+
+	secrets := storagemock.NewMockSecretRepository(ctrl)
+	secrets.EXPECT().ReadByName(gomock.Any(), okUserID, "deploy").Return(tagged, nil).AnyTimes()
+	secrets.EXPECT().ReadByName(gomock.Any(), okUserID, "db").Return(other, nil).AnyTimes()
+	secrets.EXPECT().ReadByName(gomock.Any(), okUserID, "missing").Return(nil, apperr.ErrNotFound).AnyTimes()
+	secrets.EXPECT().List(gomock.Any(), okUserID).Return([]*model.Secret{other, tagged}, nil)
+	secrets.EXPECT().Create(gomock.Any(), okUserID, gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ uuid.UUID, m *model.Secret, _ model.Quota) (*model.Secret, error) {
+			assert.Equal(t, []string{"ci", "new"}, m.Tags)
+			return m, nil
+		},
+	)
+	// kept tags are checked for the renamed secret
+	secrets.EXPECT().Update(gomock.Any(), okUserID, "deploy", gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ uuid.UUID, _ string, m *model.Secret, _ model.Quota) (*model.Secret, error) {
+			assert.Nil(t, m.Tags)
+			return m, nil
+		},
+	)
+	secrets.EXPECT().DeleteByName(gomock.Any(), okUserID, "deploy").Return(nil)
+
+	k := NewKeeper(secrets)
+
+	read, err := k.ReadSecret(ctx, &pb.ReadSecretRequest{Name: "deploy"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ci"}, read.GetTags())
+
+	_, err = k.ReadSecret(ctx, &pb.ReadSecretRequest{Name: "db"})
+	assert.Equal(t, ReasonScopeDenied, statusReason(err))
+
+	// a missing secret is not revealed
+	_, err = k.ReadSecret(ctx, &pb.ReadSecretRequest{Name: "missing"})
+	assert.Equal(t, ReasonScopeDenied, statusReason(err))
+
+	list, err := k.ListSecrets(ctx, &pb.ListSecretsRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.GetSecrets(), 1)
+	assert.Equal(t, "deploy", list.GetSecrets()[0].GetName())
+
+	_, err = k.CreateSecret(ctx, &pb.CreateSecretRequest{Name: "untagged", Type: "raw", Content: []byte("x")})
+	assert.Equal(t, ReasonScopeDenied, statusReason(err))
+	_, err = k.CreateSecret(ctx, &pb.CreateSecretRequest{Name: "x", Type: "raw", Content: []byte("x"), Tags: []string{"a b"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = k.CreateSecret(ctx, &pb.CreateSecretRequest{Name: "x", Type: "raw", Content: []byte("x"), Tags: []string{"new", "ci", "new"}})
+	assert.NoError(t, err)
+
+	// the secret can not be moved out of scopes
+	_, err = k.UpdateSecret(ctx, &pb.UpdateSecretRequest{Name: "deploy", Type: "raw", Content: []byte("x"), Tags: &pb.SecretTags{}})
+	assert.Equal(t, ReasonScopeDenied, statusReason(err))
+	_, err = k.UpdateSecret(ctx, &pb.UpdateSecretRequest{Name: "db", Type: "raw", Content: []byte("x"), Tags: &pb.SecretTags{Tags: []string{"ci"}}})
+	assert.Equal(t, ReasonScopeDenied, statusReason(err))
+	_, err = k.UpdateSecret(ctx, &pb.UpdateSecretRequest{Name: "deploy", NewName: "deploy2", Type: "raw", Content: []byte("x")})
+	assert.NoError(t, err)
+
+	_, err = k.DeleteSecret(ctx, &pb.DeleteSecretRequest{Name: "db"})
+	assert.Equal(t, ReasonScopeDenied, statusReason(err))
+	_, err = k.DeleteSecret(ctx, &pb.DeleteSecretRequest{Name: "deploy"})
+	assert.NoError(t, err)
+}
+
+func TestIntegrationKeeper_NoPrincipal(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// an auth func writing the user only fails closed
+	auth := func(ctx context.Context) (context.Context, error) {
+		return usercontext.WriteUID(ctx, okUserID), nil
+	}
+
+	cl, stop := getTestClientWithAuth(t, ctrl, auth)
+	defer stop()
+
+	_, err := cl.ReadSecret(ctx, &pb.ReadSecretRequest{Name: "secret1"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = cl.ListSecrets(ctx, &pb.ListSecretsRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = cl.GetUsage(ctx, &pb.GetUsageRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func getTestClient(t *testing.T, ctrl *gomock.Controller, opts ...KeeperOption) (pb.KeeperClient, func()) {
+	return getTestClientWithAuth(t, ctrl, testAuthFunc, opts...)
+}
+
+func getTestClientWithAuth(
+	t *testing.T,
+	ctrl *gomock.Controller,
+	auth grpcauth.AuthFunc,
+	opts ...KeeperOption,
+) (pb.KeeperClient, func()) {
 	secrets := getTestSecretRepository(ctrl)
-	svc := NewKeeper(secrets)
+	svc := NewKeeper(secrets, opts...)
 
 	s := grpcserver.New(
 		grpcserver.WithListenAddr("localhost:0"),
 		grpcserver.WithServices(svc),
-		grpcserver.WithAuthFunc(testAuthFunc),
+		grpcserver.WithUnaryInterceptors(ErrorUnaryInterceptor()),
+		grpcserver.WithAuthFunc(auth),
 	)
 	if err := s.Start(); err != nil {
 		t.Fatal(err)
@@ -127,17 +435,29 @@ func getTestClient(t *testing.T, ctrl *gomock.Controller) (pb.KeeperClient, func
 
 func getTestSecretRepository(ctrl *gomock.Controller) storage.SecretRepository {
 	secrets := storagemock.NewMockSecretRepository(ctrl)
+	usage := model.Usage{
+		Secrets:   1,
+		TotalSize: 12,
+	}
+	// the quota is checked by the repository
 	secrets.EXPECT().Create(gomock.Any(), okUserID, &model.Secret{
 		UserID:  okUserID,
 		Name:    "secret1",
 		Type:    "raw",
 		Content: []byte("keepitsecret"),
-	}).AnyTimes().Return(&model.Secret{
-		UserID:  okUserID,
-		Name:    "secret1",
-		Type:    "raw",
-		Content: []byte("keepitsecret"),
+		Tags:    []string{},
+	}, gomock.Any()).AnyTimes().DoAndReturn(func(_ context.Context, uid uuid.UUID, m *model.Secret, q model.Quota) (*model.Secret, error) {
+		if d := q.Exceeded(usage, int64(len(m.Content))); d != "" {
+			return nil, model.QuotaError(uid, d)
+		}
+		return m, nil
+	})
+	secrets.EXPECT().Update(gomock.Any(), okUserID, "secret1", gomock.Any(), gomock.Any()).AnyTimes().Return(&model.Secret{
+		Name: "secret2",
+		Type: "raw",
 	}, nil)
+	secrets.EXPECT().Update(gomock.Any(), okUserID, "missing", gomock.Any(), gomock.Any()).AnyTimes().
+		Return(nil, apperr.ErrNotFound)
 	secrets.EXPECT().ReadByName(gomock.Any(), okUserID, "secret1").AnyTimes().Return(&model.Secret{
 		Name:    "secret1",
 		Type:    "raw",
@@ -150,12 +470,13 @@ func getTestSecretRepository(ctrl *gomock.Controller) storage.SecretRepository {
 			Type: "raw",
 		},
 	}, nil)
+	secrets.EXPECT().Usage(gomock.Any(), okUserID).AnyTimes().Return(&usage, nil)
 
 	return secrets
 }
 
 func testAuthFunc(ctx context.Context) (context.Context, error) {
 	log.Println("test auth func")
-	ctx = usercontext.WriteUID(ctx, okUserID)
+	ctx = usercontext.WritePrincipal(ctx, usercontext.Principal{UserID: okUserID})
 	return ctx, nil
 }
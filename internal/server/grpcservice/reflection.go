@@ -0,0 +1,34 @@
+package grpcservice
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// Reflection serves grpc.reflection.v1alpha for grpcurl and alike, calls need no auth
+// as it describes only the API, lists services of the server it is registered at
+type Reflection struct{}
+
+func NewReflection() *Reflection {
+	return &Reflection{}
+}
+
+func (s *Reflection) RegisterService(r grpc.ServiceRegistrar) {
+	var services reflection.ServiceInfoProvider
+	if p, ok := r.(reflection.ServiceInfoProvider); ok {
+		services = p
+	}
+	rpb.RegisterServerReflectionServer(r, reflectionServer{
+		reflection.NewServer(reflection.ServerOptions{Services: services}),
+	})
+}
+
+type reflectionServer struct {
+	rpb.ServerReflectionServer
+}
+
+func (reflectionServer) AuthFuncOverride(ctx context.Context, _ string) (context.Context, error) {
+	return ctx, nil
+}
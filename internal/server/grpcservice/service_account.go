@@ -0,0 +1,178 @@
+package grpcservice
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/scope"
+)
+
+const maxServiceAccountNameLength = 64
+
+// CreateServiceAccount owned by the user, grants limit its tokens to the user secrets
+func (s User) CreateServiceAccount(
+	ctx context.Context,
+	request *pb.CreateServiceAccountRequest,
+) (*pb.CreateServiceAccountResponse, error) {
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name := request.GetName()
+	if name == "" || len(name) > maxServiceAccountNameLength {
+		return nil, status.Errorf(codes.InvalidArgument, "service account name must be 1 to %d bytes long", maxServiceAccountNameLength)
+	}
+
+	grants, err := parseGrants(request.GetGrants())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	m, err := s.serviceAccounts.Create(ctx, &model.ServiceAccount{
+		OwnerID: uid,
+		Name:    name,
+		Grants:  grants.Strings(),
+	})
+	switch {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, apperr.ErrConflict):
+		return nil, status.Errorf(codes.AlreadyExists, "service account %q already exists", name)
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.CreateServiceAccountResponse{
+		ServiceAccount: newServiceAccountInfo(m),
+	}, nil
+}
+
+// ListServiceAccounts of the user
+func (s User) ListServiceAccounts(
+	ctx context.Context,
+	_ *pb.ListServiceAccountsRequest,
+) (*pb.ListServiceAccountsResponse, error) {
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mm, err := s.serviceAccounts.List(ctx, uid)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &pb.ListServiceAccountsResponse{
+		ServiceAccounts: make([]*pb.ServiceAccount, 0, len(mm)),
+	}
+	for _, m := range mm {
+		resp.ServiceAccounts = append(resp.ServiceAccounts, newServiceAccountInfo(m))
+	}
+
+	return resp, nil
+}
+
+// UpdateServiceAccountGrants of the user service account, its tokens are limited by new grants immediately
+func (s User) UpdateServiceAccountGrants(
+	ctx context.Context,
+	request *pb.UpdateServiceAccountGrantsRequest,
+) (*pb.UpdateServiceAccountGrantsResponse, error) {
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(request.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid service account id")
+	}
+
+	grants, err := parseGrants(request.GetGrants())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	switch err := s.serviceAccounts.UpdateGrants(ctx, uid, id, grants.Strings()); {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, apperr.ErrNotFound):
+		return nil, status.Error(codes.NotFound, "service account not found")
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.UpdateServiceAccountGrantsResponse{}, nil
+}
+
+// DeleteServiceAccount of the user with all its tokens
+func (s User) DeleteServiceAccount(
+	ctx context.Context,
+	request *pb.DeleteServiceAccountRequest,
+) (*pb.DeleteServiceAccountResponse, error) {
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(request.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid service account id")
+	}
+
+	switch err := s.serviceAccounts.Delete(ctx, uid, id); {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, apperr.ErrNotFound):
+		return nil, status.Error(codes.NotFound, "service account not found")
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.DeleteServiceAccountResponse{}, nil
+}
+
+// ownServiceAccount parses id of a service account owned by the user, uuid.Nil for an empty id
+func (s User) ownServiceAccount(ctx context.Context, uid uuid.UUID, id string) (uuid.UUID, error) {
+	if id == "" {
+		return uuid.Nil, nil
+	}
+
+	said, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.InvalidArgument, "invalid service account id")
+	}
+
+	sa, err := s.serviceAccounts.Read(ctx, said)
+	switch {
+	case err == nil && sa.OwnerID == uid:
+		return sa.ID, nil
+	case err == nil, errors.Is(err, apperr.ErrNotFound):
+		return uuid.Nil, status.Error(codes.NotFound, "service account not found")
+	default:
+		return uuid.Nil, status.Error(codes.Internal, err.Error())
+	}
+}
+
+// parseGrants of a service account, unlike token scopes they can be empty to deny everything
+func parseGrants(ss []string) (scope.Set, error) {
+	if len(ss) == 0 {
+		return scope.Set{}, nil
+	}
+	return scope.ParseSet(ss)
+}
+
+func newServiceAccountInfo(m *model.ServiceAccount) *pb.ServiceAccount {
+	return &pb.ServiceAccount{
+		Id:        m.ID.String(),
+		Name:      m.Name,
+		Grants:    m.Grants,
+		CreatedAt: timestamppb.New(m.CreatedAt),
+	}
+}
@@ -0,0 +1,135 @@
+package grpcservice
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/server/model"
+	storagemock "gophkeeper/internal/server/storage/mock"
+	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/scope"
+	"gophkeeper/pkg/token"
+	"gophkeeper/pkg/usercontext"
+	"testing"
+	"time"
+)
+
+func TestUser_ServiceAccounts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid, said, foreign := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	ctx := usercontext.WriteSessionID(usercontext.WriteUID(context.Background(), uid), sid)
+
+	tm, err := token.NewJWT("secret")
+	require.NoError(t, err)
+
+	sa := storagemock.NewMockServiceAccountRepository(ctrl)
+	sa.EXPECT().Create(gomock.Any(), &model.ServiceAccount{OwnerID: uid, Name: "ci", Grants: []string{}}).
+		DoAndReturn(func(_ context.Context, m *model.ServiceAccount) (*model.ServiceAccount, error) {
+			m.ID = said
+			return m, nil
+		})
+	sa.EXPECT().UpdateGrants(gomock.Any(), uid, said, []string{"read:ci/"}).Return(nil)
+	sa.EXPECT().Read(gomock.Any(), said).Return(&model.ServiceAccount{ID: said, OwnerID: uid}, nil)
+	sa.EXPECT().Read(gomock.Any(), foreign).Return(&model.ServiceAccount{ID: foreign, OwnerID: uuid.New()}, nil)
+
+	at := storagemock.NewMockAPITokenRepository(ctrl)
+	at.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, m *model.APIToken) (*model.APIToken, error) {
+			assert.Equal(t, said, m.ServiceAccountID)
+			m.ID = uuid.New()
+			return m, nil
+		})
+
+	svc := NewUser(
+		storagemock.NewMockUserRepository(ctrl),
+		storagemock.NewMockSessionRepository(ctrl),
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		storagemock.NewMockUserTokenRepository(ctrl),
+		at,
+		sa,
+		tm,
+	)
+
+	_, err = svc.CreateServiceAccount(ctx, &pb.CreateServiceAccountRequest{Name: "ci", Grants: []string{"admin"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	resp, err := svc.CreateServiceAccount(ctx, &pb.CreateServiceAccountRequest{Name: "ci"})
+	require.NoError(t, err)
+	assert.Equal(t, said.String(), resp.GetServiceAccount().GetId())
+
+	_, err = svc.UpdateServiceAccountGrants(ctx, &pb.UpdateServiceAccountGrantsRequest{
+		Id:     said.String(),
+		Grants: []string{"read:ci/", "read:ci/"},
+	})
+	assert.NoError(t, err)
+
+	tk, err := svc.CreateAPIToken(ctx, &pb.CreateAPITokenRequest{
+		Name:             "deploy",
+		Scopes:           []string{"read"},
+		ServiceAccountId: said.String(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, said.String(), tk.GetInfo().GetServiceAccountId())
+
+	// service accounts of other users are not revealed
+	_, err = svc.CreateAPIToken(ctx, &pb.CreateAPITokenRequest{
+		Name:             "deploy",
+		Scopes:           []string{"read"},
+		ServiceAccountId: foreign.String(),
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestBuildAuthFunc_ServiceAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, tid, said := uuid.New(), uuid.New(), uuid.New()
+
+	tm, err := token.NewJWT("secret")
+	require.NoError(t, err)
+	tk, err := tm.Issue(&model.APIToken{ID: tid, UserID: uid, Scopes: []string{"read", "write"}}, time.Minute)
+	require.NoError(t, err)
+
+	at := storagemock.NewMockAPITokenRepository(ctrl)
+	at.EXPECT().Touch(gomock.Any(), tid, "").Return(&model.APIToken{ID: tid, UserID: uid, ServiceAccountID: said}, nil).Times(3)
+
+	sa := storagemock.NewMockServiceAccountRepository(ctrl)
+	gomock.InOrder(
+		sa.EXPECT().Read(gomock.Any(), said).Return(&model.ServiceAccount{ID: said, OwnerID: uid, Grants: []string{"read:ci/"}}, nil),
+		sa.EXPECT().Read(gomock.Any(), said).Return(&model.ServiceAccount{ID: said, OwnerID: uid}, nil),
+		sa.EXPECT().Read(gomock.Any(), said).Return(nil, apperr.ErrNotFound),
+	)
+
+	auth := BuildAuthFunc(tm, storagemock.NewMockSessionRepository(ctrl), at, sa)
+	bearer := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "bearer "+tk))
+
+	ctx, err := auth(bearer)
+	require.NoError(t, err)
+	p, _ := usercontext.ReadPrincipal(ctx)
+	assert.Equal(t, uid, p.UserID)
+	assert.Equal(t, said, p.ServiceAccountID)
+	// token scopes are limited by the grants
+	assert.True(t, p.Allows(scope.Read, "ci/deploy", nil))
+	assert.False(t, p.Allows(scope.Read, "prod/db", nil))
+	assert.False(t, p.AllowsAny(scope.Write))
+
+	// no grants deny everything
+	ctx, err = auth(bearer)
+	require.NoError(t, err)
+	p, _ = usercontext.ReadPrincipal(ctx)
+	assert.False(t, p.AllowsAny(scope.Read))
+
+	// deleted service account
+	_, err = auth(bearer)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,396 @@
+package grpcservice
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/srp"
+	"sync"
+	"time"
+)
+
+const (
+	// ReasonSRPNotEnrolled is set in ErrorInfo when the user has a password and no SRP verifier yet
+	ReasonSRPNotEnrolled = "SRP_NOT_ENROLLED"
+	// ReasonPasswordDisabled is set in ErrorInfo when the server accepts SRP only, see WithSRPOnly
+	ReasonPasswordDisabled = "PASSWORD_DISABLED"
+
+	srpHandshakeLifetime = time.Minute
+	// handshakes in progress are limited per email and per peer IP, so nobody can exhaust them for others
+	maxSRPHandshakesPerEmail = 10
+	maxSRPHandshakesPerIP    = 100
+	fakeVerifierSize         = 256
+)
+
+var errTooManyHandshakes = errors.New("too many srp handshakes in progress")
+
+// srpHandshake started by StartSRPLogin waiting for the client proof
+type srpHandshake struct {
+	// user is nil for unknown emails, the proof fails then
+	user      *model.User
+	email     string
+	ip        string
+	server    *srp.Server
+	expiresAt time.Time
+	// attempt reserved by StartSRPLogin, it is counted as failed unless the proof is valid
+	attempt *attempt
+}
+
+// verify the client proof, returns the server proof
+func (h *srpHandshake) verify(proof []byte) ([]byte, error) {
+	m2, err := h.server.Verify(proof)
+	if err != nil {
+		return nil, err
+	}
+	if h.user == nil {
+		return nil, srp.ErrInvalidProof
+	}
+	return m2, nil
+}
+
+// queuedHandshake id with the handshake it was put for, the id may be taken already
+type queuedHandshake struct {
+	id string
+	hs *srpHandshake
+}
+
+// srpHandshakes in progress, they live for a minute so are kept in memory like throttle counters
+type srpHandshakes struct {
+	mu sync.Mutex
+	m  map[string]*srpHandshake
+	// queue of handshakes in the order of expiration as all of them live for the same time,
+	// so expired ones are dropped from its head without scanning the rest
+	queue  []queuedHandshake
+	emails map[string]int
+	ips    map[string]int
+	// fakeKey derives stable salts of unknown emails, so they look like existing ones
+	fakeKey []byte
+}
+
+func newSRPHandshakes() *srpHandshakes {
+	return &srpHandshakes{
+		m:      make(map[string]*srpHandshake),
+		emails: make(map[string]int),
+		ips:    make(map[string]int),
+	}
+}
+
+// put the handshake returning its id, errTooManyHandshakes if its email or IP has too many in progress
+func (h *srpHandshakes) put(hs *srpHandshake) (string, error) {
+	id, err := randomToken()
+	if err != nil {
+		return "", fmt.Errorf("generate handshake id: %w", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.expire(time.Now())
+
+	email := emailKey(hs.email)
+	if h.emails[email] >= maxSRPHandshakesPerEmail {
+		return "", errTooManyHandshakes
+	}
+	if hs.ip != "" && h.ips[hs.ip] >= maxSRPHandshakesPerIP {
+		return "", errTooManyHandshakes
+	}
+
+	h.m[id] = hs
+	h.queue = append(h.queue, queuedHandshake{id: id, hs: hs})
+	h.emails[email]++
+	if hs.ip != "" {
+		h.ips[hs.ip]++
+	}
+	return id, nil
+}
+
+// take the unexpired handshake, each one can be used once
+func (h *srpHandshakes) take(id string) *srpHandshake {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	h.expire(now)
+
+	hs, ok := h.m[id]
+	if !ok {
+		return nil
+	}
+	h.remove(id, hs)
+
+	if now.After(hs.expiresAt) {
+		return nil
+	}
+	return hs
+}
+
+// expire handshakes from the queue head, must be called with the lock held
+func (h *srpHandshakes) expire(now time.Time) {
+	for len(h.queue) > 0 && now.After(h.queue[0].hs.expiresAt) {
+		q := h.queue[0]
+		h.queue[0] = queuedHandshake{}
+		h.queue = h.queue[1:]
+		if h.m[q.id] == q.hs {
+			h.remove(q.id, q.hs)
+		}
+	}
+	if len(h.queue) == 0 {
+		// drop the backing array grown by a burst
+		h.queue = nil
+	}
+}
+
+// remove the handshake releasing its email and IP slots, must be called with the lock held
+func (h *srpHandshakes) remove(id string, hs *srpHandshake) {
+	delete(h.m, id)
+	release(h.emails, emailKey(hs.email))
+	if hs.ip != "" {
+		release(h.ips, hs.ip)
+	}
+}
+
+func release(counts map[string]int, key string) {
+	if counts[key] <= 1 {
+		delete(counts, key)
+		return
+	}
+	counts[key]--
+}
+
+// setSecret derives the fake salt key from the server secret, so salts of unknown emails survive restarts
+// like real ones do and can not be told apart by comparing them
+func (h *srpHandshakes) setSecret(secret string) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("srp fake salt"))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fakeKey = mac.Sum(nil)
+}
+
+// fakeSalt of an unknown email, the same for each call
+func (h *srpHandshakes) fakeSalt(email string) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.fakeKey == nil {
+		key := make([]byte, sha256.Size)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("generate key: %w", err)
+		}
+		h.fakeKey = key
+	}
+
+	mac := hmac.New(sha256.New, h.fakeKey)
+	mac.Write([]byte(emailKey(email)))
+	return mac.Sum(nil)[:srp.SaltSize], nil
+}
+
+// RegisterSRP registers the user with an SRP verifier, the password is never sent to the server
+func (s User) RegisterSRP(ctx context.Context, request *pb.RegisterSRPRequest) (*pb.RegisterSRPResponse, error) {
+	if err := validSRPVerifier(request.GetVerifier()); err != nil {
+		return nil, err
+	}
+
+	t, rt, err := s.register(ctx, &model.User{
+		Email:       request.GetEmail(),
+		SRPSalt:     request.GetVerifier().GetSalt(),
+		SRPVerifier: request.GetVerifier().GetVerifier(),
+	}, request.GetDevice())
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.RegisterSRPResponse{
+		Token:        t,
+		RefreshToken: rt,
+	}, nil
+}
+
+// StartSRPLogin of the user, the handshake is finished by FinishSRPLogin or used as SRPProof.
+// Users with a password get SRP_NOT_ENROLLED, registration tells existing emails anyway.
+// Each handshake is counted as a failed login attempt until its proof is valid.
+func (s User) StartSRPLogin(ctx context.Context, request *pb.StartSRPLoginRequest) (*pb.StartSRPLoginResponse, error) {
+	email := request.GetEmail()
+	a, err := s.reserveLogin(ctx, email, peerIP(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.startSRPLogin(ctx, email, request.GetClientPublic(), a)
+	if err != nil {
+		a.refund()
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s User) startSRPLogin(
+	ctx context.Context,
+	email string,
+	clientPublic []byte,
+	a *attempt,
+) (*pb.StartSRPLoginResponse, error) {
+	hs := &srpHandshake{
+		email:     email,
+		ip:        peerIP(ctx),
+		expiresAt: time.Now().Add(srpHandshakeLifetime),
+		attempt:   a,
+	}
+
+	var salt, verifier []byte
+	u, err := s.users.ReadByEmail(ctx, email)
+	switch {
+	case err == nil && u.SRP():
+		hs.user = u
+		salt, verifier = u.SRPSalt, u.SRPVerifier
+	case err == nil && s.srpOnly:
+		return nil, reasonError(codes.FailedPrecondition, ReasonSRPNotEnrolled, "reset the password to enroll srp")
+	case err == nil:
+		return nil, reasonError(codes.FailedPrecondition, ReasonSRPNotEnrolled, "login with password once to enroll srp")
+	case errors.Is(err, apperr.ErrNotFound):
+		// the handshake fails at the proof, so unknown emails look like existing ones
+		if salt, err = s.handshakes.fakeSalt(email); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		verifier = make([]byte, fakeVerifierSize)
+		if _, err := rand.Read(verifier); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	hs.server, err = srp.NewServer(email, salt, verifier, clientPublic)
+	switch {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, srp.ErrInvalidPublic):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	id, err := s.handshakes.put(hs)
+	switch {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, errTooManyHandshakes):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.StartSRPLoginResponse{
+		HandshakeId:  id,
+		Salt:         salt,
+		ServerPublic: hs.server.Public(),
+	}, nil
+}
+
+// FinishSRPLogin checks the client proof and issues tokens, the attempt is throttled by StartSRPLogin
+func (s User) FinishSRPLogin(ctx context.Context, request *pb.FinishSRPLoginRequest) (*pb.FinishSRPLoginResponse, error) {
+	hs := s.handshakes.take(request.GetHandshakeId())
+	if hs == nil {
+		return nil, status.Error(codes.Unauthenticated, "srp handshake expired")
+	}
+
+	m2, err := hs.verify(request.GetClientProof())
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid email or password")
+	}
+
+	err = s.checkTwoFactor(ctx, hs.user.ID, request.GetOtpCode(), request.GetRecoveryCode(), codes.Unauthenticated)
+	if err != nil {
+		if statusReason(err) != ReasonTwoFactorInvalid {
+			hs.attempt.refund()
+		}
+		return nil, err
+	}
+	s.succeedLogin(hs.attempt, hs.email)
+
+	t, rt, err := s.issueTokens(ctx, hs.user, request.GetDevice())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.FinishSRPLoginResponse{
+		Token:         t,
+		RefreshToken:  rt,
+		EmailVerified: hs.user.EmailVerified,
+		ServerProof:   m2,
+	}, nil
+}
+
+// EnrollSRP replaces the password of the user with the SRP verifier
+func (s User) EnrollSRP(ctx context.Context, request *pb.EnrollSRPRequest) (*pb.EnrollSRPResponse, error) {
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validSRPVerifier(request.GetVerifier()); err != nil {
+		return nil, err
+	}
+
+	u, err := s.users.Read(ctx, uid)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// the password is sent to enroll, so SRP only servers enroll users by password reset
+	if err := s.checkPassword(ctx, u, request.GetPassword(), nil); err != nil {
+		return nil, err
+	}
+
+	if err := s.updateCredentials(ctx, uid, "", request.GetVerifier()); err != nil {
+		return nil, err
+	}
+
+	return &pb.EnrollSRPResponse{}, nil
+}
+
+// checkSRPProof of the authenticated user, the handshake is started by StartSRPLogin
+func (s User) checkSRPProof(uid uuid.UUID, proof *pb.SRPProof) error {
+	hs := s.handshakes.take(proof.GetHandshakeId())
+	if hs == nil {
+		return status.Error(codes.PermissionDenied, "srp handshake expired")
+	}
+
+	if _, err := hs.verify(proof.GetClientProof()); err != nil || hs.user.ID != uid {
+		return status.Error(codes.PermissionDenied, "invalid password")
+	}
+	s.succeedLogin(hs.attempt, hs.email)
+
+	return nil
+}
+
+// updateCredentials of the user with the SRP verifier if set, otherwise with the password
+func (s User) updateCredentials(ctx context.Context, uid uuid.UUID, password string, v *pb.SRPVerifier) error {
+	var err error
+	if v != nil {
+		err = s.users.UpdateVerifier(ctx, uid, v.GetSalt(), v.GetVerifier())
+	} else {
+		err = s.users.UpdatePassword(ctx, uid, password)
+	}
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+func validSRPVerifier(v *pb.SRPVerifier) error {
+	if !srp.ValidVerifier(v.GetSalt(), v.GetVerifier()) {
+		return status.Error(codes.InvalidArgument, "invalid srp verifier")
+	}
+	return nil
+}
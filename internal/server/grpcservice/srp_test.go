@@ -0,0 +1,316 @@
+package grpcservice
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/server/model"
+	storagemock "gophkeeper/internal/server/storage/mock"
+	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/srp"
+	"gophkeeper/pkg/token"
+	tokenmock "gophkeeper/pkg/token/mock"
+	"gophkeeper/pkg/usercontext"
+	"testing"
+	"time"
+)
+
+// srpStart begins a handshake of the email with the password
+func srpStart(t *testing.T, svc *User, email, password string) (*srp.Client, *pb.StartSRPLoginResponse) {
+	c, err := srp.NewClient(email, password)
+	require.NoError(t, err)
+
+	resp, err := svc.StartSRPLogin(context.Background(), &pb.StartSRPLoginRequest{
+		Email:        email,
+		ClientPublic: c.Public(),
+	})
+	require.NoError(t, err)
+
+	return c, resp
+}
+
+func TestUser_SRPLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid := uuid.New()
+	salt, verifier, err := srp.NewVerifier("password")
+	require.NoError(t, err)
+	alice := &model.User{ID: uid, Email: "alice@example.org", SRPSalt: salt, SRPVerifier: verifier}
+
+	u := storagemock.NewMockUserRepository(ctrl)
+	u.EXPECT().ReadByEmail(gomock.Any(), "alice@example.org").Return(alice, nil).AnyTimes()
+	u.EXPECT().ReadByEmail(gomock.Any(), "bob@example.org").Return(&model.User{ID: uuid.New(), Email: "bob@example.org"}, nil)
+	u.EXPECT().ReadByEmail(gomock.Any(), "eve@example.org").Return(nil, apperr.ErrNotFound).AnyTimes()
+
+	tf := storagemock.NewMockTwoFactorRepository(ctrl)
+	tf.EXPECT().Read(gomock.Any(), uid).Return(nil, apperr.ErrNotFound)
+
+	sr := storagemock.NewMockSessionRepository(ctrl)
+	sr.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, m *model.Session) (*model.Session, error) {
+		m.ID = uuid.New()
+		return m, nil
+	})
+
+	rt := storagemock.NewMockRefreshTokenRepository(ctrl)
+	rt.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, m *model.RefreshToken) (*model.RefreshToken, error) {
+		return m, nil
+	})
+
+	tm, err := token.NewJWT("secret")
+	require.NoError(t, err)
+
+	svc := NewUser(u, sr, rt, tf, storagemock.NewMockUserTokenRepository(ctrl), storagemock.NewMockAPITokenRepository(ctrl), storagemock.NewMockServiceAccountRepository(ctrl), tm)
+
+	c, start := srpStart(t, svc, "alice@example.org", "password")
+	assert.Equal(t, salt, start.GetSalt())
+	m1, err := c.Proof(start.GetSalt(), start.GetServerPublic())
+	require.NoError(t, err)
+
+	resp, err := svc.FinishSRPLogin(context.Background(), &pb.FinishSRPLoginRequest{
+		HandshakeId: start.GetHandshakeId(),
+		ClientProof: m1,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.GetToken())
+	assert.NotEmpty(t, resp.GetRefreshToken())
+	assert.NoError(t, c.VerifyServer(resp.GetServerProof()))
+
+	// handshakes are single use
+	_, err = svc.FinishSRPLogin(context.Background(), &pb.FinishSRPLoginRequest{
+		HandshakeId: start.GetHandshakeId(),
+		ClientProof: m1,
+	})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// wrong password
+	c, start = srpStart(t, svc, "alice@example.org", "guess")
+	m1, err = c.Proof(start.GetSalt(), start.GetServerPublic())
+	require.NoError(t, err)
+	_, err = svc.FinishSRPLogin(context.Background(), &pb.FinishSRPLoginRequest{
+		HandshakeId: start.GetHandshakeId(),
+		ClientProof: m1,
+	})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// users with a password enroll after a password login
+	_, err = svc.StartSRPLogin(context.Background(), &pb.StartSRPLoginRequest{Email: "bob@example.org", ClientPublic: c.Public()})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, ReasonSRPNotEnrolled, statusReason(err))
+
+	// unknown emails get a stable salt and fail at the proof
+	_, first := srpStart(t, svc, "eve@example.org", "password")
+	c, start = srpStart(t, svc, "eve@example.org", "password")
+	assert.Equal(t, first.GetSalt(), start.GetSalt())
+	m1, err = c.Proof(start.GetSalt(), start.GetServerPublic())
+	require.NoError(t, err)
+	_, err = svc.FinishSRPLogin(context.Background(), &pb.FinishSRPLoginRequest{
+		HandshakeId: start.GetHandshakeId(),
+		ClientProof: m1,
+	})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = svc.StartSRPLogin(context.Background(), &pb.StartSRPLoginRequest{Email: "alice@example.org"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUser_SRPCredentials(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid := uuid.New(), uuid.New()
+	ctx := usercontext.WriteSessionID(usercontext.WriteUID(context.Background(), uid), sid)
+
+	salt, verifier, err := srp.NewVerifier("password")
+	require.NoError(t, err)
+	nv := &pb.SRPVerifier{Salt: salt, Verifier: verifier}
+
+	u := storagemock.NewMockUserRepository(ctrl)
+	// read to enroll and on every password change
+	u.EXPECT().Read(gomock.Any(), uid).Return(&model.User{ID: uid, Email: "user@example.org"}, nil).Times(4)
+	u.EXPECT().ReadByEmailAndPassword(gomock.Any(), "user@example.org", "old").Return(&model.User{ID: uid}, nil)
+	u.EXPECT().ReadByEmail(gomock.Any(), "user@example.org").Return(
+		&model.User{ID: uid, Email: "user@example.org", SRPSalt: salt, SRPVerifier: verifier}, nil,
+	).Times(2)
+	u.EXPECT().ReadByEmail(gomock.Any(), "other@example.org").Return(
+		&model.User{ID: uuid.New(), Email: "other@example.org", SRPSalt: salt, SRPVerifier: verifier}, nil,
+	)
+	u.EXPECT().UpdateVerifier(gomock.Any(), uid, salt, verifier).Return(nil).Times(2)
+
+	tf := storagemock.NewMockTwoFactorRepository(ctrl)
+	tf.EXPECT().Read(gomock.Any(), uid).Return(nil, apperr.ErrNotFound)
+
+	sr := storagemock.NewMockSessionRepository(ctrl)
+	sr.EXPECT().DeleteOthers(gomock.Any(), uid, sid).Return(int64(1), nil)
+
+	at := storagemock.NewMockAPITokenRepository(ctrl)
+	at.EXPECT().DeleteUserTokens(gomock.Any(), uid).Return(int64(0), nil)
+
+	svc := NewUser(u, sr, storagemock.NewMockRefreshTokenRepository(ctrl), tf, storagemock.NewMockUserTokenRepository(ctrl), at, storagemock.NewMockServiceAccountRepository(ctrl), tokenmock.NewMockManager(ctrl))
+
+	_, err = svc.EnrollSRP(ctx, &pb.EnrollSRPRequest{Password: "old", Verifier: &pb.SRPVerifier{Salt: salt}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// the password is sent for the last time
+	_, err = svc.EnrollSRP(ctx, &pb.EnrollSRPRequest{Password: "old", Verifier: nv})
+	require.NoError(t, err)
+
+	proof := func(email, password string) *pb.SRPProof {
+		c, start := srpStart(t, svc, email, password)
+		m1, err := c.Proof(start.GetSalt(), start.GetServerPublic())
+		require.NoError(t, err)
+		return &pb.SRPProof{HandshakeId: start.GetHandshakeId(), ClientProof: m1}
+	}
+
+	// proof of another user
+	_, err = svc.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentSrpProof: proof("other@example.org", "password"), NewSrpVerifier: nv})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// wrong password
+	_, err = svc.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentSrpProof: proof("user@example.org", "guess"), NewSrpVerifier: nv})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	resp, err := svc.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentSrpProof: proof("user@example.org", "password"), NewSrpVerifier: nv})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), resp.GetRevokedSessions())
+}
+
+func TestSRPHandshakes_Limits(t *testing.T) {
+	h := newSRPHandshakes()
+	live := func(email, ip string) *srpHandshake {
+		return &srpHandshake{email: email, ip: ip, expiresAt: time.Now().Add(time.Minute)}
+	}
+
+	// the email cap ignores the case like throttling does
+	var first string
+	for i := 0; i < maxSRPHandshakesPerEmail; i++ {
+		id, err := h.put(live("Alice@example.org", "10.0.0.1"))
+		require.NoError(t, err)
+		if first == "" {
+			first = id
+		}
+	}
+	_, err := h.put(live("alice@example.org", "10.0.0.2"))
+	assert.ErrorIs(t, err, errTooManyHandshakes)
+
+	// other emails are not affected, a taken handshake frees its slot
+	_, err = h.put(live("bob@example.org", "10.0.0.2"))
+	assert.NoError(t, err)
+	assert.NotNil(t, h.take(first))
+	_, err = h.put(live("alice@example.org", "10.0.0.2"))
+	assert.NoError(t, err)
+
+	// the IP cap spans emails
+	for i := 1; i < maxSRPHandshakesPerIP; i++ {
+		_, err := h.put(live(fmt.Sprintf("user%d@example.org", i), "10.0.0.3"))
+		require.NoError(t, err)
+	}
+	_, err = h.put(live("carol@example.org", "10.0.0.3"))
+	assert.NoError(t, err)
+	_, err = h.put(live("dave@example.org", "10.0.0.3"))
+	assert.ErrorIs(t, err, errTooManyHandshakes)
+}
+
+func TestSRPHandshakes_Expire(t *testing.T) {
+	h := newSRPHandshakes()
+
+	for i := 0; i < maxSRPHandshakesPerEmail; i++ {
+		_, err := h.put(&srpHandshake{email: "alice@example.org", ip: "10.0.0.1", expiresAt: time.Now().Add(-time.Second)})
+		require.NoError(t, err)
+	}
+
+	// expired handshakes are dropped from the queue releasing their slots
+	id, err := h.put(&srpHandshake{email: "alice@example.org", ip: "10.0.0.1", expiresAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	assert.Len(t, h.m, 1)
+	assert.Len(t, h.queue, 1)
+	assert.Equal(t, map[string]int{"alice@example.org": 1}, h.emails)
+	assert.Equal(t, map[string]int{"10.0.0.1": 1}, h.ips)
+
+	assert.NotNil(t, h.take(id))
+	assert.Nil(t, h.take(id))
+	assert.Empty(t, h.emails)
+	assert.Empty(t, h.ips)
+}
+
+func TestSRPHandshakes_FakeSalt(t *testing.T) {
+	a, b, other := newSRPHandshakes(), newSRPHandshakes(), newSRPHandshakes()
+	a.setSecret("secret")
+	b.setSecret("secret")
+	other.setSecret("other")
+
+	salt, err := a.fakeSalt("eve@example.org")
+	require.NoError(t, err)
+	assert.Len(t, salt, srp.SaltSize)
+
+	// the same secret gives the same salts after a restart
+	restarted, err := b.fakeSalt("Eve@example.org")
+	require.NoError(t, err)
+	assert.Equal(t, salt, restarted)
+
+	changed, err := other.fakeSalt("eve@example.org")
+	require.NoError(t, err)
+	assert.NotEqual(t, salt, changed)
+}
+
+func TestUser_SRPOnly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid := uuid.New(), uuid.New()
+	ctx := usercontext.WriteSessionID(usercontext.WriteUID(context.Background(), uid), sid)
+
+	salt, verifier, err := srp.NewVerifier("password")
+	require.NoError(t, err)
+	nv := &pb.SRPVerifier{Salt: salt, Verifier: verifier}
+
+	u := storagemock.NewMockUserRepository(ctrl)
+	u.EXPECT().ReadByEmail(gomock.Any(), "bob@example.org").Return(&model.User{ID: uuid.New(), Email: "bob@example.org"}, nil)
+	u.EXPECT().Read(gomock.Any(), uid).Return(&model.User{ID: uid, Email: "alice@example.org"}, nil).AnyTimes()
+
+	svc := NewUser(
+		u,
+		storagemock.NewMockSessionRepository(ctrl),
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		storagemock.NewMockUserTokenRepository(ctrl),
+		storagemock.NewMockAPITokenRepository(ctrl),
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		nil,
+		WithSRPOnly(),
+	)
+
+	assertDisabled := func(err error) {
+		t.Helper()
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+		assert.Equal(t, ReasonPasswordDisabled, statusReason(err))
+	}
+
+	_, err = svc.Register(context.Background(), &pb.RegisterRequest{Email: "alice@example.org", Password: "password"})
+	assertDisabled(err)
+	_, err = svc.Login(context.Background(), &pb.LoginRequest{Email: "alice@example.org", Password: "password"})
+	assertDisabled(err)
+	_, err = svc.EnrollSRP(ctx, &pb.EnrollSRPRequest{Password: "password", Verifier: nv})
+	assertDisabled(err)
+	_, err = svc.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentPassword: "password", NewSrpVerifier: nv})
+	assertDisabled(err)
+	_, err = svc.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentSrpProof: &pb.SRPProof{}, NewPassword: "new"})
+	assertDisabled(err)
+	_, err = svc.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: "password"})
+	assertDisabled(err)
+	_, err = svc.ResetPassword(context.Background(), &pb.ResetPasswordRequest{Token: "token", NewPassword: "new"})
+	assertDisabled(err)
+
+	// users with a password enroll by the password reset
+	c, err := srp.NewClient("bob@example.org", "password")
+	require.NoError(t, err)
+	_, err = svc.StartSRPLogin(context.Background(), &pb.StartSRPLoginRequest{Email: "bob@example.org", ClientPublic: c.Public()})
+	assert.Equal(t, ReasonSRPNotEnrolled, statusReason(err))
+	assert.Contains(t, status.Convert(err).Message(), "reset")
+}
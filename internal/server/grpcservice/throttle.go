@@ -0,0 +1,100 @@
+package grpcservice
+
+import (
+	"context"
+	"gophkeeper/internal/server/throttle"
+	"gophkeeper/pkg/logger"
+	"strings"
+	"time"
+)
+
+const (
+	// ReasonTooManyAttempts is set in ErrorInfo when an attempt is throttled, RetryInfo tells when to retry
+	ReasonTooManyAttempts = "TOO_MANY_ATTEMPTS"
+
+	registerKeyPrefix = "register:"
+	resetKeyPrefix    = "reset:"
+)
+
+// attempt reserved in the email and IP limiters, counted as failed unless refunded
+type attempt struct {
+	email *throttle.Reservation
+	ip    *throttle.Reservation
+}
+
+// refund the attempt, e.g. when it is not checked because of a missing two-factor code or a server error
+func (a *attempt) refund() {
+	if a == nil {
+		return
+	}
+	a.email.Cancel()
+	a.ip.Cancel()
+}
+
+// reserve an attempt of the email, if not empty, and from the peer IP before it is checked,
+// so a burst of concurrent attempts can't pass the limits. Action names the attempt in logs,
+// prefix separates its keys from login ones.
+func (s User) reserve(ctx context.Context, action, prefix, email, ip string) (*attempt, error) {
+	a := &attempt{}
+
+	var wait time.Duration
+	var emailLocked, ipLocked bool
+	if s.emailLimiter != nil && email != "" {
+		a.email, wait, emailLocked = s.emailLimiter.Reserve(prefix + emailKey(email))
+	}
+	if s.ipLimiter != nil && ip != "" {
+		var d time.Duration
+		a.ip, d, ipLocked = s.ipLimiter.Reserve(prefix + ip)
+		if d > wait {
+			wait = d
+		}
+	}
+
+	if wait > 0 {
+		a.refund()
+		return nil, throttledError(wait)
+	}
+
+	l := logger.Ctx(ctx)
+	if emailLocked {
+		l.Warn().Str("email", email).Str("ip", ip).Msgf("%s locked out for email", action)
+	}
+	if ipLocked {
+		l.Warn().Str("email", email).Str("ip", ip).Msgf("%s locked out for IP", action)
+	}
+
+	return a, nil
+}
+
+// reserveLogin attempt of the email from the peer IP, it is counted as failed unless it succeeds or is refunded
+func (s User) reserveLogin(ctx context.Context, email, ip string) (*attempt, error) {
+	return s.reserve(ctx, "Login", "", email, ip)
+}
+
+// succeedLogin resets attempts of the email and refunds the IP one,
+// other IP attempts are kept as other accounts could be guessed from it
+func (s User) succeedLogin(a *attempt, email string) {
+	if a == nil {
+		return
+	}
+	a.ip.Cancel()
+	if s.emailLimiter != nil {
+		s.emailLimiter.Reset(emailKey(email))
+	}
+}
+
+// throttleRegister counts every registration from the peer IP, as there is no failure to count
+func (s User) throttleRegister(ctx context.Context, ip string) error {
+	_, err := s.reserve(ctx, "Registration", registerKeyPrefix, "", ip)
+	return err
+}
+
+// throttleReset counts every password reset request of the email and from the peer IP, so mails can't be flooded
+func (s User) throttleReset(ctx context.Context, email, ip string) error {
+	_, err := s.reserve(ctx, "Password reset", resetKeyPrefix, email, ip)
+	return err
+}
+
+func emailKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
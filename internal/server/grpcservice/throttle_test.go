@@ -0,0 +1,270 @@
+package grpcservice
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/server/model"
+	storagemock "gophkeeper/internal/server/storage/mock"
+	"gophkeeper/internal/server/throttle"
+	"gophkeeper/pkg/apperr"
+	tokenmock "gophkeeper/pkg/token/mock"
+	"gophkeeper/pkg/totp"
+	"gophkeeper/pkg/usercontext"
+)
+
+func retryDelay(err error) time.Duration {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.RetryInfo); ok {
+			return info.GetRetryDelay().AsDuration()
+		}
+	}
+	return 0
+}
+
+func peerContext(ip string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 12345}})
+}
+
+func TestPeerIP(t *testing.T) {
+	assert.Equal(t, "192.0.2.1", peerIP(peerContext("192.0.2.1")))
+	assert.Equal(t, "", peerIP(context.Background()))
+
+	// the gateway appends the client address to the header, earlier entries are set by clients
+	md := metadata.Pairs("x-forwarded-for", "198.51.100.1, 192.0.2.7")
+	gw := peer.NewContext(metadata.NewIncomingContext(context.Background(), md), &peer.Peer{Addr: bufconn.Listen(1).Addr()})
+	assert.Equal(t, "192.0.2.7", peerIP(gw))
+
+	// the header is ignored unless the call comes from the gateway
+	direct := peer.NewContext(metadata.NewIncomingContext(context.Background(), md), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1")}})
+	assert.Equal(t, "192.0.2.1", peerIP(direct))
+}
+
+func TestUser_LoginThrottle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	u := storagemock.NewMockUserRepository(ctrl)
+	u.EXPECT().ReadByEmailAndPassword(gomock.Any(), gomock.Any(), "wrong").Return(nil, apperr.ErrNotFound).AnyTimes()
+
+	policy := throttle.Policy{FreeAttempts: 2, BaseDelay: time.Minute}
+	ipPolicy := throttle.Policy{FreeAttempts: 4, BaseDelay: time.Minute}
+
+	svc := NewUser(
+		u,
+		storagemock.NewMockSessionRepository(ctrl),
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		storagemock.NewMockUserTokenRepository(ctrl),
+		storagemock.NewMockAPITokenRepository(ctrl),
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		tokenmock.NewMockManager(ctrl),
+		WithThrottle(throttle.New(policy), throttle.New(ipPolicy)),
+	)
+
+	ctx := peerContext("192.0.2.1")
+	login := func(ctx context.Context, email string) error {
+		_, err := svc.Login(ctx, &pb.LoginRequest{Email: email, Password: "wrong"})
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, codes.Unauthenticated, status.Code(login(ctx, "user@example.org")))
+	}
+
+	err := login(ctx, "User@Example.org")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, ReasonTooManyAttempts, statusReason(err))
+	assert.Equal(t, time.Minute, retryDelay(err))
+
+	// another email is allowed until the IP limit is hit
+	assert.NotEqual(t, codes.ResourceExhausted, status.Code(login(ctx, "other@example.org")))
+	assert.NotEqual(t, codes.ResourceExhausted, status.Code(login(ctx, "other@example.org")))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(login(ctx, "third@example.org")))
+
+	// other peers are limited by email only
+	assert.NotEqual(t, codes.ResourceExhausted, status.Code(login(peerContext("192.0.2.2"), "third@example.org")))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(login(peerContext("192.0.2.2"), "user@example.org")))
+}
+
+func TestUser_LoginThrottleBurst(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// checks are slow, so all attempts of the burst are in flight together
+	var checked int64
+	release := make(chan struct{})
+	u := storagemock.NewMockUserRepository(ctrl)
+	u.EXPECT().ReadByEmailAndPassword(gomock.Any(), gomock.Any(), "wrong").DoAndReturn(
+		func(context.Context, string, string) (*model.User, error) {
+			atomic.AddInt64(&checked, 1)
+			<-release
+			return nil, apperr.ErrNotFound
+		},
+	).AnyTimes()
+
+	svc := NewUser(
+		u,
+		storagemock.NewMockSessionRepository(ctrl),
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		storagemock.NewMockUserTokenRepository(ctrl),
+		storagemock.NewMockAPITokenRepository(ctrl),
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		tokenmock.NewMockManager(ctrl),
+		WithThrottle(throttle.New(throttle.Policy{FreeAttempts: 2, BaseDelay: time.Minute}), nil),
+	)
+
+	var wg sync.WaitGroup
+	var throttled int64
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.Login(peerContext("192.0.2.1"), &pb.LoginRequest{Email: "user@example.org", Password: "wrong"})
+			if status.Code(err) == codes.ResourceExhausted {
+				atomic.AddInt64(&throttled, 1)
+			}
+		}()
+	}
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&throttled) == 17
+	}, time.Second, 10*time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// free attempts and the one setting the delay
+	assert.Equal(t, int64(3), atomic.LoadInt64(&checked))
+}
+
+func TestUser_RegisterThrottle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := NewUser(
+		storagemock.NewMockUserRepository(ctrl),
+		storagemock.NewMockSessionRepository(ctrl),
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		storagemock.NewMockUserTokenRepository(ctrl),
+		storagemock.NewMockAPITokenRepository(ctrl),
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		tokenmock.NewMockManager(ctrl),
+		WithThrottle(nil, throttle.New(throttle.Policy{FreeAttempts: 1, BaseDelay: time.Hour})),
+	)
+
+	ctx := peerContext("192.0.2.1")
+	register := func(ctx context.Context) error {
+		_, err := svc.Register(ctx, &pb.RegisterRequest{Email: "invalid", Password: "pass"})
+		return err
+	}
+
+	// every attempt is counted, even a failed one
+	assert.Equal(t, codes.InvalidArgument, status.Code(register(ctx)))
+	assert.Equal(t, codes.InvalidArgument, status.Code(register(ctx)))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(register(ctx)))
+	assert.Equal(t, codes.InvalidArgument, status.Code(register(peerContext("192.0.2.2"))))
+}
+
+func TestUser_ResetThrottle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	u := storagemock.NewMockUserRepository(ctrl)
+	u.EXPECT().ReadByEmail(gomock.Any(), gomock.Any()).Return(nil, apperr.ErrNotFound).AnyTimes()
+
+	svc := NewUser(
+		u,
+		storagemock.NewMockSessionRepository(ctrl),
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		storagemock.NewMockUserTokenRepository(ctrl),
+		storagemock.NewMockAPITokenRepository(ctrl),
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		tokenmock.NewMockManager(ctrl),
+		WithMailer(&testMailer{}),
+		WithThrottle(
+			throttle.New(throttle.Policy{FreeAttempts: 1, BaseDelay: time.Hour}),
+			throttle.New(throttle.Policy{FreeAttempts: 2, BaseDelay: time.Hour}),
+		),
+	)
+
+	reset := func(ctx context.Context, email string) error {
+		_, err := svc.RequestPasswordReset(ctx, &pb.RequestPasswordResetRequest{Email: email})
+		return err
+	}
+
+	// every request is counted, unknown emails too
+	ctx := peerContext("192.0.2.1")
+	assert.NoError(t, reset(ctx, "user@example.org"))
+	assert.NoError(t, reset(ctx, "user@example.org"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(reset(peerContext("192.0.2.2"), "User@example.org")))
+
+	// the IP limit applies to any email
+	assert.NoError(t, reset(ctx, "other@example.org"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(reset(ctx, "third@example.org")))
+}
+
+func TestUser_ReauthenticateThrottle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid := uuid.New(), uuid.New()
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+
+	u := storagemock.NewMockUserRepository(ctrl)
+	u.EXPECT().Read(gomock.Any(), uid).Return(&model.User{ID: uid, Email: "user@example.org"}, nil).AnyTimes()
+	u.EXPECT().ReadByEmailAndPassword(gomock.Any(), "user@example.org", "pass").Return(&model.User{ID: uid}, nil).AnyTimes()
+
+	tf := storagemock.NewMockTwoFactorRepository(ctrl)
+	tf.EXPECT().Read(gomock.Any(), uid).Return(&model.TwoFactor{UserID: uid, Secret: secret, Enabled: true}, nil).AnyTimes()
+
+	svc := NewUser(
+		u,
+		storagemock.NewMockSessionRepository(ctrl),
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		tf,
+		storagemock.NewMockUserTokenRepository(ctrl),
+		storagemock.NewMockAPITokenRepository(ctrl),
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		tokenmock.NewMockManager(ctrl),
+		WithThrottle(throttle.New(throttle.Policy{FreeAttempts: 2, BaseDelay: time.Minute}), nil),
+	)
+
+	ctx := usercontext.WriteSessionID(usercontext.WriteUID(peerContext("192.0.2.1"), uid), sid)
+
+	// a missing code is not a guess
+	for i := 0; i < 3; i++ {
+		_, err = svc.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: "pass"})
+		assert.Equal(t, ReasonTwoFactorRequired, statusReason(err))
+	}
+
+	// wrong codes share the limit of the email with logins
+	_, err = svc.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: "pass", OtpCode: "abcdef"})
+	assert.Equal(t, ReasonTwoFactorInvalid, statusReason(err))
+	_, err = svc.DisableTwoFactor(ctx, &pb.DisableTwoFactorRequest{Password: "pass", OtpCode: "abcdef"})
+	assert.Equal(t, ReasonTwoFactorInvalid, statusReason(err))
+	_, err = svc.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentPassword: "pass", NewPassword: "new", OtpCode: "abcdef"})
+	assert.Equal(t, ReasonTwoFactorInvalid, statusReason(err))
+
+	_, err = svc.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: "pass", OtpCode: "abcdef"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, err = svc.Login(peerContext("192.0.2.2"), &pb.LoginRequest{Email: "user@example.org", Password: "pass"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,216 @@
+package grpcservice
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/totp"
+	"strings"
+	"time"
+)
+
+const (
+	// ReasonTwoFactorRequired is set in ErrorInfo when login requires a TOTP or recovery code
+	ReasonTwoFactorRequired = "TWO_FACTOR_REQUIRED"
+	// ReasonTwoFactorInvalid is set in ErrorInfo when provided TOTP or recovery code is wrong
+	ReasonTwoFactorInvalid = "TWO_FACTOR_INVALID"
+
+	totpIssuer         = "gophkeeper"
+	recoveryCodesCount = 10
+	recoveryCodeSize   = 5
+)
+
+// EnableTwoFactor starts TOTP enrollment, requires the password or its SRP proof,
+// second factor is required only after ConfirmTwoFactor
+func (s User) EnableTwoFactor(ctx context.Context, request *pb.EnableTwoFactorRequest) (*pb.EnableTwoFactorResponse, error) {
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch tf, err := s.twoFactor.Read(ctx, uid); {
+	case err == nil && tf.Enabled:
+		return nil, status.Error(codes.FailedPrecondition, "two-factor authentication is already enabled")
+	case err == nil, errors.Is(err, apperr.ErrNotFound):
+		// enrollment is allowed
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	u, err := s.reauthenticate(ctx, uid, request.GetPassword(), request.GetSrpProof(), "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if err := s.twoFactor.Save(ctx, &model.TwoFactor{UserID: uid, Secret: secret}); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.EnableTwoFactorResponse{
+		Secret:     secret,
+		OtpauthUri: totp.URI(totpIssuer, u.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables pending second factor with a code from the authenticator app, returns recovery codes
+func (s User) ConfirmTwoFactor(
+	ctx context.Context,
+	request *pb.ConfirmTwoFactorRequest,
+) (*pb.ConfirmTwoFactorResponse, error) {
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tf, err := s.twoFactor.Read(ctx, uid)
+	switch {
+	case err == nil && !tf.Enabled:
+		// pending enrollment
+	case err == nil, errors.Is(err, apperr.ErrNotFound):
+		return nil, status.Error(codes.FailedPrecondition, "no pending two-factor enrollment")
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	step, ok := totp.Validate(tf.Secret, request.GetOtpCode(), time.Now())
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "invalid code")
+	}
+
+	recoveryCodes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	switch err := s.twoFactor.Enable(ctx, uid, step, hashes); {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, apperr.ErrNotFound):
+		return nil, status.Error(codes.FailedPrecondition, "no pending two-factor enrollment")
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.ConfirmTwoFactorResponse{
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// DisableTwoFactor requires the password or its SRP proof and a TOTP or recovery code
+func (s User) DisableTwoFactor(
+	ctx context.Context,
+	request *pb.DisableTwoFactorRequest,
+) (*pb.DisableTwoFactorResponse, error) {
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch tf, err := s.twoFactor.Read(ctx, uid); {
+	case err == nil && tf.Enabled:
+		// enabled
+	case err == nil, errors.Is(err, apperr.ErrNotFound):
+		return nil, status.Error(codes.FailedPrecondition, "two-factor authentication is not enabled")
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	_, err = s.reauthenticate(ctx, uid, request.GetPassword(), request.GetSrpProof(), request.GetOtpCode(), request.GetRecoveryCode())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.twoFactor.Delete(ctx, uid); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.DisableTwoFactorResponse{}, nil
+}
+
+// checkTwoFactor of the user if enabled, failure is reported with specified code
+func (s User) checkTwoFactor(ctx context.Context, uid uuid.UUID, otpCode, recoveryCode string, c codes.Code) error {
+	tf, err := s.twoFactor.Read(ctx, uid)
+	switch {
+	case err == nil && tf.Enabled:
+		return s.verifyTwoFactor(ctx, tf, otpCode, recoveryCode, c)
+	case err == nil, errors.Is(err, apperr.ErrNotFound):
+		return nil
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// verifyTwoFactor code, recovery code is consumed and TOTP code can not be reused
+func (s User) verifyTwoFactor(ctx context.Context, tf *model.TwoFactor, otpCode, recoveryCode string, c codes.Code) error {
+	if recoveryCode != "" {
+		switch err := s.twoFactor.UseRecoveryCode(ctx, tf.UserID, hashRecoveryCode(recoveryCode)); {
+		case err == nil:
+			return nil
+		case errors.Is(err, apperr.ErrNotFound):
+			return reasonError(c, ReasonTwoFactorInvalid, "invalid recovery code")
+		default:
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	if otpCode == "" {
+		return reasonError(c, ReasonTwoFactorRequired, "two-factor code required")
+	}
+
+	step, ok := totp.Validate(tf.Secret, otpCode, time.Now())
+	if !ok {
+		return reasonError(c, ReasonTwoFactorInvalid, "invalid two-factor code")
+	}
+
+	switch err := s.twoFactor.UseStep(ctx, tf.UserID, step); {
+	case err == nil:
+		return nil
+	case errors.Is(err, apperr.ErrConflict):
+		return reasonError(c, ReasonTwoFactorInvalid, "two-factor code already used")
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// newRecoveryCodes returns codes to show once and their hashes to store
+func newRecoveryCodes() ([]string, []string, error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	recoveryCodes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+	for i := 0; i < recoveryCodesCount; i++ {
+		b := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+		c := strings.ToLower(enc.EncodeToString(b))
+		c = c[:len(c)/2] + "-" + c[len(c)/2:]
+
+		recoveryCodes = append(recoveryCodes, c)
+		hashes = append(hashes, hashRecoveryCode(c))
+	}
+
+	return recoveryCodes, hashes, nil
+}
+
+// hashRecoveryCode ignoring case and separators
+func hashRecoveryCode(c string) string {
+	c = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(c))
+	sum := sha256.Sum256([]byte(c))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,176 @@
+package grpcservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/server/model"
+	storagemock "gophkeeper/internal/server/storage/mock"
+	"gophkeeper/pkg/apperr"
+	tokenmock "gophkeeper/pkg/token/mock"
+	"gophkeeper/pkg/totp"
+	"gophkeeper/pkg/usercontext"
+)
+
+func TestUser_TwoFactorEnrollment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid := uuid.New(), uuid.New()
+	ctx := usercontext.WriteSessionID(usercontext.WriteUID(context.Background(), uid), sid)
+
+	var pending *model.TwoFactor
+
+	u := storagemock.NewMockUserRepository(ctrl)
+	u.EXPECT().Read(gomock.Any(), uid).Return(&model.User{ID: uid, Email: "user@example.org"}, nil).Times(2)
+	u.EXPECT().ReadByEmailAndPassword(gomock.Any(), "user@example.org", "wrong").Return(nil, apperr.ErrNotFound)
+	u.EXPECT().ReadByEmailAndPassword(gomock.Any(), "user@example.org", "pass").Return(&model.User{ID: uid}, nil)
+
+	tf := storagemock.NewMockTwoFactorRepository(ctrl)
+	tf.EXPECT().Read(gomock.Any(), uid).Return(nil, apperr.ErrNotFound).Times(3)
+	tf.EXPECT().Save(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, m *model.TwoFactor) error {
+		assert.False(t, m.Enabled)
+		pending = m
+		return nil
+	})
+	tf.EXPECT().Read(gomock.Any(), uid).DoAndReturn(func(context.Context, uuid.UUID) (*model.TwoFactor, error) {
+		return pending, nil
+	}).Times(2)
+	tf.EXPECT().Enable(gomock.Any(), uid, gomock.Any(), gomock.Len(recoveryCodesCount)).Return(nil)
+
+	svc := NewUser(
+		u,
+		storagemock.NewMockSessionRepository(ctrl),
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		tf,
+		storagemock.NewMockUserTokenRepository(ctrl),
+		storagemock.NewMockAPITokenRepository(ctrl),
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		tokenmock.NewMockManager(ctrl),
+	)
+
+	_, err := svc.EnableTwoFactor(ctx, &pb.EnableTwoFactorRequest{Password: "wrong"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	enable, err := svc.EnableTwoFactor(ctx, &pb.EnableTwoFactorRequest{Password: "pass"})
+	require.NoError(t, err)
+	assert.Equal(t, pending.Secret, enable.GetSecret())
+	assert.Contains(t, enable.GetOtpauthUri(), "otpauth://totp/gophkeeper:user@example.org")
+
+	_, err = svc.ConfirmTwoFactor(ctx, &pb.ConfirmTwoFactorRequest{OtpCode: "000000x"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	code, err := totp.Code(enable.GetSecret(), time.Now())
+	require.NoError(t, err)
+	confirm, err := svc.ConfirmTwoFactor(ctx, &pb.ConfirmTwoFactorRequest{OtpCode: code})
+	require.NoError(t, err)
+	assert.Len(t, confirm.GetRecoveryCodes(), recoveryCodesCount)
+}
+
+func TestUser_LoginTwoFactor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	uid := uuid.New()
+	user := &model.User{ID: uid, Email: "user@example.org"}
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	now := time.Now()
+	code, err := totp.Code(secret, now)
+	require.NoError(t, err)
+
+	u := storagemock.NewMockUserRepository(ctrl)
+	u.EXPECT().ReadByEmailAndPassword(gomock.Any(), "user@example.org", "pass").Return(user, nil).AnyTimes()
+
+	tf := storagemock.NewMockTwoFactorRepository(ctrl)
+	tf.EXPECT().Read(gomock.Any(), uid).Return(&model.TwoFactor{UserID: uid, Secret: secret, Enabled: true}, nil).AnyTimes()
+	tf.EXPECT().UseStep(gomock.Any(), uid, totp.Step(now)).Return(nil)
+	tf.EXPECT().UseStep(gomock.Any(), uid, totp.Step(now)).Return(apperr.ErrConflict)
+	tf.EXPECT().UseRecoveryCode(gomock.Any(), uid, hashRecoveryCode("abcd-efgh")).Return(nil)
+	tf.EXPECT().UseRecoveryCode(gomock.Any(), uid, hashRecoveryCode("abcd-efgh")).Return(apperr.ErrNotFound)
+
+	sr := storagemock.NewMockSessionRepository(ctrl)
+	sr.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&model.Session{ID: uuid.New(), UserID: uid}, nil).Times(2)
+	rt := storagemock.NewMockRefreshTokenRepository(ctrl)
+	rt.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&model.RefreshToken{}, nil).Times(2)
+	tm := tokenmock.NewMockManager(ctrl)
+	tm.EXPECT().Issue(gomock.Any(), gomock.Any()).Return("token", nil).Times(2)
+
+	svc := NewUser(u, sr, rt, tf, storagemock.NewMockUserTokenRepository(ctrl), storagemock.NewMockAPITokenRepository(ctrl), storagemock.NewMockServiceAccountRepository(ctrl), tm)
+	login := func(otp, recovery string) error {
+		_, err := svc.Login(ctx, &pb.LoginRequest{
+			Email:        "user@example.org",
+			Password:     "pass",
+			OtpCode:      otp,
+			RecoveryCode: recovery,
+		})
+		return err
+	}
+
+	err = login("", "")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, ReasonTwoFactorRequired, statusReason(err))
+
+	err = login("abcdef", "")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, ReasonTwoFactorInvalid, statusReason(err))
+
+	assert.NoError(t, login(code, ""))
+
+	// replayed code
+	err = login(code, "")
+	assert.Equal(t, ReasonTwoFactorInvalid, statusReason(err))
+
+	// recovery code works once, case and separators are ignored
+	assert.NoError(t, login("", "ABCDEFGH"))
+	err = login("", "abcd-efgh")
+	assert.Equal(t, ReasonTwoFactorInvalid, statusReason(err))
+}
+
+func TestUser_DisableTwoFactor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid := uuid.New(), uuid.New()
+	ctx := usercontext.WriteSessionID(usercontext.WriteUID(context.Background(), uid), sid)
+
+	u := storagemock.NewMockUserRepository(ctrl)
+	u.EXPECT().Read(gomock.Any(), uid).Return(&model.User{ID: uid, Email: "user@example.org"}, nil).AnyTimes()
+	u.EXPECT().ReadByEmailAndPassword(gomock.Any(), "user@example.org", "wrong").Return(nil, apperr.ErrNotFound)
+	u.EXPECT().ReadByEmailAndPassword(gomock.Any(), "user@example.org", "pass").Return(&model.User{ID: uid}, nil).Times(2)
+
+	tf := storagemock.NewMockTwoFactorRepository(ctrl)
+	tf.EXPECT().Read(gomock.Any(), uid).Return(&model.TwoFactor{UserID: uid, Secret: "ABC", Enabled: true}, nil).Times(5)
+	tf.EXPECT().UseRecoveryCode(gomock.Any(), uid, hashRecoveryCode("abcd-efgh")).Return(nil)
+	tf.EXPECT().Delete(gomock.Any(), uid).Return(nil)
+
+	svc := NewUser(
+		u,
+		storagemock.NewMockSessionRepository(ctrl),
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		tf,
+		storagemock.NewMockUserTokenRepository(ctrl),
+		storagemock.NewMockAPITokenRepository(ctrl),
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		tokenmock.NewMockManager(ctrl),
+	)
+
+	_, err := svc.DisableTwoFactor(ctx, &pb.DisableTwoFactorRequest{Password: "wrong", RecoveryCode: "abcd-efgh"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = svc.DisableTwoFactor(ctx, &pb.DisableTwoFactorRequest{Password: "pass"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, ReasonTwoFactorRequired, statusReason(err))
+
+	_, err = svc.DisableTwoFactor(ctx, &pb.DisableTwoFactorRequest{Password: "pass", RecoveryCode: "abcd-efgh"})
+	assert.NoError(t, err)
+}
@@ -2,37 +2,179 @@ package grpcservice
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/v2/auth"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	pb "gophkeeper/api/proto"
+	"gophkeeper/internal/server/mailer"
 	"gophkeeper/internal/server/model"
 	"gophkeeper/internal/server/storage"
+	"gophkeeper/internal/server/throttle"
 	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/logger"
 	"gophkeeper/pkg/token"
+	"gophkeeper/pkg/usercontext"
 	"time"
 )
 
-const tokenLifetime = time.Hour * 24 * 365
+const (
+	DefaultAccessTokenLifetime  = time.Minute * 15
+	DefaultRefreshTokenLifetime = time.Hour * 24 * 30
+
+	tokenSize       = 32
+	maxDeviceLength = 255
+)
 
 type User struct {
 	pb.UnimplementedUserServer
 
-	users storage.UserRepository
-	token token.Manager
+	users           storage.UserRepository
+	sessions        storage.SessionRepository
+	refreshTokens   storage.RefreshTokenRepository
+	twoFactor       storage.TwoFactorRepository
+	userTokens      storage.UserTokenRepository
+	apiTokens       storage.APITokenRepository
+	serviceAccounts storage.ServiceAccountRepository
+	certificates    storage.ClientCertificateRepository
+	mailer          mailer.Mailer
+	emailLimiter    *throttle.Limiter
+	ipLimiter       *throttle.Limiter
+	handshakes      *srpHandshakes
+	token           token.Manager
+	auth            grpcauth.AuthFunc
+	accessLifetime  time.Duration
+	refreshLifetime time.Duration
+	srpOnly         bool
+}
+
+type UserOption func(*User)
+
+// WithAccessTokenLifetime sets lifetime of issued access tokens
+func WithAccessTokenLifetime(d time.Duration) UserOption {
+	return func(s *User) {
+		if d > 0 {
+			s.accessLifetime = d
+		}
+	}
+}
+
+// WithRefreshTokenLifetime sets lifetime of issued refresh tokens
+func WithRefreshTokenLifetime(d time.Duration) UserOption {
+	return func(s *User) {
+		if d > 0 {
+			s.refreshLifetime = d
+		}
+	}
+}
+
+// WithMailer enables email verification and password reset mails, related calls are unimplemented without it
+func WithMailer(m mailer.Mailer) UserOption {
+	return func(s *User) {
+		s.mailer = m
+	}
+}
+
+// WithCertificates enables registration of client certificates, related calls are unimplemented without it
+func WithCertificates(r storage.ClientCertificateRepository) UserOption {
+	return func(s *User) {
+		s.certificates = r
+	}
+}
+
+// WithSRPSecret derives salts shown for unknown emails by StartSRPLogin from the secret,
+// so they stay the same across restarts like salts of existing users
+func WithSRPSecret(secret string) UserOption {
+	return func(s *User) {
+		s.handshakes.setSecret(secret)
+	}
+}
+
+// WithSRPOnly rejects passwords sent to the server by Register, Login and EnrollSRP and in place of SRP fields
+// of other calls, users without an SRP verifier enroll by resetting the password
+func WithSRPOnly() UserOption {
+	return func(s *User) {
+		s.srpOnly = true
+	}
 }
 
-func NewUser(u storage.UserRepository, tm token.Manager) *User {
-	return &User{
-		users: u,
-		token: tm,
+// WithThrottle limits failed logins and password reset requests per email and per peer IP,
+// registrations are limited per peer IP
+func WithThrottle(emails, ips *throttle.Limiter) UserOption {
+	return func(s *User) {
+		s.emailLimiter = emails
+		s.ipLimiter = ips
 	}
 }
 
+func NewUser(
+	u storage.UserRepository,
+	sessions storage.SessionRepository,
+	rt storage.RefreshTokenRepository,
+	tf storage.TwoFactorRepository,
+	ut storage.UserTokenRepository,
+	at storage.APITokenRepository,
+	sa storage.ServiceAccountRepository,
+	tm token.Manager,
+	opts ...UserOption,
+) *User {
+	s := &User{
+		users:           u,
+		sessions:        sessions,
+		refreshTokens:   rt,
+		twoFactor:       tf,
+		userTokens:      ut,
+		apiTokens:       at,
+		serviceAccounts: sa,
+		handshakes:      newSRPHandshakes(),
+		token:           tm,
+		auth:            BuildAuthFunc(tm, sessions, at, sa),
+		accessLifetime:  DefaultAccessTokenLifetime,
+		refreshLifetime: DefaultRefreshTokenLifetime,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
 func (s User) Register(ctx context.Context, request *pb.RegisterRequest) (*pb.RegisterResponse, error) {
-	u := &model.User{
+	if err := s.passwordAllowed(); err != nil {
+		return nil, err
+	}
+
+	t, rt, err := s.register(ctx, &model.User{
 		Email:    request.GetEmail(),
 		Password: request.GetPassword(),
+	}, request.GetDevice())
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.RegisterResponse{
+		Token:        t,
+		RefreshToken: rt,
+	}, nil
+}
+
+// register the user with a password or an SRP verifier, returns access and refresh tokens
+func (s User) register(ctx context.Context, u *model.User, device string) (string, string, error) {
+	if err := s.throttleRegister(ctx, peerIP(ctx)); err != nil {
+		return "", "", err
+	}
+
+	if !validEmail(u.Email) {
+		return "", "", status.Error(codes.InvalidArgument, "invalid email")
 	}
 
 	u, err := s.users.Create(ctx, u)
@@ -40,28 +182,57 @@ func (s User) Register(ctx context.Context, request *pb.RegisterRequest) (*pb.Re
 	case nil:
 		// all is ok
 	case apperr.ErrConflict:
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+		return "", "", status.Error(codes.InvalidArgument, err.Error())
 	default:
-		return nil, status.Error(codes.Internal, err.Error())
+		return "", "", status.Error(codes.Internal, err.Error())
 	}
 
-	t, err := s.token.Issue(u, tokenLifetime)
+	t, rt, err := s.issueTokens(ctx, u, device)
 	if err != nil {
-		return nil, err
+		return "", "", status.Error(codes.Internal, err.Error())
 	}
 
-	return &pb.RegisterResponse{
-		Token: t,
-	}, nil
+	// the account is usable anyway, verification mail can be requested again
+	if s.mailer != nil {
+		if err := s.sendVerificationEmail(ctx, u); err != nil {
+			l := logger.Ctx(ctx)
+			l.Warn().Err(err).Msg("Verification mail is not sent")
+		}
+	}
+
+	return t, rt, nil
 }
 
 func (s User) Login(ctx context.Context, request *pb.LoginRequest) (*pb.LoginResponse, error) {
-	u, err := s.users.ReadByEmailAndPassword(ctx, request.GetEmail(), request.GetPassword())
+	if err := s.passwordAllowed(); err != nil {
+		return nil, err
+	}
+
+	a, err := s.reserveLogin(ctx, request.GetEmail(), peerIP(ctx))
 	if err != nil {
 		return nil, err
 	}
 
-	t, err := s.token.Issue(u, tokenLifetime)
+	u, err := s.users.ReadByEmailAndPassword(ctx, request.GetEmail(), request.GetPassword())
+	switch {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, apperr.ErrNotFound):
+		return nil, status.Error(codes.Unauthenticated, "invalid email or password")
+	default:
+		a.refund()
+		return nil, fmt.Errorf("read user: %w", err)
+	}
+
+	if err := s.checkTwoFactor(ctx, u.ID, request.GetOtpCode(), request.GetRecoveryCode(), codes.Unauthenticated); err != nil {
+		if statusReason(err) != ReasonTwoFactorInvalid {
+			a.refund()
+		}
+		return nil, err
+	}
+	s.succeedLogin(a, request.GetEmail())
+
+	t, rt, err := s.issueTokens(ctx, u, request.GetDevice())
 	switch err {
 	case nil:
 		// all is ok
@@ -72,14 +243,381 @@ func (s User) Login(ctx context.Context, request *pb.LoginRequest) (*pb.LoginRes
 	}
 
 	return &pb.LoginResponse{
-		Token: t,
+		Token:         t,
+		RefreshToken:  rt,
+		EmailVerified: u.EmailVerified,
+	}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new pair of access and refresh tokens, the used one is revoked
+func (s User) RefreshToken(ctx context.Context, request *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
+	if request.GetRefreshToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty refresh token")
+	}
+
+	rt, next, err := s.newRefreshToken()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	next, err = s.refreshTokens.Rotate(ctx, hashToken(request.GetRefreshToken()), next)
+	switch {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, apperr.ErrNotFound):
+		return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+	case errors.Is(err, model.ErrRefreshTokenReused):
+		return nil, status.Error(codes.Unauthenticated, "refresh token reused, the session is revoked")
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	t, err := s.token.Issue(&model.Session{ID: next.SessionID, UserID: next.UserID}, s.accessLifetime)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.RefreshTokenResponse{
+		Token:        t,
+		RefreshToken: rt,
 	}, nil
 }
 
+// Logout revokes the current session
+func (s User) Logout(ctx context.Context, _ *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+	uid, sid, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sessions.Delete(ctx, uid, sid); err != nil && !errors.Is(err, apperr.ErrNotFound) {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.LogoutResponse{}, nil
+}
+
+func (s User) ListSessions(ctx context.Context, _ *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	uid, sid, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ss, err := s.sessions.List(ctx, uid)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &pb.ListSessionsResponse{
+		Sessions: make([]*pb.Session, 0, len(ss)),
+	}
+	for _, m := range ss {
+		resp.Sessions = append(resp.Sessions, &pb.Session{
+			Id:         m.ID.String(),
+			Device:     m.Device,
+			Ip:         m.IP,
+			CreatedAt:  timestamppb.New(m.CreatedAt),
+			LastUsedAt: timestamppb.New(m.LastUsedAt),
+			Current:    m.ID == sid,
+		})
+	}
+
+	return resp, nil
+}
+
+// RevokeSession of the user, its tokens stop working immediately
+func (s User) RevokeSession(ctx context.Context, request *pb.RevokeSessionRequest) (*pb.RevokeSessionResponse, error) {
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(request.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid session id")
+	}
+
+	switch err := s.sessions.Delete(ctx, uid, id); {
+	case err == nil:
+		// all is ok
+	case errors.Is(err, apperr.ErrNotFound):
+		return nil, status.Error(codes.NotFound, "session not found")
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.RevokeSessionResponse{}, nil
+}
+
+// RevokeAllOtherSessions of the user keeping the current one
+func (s User) RevokeAllOtherSessions(
+	ctx context.Context,
+	_ *pb.RevokeAllOtherSessionsRequest,
+) (*pb.RevokeAllOtherSessionsResponse, error) {
+	uid, sid, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := s.sessions.DeleteOthers(ctx, uid, sid)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.RevokeAllOtherSessionsResponse{
+		Revoked: n,
+	}, nil
+}
+
+// ChangePassword of the user, all other sessions and API tokens of the user are revoked,
+// tokens of its service accounts are kept. The server keeps no vault key, content of e2e
+// clients is encrypted with their own keys, so nothing is re-keyed here.
+func (s User) ChangePassword(ctx context.Context, request *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	uid, sid, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nv := request.GetNewSrpVerifier()
+	if err := s.validNewCredentials(request.GetNewPassword(), nv); err != nil {
+		return nil, err
+	}
+
+	_, err = s.reauthenticate(
+		ctx, uid, request.GetCurrentPassword(), request.GetCurrentSrpProof(), request.GetOtpCode(), request.GetRecoveryCode(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.updateCredentials(ctx, uid, request.GetNewPassword(), nv); err != nil {
+		return nil, err
+	}
+
+	n, err := s.sessions.DeleteOthers(ctx, uid, sid)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	t, err := s.apiTokens.DeleteUserTokens(ctx, uid)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.ChangePasswordResponse{
+		RevokedSessions:  n,
+		RevokedApiTokens: t,
+	}, nil
+}
+
+// DeleteAccount of the user with all secrets, sessions and tokens
+func (s User) DeleteAccount(ctx context.Context, request *pb.DeleteAccountRequest) (*pb.DeleteAccountResponse, error) {
+	uid, _, err := readSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.reauthenticate(ctx, uid, request.GetPassword(), request.GetSrpProof(), request.GetOtpCode(), request.GetRecoveryCode())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.users.Delete(ctx, uid); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.DeleteAccountResponse{}, nil
+}
+
+// reauthenticate the user for sensitive operations with the password or its SRP proof and
+// the second factor if enabled. The attempt shares the login limits of the email, so codes
+// and passwords can't be guessed here instead of at login.
+func (s User) reauthenticate(
+	ctx context.Context,
+	uid uuid.UUID,
+	password string,
+	proof *pb.SRPProof,
+	otpCode, recoveryCode string,
+) (*model.User, error) {
+	u, err := s.users.Read(ctx, uid)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	a, err := s.reserveLogin(ctx, u.Email, peerIP(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkPassword(ctx, u, password, proof); err != nil {
+		// a wrong proof is already counted by the handshake
+		if proof != nil || status.Code(err) != codes.PermissionDenied {
+			a.refund()
+		}
+		return nil, err
+	}
+
+	if err := s.checkTwoFactor(ctx, uid, otpCode, recoveryCode, codes.PermissionDenied); err != nil {
+		if statusReason(err) != ReasonTwoFactorInvalid {
+			a.refund()
+		}
+		return nil, err
+	}
+	s.succeedLogin(a, u.Email)
+
+	return u, nil
+}
+
+// checkPassword of the authenticated user for sensitive operations, SRP users prove it instead
+func (s User) checkPassword(ctx context.Context, u *model.User, password string, proof *pb.SRPProof) error {
+	if proof != nil {
+		return s.checkSRPProof(u.ID, proof)
+	}
+	if err := s.passwordAllowed(); err != nil {
+		return err
+	}
+
+	switch _, err := s.users.ReadByEmailAndPassword(ctx, u.Email, password); {
+	case err == nil:
+		return nil
+	case errors.Is(err, apperr.ErrNotFound):
+		return status.Error(codes.PermissionDenied, "invalid password")
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// passwordAllowed unless the server accepts SRP only
+func (s User) passwordAllowed() error {
+	if s.srpOnly {
+		return reasonError(codes.FailedPrecondition, ReasonPasswordDisabled, "passwords are disabled, use srp")
+	}
+	return nil
+}
+
+// validNewCredentials set by the new password or the SRP verifier replacing it
+func (s User) validNewCredentials(password string, v *pb.SRPVerifier) error {
+	if v != nil {
+		return validSRPVerifier(v)
+	}
+	if err := s.passwordAllowed(); err != nil {
+		return err
+	}
+	if password == "" {
+		return status.Error(codes.InvalidArgument, "empty new password")
+	}
+	return nil
+}
+
+// issueTokens in a new session of the user, returns access and refresh tokens
+func (s User) issueTokens(ctx context.Context, u *model.User, device string) (string, string, error) {
+	if device == "" {
+		device = "unknown"
+	}
+	if len(device) > maxDeviceLength {
+		device = device[:maxDeviceLength]
+	}
+
+	rt, m, err := s.newRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	session, err := s.sessions.Create(ctx, &model.Session{
+		UserID:    u.ID,
+		Device:    device,
+		IP:        peerIP(ctx),
+		ExpiresAt: m.ExpiresAt,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("create session: %w", err)
+	}
+
+	t, err := s.token.Issue(session, s.accessLifetime)
+	if err != nil {
+		return "", "", fmt.Errorf("issue access token: %w", err)
+	}
+
+	m.UserID = u.ID
+	m.SessionID = session.ID
+
+	if _, err := s.refreshTokens.Create(ctx, m); err != nil {
+		return "", "", fmt.Errorf("store refresh token: %w", err)
+	}
+
+	return t, rt, nil
+}
+
+// newRefreshToken generates a random token, returned model contains its hash only
+func (s User) newRefreshToken() (string, *model.RefreshToken, error) {
+	rt, err := randomToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("generate refresh token: %w", err)
+	}
+
+	return rt, &model.RefreshToken{
+		TokenHash: hashToken(rt),
+		ExpiresAt: time.Now().Add(s.refreshLifetime),
+	}, nil
+}
+
+// randomToken to be given to the user, store its hash only
+func randomToken() (string, error) {
+	b := make([]byte, tokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(t string) string {
+	sum := sha256.Sum256([]byte(t))
+	return hex.EncodeToString(sum[:])
+}
+
 func (s *User) RegisterService(r grpc.ServiceRegistrar) {
 	pb.RegisterUserServer(r, s)
 }
 
+// AuthFuncOverride skips auth for methods issuing tokens and ones authorized by mailed tokens,
+// API tokens are for secrets only and can not manage the account
 func (s *User) AuthFuncOverride(ctx context.Context, fullMethodName string) (context.Context, error) {
+	switch fullMethodName {
+	case "/api.User/Register", "/api.User/Login", "/api.User/RefreshToken",
+		"/api.User/VerifyEmail", "/api.User/RequestPasswordReset", "/api.User/ResetPassword",
+		"/api.User/RegisterSRP", "/api.User/StartSRPLogin", "/api.User/FinishSRPLogin":
+		return ctx, nil
+	}
+
+	ctx, err := s.auth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p, err := readPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.Scoped() {
+		return nil, status.Error(codes.PermissionDenied, "api tokens can not be used to manage the account")
+	}
 	return ctx, nil
 }
+
+// readPrincipal written by the auth func, calls without one are denied, so a broken auth func fails closed
+func readPrincipal(ctx context.Context) (usercontext.Principal, error) {
+	p, ok := usercontext.ReadPrincipal(ctx)
+	if !ok {
+		return usercontext.Principal{}, status.Error(codes.PermissionDenied, "no authenticated principal")
+	}
+	return p, nil
+}
+
+// readSession of the authenticated user from the context
+func readSession(ctx context.Context) (uuid.UUID, uuid.UUID, error) {
+	uid := usercontext.ReadUID(ctx)
+	sid := usercontext.ReadSessionID(ctx)
+	if !uid.Valid || !sid.Valid {
+		return uuid.UUID{}, uuid.UUID{}, status.Error(codes.Unauthenticated, apperr.ErrUnauthorized.Error())
+	}
+	return uid.UUID, sid.UUID, nil
+}
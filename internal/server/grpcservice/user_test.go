@@ -6,13 +6,20 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	pb "gophkeeper/api/proto"
 	"gophkeeper/internal/server/model"
 	storagemock "gophkeeper/internal/server/storage/mock"
+	"gophkeeper/pkg/apperr"
+	"gophkeeper/pkg/token"
 	tokenmock "gophkeeper/pkg/token/mock"
+	"gophkeeper/pkg/usercontext"
 	"net"
 	"testing"
+	"time"
 )
 
 func TestIntegration(t *testing.T) {
@@ -28,22 +35,45 @@ func TestIntegration(t *testing.T) {
 		Password: "pass",
 	}
 
+	sidOk := uuid.New()
+
 	// mocking token manager
 	tm := tokenmock.NewMockManager(ctrl)
-	tm.EXPECT().Issue(userOk, gomock.Any()).AnyTimes().Return("token1", nil)
+	tm.EXPECT().Issue(&model.Session{
+		ID:        sidOk,
+		UserID:    uidOk,
+		Device:    "laptop",
+		IP:        "127.0.0.1",
+		ExpiresAt: time.Time{},
+	}, gomock.Any()).AnyTimes().Return("token1", nil)
+
+	// mocking session repo
+	sr := storagemock.NewMockSessionRepository(ctrl)
+	sr.EXPECT().Create(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, m *model.Session) (*model.Session, error) {
+			assert.WithinDuration(t, time.Now().Add(DefaultRefreshTokenLifetime), m.ExpiresAt, time.Minute)
+			m.ID = sidOk
+			m.ExpiresAt = time.Time{}
+			return m, nil
+		},
+	)
 
 	// mocking user repo
 	u := storagemock.NewMockUserRepository(ctrl)
 	u.EXPECT().Create(gomock.Any(), &model.User{
 		Email:    "user1@example.org",
 		Password: "pass",
-	}).AnyTimes().Return(
-		&model.User{
-			ID:       uidOk,
-			Email:    "user1@example.org",
-			Password: "pass",
+	}).AnyTimes().Return(userOk, nil)
+
+	// mocking refresh token repo
+	rt := storagemock.NewMockRefreshTokenRepository(ctrl)
+	rt.EXPECT().Create(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, m *model.RefreshToken) (*model.RefreshToken, error) {
+			assert.Equal(t, uidOk, m.UserID)
+			assert.Equal(t, sidOk, m.SessionID)
+			assert.Len(t, m.TokenHash, 64)
+			return m, nil
 		},
-		nil,
 	)
 
 	// run mocked server
@@ -52,7 +82,7 @@ func TestIntegration(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	svc := NewUser(u, tm)
+	svc := NewUser(u, sr, rt, storagemock.NewMockTwoFactorRepository(ctrl), storagemock.NewMockUserTokenRepository(ctrl), storagemock.NewMockAPITokenRepository(ctrl), storagemock.NewMockServiceAccountRepository(ctrl), tm)
 
 	srv := grpc.NewServer()
 	pb.RegisterUserServer(srv, svc)
@@ -77,7 +107,226 @@ func TestIntegration(t *testing.T) {
 	resp, err := cl.Register(ctx, &pb.RegisterRequest{
 		Email:    "user1@example.org",
 		Password: "pass",
+		Device:   "laptop",
 	})
 	assert.NoError(t, err)
 	assert.Equal(t, resp.Token, "token1")
+	assert.NotEmpty(t, resp.RefreshToken)
+}
+
+func TestUser_RefreshToken(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid := uuid.New()
+	sid := uuid.New()
+
+	tm := tokenmock.NewMockManager(ctrl)
+	tm.EXPECT().Issue(&model.Session{ID: sid, UserID: uid}, 5*time.Minute).Return("token2", nil)
+
+	rt := storagemock.NewMockRefreshTokenRepository(ctrl)
+	rt.EXPECT().Rotate(gomock.Any(), hashToken("valid"), gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ string, next *model.RefreshToken) (*model.RefreshToken, error) {
+			assert.WithinDuration(t, time.Now().Add(time.Hour), next.ExpiresAt, time.Minute)
+			next.UserID = uid
+			next.SessionID = sid
+			return next, nil
+		},
+	)
+	rt.EXPECT().Rotate(gomock.Any(), hashToken("used"), gomock.Any()).Return(nil, apperr.ErrNotFound)
+	rt.EXPECT().Rotate(gomock.Any(), hashToken("replayed"), gomock.Any()).Return(nil, model.ErrRefreshTokenReused)
+
+	svc := NewUser(
+		storagemock.NewMockUserRepository(ctrl),
+		storagemock.NewMockSessionRepository(ctrl),
+		rt,
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		storagemock.NewMockUserTokenRepository(ctrl),
+		storagemock.NewMockAPITokenRepository(ctrl),
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		tm,
+		WithAccessTokenLifetime(5*time.Minute),
+		WithRefreshTokenLifetime(time.Hour),
+	)
+
+	resp, err := svc.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: "valid"})
+	assert.NoError(t, err)
+	assert.Equal(t, "token2", resp.GetToken())
+	assert.NotEmpty(t, resp.GetRefreshToken())
+	assert.NotEqual(t, "valid", resp.GetRefreshToken())
+
+	_, err = svc.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: "used"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = svc.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: "replayed"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "revoked")
+
+	_, err = svc.RefreshToken(ctx, &pb.RefreshTokenRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUser_Sessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid, other := uuid.New(), uuid.New(), uuid.New()
+	ctx := usercontext.WriteSessionID(usercontext.WriteUID(context.Background(), uid), sid)
+
+	sr := storagemock.NewMockSessionRepository(ctrl)
+	sr.EXPECT().List(gomock.Any(), uid).Return([]*model.Session{
+		{ID: sid, UserID: uid, Device: "laptop", IP: "10.0.0.1"},
+		{ID: other, UserID: uid, Device: "phone", IP: "10.0.0.2"},
+	}, nil)
+	sr.EXPECT().Delete(gomock.Any(), uid, other).Return(nil)
+	sr.EXPECT().Delete(gomock.Any(), uid, other).Return(apperr.ErrNotFound)
+	sr.EXPECT().DeleteOthers(gomock.Any(), uid, sid).Return(int64(2), nil)
+	sr.EXPECT().Delete(gomock.Any(), uid, sid).Return(nil)
+
+	svc := NewUser(
+		storagemock.NewMockUserRepository(ctrl),
+		sr,
+		storagemock.NewMockRefreshTokenRepository(ctrl),
+		storagemock.NewMockTwoFactorRepository(ctrl),
+		storagemock.NewMockUserTokenRepository(ctrl),
+		storagemock.NewMockAPITokenRepository(ctrl),
+		storagemock.NewMockServiceAccountRepository(ctrl),
+		tokenmock.NewMockManager(ctrl),
+	)
+
+	list, err := svc.ListSessions(ctx, &pb.ListSessionsRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, list.GetSessions(), 2) {
+		assert.True(t, list.GetSessions()[0].GetCurrent())
+		assert.Equal(t, "phone", list.GetSessions()[1].GetDevice())
+		assert.False(t, list.GetSessions()[1].GetCurrent())
+	}
+
+	_, err = svc.RevokeSession(ctx, &pb.RevokeSessionRequest{Id: other.String()})
+	assert.NoError(t, err)
+	_, err = svc.RevokeSession(ctx, &pb.RevokeSessionRequest{Id: other.String()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = svc.RevokeSession(ctx, &pb.RevokeSessionRequest{Id: "bad"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	revoked, err := svc.RevokeAllOtherSessions(ctx, &pb.RevokeAllOtherSessionsRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), revoked.GetRevoked())
+
+	_, err = svc.Logout(ctx, &pb.LogoutRequest{})
+	assert.NoError(t, err)
+
+	_, err = svc.ListSessions(context.Background(), &pb.ListSessionsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestBuildAuthFunc(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid := uuid.New(), uuid.New()
+
+	tm, err := token.NewJWT("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withSession, err := tm.Issue(&model.Session{ID: sid, UserID: uid}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutSession, err := tm.Issue(&model.User{ID: uid}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sr := storagemock.NewMockSessionRepository(ctrl)
+	sr.EXPECT().Touch(gomock.Any(), sid, "").Return(&model.Session{ID: sid, UserID: uid}, nil)
+	sr.EXPECT().Touch(gomock.Any(), sid, "").Return(nil, apperr.ErrNotFound)
+
+	auth := BuildAuthFunc(tm, sr, storagemock.NewMockAPITokenRepository(ctrl), storagemock.NewMockServiceAccountRepository(ctrl))
+	bearer := func(tk string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "bearer "+tk))
+	}
+
+	ctx, err := auth(bearer(withSession))
+	assert.NoError(t, err)
+	assert.Equal(t, uid, usercontext.ReadUID(ctx).UUID)
+	assert.Equal(t, sid, usercontext.ReadSessionID(ctx).UUID)
+
+	// revoked session
+	_, err = auth(bearer(withSession))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = auth(bearer(withoutSession))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestUser_ChangePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid := uuid.New(), uuid.New()
+	ctx := usercontext.WriteSessionID(usercontext.WriteUID(context.Background(), uid), sid)
+
+	u := storagemock.NewMockUserRepository(ctrl)
+	u.EXPECT().Read(gomock.Any(), uid).Return(&model.User{ID: uid, Email: "user@example.org"}, nil).AnyTimes()
+	u.EXPECT().ReadByEmailAndPassword(gomock.Any(), "user@example.org", "wrong").Return(nil, apperr.ErrNotFound)
+	u.EXPECT().ReadByEmailAndPassword(gomock.Any(), "user@example.org", "old").Return(&model.User{ID: uid}, nil)
+	u.EXPECT().UpdatePassword(gomock.Any(), uid, "new").Return(nil)
+
+	tf := storagemock.NewMockTwoFactorRepository(ctrl)
+	tf.EXPECT().Read(gomock.Any(), uid).Return(nil, apperr.ErrNotFound)
+
+	sr := storagemock.NewMockSessionRepository(ctrl)
+	sr.EXPECT().DeleteOthers(gomock.Any(), uid, sid).Return(int64(3), nil)
+
+	at := storagemock.NewMockAPITokenRepository(ctrl)
+	at.EXPECT().DeleteUserTokens(gomock.Any(), uid).Return(int64(2), nil)
+
+	svc := NewUser(u, sr, storagemock.NewMockRefreshTokenRepository(ctrl), tf, storagemock.NewMockUserTokenRepository(ctrl), at, storagemock.NewMockServiceAccountRepository(ctrl), tokenmock.NewMockManager(ctrl))
+
+	_, err := svc.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentPassword: "old"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = svc.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "new"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	resp, err := svc.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentPassword: "old", NewPassword: "new"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), resp.GetRevokedSessions())
+	assert.Equal(t, int64(2), resp.GetRevokedApiTokens())
+}
+
+func TestUser_DeleteAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uid, sid := uuid.New(), uuid.New()
+	ctx := usercontext.WriteSessionID(usercontext.WriteUID(context.Background(), uid), sid)
+
+	u := storagemock.NewMockUserRepository(ctrl)
+	u.EXPECT().Read(gomock.Any(), uid).Return(&model.User{ID: uid, Email: "user@example.org"}, nil).AnyTimes()
+	u.EXPECT().ReadByEmailAndPassword(gomock.Any(), "user@example.org", "wrong").Return(nil, apperr.ErrNotFound)
+	u.EXPECT().ReadByEmailAndPassword(gomock.Any(), "user@example.org", "Password").Return(&model.User{ID: uid}, nil).Times(2)
+	u.EXPECT().Delete(gomock.Any(), uid).Return(nil)
+
+	tf := storagemock.NewMockTwoFactorRepository(ctrl)
+	gomock.InOrder(
+		tf.EXPECT().Read(gomock.Any(), uid).Return(&model.TwoFactor{UserID: uid, Enabled: true}, nil),
+		tf.EXPECT().Read(gomock.Any(), uid).Return(nil, apperr.ErrNotFound),
+	)
+
+	svc := NewUser(u, storagemock.NewMockSessionRepository(ctrl), storagemock.NewMockRefreshTokenRepository(ctrl), tf, storagemock.NewMockUserTokenRepository(ctrl), storagemock.NewMockAPITokenRepository(ctrl), storagemock.NewMockServiceAccountRepository(ctrl), tokenmock.NewMockManager(ctrl))
+
+	_, err := svc.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: "wrong"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = svc.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: "Password"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, ReasonTwoFactorRequired, statusReason(err))
+
+	_, err = svc.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: "Password"})
+	assert.NoError(t, err)
 }
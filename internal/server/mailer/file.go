@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Mailer interface implementation
+var _ Mailer = (*File)(nil)
+
+// File mailer appends messages to a file in the mbox format, so it can be read by a mail client
+type File struct {
+	mu   sync.Mutex
+	path string
+	from string
+}
+
+func NewFile(path, from string) *File {
+	return &File{
+		path: path,
+		from: from,
+	}
+}
+
+// Send implementation of interface Mailer
+func (m *File) Send(_ context.Context, msg Message) error {
+	if err := validHeader(msg.To); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+
+	now := time.Now()
+	_, err = fmt.Fprintf(f, "From %s %s\n%s\n", m.from, now.UTC().Format(time.ANSIC), msg.Bytes(m.from, now))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"context"
+	"gophkeeper/pkg/logger"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Mailer interface implementation
+var _ Mailer = (*Log)(nil)
+
+// Log mailer writes messages to the log with secrets redacted, use for development only
+type Log struct {
+	l       logger.Logger
+	secrets bool
+}
+
+type LogOption func(*Log)
+
+// WithSecrets logs secrets of messages as is, so mailed tokens can be used in development
+func WithSecrets() LogOption {
+	return func(m *Log) {
+		m.secrets = true
+	}
+}
+
+func NewLog(l logger.Logger, opts ...LogOption) *Log {
+	m := &Log{
+		l: l.WithComponent("mailer"),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Send implementation of interface Mailer
+func (m *Log) Send(_ context.Context, msg Message) error {
+	body := msg.Body
+	if !m.secrets {
+		for _, s := range msg.Secrets {
+			if s != "" {
+				body = strings.ReplaceAll(body, s, redacted)
+			}
+		}
+	}
+
+	m.l.Info().
+		Str("to", msg.To).
+		Str("subject", msg.Subject).
+		Str("body", body).
+		Msg("Mail")
+	return nil
+}
@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Mailer delivers messages to users
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	// Secrets in the body, e.g. tokens, mailers writing messages anywhere but to the recipient redact them
+	Secrets []string
+}
+
+// Bytes of the message in the RFC 5322 format with CRLF line endings
+func (m Message) Bytes(from string, date time.Time) []byte {
+	var b bytes.Buffer
+
+	header := func(name, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+	}
+	header("From", from)
+	header("To", m.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", `text/plain; charset="utf-8"`)
+	header("Content-Transfer-Encoding", "8bit")
+	b.WriteString("\r\n")
+
+	body := strings.ReplaceAll(m.Body, "\r\n", "\n")
+	for _, line := range strings.Split(body, "\n") {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+
+	return b.Bytes()
+}
+
+// validHeader rejects values able to inject headers
+func validHeader(v string) error {
+	if strings.ContainsAny(v, "\r\n") {
+		return fmt.Errorf("invalid header value %q", v)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package mailer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gophkeeper/pkg/logger"
+)
+
+func TestMessage_Bytes(t *testing.T) {
+	m := Message{
+		To:      "user@example.org",
+		Subject: "Привет",
+		Body:    "line 1\nline 2",
+	}
+
+	b := string(m.Bytes("keeper@example.org", time.Date(2022, 10, 20, 12, 0, 0, 0, time.UTC)))
+
+	assert.Contains(t, b, "From: keeper@example.org\r\n")
+	assert.Contains(t, b, "To: user@example.org\r\n")
+	assert.Contains(t, b, "Subject: =?utf-8?q?")
+	assert.Contains(t, b, "Date: Thu, 20 Oct 2022 12:00:00 +0000\r\n")
+	assert.True(t, strings.HasSuffix(b, "\r\n\r\nline 1\r\nline 2\r\n"))
+}
+
+func TestLog_Send(t *testing.T) {
+	msg := Message{To: "a@example.org", Subject: "one", Body: "run: gkcli auth reset token-1", Secrets: []string{"token-1"}}
+
+	var buf bytes.Buffer
+	require.NoError(t, NewLog(logger.Logger{Logger: zerolog.New(&buf)}).Send(context.Background(), msg))
+	assert.Contains(t, buf.String(), "a@example.org")
+	assert.Contains(t, buf.String(), "gkcli auth reset [REDACTED]")
+	assert.NotContains(t, buf.String(), "token-1")
+
+	buf.Reset()
+	require.NoError(t, NewLog(logger.Logger{Logger: zerolog.New(&buf)}, WithSecrets()).Send(context.Background(), msg))
+	assert.Contains(t, buf.String(), "gkcli auth reset token-1")
+}
+
+func TestFile_Send(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.mbox")
+	m := NewFile(path, "keeper@example.org")
+
+	require.NoError(t, m.Send(context.Background(), Message{To: "a@example.org", Subject: "one", Body: "token-1"}))
+	require.NoError(t, m.Send(context.Background(), Message{To: "b@example.org", Subject: "two", Body: "token-2"}))
+	assert.Error(t, m.Send(context.Background(), Message{To: "c@example.org\r\nBcc: evil@example.org"}))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(b), "From keeper@example.org "))
+	assert.Contains(t, string(b), "token-1")
+	assert.Contains(t, string(b), "token-2")
+	assert.NotContains(t, string(b), "evil")
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+}
+
+func TestSMTP_Send(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	received := make(chan string, 1)
+	go serveSMTP(t, ln, received)
+
+	m := NewSMTP(ln.Addr().String(), "keeper@example.org")
+	require.NoError(t, m.Send(context.Background(), Message{To: "user@example.org", Subject: "Hi", Body: "token"}))
+
+	select {
+	case data := <-received:
+		assert.Contains(t, data, "MAIL FROM:<keeper@example.org>")
+		assert.Contains(t, data, "RCPT TO:<user@example.org>")
+		assert.Contains(t, data, "Subject: Hi")
+		assert.Contains(t, data, "\r\ntoken\r\n")
+	case <-time.After(5 * time.Second):
+		t.Fatal("message is not received")
+	}
+}
+
+// serveSMTP accepts a single session of a minimal SMTP server without extensions
+func serveSMTP(t *testing.T, ln net.Listener, received chan<- string) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(s string) {
+		_, _ = conn.Write([]byte(s + "\r\n"))
+	}
+
+	var data strings.Builder
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+			data.WriteString(strings.TrimSpace(line) + "\n")
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			received <- data.String()
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// Mailer interface implementation
+var _ Mailer = (*SMTP)(nil)
+
+// SMTP mailer delivers messages to a relay, STARTTLS is used when offered
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+type SMTPOption func(*SMTP)
+
+// WithPlainAuth sets relay credentials, sent over TLS or to localhost only
+func WithPlainAuth(username, password string) SMTPOption {
+	return func(m *SMTP) {
+		if username == "" {
+			return
+		}
+		host, _, _ := net.SplitHostPort(m.addr)
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+}
+
+func NewSMTP(addr, from string, opts ...SMTPOption) *SMTP {
+	m := &SMTP{
+		addr: addr,
+		from: from,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Send implementation of interface Mailer
+func (m *SMTP) Send(_ context.Context, msg Message) error {
+	if err := validHeader(msg.To); err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, msg.Bytes(m.from, time.Now())); err != nil {
+		return fmt.Errorf("smtp send: %w", err)
+	}
+
+	return nil
+}
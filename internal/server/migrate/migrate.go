@@ -28,3 +28,23 @@ func Down(db *sql.DB) error {
 	}
 	return nil
 }
+
+// Check the schema is not behind the embedded migrations, e.g. rolled back by another instance
+func Check(db *sql.DB) error {
+	current, err := goose.GetDBVersion(db)
+	if err != nil {
+		return fmt.Errorf("goose version: %w", err)
+	}
+	migrations, err := goose.CollectMigrations("migrations", 0, goose.MaxVersion)
+	if err != nil {
+		return fmt.Errorf("goose collect: %w", err)
+	}
+	last, err := migrations.Last()
+	if err != nil {
+		return fmt.Errorf("goose last: %w", err)
+	}
+	if current < last.Version {
+		return fmt.Errorf("schema version %d is behind %d", current, last.Version)
+	}
+	return nil
+}
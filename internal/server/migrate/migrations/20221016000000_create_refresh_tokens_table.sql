@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "refresh_tokens"
+(
+    id         UUID                 DEFAULT uuid_generate_v4() NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    user_id    UUID        NOT NULL,
+    token_hash TEXT        NOT NULL UNIQUE,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_id
+    ON refresh_tokens (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "refresh_tokens";
+-- +goose StatementEnd
@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "sessions"
+(
+    id           UUID                 DEFAULT uuid_generate_v4() NOT NULL UNIQUE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ NOT NULL,
+    user_id      UUID        NOT NULL,
+    device       TEXT        NOT NULL,
+    ip           TEXT        NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS sessions_user_id
+    ON sessions (user_id);
+
+-- refresh tokens issued before sessions get a session each, so nobody is logged out
+DELETE FROM refresh_tokens
+WHERE expires_at <= NOW();
+ALTER TABLE refresh_tokens
+    ADD COLUMN session_id UUID;
+INSERT INTO sessions (id, created_at, last_used_at, expires_at, user_id, device, ip)
+SELECT id, created_at, created_at, expires_at, user_id, 'unknown', ''
+FROM refresh_tokens;
+UPDATE refresh_tokens
+SET session_id = id;
+ALTER TABLE refresh_tokens
+    ALTER COLUMN session_id SET NOT NULL,
+    ADD CONSTRAINT fk_session
+        FOREIGN KEY (session_id)
+            REFERENCES sessions (id)
+            ON DELETE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS session_id;
+DROP TABLE IF EXISTS "sessions";
+-- +goose StatementEnd
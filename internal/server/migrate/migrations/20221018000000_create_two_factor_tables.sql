@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "two_factor"
+(
+    user_id    UUID        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    secret     TEXT        NOT NULL,
+    enabled    BOOLEAN     NOT NULL DEFAULT FALSE,
+    last_step  BIGINT      NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id),
+    CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS "recovery_codes"
+(
+    id         UUID                 DEFAULT uuid_generate_v4() NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    user_id    UUID        NOT NULL,
+    code_hash  TEXT        NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS recovery_codes_unique_user_id_code_hash
+    ON recovery_codes (user_id, code_hash);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "recovery_codes";
+DROP TABLE IF EXISTS "two_factor";
+-- +goose StatementEnd
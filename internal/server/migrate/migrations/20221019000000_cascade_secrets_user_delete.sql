@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE secrets
+    DROP CONSTRAINT IF EXISTS fk_user,
+    ADD CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE secrets
+    DROP CONSTRAINT IF EXISTS fk_user,
+    ADD CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id);
+-- +goose StatementEnd
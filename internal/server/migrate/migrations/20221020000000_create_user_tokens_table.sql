@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS "user_tokens"
+(
+    id         UUID                 DEFAULT uuid_generate_v4() NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    user_id    UUID        NOT NULL,
+    purpose    TEXT        NOT NULL,
+    token_hash TEXT        NOT NULL UNIQUE,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS user_tokens_user_id_purpose
+    ON user_tokens (user_id, purpose);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "user_tokens";
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS email_verified_at;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "api_tokens"
+(
+    id           UUID                 DEFAULT uuid_generate_v4() NOT NULL UNIQUE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    last_used_ip TEXT        NOT NULL DEFAULT '',
+    user_id      UUID        NOT NULL,
+    name         TEXT        NOT NULL,
+    scopes       TEXT[]      NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE (user_id, name),
+    CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "api_tokens";
+-- +goose StatementEnd
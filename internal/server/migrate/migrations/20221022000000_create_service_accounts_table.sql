@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "service_accounts"
+(
+    id         UUID                 DEFAULT uuid_generate_v4() NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    owner_id   UUID        NOT NULL,
+    name       TEXT        NOT NULL,
+    grants     TEXT[]      NOT NULL DEFAULT '{}',
+    PRIMARY KEY (id),
+    UNIQUE (owner_id, name),
+    CONSTRAINT fk_owner
+        FOREIGN KEY (owner_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+
+ALTER TABLE api_tokens
+    ADD COLUMN IF NOT EXISTS service_account_id UUID
+        CONSTRAINT fk_service_account
+            REFERENCES service_accounts (id)
+            ON DELETE CASCADE;
+
+-- names are unique per user and per service account
+ALTER TABLE api_tokens
+    DROP CONSTRAINT IF EXISTS api_tokens_user_id_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS api_tokens_owner_name
+    ON api_tokens (user_id, COALESCE(service_account_id, '00000000-0000-0000-0000-000000000000'), name);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS api_tokens_owner_name;
+DELETE FROM api_tokens
+WHERE service_account_id IS NOT NULL;
+ALTER TABLE api_tokens
+    DROP COLUMN IF EXISTS service_account_id;
+ALTER TABLE api_tokens
+    ADD CONSTRAINT api_tokens_user_id_name_key UNIQUE (user_id, name);
+
+DROP TABLE IF EXISTS "service_accounts";
+-- +goose StatementEnd
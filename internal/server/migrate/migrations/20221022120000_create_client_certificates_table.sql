@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "client_certificates"
+(
+    id                 UUID                 DEFAULT uuid_generate_v4() NOT NULL UNIQUE,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at         TIMESTAMPTZ NOT NULL,
+    user_id            UUID        NOT NULL,
+    service_account_id UUID,
+    name               TEXT        NOT NULL,
+    fingerprint        TEXT        NOT NULL UNIQUE,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_service_account
+        FOREIGN KEY (service_account_id)
+            REFERENCES service_accounts (id)
+            ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "client_certificates";
+-- +goose StatementEnd
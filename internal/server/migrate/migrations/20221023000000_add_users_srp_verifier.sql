@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- password is NULL once the user switches to SRP, the server keeps the verifier only
+ALTER TABLE users
+    ALTER COLUMN password DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS srp_salt     BYTEA,
+    ADD COLUMN IF NOT EXISTS srp_verifier BYTEA;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- SRP users get an unknown password and have to reset it
+UPDATE users
+SET password = crypt(gen_random_uuid()::TEXT, gen_salt('bf'))
+WHERE password IS NULL;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS srp_salt,
+    DROP COLUMN IF EXISTS srp_verifier,
+    ALTER COLUMN password SET NOT NULL;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- updates kept setting created_at until now, so it is the time of the last change of existing secrets
+ALTER TABLE secrets
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE;
+UPDATE secrets
+SET updated_at = created_at;
+ALTER TABLE secrets
+    ALTER COLUMN updated_at SET DEFAULT NOW(),
+    ALTER COLUMN updated_at SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE secrets
+    DROP COLUMN IF EXISTS updated_at;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- rotated tokens are kept till they expire, so a replayed one revokes its session
+ALTER TABLE refresh_tokens
+    ADD COLUMN IF NOT EXISTS used_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS refresh_tokens_session_id
+    ON refresh_tokens (session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM refresh_tokens
+WHERE used_at IS NOT NULL;
+DROP INDEX IF EXISTS refresh_tokens_session_id;
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS used_at;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// APIToken is a personal access token for automation limited to scopes,
+// the token itself is a signed JWT and is not stored
+type APIToken struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	// ServiceAccountID is set for tokens of a service account of the user, its grants limit the token too
+	ServiceAccountID uuid.UUID
+	Name             string
+	Scopes           []string
+	CreatedAt        time.Time
+	ExpiresAt        time.Time
+	// LastUsedAt is zero for a never used token
+	LastUsedAt time.Time
+	LastUsedIP string
+}
+
+func (m *APIToken) Identity() string {
+	return m.UserID.String()
+}
+
+func (m *APIToken) TokenID() string {
+	return m.ID.String()
+}
+
+func (m *APIToken) TokenScopes() []string {
+	return m.Scopes
+}
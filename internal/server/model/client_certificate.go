@@ -0,0 +1,20 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// ClientCertificate registered by the user to authenticate calls by its public key instead of a bearer token
+type ClientCertificate struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	// ServiceAccountID is set for certificates of a service account of the user
+	ServiceAccountID uuid.UUID
+	Name             string
+	// Fingerprint is the SHA-256 of the certificate public key, so a renewal with the same key stays registered
+	Fingerprint string
+	CreatedAt   time.Time
+	// ExpiresAt of the registered certificate, validity is checked by the TLS handshake
+	ExpiresAt time.Time
+}
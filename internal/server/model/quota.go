@@ -0,0 +1,45 @@
+package model
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"gophkeeper/pkg/apperr"
+)
+
+// Usage of the storage by a single user
+type Usage struct {
+	Secrets   int64
+	TotalSize int64
+}
+
+// Quota limits storage usage per user, zero value means unlimited.
+// The size of a single secret is limited by the secret type registry.
+type Quota struct {
+	MaxSecrets   int64
+	MaxTotalSize int64
+}
+
+// Unlimited if no limit is set
+func (q Quota) Unlimited() bool {
+	return q.MaxSecrets == 0 && q.MaxTotalSize == 0
+}
+
+// Exceeded describes the limit a new secret of the size would exceed, empty if none
+func (q Quota) Exceeded(u Usage, size int64) string {
+	if q.MaxSecrets > 0 && u.Secrets >= q.MaxSecrets {
+		return fmt.Sprintf("secrets count limit of %d reached", q.MaxSecrets)
+	}
+	if q.MaxTotalSize > 0 && u.TotalSize+size > q.MaxTotalSize {
+		return fmt.Sprintf("total size %d would exceed limit of %d bytes", u.TotalSize+size, q.MaxTotalSize)
+	}
+	return ""
+}
+
+// QuotaError of the user, mapped to ResourceExhausted with quota failure details
+func QuotaError(uid uuid.UUID, description string) error {
+	return &apperr.Error{
+		Err:        apperr.ErrExhausted,
+		Message:    "quota exceeded: " + description,
+		Violations: []apperr.FieldViolation{{Field: "user:" + uid.String(), Description: description}},
+	}
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"gophkeeper/pkg/apperr"
+	"time"
+)
+
+// ErrRefreshTokenReused is returned for a token used already, its session is revoked,
+// since either the client or someone who stole the token holds a newer one
+var ErrRefreshTokenReused = fmt.Errorf("refresh token reused: %w", apperr.ErrUnauthorized)
+
+// RefreshToken is a single use token to issue a new access token, only its hash is stored
+type RefreshToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"github.com/google/uuid"
+	"time"
 )
 
 type Secret struct {
@@ -10,4 +11,9 @@ type Secret struct {
 	Name    string
 	Type    string
 	Content []byte
+	// Tags label the secret for API token scopes and filters, on update nil keeps the current ones
+	Tags      []string
+	CreatedAt time.Time
+	// UpdatedAt is the time of the last change, equal to CreatedAt for secrets never updated
+	UpdatedAt time.Time
 }
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// ServiceAccount is a machine identity owned by a user, it has no password and authenticates by API tokens only.
+// Grants are scopes limiting access of its tokens to the owner secrets.
+type ServiceAccount struct {
+	ID        uuid.UUID
+	OwnerID   uuid.UUID
+	Name      string
+	Grants    []string
+	CreatedAt time.Time
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Session of a logged-in device, access and refresh tokens are bound to it
+type Session struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Device     string
+	IP         string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+}
+
+func (m *Session) Identity() string {
+	return m.UserID.String()
+}
+
+func (m *Session) Session() string {
+	return m.ID.String()
+}
@@ -0,0 +1,14 @@
+package model
+
+import (
+	"github.com/google/uuid"
+)
+
+// TwoFactor is a TOTP second factor of a user, it is pending until confirmed with a code
+type TwoFactor struct {
+	UserID  uuid.UUID
+	Secret  string
+	Enabled bool
+	// LastStep is the last used TOTP step, codes can not be reused
+	LastStep int64
+}
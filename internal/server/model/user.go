@@ -8,6 +8,16 @@ type User struct {
 	ID       uuid.UUID `json:"id"`
 	Email    string    `json:"email"`
 	Password string    `json:"-"`
+	// EmailVerified is set once the user follows a verification mail
+	EmailVerified bool `json:"email_verified"`
+	// SRPSalt and SRPVerifier are set for users authenticating with SRP, the password is not known then
+	SRPSalt     []byte `json:"-"`
+	SRPVerifier []byte `json:"-"`
+}
+
+// SRP is set when the user authenticates with SRP
+func (m *User) SRP() bool {
+	return len(m.SRPVerifier) > 0
 }
 
 func (m *User) Identity() string {
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	// UserTokenVerifyEmail confirms the user owns the account email
+	UserTokenVerifyEmail = "verify_email"
+	// UserTokenResetPassword allows to set a new password without the current one
+	UserTokenResetPassword = "reset_password"
+)
+
+// UserToken is a single use token mailed to the user, only its hash is stored
+type UserToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Purpose   string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
@@ -0,0 +1,167 @@
+package secrettype
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+const (
+	TypeRaw           = "raw"
+	TypeLoginPassword = "lp"
+	TypeCard          = "card"
+)
+
+const (
+	// MaxNameLength matches the secrets.name column size
+	MaxNameLength = 255
+	// DefaultMaxSize of a secret content if type does not specify own limit
+	DefaultMaxSize = 1 << 20
+)
+
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.@/\-]*$`)
+
+// Violation describes a single invalid field of a secret
+type Violation struct {
+	Field       string
+	Description string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Field, v.Description)
+}
+
+// Schema checks structured secret content and returns found violations
+type Schema func(content []byte) []Violation
+
+// Type of secret known to the server
+type Type struct {
+	// Name of the type as sent by clients
+	Name string
+	// MaxSize of the content in bytes, DefaultMaxSize is used when zero
+	MaxSize int
+	// Schema of the content, optional
+	Schema Schema
+}
+
+type Registry struct {
+	types       map[string]Type
+	maxSize     int
+	skipSchemas bool
+}
+
+type RegistryOption func(*Registry)
+
+// WithMaxSize limits content size for all types
+func WithMaxSize(n int) RegistryOption {
+	return func(r *Registry) {
+		if n > 0 {
+			r.maxSize = n
+		}
+	}
+}
+
+// WithoutSchemas disables content schema checks, used when content is encrypted by clients
+func WithoutSchemas() RegistryOption {
+	return func(r *Registry) {
+		r.skipSchemas = true
+	}
+}
+
+// WithTypes registers additional types
+func WithTypes(tt ...Type) RegistryOption {
+	return func(r *Registry) {
+		for _, t := range tt {
+			r.Register(t)
+		}
+	}
+}
+
+// NewRegistry constructor with builtin types registered
+func NewRegistry(opts ...RegistryOption) *Registry {
+	r := &Registry{
+		types:   make(map[string]Type),
+		maxSize: DefaultMaxSize,
+	}
+
+	r.Register(Type{Name: TypeRaw})
+	r.Register(Type{Name: TypeLoginPassword, MaxSize: 4 << 10, Schema: loginPasswordSchema})
+	r.Register(Type{Name: TypeCard, MaxSize: 4 << 10, Schema: cardSchema})
+
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r
+}
+
+// Register a type, replaces existing one with the same name
+func (r *Registry) Register(t Type) {
+	r.types[t.Name] = t
+}
+
+// Lookup a registered type by name
+func (r *Registry) Lookup(name string) (Type, bool) {
+	t, ok := r.types[name]
+	return t, ok
+}
+
+// Limit of the content size for all types
+func (r *Registry) Limit() int {
+	return r.maxSize
+}
+
+// MaxSize of the content for a named type
+func (r *Registry) MaxSize(name string) int {
+	t, ok := r.types[name]
+	if !ok || t.MaxSize == 0 || t.MaxSize > r.maxSize {
+		return r.maxSize
+	}
+	return t.MaxSize
+}
+
+// Validate secret attributes, returns all found violations
+func (r *Registry) Validate(name, typ string, content []byte) []Violation {
+	var vv []Violation
+
+	vv = append(vv, ValidateName(name)...)
+
+	t, ok := r.types[typ]
+	if !ok {
+		return append(vv, Violation{Field: "type", Description: fmt.Sprintf("unknown secret type %q", typ)})
+	}
+
+	switch max := r.MaxSize(typ); {
+	case len(content) == 0:
+		return append(vv, Violation{Field: "content", Description: "must not be empty"})
+	case len(content) > max:
+		return append(vv, Violation{
+			Field:       "content",
+			Description: fmt.Sprintf("size %d exceeds limit of %d bytes", len(content), max),
+		})
+	}
+
+	if t.Schema != nil && !r.skipSchemas {
+		vv = append(vv, t.Schema(content)...)
+	}
+
+	return vv
+}
+
+// ValidateName checks secret name syntax
+func ValidateName(name string) []Violation {
+	switch {
+	case name == "":
+		return []Violation{{Field: "name", Description: "must not be empty"}}
+	case !utf8.ValidString(name):
+		return []Violation{{Field: "name", Description: "must be a valid utf-8 string"}}
+	case len(name) > MaxNameLength:
+		return []Violation{{Field: "name", Description: fmt.Sprintf("must not be longer than %d bytes", MaxNameLength)}}
+	case !nameRegexp.MatchString(name):
+		return []Violation{{
+			Field:       "name",
+			Description: "must start with a letter or digit and contain only letters, digits and _ . @ / -",
+		}}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package secrettype
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Validate(t *testing.T) {
+	r := NewRegistry(WithMaxSize(128))
+
+	tests := []struct {
+		name       string
+		secretName string
+		secretType string
+		content    string
+		wantFields []string
+	}{
+		{
+			name:       "valid raw",
+			secretName: "prod/db",
+			secretType: TypeRaw,
+			content:    "keepitsecret",
+		},
+		{
+			name:       "empty name",
+			secretName: "",
+			secretType: TypeRaw,
+			content:    "keepitsecret",
+			wantFields: []string{"name"},
+		},
+		{
+			name:       "bad name syntax",
+			secretName: "prod db:password",
+			secretType: TypeRaw,
+			content:    "keepitsecret",
+			wantFields: []string{"name"},
+		},
+		{
+			name:       "too long name",
+			secretName: strings.Repeat("a", MaxNameLength+1),
+			secretType: TypeRaw,
+			content:    "keepitsecret",
+			wantFields: []string{"name"},
+		},
+		{
+			name:       "unknown type",
+			secretName: "secret1",
+			secretType: "bitcoin",
+			content:    "keepitsecret",
+			wantFields: []string{"type"},
+		},
+		{
+			name:       "empty content",
+			secretName: "secret1",
+			secretType: TypeRaw,
+			content:    "",
+			wantFields: []string{"content"},
+		},
+		{
+			name:       "too big content",
+			secretName: "secret1",
+			secretType: TypeRaw,
+			content:    strings.Repeat("a", 129),
+			wantFields: []string{"content"},
+		},
+		{
+			name:       "valid login password",
+			secretName: "secret1",
+			secretType: TypeLoginPassword,
+			content:    `{"login":"user","password":"pass"}`,
+		},
+		{
+			name:       "login password without password",
+			secretName: "secret1",
+			secretType: TypeLoginPassword,
+			content:    `{"login":"user"}`,
+			wantFields: []string{"content.password"},
+		},
+		{
+			name:       "login password not a json",
+			secretName: "secret1",
+			secretType: TypeLoginPassword,
+			content:    `user:pass`,
+			wantFields: []string{"content"},
+		},
+		{
+			name:       "valid card",
+			secretName: "secret1",
+			secretType: TypeCard,
+			content:    `{"number":"4111 1111 1111 1111","expires":"12/30","cvv":"123","holder":"J DOE"}`,
+		},
+		{
+			name:       "invalid card",
+			secretName: "secret1",
+			secretType: TypeCard,
+			content:    `{"number":"4111","expires":"13/30","cvv":12,"holder":"J DOE"}`,
+			wantFields: []string{"content.cvv", "content.number", "content.expires"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields []string
+			for _, v := range r.Validate(tt.secretName, tt.secretType, []byte(tt.content)) {
+				fields = append(fields, v.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
+
+func TestRegistry_WithoutSchemas(t *testing.T) {
+	r := NewRegistry(WithoutSchemas())
+
+	assert.Empty(t, r.Validate("secret1", TypeCard, []byte("ciphertext")))
+	assert.NotEmpty(t, r.Validate("secret1", "bitcoin", []byte("ciphertext")))
+}
@@ -0,0 +1,86 @@
+package secrettype
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	cardNumberRegexp  = regexp.MustCompile(`^[0-9]{12,19}$`)
+	cardExpiresRegexp = regexp.MustCompile(`^(0[1-9]|1[0-2])/([0-9]{2}|[0-9]{4})$`)
+	cardCVVRegexp     = regexp.MustCompile(`^[0-9]{3,4}$`)
+)
+
+// decodeObject content into string fields, reports violation if content is not a json object of strings
+func decodeObject(content []byte) (map[string]string, []Violation) {
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, []Violation{{Field: "content", Description: "must be a json object"}}
+	}
+
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var vv []Violation
+	fields := make(map[string]string, len(raw))
+	for _, k := range keys {
+		var s string
+		if err := json.Unmarshal(raw[k], &s); err != nil {
+			vv = append(vv, Violation{Field: "content." + k, Description: "must be a string"})
+			continue
+		}
+		fields[k] = s
+	}
+
+	return fields, vv
+}
+
+func loginPasswordSchema(content []byte) []Violation {
+	f, vv := decodeObject(content)
+	if f == nil {
+		return vv
+	}
+
+	if _, ok := f["login"]; !ok {
+		vv = appendViolation(vv, Violation{Field: "content.login", Description: "is required"})
+	}
+	if f["password"] == "" {
+		vv = appendViolation(vv, Violation{Field: "content.password", Description: "must not be empty"})
+	}
+
+	return vv
+}
+
+func cardSchema(content []byte) []Violation {
+	f, vv := decodeObject(content)
+	if f == nil {
+		return vv
+	}
+
+	if !cardNumberRegexp.MatchString(strings.ReplaceAll(f["number"], " ", "")) {
+		vv = appendViolation(vv, Violation{Field: "content.number", Description: "must contain 12 to 19 digits"})
+	}
+	if !cardExpiresRegexp.MatchString(f["expires"]) {
+		vv = appendViolation(vv, Violation{Field: "content.expires", Description: "must be in MM/YY or MM/YYYY format"})
+	}
+	if !cardCVVRegexp.MatchString(f["cvv"]) {
+		vv = appendViolation(vv, Violation{Field: "content.cvv", Description: "must contain 3 or 4 digits"})
+	}
+
+	return vv
+}
+
+// appendViolation unless the field is already reported, e.g. as not a string by decodeObject
+func appendViolation(vv []Violation, v Violation) []Violation {
+	for _, r := range vv {
+		if r.Field == v.Field {
+			return vv
+		}
+	}
+	return append(vv, v)
+}
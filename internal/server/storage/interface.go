@@ -14,15 +14,112 @@ type UserRepository interface {
 	ReadByEmailAndPassword(ctx context.Context, name string, password string) (*model.User, error)
 	// Read instance of model.User
 	Read(ctx context.Context, id uuid.UUID) (*model.User, error)
+	// ReadByEmail instance of model.User with its SRP verifier if any
+	ReadByEmail(ctx context.Context, email string) (*model.User, error)
+	// VerifyEmail of specified user
+	VerifyEmail(ctx context.Context, id uuid.UUID) error
+	// UpdatePassword of specified user, SRP verifier is removed
+	UpdatePassword(ctx context.Context, id uuid.UUID, password string) error
+	// UpdateVerifier of specified user, the password is removed
+	UpdateVerifier(ctx context.Context, id uuid.UUID, salt []byte, verifier []byte) error
+	// Delete specified user with all the data owned
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type SessionRepository interface {
+	// Create a new model.Session
+	Create(ctx context.Context, m *model.Session) (*model.Session, error)
+	// Touch unexpired session updating its last use time and address, at most once a minute for the same address
+	Touch(ctx context.Context, id uuid.UUID, ip string) (*model.Session, error)
+	// List unexpired sessions of specified user
+	List(ctx context.Context, uid uuid.UUID) ([]*model.Session, error)
+	// Delete specified session of the user with its refresh tokens
+	Delete(ctx context.Context, uid uuid.UUID, id uuid.UUID) error
+	// DeleteOthers deletes all sessions of the user except the kept one, returns number of deleted
+	DeleteOthers(ctx context.Context, uid uuid.UUID, keep uuid.UUID) (int64, error)
+}
+
+type TwoFactorRepository interface {
+	// Read second factor of specified user, pending one included
+	Read(ctx context.Context, uid uuid.UUID) (*model.TwoFactor, error)
+	// Save a pending second factor replacing any existing one
+	Save(ctx context.Context, m *model.TwoFactor) error
+	// Enable pending second factor with matched step replacing recovery codes with specified hashes
+	Enable(ctx context.Context, uid uuid.UUID, step int64, recoveryHashes []string) error
+	// UseStep marks a TOTP step used, apperr.ErrConflict if the step or a later one was already used
+	UseStep(ctx context.Context, uid uuid.UUID, step int64) error
+	// UseRecoveryCode with specified hash, apperr.ErrNotFound if there is no such unused code
+	UseRecoveryCode(ctx context.Context, uid uuid.UUID, hash string) error
+	// Delete second factor of the user with its recovery codes
+	Delete(ctx context.Context, uid uuid.UUID) error
+}
+
+type RefreshTokenRepository interface {
+	// Create a new model.RefreshToken
+	Create(ctx context.Context, m *model.RefreshToken) (*model.RefreshToken, error)
+	// Rotate consumes an unexpired token with specified hash and creates the next one for the same session,
+	// session expiration is extended to the next token one. Consumed tokens are kept till they expire,
+	// using one again revokes the session and returns model.ErrRefreshTokenReused
+	Rotate(ctx context.Context, hash string, next *model.RefreshToken) (*model.RefreshToken, error)
+}
+
+type UserTokenRepository interface {
+	// Create a new model.UserToken revoking other tokens of the user with the same purpose
+	Create(ctx context.Context, m *model.UserToken) (*model.UserToken, error)
+	// Use consumes an unexpired token with specified purpose and hash, apperr.ErrNotFound if there is none
+	Use(ctx context.Context, purpose string, hash string) (*model.UserToken, error)
+}
+
+type APITokenRepository interface {
+	// Create a new model.APIToken, apperr.ErrConflict if the user has a token with the same name
+	Create(ctx context.Context, m *model.APIToken) (*model.APIToken, error)
+	// Touch unexpired token updating its last use time and address
+	Touch(ctx context.Context, id uuid.UUID, ip string) (*model.APIToken, error)
+	// List unexpired tokens of specified user, or of its service account if sa is not uuid.Nil
+	List(ctx context.Context, uid uuid.UUID, sa uuid.UUID) ([]*model.APIToken, error)
+	// Delete specified token of the user or its service accounts
+	Delete(ctx context.Context, uid uuid.UUID, id uuid.UUID) error
+	// DeleteUserTokens deletes all tokens of the user itself, returns number of deleted,
+	// tokens of its service accounts are kept
+	DeleteUserTokens(ctx context.Context, uid uuid.UUID) (int64, error)
+}
+
+type ClientCertificateRepository interface {
+	// Create a new model.ClientCertificate, apperr.ErrConflict if its public key is already registered
+	Create(ctx context.Context, m *model.ClientCertificate) (*model.ClientCertificate, error)
+	// ReadByFingerprint a certificate by its public key fingerprint, apperr.ErrNotFound if there is none
+	ReadByFingerprint(ctx context.Context, fingerprint string) (*model.ClientCertificate, error)
+	// List certificates of specified user, or of its service account if sa is not uuid.Nil
+	List(ctx context.Context, uid uuid.UUID, sa uuid.UUID) ([]*model.ClientCertificate, error)
+	// Delete specified certificate of the user or its service accounts
+	Delete(ctx context.Context, uid uuid.UUID, id uuid.UUID) error
+}
+
+type ServiceAccountRepository interface {
+	// Create a new model.ServiceAccount, apperr.ErrConflict if the owner has one with the same name
+	Create(ctx context.Context, m *model.ServiceAccount) (*model.ServiceAccount, error)
+	// Read service account by id, for any owner
+	Read(ctx context.Context, id uuid.UUID) (*model.ServiceAccount, error)
+	// List service accounts of specified owner
+	List(ctx context.Context, owner uuid.UUID) ([]*model.ServiceAccount, error)
+	// UpdateGrants of the owner service account replacing existing ones
+	UpdateGrants(ctx context.Context, owner uuid.UUID, id uuid.UUID, grants []string) error
+	// Delete the owner service account with its tokens
+	Delete(ctx context.Context, owner uuid.UUID, id uuid.UUID) error
 }
 
 type SecretRepository interface {
-	// Create a new model.Secret
-	Create(ctx context.Context, uid uuid.UUID, m *model.Secret) (*model.Secret, error)
+	// Create a new model.Secret unless the quota would be exceeded, see model.QuotaError
+	Create(ctx context.Context, uid uuid.UUID, m *model.Secret, q model.Quota) (*model.Secret, error)
+	// Update content and type of the named secret, renaming it to m.Name, tags are replaced unless m.Tags is nil.
+	// The quota is checked as for Create
+	Update(ctx context.Context, uid uuid.UUID, name string, m *model.Secret, q model.Quota) (*model.Secret, error)
 	// ReadByName specified secret
 	ReadByName(ctx context.Context, uid uuid.UUID, name string) (*model.Secret, error)
 	// DeleteByName specified secret if available
 	DeleteByName(ctx context.Context, uid uuid.UUID, name string) error
 	// List all secrets of specified user
 	List(ctx context.Context, uid uuid.UUID) ([]*model.Secret, error)
+	// Usage of the storage by specified user
+	Usage(ctx context.Context, uid uuid.UUID) (*model.Usage, error)
 }
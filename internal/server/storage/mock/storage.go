@@ -51,6 +51,20 @@ func (mr *MockUserRepositoryMockRecorder) Create(ctx, m interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserRepository)(nil).Create), ctx, m)
 }
 
+// Delete mocks base method.
+func (m *MockUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockUserRepositoryMockRecorder) Delete(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUserRepository)(nil).Delete), ctx, id)
+}
+
 // Read mocks base method.
 func (m *MockUserRepository) Read(ctx context.Context, id uuid.UUID) (*model.User, error) {
 	m.ctrl.T.Helper()
@@ -66,6 +80,21 @@ func (mr *MockUserRepositoryMockRecorder) Read(ctx, id interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Read", reflect.TypeOf((*MockUserRepository)(nil).Read), ctx, id)
 }
 
+// ReadByEmail mocks base method.
+func (m *MockUserRepository) ReadByEmail(ctx context.Context, email string) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadByEmail", ctx, email)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadByEmail indicates an expected call of ReadByEmail.
+func (mr *MockUserRepositoryMockRecorder) ReadByEmail(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadByEmail", reflect.TypeOf((*MockUserRepository)(nil).ReadByEmail), ctx, email)
+}
+
 // ReadByEmailAndPassword mocks base method.
 func (m *MockUserRepository) ReadByEmailAndPassword(ctx context.Context, name, password string) (*model.User, error) {
 	m.ctrl.T.Helper()
@@ -81,6 +110,634 @@ func (mr *MockUserRepositoryMockRecorder) ReadByEmailAndPassword(ctx, name, pass
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadByEmailAndPassword", reflect.TypeOf((*MockUserRepository)(nil).ReadByEmailAndPassword), ctx, name, password)
 }
 
+// UpdatePassword mocks base method.
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", ctx, id, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockUserRepositoryMockRecorder) UpdatePassword(ctx, id, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockUserRepository)(nil).UpdatePassword), ctx, id, password)
+}
+
+// UpdateVerifier mocks base method.
+func (m *MockUserRepository) UpdateVerifier(ctx context.Context, id uuid.UUID, salt, verifier []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateVerifier", ctx, id, salt, verifier)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateVerifier indicates an expected call of UpdateVerifier.
+func (mr *MockUserRepositoryMockRecorder) UpdateVerifier(ctx, id, salt, verifier interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateVerifier", reflect.TypeOf((*MockUserRepository)(nil).UpdateVerifier), ctx, id, salt, verifier)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockUserRepository) VerifyEmail(ctx context.Context, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockUserRepositoryMockRecorder) VerifyEmail(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockUserRepository)(nil).VerifyEmail), ctx, id)
+}
+
+// MockSessionRepository is a mock of SessionRepository interface.
+type MockSessionRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockSessionRepositoryMockRecorder
+}
+
+// MockSessionRepositoryMockRecorder is the mock recorder for MockSessionRepository.
+type MockSessionRepositoryMockRecorder struct {
+	mock *MockSessionRepository
+}
+
+// NewMockSessionRepository creates a new mock instance.
+func NewMockSessionRepository(ctrl *gomock.Controller) *MockSessionRepository {
+	mock := &MockSessionRepository{ctrl: ctrl}
+	mock.recorder = &MockSessionRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSessionRepository) EXPECT() *MockSessionRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m_2 *MockSessionRepository) Create(ctx context.Context, m *model.Session) (*model.Session, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Create", ctx, m)
+	ret0, _ := ret[0].(*model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockSessionRepositoryMockRecorder) Create(ctx, m interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSessionRepository)(nil).Create), ctx, m)
+}
+
+// Delete mocks base method.
+func (m *MockSessionRepository) Delete(ctx context.Context, uid, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, uid, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockSessionRepositoryMockRecorder) Delete(ctx, uid, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSessionRepository)(nil).Delete), ctx, uid, id)
+}
+
+// DeleteOthers mocks base method.
+func (m *MockSessionRepository) DeleteOthers(ctx context.Context, uid, keep uuid.UUID) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOthers", ctx, uid, keep)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteOthers indicates an expected call of DeleteOthers.
+func (mr *MockSessionRepositoryMockRecorder) DeleteOthers(ctx, uid, keep interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOthers", reflect.TypeOf((*MockSessionRepository)(nil).DeleteOthers), ctx, uid, keep)
+}
+
+// List mocks base method.
+func (m *MockSessionRepository) List(ctx context.Context, uid uuid.UUID) ([]*model.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, uid)
+	ret0, _ := ret[0].([]*model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockSessionRepositoryMockRecorder) List(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSessionRepository)(nil).List), ctx, uid)
+}
+
+// Touch mocks base method.
+func (m *MockSessionRepository) Touch(ctx context.Context, id uuid.UUID, ip string) (*model.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Touch", ctx, id, ip)
+	ret0, _ := ret[0].(*model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Touch indicates an expected call of Touch.
+func (mr *MockSessionRepositoryMockRecorder) Touch(ctx, id, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Touch", reflect.TypeOf((*MockSessionRepository)(nil).Touch), ctx, id, ip)
+}
+
+// MockTwoFactorRepository is a mock of TwoFactorRepository interface.
+type MockTwoFactorRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockTwoFactorRepositoryMockRecorder
+}
+
+// MockTwoFactorRepositoryMockRecorder is the mock recorder for MockTwoFactorRepository.
+type MockTwoFactorRepositoryMockRecorder struct {
+	mock *MockTwoFactorRepository
+}
+
+// NewMockTwoFactorRepository creates a new mock instance.
+func NewMockTwoFactorRepository(ctrl *gomock.Controller) *MockTwoFactorRepository {
+	mock := &MockTwoFactorRepository{ctrl: ctrl}
+	mock.recorder = &MockTwoFactorRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTwoFactorRepository) EXPECT() *MockTwoFactorRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockTwoFactorRepository) Delete(ctx context.Context, uid uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockTwoFactorRepositoryMockRecorder) Delete(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockTwoFactorRepository)(nil).Delete), ctx, uid)
+}
+
+// Enable mocks base method.
+func (m *MockTwoFactorRepository) Enable(ctx context.Context, uid uuid.UUID, step int64, recoveryHashes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enable", ctx, uid, step, recoveryHashes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Enable indicates an expected call of Enable.
+func (mr *MockTwoFactorRepositoryMockRecorder) Enable(ctx, uid, step, recoveryHashes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enable", reflect.TypeOf((*MockTwoFactorRepository)(nil).Enable), ctx, uid, step, recoveryHashes)
+}
+
+// Read mocks base method.
+func (m *MockTwoFactorRepository) Read(ctx context.Context, uid uuid.UUID) (*model.TwoFactor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Read", ctx, uid)
+	ret0, _ := ret[0].(*model.TwoFactor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Read indicates an expected call of Read.
+func (mr *MockTwoFactorRepositoryMockRecorder) Read(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Read", reflect.TypeOf((*MockTwoFactorRepository)(nil).Read), ctx, uid)
+}
+
+// Save mocks base method.
+func (m_2 *MockTwoFactorRepository) Save(ctx context.Context, m *model.TwoFactor) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Save", ctx, m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockTwoFactorRepositoryMockRecorder) Save(ctx, m interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockTwoFactorRepository)(nil).Save), ctx, m)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockTwoFactorRepository) UseRecoveryCode(ctx context.Context, uid uuid.UUID, hash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, uid, hash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockTwoFactorRepositoryMockRecorder) UseRecoveryCode(ctx, uid, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockTwoFactorRepository)(nil).UseRecoveryCode), ctx, uid, hash)
+}
+
+// UseStep mocks base method.
+func (m *MockTwoFactorRepository) UseStep(ctx context.Context, uid uuid.UUID, step int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseStep", ctx, uid, step)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseStep indicates an expected call of UseStep.
+func (mr *MockTwoFactorRepositoryMockRecorder) UseStep(ctx, uid, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseStep", reflect.TypeOf((*MockTwoFactorRepository)(nil).UseStep), ctx, uid, step)
+}
+
+// MockRefreshTokenRepository is a mock of RefreshTokenRepository interface.
+type MockRefreshTokenRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockRefreshTokenRepositoryMockRecorder
+}
+
+// MockRefreshTokenRepositoryMockRecorder is the mock recorder for MockRefreshTokenRepository.
+type MockRefreshTokenRepositoryMockRecorder struct {
+	mock *MockRefreshTokenRepository
+}
+
+// NewMockRefreshTokenRepository creates a new mock instance.
+func NewMockRefreshTokenRepository(ctrl *gomock.Controller) *MockRefreshTokenRepository {
+	mock := &MockRefreshTokenRepository{ctrl: ctrl}
+	mock.recorder = &MockRefreshTokenRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRefreshTokenRepository) EXPECT() *MockRefreshTokenRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m_2 *MockRefreshTokenRepository) Create(ctx context.Context, m *model.RefreshToken) (*model.RefreshToken, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Create", ctx, m)
+	ret0, _ := ret[0].(*model.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockRefreshTokenRepositoryMockRecorder) Create(ctx, m interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRefreshTokenRepository)(nil).Create), ctx, m)
+}
+
+// Rotate mocks base method.
+func (m *MockRefreshTokenRepository) Rotate(ctx context.Context, hash string, next *model.RefreshToken) (*model.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rotate", ctx, hash, next)
+	ret0, _ := ret[0].(*model.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Rotate indicates an expected call of Rotate.
+func (mr *MockRefreshTokenRepositoryMockRecorder) Rotate(ctx, hash, next interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rotate", reflect.TypeOf((*MockRefreshTokenRepository)(nil).Rotate), ctx, hash, next)
+}
+
+// MockUserTokenRepository is a mock of UserTokenRepository interface.
+type MockUserTokenRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserTokenRepositoryMockRecorder
+}
+
+// MockUserTokenRepositoryMockRecorder is the mock recorder for MockUserTokenRepository.
+type MockUserTokenRepositoryMockRecorder struct {
+	mock *MockUserTokenRepository
+}
+
+// NewMockUserTokenRepository creates a new mock instance.
+func NewMockUserTokenRepository(ctrl *gomock.Controller) *MockUserTokenRepository {
+	mock := &MockUserTokenRepository{ctrl: ctrl}
+	mock.recorder = &MockUserTokenRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserTokenRepository) EXPECT() *MockUserTokenRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m_2 *MockUserTokenRepository) Create(ctx context.Context, m *model.UserToken) (*model.UserToken, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Create", ctx, m)
+	ret0, _ := ret[0].(*model.UserToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockUserTokenRepositoryMockRecorder) Create(ctx, m interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserTokenRepository)(nil).Create), ctx, m)
+}
+
+// Use mocks base method.
+func (m *MockUserTokenRepository) Use(ctx context.Context, purpose, hash string) (*model.UserToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Use", ctx, purpose, hash)
+	ret0, _ := ret[0].(*model.UserToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Use indicates an expected call of Use.
+func (mr *MockUserTokenRepositoryMockRecorder) Use(ctx, purpose, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Use", reflect.TypeOf((*MockUserTokenRepository)(nil).Use), ctx, purpose, hash)
+}
+
+// MockAPITokenRepository is a mock of APITokenRepository interface.
+type MockAPITokenRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPITokenRepositoryMockRecorder
+}
+
+// MockAPITokenRepositoryMockRecorder is the mock recorder for MockAPITokenRepository.
+type MockAPITokenRepositoryMockRecorder struct {
+	mock *MockAPITokenRepository
+}
+
+// NewMockAPITokenRepository creates a new mock instance.
+func NewMockAPITokenRepository(ctrl *gomock.Controller) *MockAPITokenRepository {
+	mock := &MockAPITokenRepository{ctrl: ctrl}
+	mock.recorder = &MockAPITokenRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPITokenRepository) EXPECT() *MockAPITokenRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m_2 *MockAPITokenRepository) Create(ctx context.Context, m *model.APIToken) (*model.APIToken, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Create", ctx, m)
+	ret0, _ := ret[0].(*model.APIToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockAPITokenRepositoryMockRecorder) Create(ctx, m interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAPITokenRepository)(nil).Create), ctx, m)
+}
+
+// Delete mocks base method.
+func (m *MockAPITokenRepository) Delete(ctx context.Context, uid, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, uid, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockAPITokenRepositoryMockRecorder) Delete(ctx, uid, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockAPITokenRepository)(nil).Delete), ctx, uid, id)
+}
+
+// DeleteUserTokens mocks base method.
+func (m *MockAPITokenRepository) DeleteUserTokens(ctx context.Context, uid uuid.UUID) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserTokens", ctx, uid)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUserTokens indicates an expected call of DeleteUserTokens.
+func (mr *MockAPITokenRepositoryMockRecorder) DeleteUserTokens(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserTokens", reflect.TypeOf((*MockAPITokenRepository)(nil).DeleteUserTokens), ctx, uid)
+}
+
+// List mocks base method.
+func (m *MockAPITokenRepository) List(ctx context.Context, uid, sa uuid.UUID) ([]*model.APIToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, uid, sa)
+	ret0, _ := ret[0].([]*model.APIToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockAPITokenRepositoryMockRecorder) List(ctx, uid, sa interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockAPITokenRepository)(nil).List), ctx, uid, sa)
+}
+
+// Touch mocks base method.
+func (m *MockAPITokenRepository) Touch(ctx context.Context, id uuid.UUID, ip string) (*model.APIToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Touch", ctx, id, ip)
+	ret0, _ := ret[0].(*model.APIToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Touch indicates an expected call of Touch.
+func (mr *MockAPITokenRepositoryMockRecorder) Touch(ctx, id, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Touch", reflect.TypeOf((*MockAPITokenRepository)(nil).Touch), ctx, id, ip)
+}
+
+// MockClientCertificateRepository is a mock of ClientCertificateRepository interface.
+type MockClientCertificateRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockClientCertificateRepositoryMockRecorder
+}
+
+// MockClientCertificateRepositoryMockRecorder is the mock recorder for MockClientCertificateRepository.
+type MockClientCertificateRepositoryMockRecorder struct {
+	mock *MockClientCertificateRepository
+}
+
+// NewMockClientCertificateRepository creates a new mock instance.
+func NewMockClientCertificateRepository(ctrl *gomock.Controller) *MockClientCertificateRepository {
+	mock := &MockClientCertificateRepository{ctrl: ctrl}
+	mock.recorder = &MockClientCertificateRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClientCertificateRepository) EXPECT() *MockClientCertificateRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m_2 *MockClientCertificateRepository) Create(ctx context.Context, m *model.ClientCertificate) (*model.ClientCertificate, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Create", ctx, m)
+	ret0, _ := ret[0].(*model.ClientCertificate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockClientCertificateRepositoryMockRecorder) Create(ctx, m interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockClientCertificateRepository)(nil).Create), ctx, m)
+}
+
+// Delete mocks base method.
+func (m *MockClientCertificateRepository) Delete(ctx context.Context, uid, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, uid, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockClientCertificateRepositoryMockRecorder) Delete(ctx, uid, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockClientCertificateRepository)(nil).Delete), ctx, uid, id)
+}
+
+// List mocks base method.
+func (m *MockClientCertificateRepository) List(ctx context.Context, uid, sa uuid.UUID) ([]*model.ClientCertificate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, uid, sa)
+	ret0, _ := ret[0].([]*model.ClientCertificate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockClientCertificateRepositoryMockRecorder) List(ctx, uid, sa interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockClientCertificateRepository)(nil).List), ctx, uid, sa)
+}
+
+// ReadByFingerprint mocks base method.
+func (m *MockClientCertificateRepository) ReadByFingerprint(ctx context.Context, fingerprint string) (*model.ClientCertificate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadByFingerprint", ctx, fingerprint)
+	ret0, _ := ret[0].(*model.ClientCertificate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadByFingerprint indicates an expected call of ReadByFingerprint.
+func (mr *MockClientCertificateRepositoryMockRecorder) ReadByFingerprint(ctx, fingerprint interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadByFingerprint", reflect.TypeOf((*MockClientCertificateRepository)(nil).ReadByFingerprint), ctx, fingerprint)
+}
+
+// MockServiceAccountRepository is a mock of ServiceAccountRepository interface.
+type MockServiceAccountRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockServiceAccountRepositoryMockRecorder
+}
+
+// MockServiceAccountRepositoryMockRecorder is the mock recorder for MockServiceAccountRepository.
+type MockServiceAccountRepositoryMockRecorder struct {
+	mock *MockServiceAccountRepository
+}
+
+// NewMockServiceAccountRepository creates a new mock instance.
+func NewMockServiceAccountRepository(ctrl *gomock.Controller) *MockServiceAccountRepository {
+	mock := &MockServiceAccountRepository{ctrl: ctrl}
+	mock.recorder = &MockServiceAccountRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockServiceAccountRepository) EXPECT() *MockServiceAccountRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m_2 *MockServiceAccountRepository) Create(ctx context.Context, m *model.ServiceAccount) (*model.ServiceAccount, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Create", ctx, m)
+	ret0, _ := ret[0].(*model.ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockServiceAccountRepositoryMockRecorder) Create(ctx, m interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockServiceAccountRepository)(nil).Create), ctx, m)
+}
+
+// Delete mocks base method.
+func (m *MockServiceAccountRepository) Delete(ctx context.Context, owner, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, owner, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockServiceAccountRepositoryMockRecorder) Delete(ctx, owner, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockServiceAccountRepository)(nil).Delete), ctx, owner, id)
+}
+
+// List mocks base method.
+func (m *MockServiceAccountRepository) List(ctx context.Context, owner uuid.UUID) ([]*model.ServiceAccount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, owner)
+	ret0, _ := ret[0].([]*model.ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockServiceAccountRepositoryMockRecorder) List(ctx, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockServiceAccountRepository)(nil).List), ctx, owner)
+}
+
+// Read mocks base method.
+func (m *MockServiceAccountRepository) Read(ctx context.Context, id uuid.UUID) (*model.ServiceAccount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Read", ctx, id)
+	ret0, _ := ret[0].(*model.ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Read indicates an expected call of Read.
+func (mr *MockServiceAccountRepositoryMockRecorder) Read(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Read", reflect.TypeOf((*MockServiceAccountRepository)(nil).Read), ctx, id)
+}
+
+// UpdateGrants mocks base method.
+func (m *MockServiceAccountRepository) UpdateGrants(ctx context.Context, owner, id uuid.UUID, grants []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateGrants", ctx, owner, id, grants)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateGrants indicates an expected call of UpdateGrants.
+func (mr *MockServiceAccountRepositoryMockRecorder) UpdateGrants(ctx, owner, id, grants interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateGrants", reflect.TypeOf((*MockServiceAccountRepository)(nil).UpdateGrants), ctx, owner, id, grants)
+}
+
 // MockSecretRepository is a mock of SecretRepository interface.
 type MockSecretRepository struct {
 	ctrl     *gomock.Controller
@@ -105,18 +762,18 @@ func (m *MockSecretRepository) EXPECT() *MockSecretRepositoryMockRecorder {
 }
 
 // Create mocks base method.
-func (m_2 *MockSecretRepository) Create(ctx context.Context, uid uuid.UUID, m *model.Secret) (*model.Secret, error) {
+func (m_2 *MockSecretRepository) Create(ctx context.Context, uid uuid.UUID, m *model.Secret, q model.Quota) (*model.Secret, error) {
 	m_2.ctrl.T.Helper()
-	ret := m_2.ctrl.Call(m_2, "Create", ctx, uid, m)
+	ret := m_2.ctrl.Call(m_2, "Create", ctx, uid, m, q)
 	ret0, _ := ret[0].(*model.Secret)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockSecretRepositoryMockRecorder) Create(ctx, uid, m interface{}) *gomock.Call {
+func (mr *MockSecretRepositoryMockRecorder) Create(ctx, uid, m, q interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSecretRepository)(nil).Create), ctx, uid, m)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSecretRepository)(nil).Create), ctx, uid, m, q)
 }
 
 // DeleteByName mocks base method.
@@ -162,3 +819,33 @@ func (mr *MockSecretRepositoryMockRecorder) ReadByName(ctx, uid, name interface{
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadByName", reflect.TypeOf((*MockSecretRepository)(nil).ReadByName), ctx, uid, name)
 }
+
+// Update mocks base method.
+func (m_2 *MockSecretRepository) Update(ctx context.Context, uid uuid.UUID, name string, m *model.Secret, q model.Quota) (*model.Secret, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Update", ctx, uid, name, m, q)
+	ret0, _ := ret[0].(*model.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockSecretRepositoryMockRecorder) Update(ctx, uid, name, m, q interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockSecretRepository)(nil).Update), ctx, uid, name, m, q)
+}
+
+// Usage mocks base method.
+func (m *MockSecretRepository) Usage(ctx context.Context, uid uuid.UUID) (*model.Usage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Usage", ctx, uid)
+	ret0, _ := ret[0].(*model.Usage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Usage indicates an expected call of Usage.
+func (mr *MockSecretRepositoryMockRecorder) Usage(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Usage", reflect.TypeOf((*MockSecretRepository)(nil).Usage), ctx, uid)
+}
@@ -0,0 +1,189 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	pg "github.com/lib/pq"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/internal/server/storage"
+	"gophkeeper/pkg/apperr"
+)
+
+// storage.APITokenRepository interface implementation
+var _ storage.APITokenRepository = (*APITokenRepository)(nil)
+
+type APITokenRepository struct {
+	db *sql.DB
+}
+
+func NewAPITokenRepository(db *sql.DB) (*APITokenRepository, error) {
+	s := &APITokenRepository{
+		db: db,
+	}
+
+	return s, nil
+}
+
+// Create implementation of interface storage.APITokenRepository
+func (r *APITokenRepository) Create(ctx context.Context, token *model.APIToken) (*model.APIToken, error) {
+	const SQL = `
+		INSERT INTO api_tokens (user_id, service_account_id, name, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+`
+
+	err := r.db.QueryRowContext(
+		ctx,
+		SQL,
+		token.UserID,
+		nullUUID(token.ServiceAccountID),
+		token.Name,
+		pg.Array(token.Scopes),
+		token.ExpiresAt,
+	).Scan(
+		&token.ID,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if pgErr, ok := err.(*pg.Error); ok {
+			if pgerrcode.IsIntegrityConstraintViolation(string(pgErr.Code)) {
+				return nil, apperr.ErrConflict
+			}
+		}
+
+		return nil, fmt.Errorf("insert: %w", err)
+	}
+
+	return token, nil
+}
+
+// Touch implementation of interface storage.APITokenRepository
+func (r *APITokenRepository) Touch(ctx context.Context, id uuid.UUID, ip string) (*model.APIToken, error) {
+	const SQL = `
+		UPDATE api_tokens
+		SET last_used_at = NOW(), last_used_ip = $2
+		WHERE id = $1 AND expires_at > NOW()
+		RETURNING id, user_id, service_account_id, name, scopes, created_at, expires_at, last_used_at, last_used_ip
+`
+
+	token, err := scanAPIToken(r.db.QueryRowContext(ctx, SQL, id, ip))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.ErrNotFound
+		}
+		return nil, fmt.Errorf("update: %w", err)
+	}
+
+	return token, nil
+}
+
+// List implementation of interface storage.APITokenRepository
+func (r *APITokenRepository) List(ctx context.Context, uid uuid.UUID, sa uuid.UUID) ([]*model.APIToken, error) {
+	const SQL = `
+		SELECT id, user_id, service_account_id, name, scopes, created_at, expires_at, last_used_at, last_used_ip
+		FROM api_tokens
+		WHERE user_id = $1 AND service_account_id IS NOT DISTINCT FROM $2 AND expires_at > NOW()
+		ORDER BY created_at
+`
+
+	rows, err := r.db.QueryContext(ctx, SQL, uid, nullUUID(sa))
+	if err != nil {
+		return nil, fmt.Errorf("select: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var res []*model.APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		res = append(res, token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return res, nil
+}
+
+// Delete implementation of interface storage.APITokenRepository
+func (r *APITokenRepository) Delete(ctx context.Context, uid uuid.UUID, id uuid.UUID) error {
+	const SQL = `
+		DELETE FROM api_tokens
+		WHERE user_id = $1 AND id = $2
+`
+
+	res, err := r.db.ExecContext(ctx, SQL, uid, id)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteUserTokens implementation of interface storage.APITokenRepository
+func (r *APITokenRepository) DeleteUserTokens(ctx context.Context, uid uuid.UUID) (int64, error) {
+	const SQL = `
+		DELETE FROM api_tokens
+		WHERE user_id = $1 AND service_account_id IS NULL
+`
+
+	res, err := r.db.ExecContext(ctx, SQL, uid)
+	if err != nil {
+		return 0, fmt.Errorf("delete: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rows affected: %w", err)
+	}
+
+	return n, nil
+}
+
+func scanAPIToken(row rowScanner) (*model.APIToken, error) {
+	token := &model.APIToken{}
+	var serviceAccountID uuid.NullUUID
+	var lastUsedAt sql.NullTime
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&serviceAccountID,
+		&token.Name,
+		pg.Array(&token.Scopes),
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&lastUsedAt,
+		&token.LastUsedIP,
+	)
+	if err != nil {
+		return nil, err
+	}
+	token.ServiceAccountID = serviceAccountID.UUID
+	token.LastUsedAt = lastUsedAt.Time
+	return token, nil
+}
+
+// nullUUID stores uuid.Nil as NULL
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{
+		UUID:  id,
+		Valid: id != uuid.Nil,
+	}
+}
@@ -0,0 +1,183 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	pg "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/pkg/apperr"
+)
+
+var apiTokenColumns = []string{
+	"id", "user_id", "service_account_id", "name", "scopes", "created_at", "expires_at", "last_used_at", "last_used_ip",
+}
+
+func TestAPITokenRepository_Create(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid, id := uuid.New(), uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery(`INSERT INTO api_tokens`).WithArgs(uid, nil, "ci", `{"read","write:ci/"}`, now).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "created_at"}).AddRow(id.String(), now),
+	)
+	mock.ExpectQuery(`INSERT INTO api_tokens`).WithArgs(uid, nil, "ci", `{"read","write:ci/"}`, now).WillReturnError(
+		&pg.Error{
+			Code:    pgerrcode.UniqueViolation,
+			Message: "duplicate key",
+		})
+
+	r, err := NewAPITokenRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.Create(context.TODO(), &model.APIToken{
+		UserID:    uid,
+		Name:      "ci",
+		Scopes:    []string{"read", "write:ci/"},
+		ExpiresAt: now,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, id, got.ID)
+	assert.Equal(t, now, got.CreatedAt)
+
+	_, err = r.Create(context.TODO(), &model.APIToken{
+		UserID:    uid,
+		Name:      "ci",
+		Scopes:    []string{"read", "write:ci/"},
+		ExpiresAt: now,
+	})
+	assert.ErrorIs(t, err, apperr.ErrConflict)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPITokenRepository_Touch(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid, id := uuid.New(), uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery(`UPDATE api_tokens`).WithArgs(id, "10.0.0.1").WillReturnRows(
+		sqlmock.NewRows(apiTokenColumns).AddRow(id.String(), uid.String(), nil, "ci", "{read,write:ci/}", now, now, now, "10.0.0.1"),
+	)
+	mock.ExpectQuery(`UPDATE api_tokens`).WithArgs(id, "10.0.0.1").WillReturnRows(
+		sqlmock.NewRows(apiTokenColumns),
+	)
+	mock.ExpectQuery(`UPDATE api_tokens`).WithArgs(id, "10.0.0.1").WillReturnError(errors.New("db is down"))
+
+	r, err := NewAPITokenRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.Touch(context.TODO(), id, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.APIToken{
+		ID:         id,
+		UserID:     uid,
+		Name:       "ci",
+		Scopes:     []string{"read", "write:ci/"},
+		CreatedAt:  now,
+		ExpiresAt:  now,
+		LastUsedAt: now,
+		LastUsedIP: "10.0.0.1",
+	}, got)
+
+	_, err = r.Touch(context.TODO(), id, "10.0.0.1")
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	_, err = r.Touch(context.TODO(), id, "10.0.0.1")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, apperr.ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPITokenRepository_List(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid, sa := uuid.New(), uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM api_tokens`).WithArgs(uid, sa).WillReturnRows(
+		sqlmock.NewRows(apiTokenColumns).
+			AddRow(uuid.New().String(), uid.String(), sa.String(), "ci", "{read}", now, now, nil, "").
+			AddRow(uuid.New().String(), uid.String(), sa.String(), "deploy", "{write:deploy/}", now, now, now, "10.0.0.2"),
+	)
+
+	r, err := NewAPITokenRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.List(context.TODO(), uid, sa)
+	assert.NoError(t, err)
+	if assert.Len(t, got, 2) {
+		assert.True(t, got[0].LastUsedAt.IsZero())
+		assert.Equal(t, sa, got[0].ServiceAccountID)
+		assert.Equal(t, []string{"write:deploy/"}, got[1].Scopes)
+		assert.Equal(t, now, got[1].LastUsedAt)
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPITokenRepository_Delete(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid, id := uuid.New(), uuid.New()
+
+	mock.ExpectExec(`DELETE FROM api_tokens`).WithArgs(uid, id).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM api_tokens`).WithArgs(uid, id).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	r, err := NewAPITokenRepository(mdb)
+	require.NoError(t, err)
+
+	assert.NoError(t, r.Delete(context.TODO(), uid, id))
+	assert.ErrorIs(t, r.Delete(context.TODO(), uid, id), apperr.ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPITokenRepository_DeleteUserTokens(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid := uuid.New()
+
+	mock.ExpectExec(`DELETE FROM api_tokens\s+WHERE user_id = \$1 AND service_account_id IS NULL`).
+		WithArgs(uid).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	r, err := NewAPITokenRepository(mdb)
+	require.NoError(t, err)
+
+	n, err := r.DeleteUserTokens(context.TODO(), uid)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
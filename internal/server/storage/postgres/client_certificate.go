@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	pg "github.com/lib/pq"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/internal/server/storage"
+	"gophkeeper/pkg/apperr"
+)
+
+// storage.ClientCertificateRepository interface implementation
+var _ storage.ClientCertificateRepository = (*ClientCertificateRepository)(nil)
+
+type ClientCertificateRepository struct {
+	db *sql.DB
+}
+
+func NewClientCertificateRepository(db *sql.DB) (*ClientCertificateRepository, error) {
+	s := &ClientCertificateRepository{
+		db: db,
+	}
+
+	return s, nil
+}
+
+// Create implementation of interface storage.ClientCertificateRepository
+func (r *ClientCertificateRepository) Create(ctx context.Context, cert *model.ClientCertificate) (*model.ClientCertificate, error) {
+	const SQL = `
+		INSERT INTO client_certificates (user_id, service_account_id, name, fingerprint, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+`
+
+	err := r.db.QueryRowContext(
+		ctx,
+		SQL,
+		cert.UserID,
+		nullUUID(cert.ServiceAccountID),
+		cert.Name,
+		cert.Fingerprint,
+		cert.ExpiresAt,
+	).Scan(
+		&cert.ID,
+		&cert.CreatedAt,
+	)
+	if err != nil {
+		if pgErr, ok := err.(*pg.Error); ok {
+			if pgerrcode.IsIntegrityConstraintViolation(string(pgErr.Code)) {
+				return nil, apperr.ErrConflict
+			}
+		}
+
+		return nil, fmt.Errorf("insert: %w", err)
+	}
+
+	return cert, nil
+}
+
+// ReadByFingerprint implementation of interface storage.ClientCertificateRepository
+func (r *ClientCertificateRepository) ReadByFingerprint(ctx context.Context, fingerprint string) (*model.ClientCertificate, error) {
+	const SQL = `
+		SELECT id, user_id, service_account_id, name, fingerprint, created_at, expires_at
+		FROM client_certificates
+		WHERE fingerprint = $1
+`
+
+	cert, err := scanClientCertificate(r.db.QueryRowContext(ctx, SQL, fingerprint))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.ErrNotFound
+		}
+		return nil, fmt.Errorf("select: %w", err)
+	}
+
+	return cert, nil
+}
+
+// List implementation of interface storage.ClientCertificateRepository
+func (r *ClientCertificateRepository) List(ctx context.Context, uid uuid.UUID, sa uuid.UUID) ([]*model.ClientCertificate, error) {
+	const SQL = `
+		SELECT id, user_id, service_account_id, name, fingerprint, created_at, expires_at
+		FROM client_certificates
+		WHERE user_id = $1 AND service_account_id IS NOT DISTINCT FROM $2
+		ORDER BY created_at
+`
+
+	rows, err := r.db.QueryContext(ctx, SQL, uid, nullUUID(sa))
+	if err != nil {
+		return nil, fmt.Errorf("select: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var res []*model.ClientCertificate
+	for rows.Next() {
+		cert, err := scanClientCertificate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		res = append(res, cert)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return res, nil
+}
+
+// Delete implementation of interface storage.ClientCertificateRepository
+func (r *ClientCertificateRepository) Delete(ctx context.Context, uid uuid.UUID, id uuid.UUID) error {
+	const SQL = `
+		DELETE FROM client_certificates
+		WHERE user_id = $1 AND id = $2
+`
+
+	res, err := r.db.ExecContext(ctx, SQL, uid, id)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+func scanClientCertificate(row rowScanner) (*model.ClientCertificate, error) {
+	cert := &model.ClientCertificate{}
+	var serviceAccountID uuid.NullUUID
+	err := row.Scan(
+		&cert.ID,
+		&cert.UserID,
+		&serviceAccountID,
+		&cert.Name,
+		&cert.Fingerprint,
+		&cert.CreatedAt,
+		&cert.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	cert.ServiceAccountID = serviceAccountID.UUID
+	return cert, nil
+}
@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	pg "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/pkg/apperr"
+)
+
+var clientCertificateColumns = []string{
+	"id", "user_id", "service_account_id", "name", "fingerprint", "created_at", "expires_at",
+}
+
+func TestClientCertificateRepository_Create(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid, sa, id := uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery(`INSERT INTO client_certificates`).WithArgs(uid, sa, "ci", "SHA256:ab", now).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "created_at"}).AddRow(id.String(), now),
+	)
+	mock.ExpectQuery(`INSERT INTO client_certificates`).WithArgs(uid, nil, "laptop", "SHA256:ab", now).WillReturnError(
+		&pg.Error{
+			Code:    pgerrcode.UniqueViolation,
+			Message: "duplicate key",
+		})
+
+	r, err := NewClientCertificateRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.Create(context.TODO(), &model.ClientCertificate{
+		UserID:           uid,
+		ServiceAccountID: sa,
+		Name:             "ci",
+		Fingerprint:      "SHA256:ab",
+		ExpiresAt:        now,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, id, got.ID)
+	assert.Equal(t, now, got.CreatedAt)
+
+	_, err = r.Create(context.TODO(), &model.ClientCertificate{
+		UserID:      uid,
+		Name:        "laptop",
+		Fingerprint: "SHA256:ab",
+		ExpiresAt:   now,
+	})
+	assert.ErrorIs(t, err, apperr.ErrConflict)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClientCertificateRepository_ReadByFingerprint(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid, id := uuid.New(), uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM client_certificates`).WithArgs("SHA256:ab").WillReturnRows(
+		sqlmock.NewRows(clientCertificateColumns).AddRow(id.String(), uid.String(), nil, "laptop", "SHA256:ab", now, now),
+	)
+	mock.ExpectQuery(`SELECT (.+) FROM client_certificates`).WithArgs("SHA256:ab").WillReturnRows(
+		sqlmock.NewRows(clientCertificateColumns),
+	)
+	mock.ExpectQuery(`SELECT (.+) FROM client_certificates`).WithArgs("SHA256:ab").WillReturnError(errors.New("db is down"))
+
+	r, err := NewClientCertificateRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.ReadByFingerprint(context.TODO(), "SHA256:ab")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.ClientCertificate{
+		ID:          id,
+		UserID:      uid,
+		Name:        "laptop",
+		Fingerprint: "SHA256:ab",
+		CreatedAt:   now,
+		ExpiresAt:   now,
+	}, got)
+
+	_, err = r.ReadByFingerprint(context.TODO(), "SHA256:ab")
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	_, err = r.ReadByFingerprint(context.TODO(), "SHA256:ab")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, apperr.ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClientCertificateRepository_List(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid, sa := uuid.New(), uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM client_certificates`).WithArgs(uid, sa).WillReturnRows(
+		sqlmock.NewRows(clientCertificateColumns).
+			AddRow(uuid.New().String(), uid.String(), sa.String(), "ci", "SHA256:ab", now, now).
+			AddRow(uuid.New().String(), uid.String(), sa.String(), "deploy", "SHA256:cd", now, now),
+	)
+
+	r, err := NewClientCertificateRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.List(context.TODO(), uid, sa)
+	assert.NoError(t, err)
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, sa, got[0].ServiceAccountID)
+		assert.Equal(t, "SHA256:cd", got[1].Fingerprint)
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClientCertificateRepository_Delete(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid, id := uuid.New(), uuid.New()
+
+	mock.ExpectExec(`DELETE FROM client_certificates`).WithArgs(uid, id).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM client_certificates`).WithArgs(uid, id).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	r, err := NewClientCertificateRepository(mdb)
+	require.NoError(t, err)
+
+	assert.NoError(t, r.Delete(context.TODO(), uid, id))
+	assert.ErrorIs(t, r.Delete(context.TODO(), uid, id), apperr.ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/internal/server/storage"
+	"gophkeeper/pkg/apperr"
+)
+
+// storage.RefreshTokenRepository interface implementation
+var _ storage.RefreshTokenRepository = (*RefreshTokenRepository)(nil)
+
+type RefreshTokenRepository struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenRepository(db *sql.DB) (*RefreshTokenRepository, error) {
+	s := &RefreshTokenRepository{
+		db: db,
+	}
+
+	return s, nil
+}
+
+// Create implementation of interface storage.RefreshTokenRepository
+func (r *RefreshTokenRepository) Create(ctx context.Context, token *model.RefreshToken) (*model.RefreshToken, error) {
+	return createRefreshToken(ctx, r.db, token)
+}
+
+// Rotate implementation of interface storage.RefreshTokenRepository
+func (r *RefreshTokenRepository) Rotate(
+	ctx context.Context,
+	hash string,
+	next *model.RefreshToken,
+) (*model.RefreshToken, error) {
+	const lockSQL = `
+		SELECT user_id, session_id, expires_at > NOW(), used_at IS NOT NULL
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+`
+	const revokeSQL = `
+		DELETE FROM sessions
+		WHERE id = $1
+`
+	const useSQL = `
+		UPDATE refresh_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1
+`
+	const pruneSQL = `
+		DELETE FROM refresh_tokens
+		WHERE session_id = $1 AND expires_at <= NOW()
+`
+	const sessionSQL = `
+		UPDATE sessions
+		SET expires_at = $2, last_used_at = NOW()
+		WHERE id = $1
+`
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var valid, used bool
+	err = tx.QueryRowContext(ctx, lockSQL, hash).Scan(&next.UserID, &next.SessionID, &valid, &used)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.ErrNotFound
+		}
+		return nil, fmt.Errorf("lock: %w", err)
+	}
+
+	if used {
+		// refresh tokens of the session are deleted with it
+		if _, err := tx.ExecContext(ctx, revokeSQL, next.SessionID); err != nil {
+			return nil, fmt.Errorf("revoke session: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("commit: %w", err)
+		}
+		return nil, model.ErrRefreshTokenReused
+	}
+
+	if _, err := tx.ExecContext(ctx, useSQL, hash); err != nil {
+		return nil, fmt.Errorf("use: %w", err)
+	}
+	if !valid {
+		// expired token is consumed anyway
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("commit: %w", err)
+		}
+		return nil, apperr.ErrNotFound
+	}
+
+	if _, err := createRefreshToken(ctx, tx, next); err != nil {
+		return nil, err
+	}
+
+	// consumed tokens are kept for reuse detection till they expire
+	if _, err := tx.ExecContext(ctx, pruneSQL, next.SessionID); err != nil {
+		return nil, fmt.Errorf("prune: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, sessionSQL, next.SessionID, next.ExpiresAt); err != nil {
+		return nil, fmt.Errorf("update session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	return next, nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func createRefreshToken(ctx context.Context, q queryRower, token *model.RefreshToken) (*model.RefreshToken, error) {
+	const SQL = `
+		INSERT INTO refresh_tokens (user_id, session_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+`
+
+	err := q.QueryRowContext(ctx, SQL, token.UserID, token.SessionID, token.TokenHash, token.ExpiresAt).Scan(
+		&token.ID,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("insert: %w", err)
+	}
+
+	return token, nil
+}
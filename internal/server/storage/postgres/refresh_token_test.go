@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/pkg/apperr"
+)
+
+func TestRefreshTokenRepository_Rotate(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid := uuid.New()
+	sid := uuid.New()
+	nextID := uuid.New()
+	now := time.Now()
+	exp := now.Add(time.Hour)
+
+	// valid token
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT user_id, session_id, expires_at > NOW\(\), used_at IS NOT NULL\s+FROM refresh_tokens\s+WHERE token_hash = \$1\s+FOR UPDATE`).
+		WithArgs("valid").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"user_id", "session_id", "valid", "used"}).AddRow(uid.String(), sid.String(), true, false),
+		)
+	mock.ExpectExec(`UPDATE refresh_tokens\s+SET used_at = NOW\(\)`).WithArgs("valid").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO refresh_tokens`).WithArgs(uid, sid, "next", exp).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "created_at"}).AddRow(nextID.String(), now),
+	)
+	mock.ExpectExec(`DELETE FROM refresh_tokens\s+WHERE session_id = \$1 AND expires_at <= NOW\(\)`).WithArgs(sid).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`UPDATE sessions`).WithArgs(sid, exp).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// expired token
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM refresh_tokens`).WithArgs("expired").WillReturnRows(
+		sqlmock.NewRows([]string{"user_id", "session_id", "valid", "used"}).AddRow(uid.String(), sid.String(), false, false),
+	)
+	mock.ExpectExec(`UPDATE refresh_tokens`).WithArgs("expired").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// used token revokes the session
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM refresh_tokens`).WithArgs("used").WillReturnRows(
+		sqlmock.NewRows([]string{"user_id", "session_id", "valid", "used"}).AddRow(uid.String(), sid.String(), true, true),
+	)
+	mock.ExpectExec(`DELETE FROM sessions\s+WHERE id = \$1`).WithArgs(sid).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// unknown token
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM refresh_tokens`).WithArgs("unknown").WillReturnRows(
+		sqlmock.NewRows([]string{"user_id", "session_id", "valid", "used"}),
+	)
+	mock.ExpectRollback()
+
+	r, err := NewRefreshTokenRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.Rotate(context.TODO(), "valid", &model.RefreshToken{TokenHash: "next", ExpiresAt: exp})
+	assert.NoError(t, err)
+	assert.Equal(t, &model.RefreshToken{
+		ID:        nextID,
+		UserID:    uid,
+		SessionID: sid,
+		TokenHash: "next",
+		CreatedAt: now,
+		ExpiresAt: exp,
+	}, got)
+
+	_, err = r.Rotate(context.TODO(), "expired", &model.RefreshToken{TokenHash: "next", ExpiresAt: exp})
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	_, err = r.Rotate(context.TODO(), "used", &model.RefreshToken{TokenHash: "next", ExpiresAt: exp})
+	assert.ErrorIs(t, err, model.ErrRefreshTokenReused)
+
+	_, err = r.Rotate(context.TODO(), "unknown", &model.RefreshToken{TokenHash: "next", ExpiresAt: exp})
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
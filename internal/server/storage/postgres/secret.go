@@ -28,23 +28,160 @@ func NewSecretRepository(db *sql.DB) (*SecretRepository, error) {
 	return s, nil
 }
 
-// Create implementation of interface storage.SecretRepository
-func (r *SecretRepository) Create(ctx context.Context, uid uuid.UUID, secret *model.Secret) (*model.Secret, error) {
+// Create implementation of interface storage.SecretRepository,
+// the user row is locked while usage is checked, so concurrent creates can not exceed the quota
+func (r *SecretRepository) Create(ctx context.Context, uid uuid.UUID, secret *model.Secret, q model.Quota) (*model.Secret, error) {
+	const lockSQL = `
+		SELECT id
+		FROM users
+		WHERE id = $1
+		FOR UPDATE
+`
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if !q.Unlimited() {
+		var id uuid.UUID
+		if err := tx.QueryRowContext(ctx, lockSQL, uid).Scan(&id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, apperr.ErrNotFound
+			}
+			return nil, fmt.Errorf("lock user: %w", err)
+		}
+
+		u, err := usage(ctx, tx, uid)
+		if err != nil {
+			return nil, err
+		}
+		if d := q.Exceeded(*u, int64(len(secret.Content))); d != "" {
+			return nil, model.QuotaError(uid, d)
+		}
+	}
+
+	if err := createSecret(ctx, tx, secret); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	return secret, nil
+}
+
+func createSecret(ctx context.Context, q queryRower, secret *model.Secret) error {
 	const SQL = `
-		INSERT INTO secrets (user_id, type, name, content)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id
+		INSERT INTO secrets (user_id, type, name, content, tags)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
 `
 
-	err := r.db.QueryRowContext(ctx, SQL, secret.UserID, secret.Type, secret.Name, secret.Content).Scan(&secret.ID)
+	if secret.Tags == nil {
+		secret.Tags = []string{}
+	}
+
+	err := q.QueryRowContext(ctx, SQL, secret.UserID, secret.Type, secret.Name, secret.Content, pg.Array(secret.Tags)).Scan(
+		&secret.ID,
+		&secret.CreatedAt,
+		&secret.UpdatedAt,
+	)
+	if err != nil {
+		if pgErr, ok := err.(*pg.Error); ok {
+			if pgerrcode.IsIntegrityConstraintViolation(string(pgErr.Code)) {
+				return apperr.ErrConflict
+			}
+		}
+
+		return fmt.Errorf("insert: %w", err)
+	}
+
+	return nil
+}
+
+// Update implementation of interface storage.SecretRepository, the secret row is locked,
+// and the user row as well when the quota is limited, see Create
+func (r *SecretRepository) Update(ctx context.Context, uid uuid.UUID, name string, secret *model.Secret, q model.Quota) (*model.Secret, error) {
+	const lockUserSQL = `
+		SELECT id
+		FROM users
+		WHERE id = $1
+		FOR UPDATE
+`
+	const lockSQL = `
+		SELECT OCTET_LENGTH(content)
+		FROM secrets
+		WHERE user_id = $1 AND name = $2
+		FOR UPDATE
+`
+	const SQL = `
+		UPDATE secrets
+		SET name = $3, type = $4, content = $5, tags = COALESCE($6, tags), updated_at = NOW()
+		WHERE user_id = $1 AND name = $2
+		RETURNING id, tags, created_at, updated_at
+`
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if !q.Unlimited() {
+		var id uuid.UUID
+		if err := tx.QueryRowContext(ctx, lockUserSQL, uid).Scan(&id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, apperr.ErrNotFound
+			}
+			return nil, fmt.Errorf("lock user: %w", err)
+		}
+	}
+
+	var size int64
+	if err := tx.QueryRowContext(ctx, lockSQL, uid, name).Scan(&size); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.ErrNotFound
+		}
+		return nil, fmt.Errorf("lock secret: %w", err)
+	}
+
+	if !q.Unlimited() {
+		u, err := usage(ctx, tx, uid)
+		if err != nil {
+			return nil, err
+		}
+		// the replaced secret is not counted
+		u.Secrets--
+		u.TotalSize -= size
+		if d := q.Exceeded(*u, int64(len(secret.Content))); d != "" {
+			return nil, model.QuotaError(uid, d)
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, SQL, uid, name, secret.Name, secret.Type, secret.Content, pg.Array(secret.Tags)).Scan(
+		&secret.ID,
+		pg.Array(&secret.Tags),
+		&secret.CreatedAt,
+		&secret.UpdatedAt,
+	)
 	if err != nil {
 		if pgErr, ok := err.(*pg.Error); ok {
 			if pgerrcode.IsIntegrityConstraintViolation(string(pgErr.Code)) {
 				return nil, apperr.ErrConflict
 			}
 		}
+		return nil, fmt.Errorf("update: %w", err)
+	}
 
-		return nil, fmt.Errorf("insert: %w", err)
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
 	}
 
 	return secret, nil
@@ -52,13 +189,15 @@ func (r *SecretRepository) Create(ctx context.Context, uid uuid.UUID, secret *mo
 
 func (r *SecretRepository) ReadByName(ctx context.Context, uid uuid.UUID, name string) (*model.Secret, error) {
 	const SQL = `
-		SELECT id, type, name, content
+		SELECT id, type, name, content, tags, created_at, updated_at
 		FROM secrets
 		WHERE user_id = $1 AND name = $2;
 `
 	m := &model.Secret{}
 
-	err := r.db.QueryRowContext(ctx, SQL, uid.String(), name).Scan(&m.ID, &m.Type, &m.Name, &m.Content)
+	err := r.db.QueryRowContext(ctx, SQL, uid.String(), name).Scan(
+		&m.ID, &m.Type, &m.Name, &m.Content, pg.Array(&m.Tags), &m.CreatedAt, &m.UpdatedAt,
+	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, apperr.ErrNotFound
@@ -97,7 +236,10 @@ func (r *SecretRepository) List(ctx context.Context, uid uuid.UUID) ([]*model.Se
 		SELECT
 			id,
 			type,
-			name
+			name,
+			tags,
+			created_at,
+			updated_at
 		FROM secrets
 		WHERE user_id = $1
 		ORDER BY name
@@ -124,6 +266,9 @@ func (r *SecretRepository) List(ctx context.Context, uid uuid.UUID) ([]*model.Se
 			&m.ID,
 			&m.Type,
 			&m.Name,
+			pg.Array(&m.Tags),
+			&m.CreatedAt,
+			&m.UpdatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("scan: %w", err)
 		}
@@ -132,3 +277,22 @@ func (r *SecretRepository) List(ctx context.Context, uid uuid.UUID) ([]*model.Se
 
 	return res, nil
 }
+
+func (r *SecretRepository) Usage(ctx context.Context, uid uuid.UUID) (*model.Usage, error) {
+	return usage(ctx, r.db, uid)
+}
+
+func usage(ctx context.Context, q queryRower, uid uuid.UUID) (*model.Usage, error) {
+	const SQL = `
+		SELECT COUNT(*), COALESCE(SUM(OCTET_LENGTH(content)), 0)
+		FROM secrets
+		WHERE user_id = $1;
+`
+	m := &model.Usage{}
+
+	if err := q.QueryRowContext(ctx, SQL, uid).Scan(&m.Secrets, &m.TotalSize); err != nil {
+		return nil, fmt.Errorf("select: %w", err)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/pkg/apperr"
+)
+
+func TestSecretRepository_Create(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid := uuid.New()
+	id := uuid.New()
+	now := time.Now()
+	q := model.Quota{MaxSecrets: 2, MaxTotalSize: 16}
+	secret := func() *model.Secret {
+		return &model.Secret{UserID: uid, Name: "db", Type: "raw", Content: []byte("password")}
+	}
+
+	// unlimited, no lock
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO secrets`).WithArgs(uid, "raw", "db", []byte("password"), "{}").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(id.String(), now, now),
+	)
+	mock.ExpectCommit()
+
+	// within the quota
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id\s+FROM users\s+WHERE id = \$1\s+FOR UPDATE`).WithArgs(uid).WillReturnRows(
+		sqlmock.NewRows([]string{"id"}).AddRow(uid.String()),
+	)
+	mock.ExpectQuery(`SELECT COUNT\(\*\)`).WithArgs(uid).WillReturnRows(
+		sqlmock.NewRows([]string{"count", "size"}).AddRow(1, 8),
+	)
+	mock.ExpectQuery(`INSERT INTO secrets`).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(id.String(), now, now),
+	)
+	mock.ExpectCommit()
+
+	// total size would be exceeded
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FOR UPDATE`).WithArgs(uid).WillReturnRows(
+		sqlmock.NewRows([]string{"id"}).AddRow(uid.String()),
+	)
+	mock.ExpectQuery(`SELECT COUNT\(\*\)`).WithArgs(uid).WillReturnRows(
+		sqlmock.NewRows([]string{"count", "size"}).AddRow(1, 9),
+	)
+	mock.ExpectRollback()
+
+	r, err := NewSecretRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.Create(context.TODO(), uid, secret(), model.Quota{})
+	require.NoError(t, err)
+	assert.Equal(t, id, got.ID)
+	assert.Equal(t, []string{}, got.Tags)
+
+	_, err = r.Create(context.TODO(), uid, secret(), q)
+	require.NoError(t, err)
+
+	_, err = r.Create(context.TODO(), uid, secret(), q)
+	assert.ErrorIs(t, err, apperr.ErrExhausted)
+	assert.EqualError(t, err, "quota exceeded: total size 17 would exceed limit of 16 bytes")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSecretRepository_Update(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid := uuid.New()
+	id := uuid.New()
+	now := time.Now()
+	created := now.Add(-time.Hour)
+	secret := func() *model.Secret {
+		return &model.Secret{UserID: uid, Name: "prod/db", Type: "raw", Content: []byte("password")}
+	}
+
+	// the replaced secret is not counted against the quota
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM users`).WithArgs(uid).WillReturnRows(
+		sqlmock.NewRows([]string{"id"}).AddRow(uid.String()),
+	)
+	mock.ExpectQuery(`SELECT OCTET_LENGTH\(content\)`).WithArgs(uid, "db").WillReturnRows(
+		sqlmock.NewRows([]string{"size"}).AddRow(8),
+	)
+	mock.ExpectQuery(`SELECT COUNT\(\*\)`).WithArgs(uid).WillReturnRows(
+		sqlmock.NewRows([]string{"count", "size"}).AddRow(2, 16),
+	)
+	mock.ExpectQuery(`UPDATE secrets\s+SET name = \$3, type = \$4, content = \$5, tags = COALESCE\(\$6, tags\), updated_at = NOW\(\)`).
+		WithArgs(uid, "db", "prod/db", "raw", []byte("password"), nil).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "tags", "created_at", "updated_at"}).AddRow(id.String(), "{ci}", created, now),
+		)
+	mock.ExpectCommit()
+
+	// tags are replaced
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT OCTET_LENGTH\(content\)`).WithArgs(uid, "prod/db").WillReturnRows(
+		sqlmock.NewRows([]string{"size"}).AddRow(8),
+	)
+	mock.ExpectQuery(`UPDATE secrets`).
+		WithArgs(uid, "prod/db", "prod/db", "raw", []byte("password"), `{"prod"}`).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "tags", "created_at", "updated_at"}).AddRow(id.String(), "{prod}", created, now),
+		)
+	mock.ExpectCommit()
+
+	// unknown secret
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT OCTET_LENGTH\(content\)`).WithArgs(uid, "missing").WillReturnRows(
+		sqlmock.NewRows([]string{"size"}),
+	)
+	mock.ExpectRollback()
+
+	r, err := NewSecretRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.Update(context.TODO(), uid, "db", secret(), model.Quota{MaxSecrets: 2, MaxTotalSize: 16})
+	require.NoError(t, err)
+	assert.Equal(t, id, got.ID)
+	assert.Equal(t, "prod/db", got.Name)
+	assert.Equal(t, created, got.CreatedAt)
+	assert.Equal(t, now, got.UpdatedAt)
+	// kept tags are returned
+	assert.Equal(t, []string{"ci"}, got.Tags)
+
+	tagged := secret()
+	tagged.Tags = []string{"prod"}
+	got, err = r.Update(context.TODO(), uid, "prod/db", tagged, model.Quota{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod"}, got.Tags)
+
+	_, err = r.Update(context.TODO(), uid, "missing", secret(), model.Quota{})
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
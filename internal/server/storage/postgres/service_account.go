@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	pg "github.com/lib/pq"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/internal/server/storage"
+	"gophkeeper/pkg/apperr"
+)
+
+// storage.ServiceAccountRepository interface implementation
+var _ storage.ServiceAccountRepository = (*ServiceAccountRepository)(nil)
+
+type ServiceAccountRepository struct {
+	db *sql.DB
+}
+
+func NewServiceAccountRepository(db *sql.DB) (*ServiceAccountRepository, error) {
+	s := &ServiceAccountRepository{
+		db: db,
+	}
+
+	return s, nil
+}
+
+// Create implementation of interface storage.ServiceAccountRepository
+func (r *ServiceAccountRepository) Create(ctx context.Context, sa *model.ServiceAccount) (*model.ServiceAccount, error) {
+	const SQL = `
+		INSERT INTO service_accounts (owner_id, name, grants)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+`
+
+	err := r.db.QueryRowContext(ctx, SQL, sa.OwnerID, sa.Name, pg.Array(nonNil(sa.Grants))).Scan(
+		&sa.ID,
+		&sa.CreatedAt,
+	)
+	if err != nil {
+		if pgErr, ok := err.(*pg.Error); ok {
+			if pgerrcode.IsIntegrityConstraintViolation(string(pgErr.Code)) {
+				return nil, apperr.ErrConflict
+			}
+		}
+
+		return nil, fmt.Errorf("insert: %w", err)
+	}
+
+	return sa, nil
+}
+
+// Read implementation of interface storage.ServiceAccountRepository
+func (r *ServiceAccountRepository) Read(ctx context.Context, id uuid.UUID) (*model.ServiceAccount, error) {
+	const SQL = `
+		SELECT id, owner_id, name, grants, created_at
+		FROM service_accounts
+		WHERE id = $1
+`
+
+	sa, err := scanServiceAccount(r.db.QueryRowContext(ctx, SQL, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.ErrNotFound
+		}
+		return nil, fmt.Errorf("select: %w", err)
+	}
+
+	return sa, nil
+}
+
+// List implementation of interface storage.ServiceAccountRepository
+func (r *ServiceAccountRepository) List(ctx context.Context, owner uuid.UUID) ([]*model.ServiceAccount, error) {
+	const SQL = `
+		SELECT id, owner_id, name, grants, created_at
+		FROM service_accounts
+		WHERE owner_id = $1
+		ORDER BY name
+`
+
+	rows, err := r.db.QueryContext(ctx, SQL, owner)
+	if err != nil {
+		return nil, fmt.Errorf("select: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var res []*model.ServiceAccount
+	for rows.Next() {
+		sa, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		res = append(res, sa)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return res, nil
+}
+
+// UpdateGrants implementation of interface storage.ServiceAccountRepository
+func (r *ServiceAccountRepository) UpdateGrants(ctx context.Context, owner uuid.UUID, id uuid.UUID, grants []string) error {
+	const SQL = `
+		UPDATE service_accounts
+		SET grants = $3
+		WHERE owner_id = $1 AND id = $2
+`
+
+	res, err := r.db.ExecContext(ctx, SQL, owner, id, pg.Array(nonNil(grants)))
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete implementation of interface storage.ServiceAccountRepository
+func (r *ServiceAccountRepository) Delete(ctx context.Context, owner uuid.UUID, id uuid.UUID) error {
+	const SQL = `
+		DELETE FROM service_accounts
+		WHERE owner_id = $1 AND id = $2
+`
+
+	res, err := r.db.ExecContext(ctx, SQL, owner, id)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+func scanServiceAccount(row rowScanner) (*model.ServiceAccount, error) {
+	sa := &model.ServiceAccount{}
+	err := row.Scan(
+		&sa.ID,
+		&sa.OwnerID,
+		&sa.Name,
+		pg.Array(&sa.Grants),
+		&sa.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sa, nil
+}
+
+// nonNil slice is stored as an empty array instead of NULL
+func nonNil(ss []string) []string {
+	if ss == nil {
+		return []string{}
+	}
+	return ss
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	pg "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/pkg/apperr"
+)
+
+var serviceAccountColumns = []string{"id", "owner_id", "name", "grants", "created_at"}
+
+func TestServiceAccountRepository_Create(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	owner, id := uuid.New(), uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery(`INSERT INTO service_accounts`).WithArgs(owner, "ci", `{}`).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "created_at"}).AddRow(id.String(), now),
+	)
+	mock.ExpectQuery(`INSERT INTO service_accounts`).WithArgs(owner, "ci", `{"read:ci/"}`).WillReturnError(
+		&pg.Error{
+			Code:    pgerrcode.UniqueViolation,
+			Message: "duplicate key",
+		})
+
+	r, err := NewServiceAccountRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.Create(context.TODO(), &model.ServiceAccount{OwnerID: owner, Name: "ci"})
+	assert.NoError(t, err)
+	assert.Equal(t, id, got.ID)
+
+	_, err = r.Create(context.TODO(), &model.ServiceAccount{OwnerID: owner, Name: "ci", Grants: []string{"read:ci/"}})
+	assert.ErrorIs(t, err, apperr.ErrConflict)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServiceAccountRepository_Read(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	owner, id := uuid.New(), uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM service_accounts`).WithArgs(id).WillReturnRows(
+		sqlmock.NewRows(serviceAccountColumns).AddRow(id.String(), owner.String(), "ci", "{read:ci/,write:ci/}", now),
+	)
+	mock.ExpectQuery(`SELECT (.+) FROM service_accounts`).WithArgs(id).WillReturnRows(
+		sqlmock.NewRows(serviceAccountColumns),
+	)
+
+	r, err := NewServiceAccountRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.Read(context.TODO(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.ServiceAccount{
+		ID:        id,
+		OwnerID:   owner,
+		Name:      "ci",
+		Grants:    []string{"read:ci/", "write:ci/"},
+		CreatedAt: now,
+	}, got)
+
+	_, err = r.Read(context.TODO(), id)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServiceAccountRepository_UpdateAndDelete(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	owner, id := uuid.New(), uuid.New()
+
+	mock.ExpectExec(`UPDATE service_accounts`).WithArgs(owner, id, `{"read"}`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE service_accounts`).WithArgs(owner, id, `{}`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM service_accounts`).WithArgs(owner, id).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM service_accounts`).WithArgs(owner, id).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	r, err := NewServiceAccountRepository(mdb)
+	require.NoError(t, err)
+
+	assert.NoError(t, r.UpdateGrants(context.TODO(), owner, id, []string{"read"}))
+	assert.ErrorIs(t, r.UpdateGrants(context.TODO(), owner, id, nil), apperr.ErrNotFound)
+	assert.NoError(t, r.Delete(context.TODO(), owner, id))
+	assert.ErrorIs(t, r.Delete(context.TODO(), owner, id), apperr.ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
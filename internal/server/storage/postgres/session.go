@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/internal/server/storage"
+	"gophkeeper/pkg/apperr"
+)
+
+// storage.SessionRepository interface implementation
+var _ storage.SessionRepository = (*SessionRepository)(nil)
+
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) (*SessionRepository, error) {
+	s := &SessionRepository{
+		db: db,
+	}
+
+	return s, nil
+}
+
+// Create implementation of interface storage.SessionRepository
+func (r *SessionRepository) Create(ctx context.Context, session *model.Session) (*model.Session, error) {
+	const SQL = `
+		INSERT INTO sessions (user_id, device, ip, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, last_used_at
+`
+
+	err := r.db.QueryRowContext(ctx, SQL, session.UserID, session.Device, session.IP, session.ExpiresAt).Scan(
+		&session.ID,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("insert: %w", err)
+	}
+
+	return session, nil
+}
+
+// Touch implementation of interface storage.SessionRepository, the session is written
+// only if its last use is older than a minute or the ip changed, as every authenticated call touches it
+func (r *SessionRepository) Touch(ctx context.Context, id uuid.UUID, ip string) (*model.Session, error) {
+	const selectSQL = `
+		SELECT id, user_id, device, ip, created_at, last_used_at, expires_at,
+			last_used_at < NOW() - INTERVAL '1 minute' OR ip <> $2
+		FROM sessions
+		WHERE id = $1 AND expires_at > NOW()
+`
+	const SQL = `
+		UPDATE sessions
+		SET last_used_at = NOW(), ip = $2
+		WHERE id = $1 AND expires_at > NOW()
+		RETURNING id, user_id, device, ip, created_at, last_used_at, expires_at
+`
+
+	session := &model.Session{}
+	var stale bool
+	err := r.db.QueryRowContext(ctx, selectSQL, id, ip).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.Device,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+		&stale,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.ErrNotFound
+		}
+		return nil, fmt.Errorf("select: %w", err)
+	}
+	if !stale {
+		return session, nil
+	}
+
+	session, err = scanSession(r.db.QueryRowContext(ctx, SQL, id, ip))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.ErrNotFound
+		}
+		return nil, fmt.Errorf("update: %w", err)
+	}
+
+	return session, nil
+}
+
+// List implementation of interface storage.SessionRepository
+func (r *SessionRepository) List(ctx context.Context, uid uuid.UUID) ([]*model.Session, error) {
+	const SQL = `
+		SELECT id, user_id, device, ip, created_at, last_used_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND expires_at > NOW()
+		ORDER BY last_used_at DESC
+`
+
+	rows, err := r.db.QueryContext(ctx, SQL, uid)
+	if err != nil {
+		return nil, fmt.Errorf("select: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var res []*model.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		res = append(res, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return res, nil
+}
+
+// Delete implementation of interface storage.SessionRepository
+func (r *SessionRepository) Delete(ctx context.Context, uid uuid.UUID, id uuid.UUID) error {
+	const SQL = `
+		DELETE FROM sessions
+		WHERE user_id = $1 AND id = $2
+`
+
+	res, err := r.db.ExecContext(ctx, SQL, uid, id)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteOthers implementation of interface storage.SessionRepository
+func (r *SessionRepository) DeleteOthers(ctx context.Context, uid uuid.UUID, keep uuid.UUID) (int64, error) {
+	const SQL = `
+		DELETE FROM sessions
+		WHERE user_id = $1 AND id <> $2
+`
+
+	res, err := r.db.ExecContext(ctx, SQL, uid, keep)
+	if err != nil {
+		return 0, fmt.Errorf("delete: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rows affected: %w", err)
+	}
+
+	return n, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row rowScanner) (*model.Session, error) {
+	session := &model.Session{}
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.Device,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/pkg/apperr"
+)
+
+var sessionColumns = []string{"id", "user_id", "device", "ip", "created_at", "last_used_at", "expires_at"}
+
+func TestSessionRepository_Touch(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid, sid := uuid.New(), uuid.New()
+	now := time.Now()
+
+	touchColumns := append(sessionColumns, "stale")
+	row := func(stale bool) *sqlmock.Rows {
+		return sqlmock.NewRows(touchColumns).AddRow(sid.String(), uid.String(), "laptop", "10.0.0.1", now, now, now, stale)
+	}
+
+	// stale session is updated
+	mock.ExpectQuery(`SELECT (.+) FROM sessions`).WithArgs(sid, "10.0.0.1").WillReturnRows(row(true))
+	mock.ExpectQuery(`UPDATE sessions`).WithArgs(sid, "10.0.0.1").WillReturnRows(
+		sqlmock.NewRows(sessionColumns).AddRow(sid.String(), uid.String(), "laptop", "10.0.0.1", now, now, now),
+	)
+	// recently used one is not
+	mock.ExpectQuery(`SELECT (.+) FROM sessions`).WithArgs(sid, "10.0.0.1").WillReturnRows(row(false))
+	mock.ExpectQuery(`SELECT (.+) FROM sessions`).WithArgs(sid, "10.0.0.1").WillReturnRows(
+		sqlmock.NewRows(touchColumns),
+	)
+	mock.ExpectQuery(`SELECT (.+) FROM sessions`).WithArgs(sid, "10.0.0.1").WillReturnError(errors.New("db is down"))
+
+	r, err := NewSessionRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.Touch(context.TODO(), sid, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Session{
+		ID:         sid,
+		UserID:     uid,
+		Device:     "laptop",
+		IP:         "10.0.0.1",
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now,
+	}, got)
+
+	got, err = r.Touch(context.TODO(), sid, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, sid, got.ID)
+
+	_, err = r.Touch(context.TODO(), sid, "10.0.0.1")
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	_, err = r.Touch(context.TODO(), sid, "10.0.0.1")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, apperr.ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepository_List(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid := uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM sessions`).WithArgs(uid).WillReturnRows(
+		sqlmock.NewRows(sessionColumns).
+			AddRow(uuid.New().String(), uid.String(), "laptop", "10.0.0.1", now, now, now).
+			AddRow(uuid.New().String(), uid.String(), "phone", "10.0.0.2", now, now, now),
+	)
+
+	r, err := NewSessionRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.List(context.TODO(), uid)
+	assert.NoError(t, err)
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, "laptop", got[0].Device)
+		assert.Equal(t, "10.0.0.2", got[1].IP)
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepository_Delete(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid, sid := uuid.New(), uuid.New()
+
+	mock.ExpectExec(`DELETE FROM sessions`).WithArgs(uid, sid).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM sessions`).WithArgs(uid, sid).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM sessions`).WithArgs(uid, sid).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	r, err := NewSessionRepository(mdb)
+	require.NoError(t, err)
+
+	assert.NoError(t, r.Delete(context.TODO(), uid, sid))
+	assert.ErrorIs(t, r.Delete(context.TODO(), uid, sid), apperr.ErrNotFound)
+
+	n, err := r.DeleteOthers(context.TODO(), uid, sid)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/internal/server/storage"
+	"gophkeeper/pkg/apperr"
+)
+
+// storage.TwoFactorRepository interface implementation
+var _ storage.TwoFactorRepository = (*TwoFactorRepository)(nil)
+
+type TwoFactorRepository struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepository(db *sql.DB) (*TwoFactorRepository, error) {
+	s := &TwoFactorRepository{
+		db: db,
+	}
+
+	return s, nil
+}
+
+// Read implementation of interface storage.TwoFactorRepository
+func (r *TwoFactorRepository) Read(ctx context.Context, uid uuid.UUID) (*model.TwoFactor, error) {
+	const SQL = `
+		SELECT user_id, secret, enabled, last_step
+		FROM two_factor
+		WHERE user_id = $1
+`
+	m := &model.TwoFactor{}
+
+	err := r.db.QueryRowContext(ctx, SQL, uid).Scan(&m.UserID, &m.Secret, &m.Enabled, &m.LastStep)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.ErrNotFound
+		}
+		return nil, fmt.Errorf("select: %w", err)
+	}
+
+	return m, nil
+}
+
+// Save implementation of interface storage.TwoFactorRepository
+func (r *TwoFactorRepository) Save(ctx context.Context, m *model.TwoFactor) error {
+	const SQL = `
+		INSERT INTO two_factor (user_id, secret, enabled, last_step)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, enabled = EXCLUDED.enabled, last_step = EXCLUDED.last_step, created_at = NOW()
+`
+
+	if _, err := r.db.ExecContext(ctx, SQL, m.UserID, m.Secret, m.Enabled, m.LastStep); err != nil {
+		return fmt.Errorf("upsert: %w", err)
+	}
+
+	return nil
+}
+
+// Enable implementation of interface storage.TwoFactorRepository
+func (r *TwoFactorRepository) Enable(ctx context.Context, uid uuid.UUID, step int64, recoveryHashes []string) error {
+	const enableSQL = `
+		UPDATE two_factor
+		SET enabled = TRUE, last_step = $2
+		WHERE user_id = $1 AND NOT enabled
+`
+	const deleteCodesSQL = `
+		DELETE FROM recovery_codes
+		WHERE user_id = $1
+`
+	const insertCodeSQL = `
+		INSERT INTO recovery_codes (user_id, code_hash)
+		VALUES ($1, $2)
+`
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx, enableSQL, uid, step)
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return apperr.ErrNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, deleteCodesSQL, uid); err != nil {
+		return fmt.Errorf("delete codes: %w", err)
+	}
+	for _, h := range recoveryHashes {
+		if _, err := tx.ExecContext(ctx, insertCodeSQL, uid, h); err != nil {
+			return fmt.Errorf("insert code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	return nil
+}
+
+// UseStep implementation of interface storage.TwoFactorRepository
+func (r *TwoFactorRepository) UseStep(ctx context.Context, uid uuid.UUID, step int64) error {
+	const SQL = `
+		UPDATE two_factor
+		SET last_step = $2
+		WHERE user_id = $1 AND last_step < $2
+`
+
+	res, err := r.db.ExecContext(ctx, SQL, uid, step)
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return apperr.ErrConflict
+	}
+
+	return nil
+}
+
+// UseRecoveryCode implementation of interface storage.TwoFactorRepository
+func (r *TwoFactorRepository) UseRecoveryCode(ctx context.Context, uid uuid.UUID, hash string) error {
+	const SQL = `
+		DELETE FROM recovery_codes
+		WHERE user_id = $1 AND code_hash = $2
+`
+
+	res, err := r.db.ExecContext(ctx, SQL, uid, hash)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete implementation of interface storage.TwoFactorRepository
+func (r *TwoFactorRepository) Delete(ctx context.Context, uid uuid.UUID) error {
+	const deleteCodesSQL = `
+		DELETE FROM recovery_codes
+		WHERE user_id = $1
+`
+	const SQL = `
+		DELETE FROM two_factor
+		WHERE user_id = $1
+`
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, deleteCodesSQL, uid); err != nil {
+		return fmt.Errorf("delete codes: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, SQL, uid); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gophkeeper/pkg/apperr"
+)
+
+func TestTwoFactorRepository_Enable(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE two_factor`).WithArgs(uid, int64(42)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM recovery_codes`).WithArgs(uid).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO recovery_codes`).WithArgs(uid, "h1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO recovery_codes`).WithArgs(uid, "h2").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// already enabled or not enrolled
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE two_factor`).WithArgs(uid, int64(42)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	r, err := NewTwoFactorRepository(mdb)
+	require.NoError(t, err)
+
+	assert.NoError(t, r.Enable(context.TODO(), uid, 42, []string{"h1", "h2"}))
+	assert.ErrorIs(t, r.Enable(context.TODO(), uid, 42, []string{"h1", "h2"}), apperr.ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTwoFactorRepository_Use(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid := uuid.New()
+
+	mock.ExpectExec(`UPDATE two_factor`).WithArgs(uid, int64(42)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE two_factor`).WithArgs(uid, int64(42)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM recovery_codes`).WithArgs(uid, "h1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM recovery_codes`).WithArgs(uid, "h1").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	r, err := NewTwoFactorRepository(mdb)
+	require.NoError(t, err)
+
+	assert.NoError(t, r.UseStep(context.TODO(), uid, 42))
+	assert.ErrorIs(t, r.UseStep(context.TODO(), uid, 42), apperr.ErrConflict)
+	assert.NoError(t, r.UseRecoveryCode(context.TODO(), uid, "h1"))
+	assert.ErrorIs(t, r.UseRecoveryCode(context.TODO(), uid, "h1"), apperr.ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
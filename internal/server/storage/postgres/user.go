@@ -28,15 +28,15 @@ func NewUserRepository(db *sql.DB) (*UserRepository, error) {
 	return s, nil
 }
 
-// Create implementation of interface storage.UserRepository
+// Create implementation of interface storage.UserRepository, either a password or an SRP verifier is stored
 func (r *UserRepository) Create(ctx context.Context, user *model.User) (*model.User, error) {
 	const SQL = `
-		INSERT INTO users (email, password)
-		VALUES ($1, crypt($2, gen_salt('bf')))
+		INSERT INTO users (email, password, srp_salt, srp_verifier)
+		VALUES ($1, crypt(NULLIF($2, ''), gen_salt('bf')), $3, $4)
 		RETURNING id
 `
 
-	err := r.db.QueryRowContext(ctx, SQL, user.Email, user.Password).Scan(&user.ID)
+	err := r.db.QueryRowContext(ctx, SQL, user.Email, user.Password, user.SRPSalt, user.SRPVerifier).Scan(&user.ID)
 	if err != nil {
 		if pgErr, ok := err.(*pg.Error); ok {
 			if pgerrcode.IsIntegrityConstraintViolation(string(pgErr.Code)) {
@@ -53,13 +53,13 @@ func (r *UserRepository) Create(ctx context.Context, user *model.User) (*model.U
 // Get implementation of interface storage.UserRepository
 func (r *UserRepository) Read(ctx context.Context, id uuid.UUID) (*model.User, error) {
 	const SQL = `
-		SELECT id, email
+		SELECT id, email, email_verified_at IS NOT NULL
 		FROM users 
 		WHERE id=$1
 `
 	user := &model.User{}
 
-	err := r.db.QueryRowContext(ctx, SQL, id).Scan(&user.ID, &user.Email)
+	err := r.db.QueryRowContext(ctx, SQL, id).Scan(&user.ID, &user.Email, &user.EmailVerified)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, apperr.ErrNotFound
@@ -72,14 +72,14 @@ func (r *UserRepository) Read(ctx context.Context, id uuid.UUID) (*model.User, e
 
 func (r *UserRepository) ReadByEmailAndPassword(ctx context.Context, email string, password string) (*model.User, error) {
 	const SQL = `
-		SELECT id, email
+		SELECT id, email, email_verified_at IS NOT NULL
 		FROM users
 		WHERE email = $1 
 		AND password = crypt($2, password);
 `
 	user := &model.User{}
 
-	err := r.db.QueryRowContext(ctx, SQL, email, password).Scan(&user.ID, &user.Email)
+	err := r.db.QueryRowContext(ctx, SQL, email, password).Scan(&user.ID, &user.Email, &user.EmailVerified)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, apperr.ErrNotFound
@@ -89,3 +89,124 @@ func (r *UserRepository) ReadByEmailAndPassword(ctx context.Context, email strin
 
 	return user, nil
 }
+
+// ReadByEmail implementation of interface storage.UserRepository
+func (r *UserRepository) ReadByEmail(ctx context.Context, email string) (*model.User, error) {
+	const SQL = `
+		SELECT id, email, email_verified_at IS NOT NULL, srp_salt, srp_verifier
+		FROM users
+		WHERE email = $1
+`
+	user := &model.User{}
+
+	err := r.db.QueryRowContext(ctx, SQL, email).Scan(
+		&user.ID,
+		&user.Email,
+		&user.EmailVerified,
+		&user.SRPSalt,
+		&user.SRPVerifier,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.ErrNotFound
+		}
+		return nil, fmt.Errorf("select: %w", err)
+	}
+
+	return user, nil
+}
+
+// VerifyEmail implementation of interface storage.UserRepository, the first verification time is kept
+func (r *UserRepository) VerifyEmail(ctx context.Context, id uuid.UUID) error {
+	const SQL = `
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $1
+`
+
+	res, err := r.db.ExecContext(ctx, SQL, id)
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+// UpdatePassword implementation of interface storage.UserRepository
+func (r *UserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, password string) error {
+	const SQL = `
+		UPDATE users
+		SET password = crypt($2, gen_salt('bf')), srp_salt = NULL, srp_verifier = NULL
+		WHERE id = $1
+`
+
+	res, err := r.db.ExecContext(ctx, SQL, id, password)
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+// UpdateVerifier implementation of interface storage.UserRepository
+func (r *UserRepository) UpdateVerifier(ctx context.Context, id uuid.UUID, salt []byte, verifier []byte) error {
+	const SQL = `
+		UPDATE users
+		SET password = NULL, srp_salt = $2, srp_verifier = $3
+		WHERE id = $1
+`
+
+	res, err := r.db.ExecContext(ctx, SQL, id, salt, verifier)
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete implementation of interface storage.UserRepository, secrets, sessions and tokens are deleted by cascade
+func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	const SQL = `
+		DELETE FROM users
+		WHERE id = $1
+`
+
+	res, err := r.db.ExecContext(ctx, SQL, id)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/jackc/pgerrcode"
 	pg "github.com/lib/pq"
 	"gophkeeper/internal/server/model"
+	"gophkeeper/pkg/apperr"
 	"reflect"
 	"testing"
 )
@@ -21,17 +22,20 @@ func TestUserRepository_Create(t *testing.T) {
 
 	newUUID := uuid.New()
 
-	mock.ExpectQuery(`INSERT INTO users`).WithArgs("good@example.org", "Password").WillReturnRows(
+	mock.ExpectQuery(`INSERT INTO users`).WithArgs("good@example.org", "Password", []byte(nil), []byte(nil)).WillReturnRows(
 		sqlmock.NewRows([]string{"id"}).AddRow(newUUID.String()),
 	)
-	mock.ExpectQuery(`INSERT INTO users`).WithArgs("existing@example.org", "Password").WillReturnError(
+	mock.ExpectQuery(`INSERT INTO users`).WithArgs("existing@example.org", "Password", []byte(nil), []byte(nil)).WillReturnError(
 		&pg.Error{
 			Code:    pgerrcode.IntegrityConstraintViolation,
 			Message: "some error",
 		})
-	mock.ExpectQuery(`INSERT INTO users`).WithArgs("failing@example.org", "Password").WillReturnError(
+	mock.ExpectQuery(`INSERT INTO users`).WithArgs("failing@example.org", "Password", []byte(nil), []byte(nil)).WillReturnError(
 		errors.New("you shall not pass"),
 	)
+	mock.ExpectQuery(`INSERT INTO users`).WithArgs("srp@example.org", "", []byte("salt"), []byte("verifier")).WillReturnRows(
+		sqlmock.NewRows([]string{"id"}).AddRow(newUUID.String()),
+	)
 	defer func() {
 		_ = mdb.Close()
 	}()
@@ -86,6 +90,24 @@ func TestUserRepository_Create(t *testing.T) {
 			want:    nil,
 			wantErr: true,
 		},
+		{
+			name: "create srp user",
+			args: args{
+				context.TODO(),
+				&model.User{
+					Email:       "srp@example.org",
+					SRPSalt:     []byte("salt"),
+					SRPVerifier: []byte("verifier"),
+				},
+			},
+			want: &model.User{
+				ID:          newUUID,
+				Email:       "srp@example.org",
+				SRPSalt:     []byte("salt"),
+				SRPVerifier: []byte("verifier"),
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -115,7 +137,7 @@ func TestUserRepository_Read(t *testing.T) {
 	failingUUID := uuid.New()
 
 	mock.ExpectQuery(`SELECT (.+) FROM users`).WithArgs(goodUUID.String()).WillReturnRows(
-		sqlmock.NewRows([]string{"id", "email"}).AddRow(goodUUID.String(), "good@example.org"),
+		sqlmock.NewRows([]string{"id", "email", "verified"}).AddRow(goodUUID.String(), "good@example.org", true),
 	)
 	mock.ExpectQuery(`SELECT (.+) FROM users`).WithArgs(missingUUID.String()).WillReturnError(sql.ErrNoRows)
 	mock.ExpectQuery(`SELECT (.+) FROM users`).WithArgs(failingUUID.String()).WillReturnError(
@@ -142,8 +164,9 @@ func TestUserRepository_Read(t *testing.T) {
 				goodUUID,
 			},
 			want: &model.User{
-				ID:    goodUUID,
-				Email: "good@example.org",
+				ID:            goodUUID,
+				Email:         "good@example.org",
+				EmailVerified: true,
 			},
 			wantErr: false,
 		},
@@ -192,7 +215,7 @@ func TestUserRepository_ReadByEmailAndPassword(t *testing.T) {
 	goodUUID := uuid.New()
 
 	mock.ExpectQuery(`SELECT (.+) FROM users`).WithArgs("good@example.org", "Password").WillReturnRows(
-		sqlmock.NewRows([]string{"id", "email"}).AddRow(goodUUID.String(), "good@example.org"),
+		sqlmock.NewRows([]string{"id", "email", "verified"}).AddRow(goodUUID.String(), "good@example.org", false),
 	)
 	mock.ExpectQuery(`SELECT (.+) FROM users`).WithArgs("good@example.org", "BadPassword").WillReturnError(sql.ErrNoRows)
 	mock.ExpectQuery(`SELECT (.+) FROM users`).WithArgs("failing@example.org", "Password").WillReturnError(
@@ -263,3 +286,185 @@ func TestUserRepository_ReadByEmailAndPassword(t *testing.T) {
 		})
 	}
 }
+
+func TestUserRepository_ReadByEmail(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid := uuid.New()
+
+	mock.ExpectQuery(`SELECT (.+) FROM users`).WithArgs("good@example.org").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "email", "verified", "srp_salt", "srp_verifier"}).
+			AddRow(uid.String(), "good@example.org", true, []byte("salt"), []byte("verifier")),
+	)
+	mock.ExpectQuery(`SELECT (.+) FROM users`).WithArgs("missing@example.org").WillReturnError(sql.ErrNoRows)
+
+	r, err := NewUserRepository(mdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.ReadByEmail(context.TODO(), "good@example.org")
+	if err != nil {
+		t.Fatalf("ReadByEmail() error = %v", err)
+	}
+	want := &model.User{
+		ID:            uid,
+		Email:         "good@example.org",
+		EmailVerified: true,
+		SRPSalt:       []byte("salt"),
+		SRPVerifier:   []byte("verifier"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadByEmail() got = %v, want %v", got, want)
+	}
+	if _, err := r.ReadByEmail(context.TODO(), "missing@example.org"); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("ReadByEmail() error = %v, want %v", err, apperr.ErrNotFound)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUserRepository_VerifyEmail(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid := uuid.New()
+
+	mock.ExpectExec(`UPDATE users SET email_verified_at`).WithArgs(uid).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE users SET email_verified_at`).WithArgs(uid).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	r, err := NewUserRepository(mdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.VerifyEmail(context.TODO(), uid); err != nil {
+		t.Errorf("VerifyEmail() error = %v", err)
+	}
+	if err := r.VerifyEmail(context.TODO(), uid); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("VerifyEmail() error = %v, want %v", err, apperr.ErrNotFound)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUserRepository_UpdatePassword(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid := uuid.New()
+
+	mock.ExpectExec(`UPDATE users`).WithArgs(uid, "Password").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE users`).WithArgs(uid, "Password").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE users`).WithArgs(uid, "Password").WillReturnError(errors.New("you shall not pass"))
+
+	r, err := NewUserRepository(mdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.UpdatePassword(context.TODO(), uid, "Password"); err != nil {
+		t.Errorf("UpdatePassword() error = %v", err)
+	}
+	if err := r.UpdatePassword(context.TODO(), uid, "Password"); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("UpdatePassword() error = %v, want %v", err, apperr.ErrNotFound)
+	}
+	if err := r.UpdatePassword(context.TODO(), uid, "Password"); err == nil {
+		t.Error("UpdatePassword() error expected")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUserRepository_UpdateVerifier(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid := uuid.New()
+	salt, verifier := []byte("salt"), []byte("verifier")
+
+	mock.ExpectExec(`UPDATE users SET password = NULL`).WithArgs(uid, salt, verifier).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE users SET password = NULL`).WithArgs(uid, salt, verifier).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE users SET password = NULL`).WithArgs(uid, salt, verifier).WillReturnError(errors.New("you shall not pass"))
+
+	r, err := NewUserRepository(mdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.UpdateVerifier(context.TODO(), uid, salt, verifier); err != nil {
+		t.Errorf("UpdateVerifier() error = %v", err)
+	}
+	if err := r.UpdateVerifier(context.TODO(), uid, salt, verifier); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("UpdateVerifier() error = %v, want %v", err, apperr.ErrNotFound)
+	}
+	if err := r.UpdateVerifier(context.TODO(), uid, salt, verifier); err == nil {
+		t.Error("UpdateVerifier() error expected")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUserRepository_Delete(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid := uuid.New()
+
+	mock.ExpectExec(`DELETE FROM users`).WithArgs(uid).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM users`).WithArgs(uid).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM users`).WithArgs(uid).WillReturnError(errors.New("you shall not pass"))
+
+	r, err := NewUserRepository(mdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Delete(context.TODO(), uid); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if err := r.Delete(context.TODO(), uid); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("Delete() error = %v, want %v", err, apperr.ErrNotFound)
+	}
+	if err := r.Delete(context.TODO(), uid); err == nil {
+		t.Error("Delete() error expected")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
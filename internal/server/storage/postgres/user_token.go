@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/internal/server/storage"
+	"gophkeeper/pkg/apperr"
+)
+
+// storage.UserTokenRepository interface implementation
+var _ storage.UserTokenRepository = (*UserTokenRepository)(nil)
+
+type UserTokenRepository struct {
+	db *sql.DB
+}
+
+func NewUserTokenRepository(db *sql.DB) (*UserTokenRepository, error) {
+	s := &UserTokenRepository{
+		db: db,
+	}
+
+	return s, nil
+}
+
+// Create implementation of interface storage.UserTokenRepository
+func (r *UserTokenRepository) Create(ctx context.Context, token *model.UserToken) (*model.UserToken, error) {
+	const deleteSQL = `
+		DELETE FROM user_tokens
+		WHERE user_id = $1 AND purpose = $2
+`
+	const SQL = `
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+`
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, deleteSQL, token.UserID, token.Purpose); err != nil {
+		return nil, fmt.Errorf("delete: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, SQL, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt).Scan(
+		&token.ID,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("insert: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	return token, nil
+}
+
+// Use implementation of interface storage.UserTokenRepository, expired token is consumed anyway
+func (r *UserTokenRepository) Use(ctx context.Context, purpose string, hash string) (*model.UserToken, error) {
+	const SQL = `
+		DELETE FROM user_tokens
+		WHERE purpose = $1 AND token_hash = $2
+		RETURNING id, user_id, created_at, expires_at, expires_at > NOW()
+`
+
+	token := &model.UserToken{
+		Purpose:   purpose,
+		TokenHash: hash,
+	}
+
+	var valid bool
+	err := r.db.QueryRowContext(ctx, SQL, purpose, hash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&valid,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.ErrNotFound
+		}
+		return nil, fmt.Errorf("delete: %w", err)
+	}
+	if !valid {
+		return nil, apperr.ErrNotFound
+	}
+
+	return token, nil
+}
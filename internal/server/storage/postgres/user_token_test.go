@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gophkeeper/internal/server/model"
+	"gophkeeper/pkg/apperr"
+)
+
+func TestUserTokenRepository_Create(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid := uuid.New()
+	id := uuid.New()
+	now := time.Now()
+	exp := now.Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM user_tokens`).WithArgs(uid, model.UserTokenVerifyEmail).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO user_tokens`).WithArgs(uid, model.UserTokenVerifyEmail, "hash", exp).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "created_at"}).AddRow(id.String(), now),
+	)
+	mock.ExpectCommit()
+
+	r, err := NewUserTokenRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.Create(context.TODO(), &model.UserToken{
+		UserID:    uid,
+		Purpose:   model.UserTokenVerifyEmail,
+		TokenHash: "hash",
+		ExpiresAt: exp,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, id, got.ID)
+	assert.Equal(t, now, got.CreatedAt)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserTokenRepository_Use(t *testing.T) {
+	mdb, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = mdb.Close()
+	}()
+
+	uid := uuid.New()
+	id := uuid.New()
+	now := time.Now()
+	exp := now.Add(time.Hour)
+	columns := []string{"id", "user_id", "created_at", "expires_at", "valid"}
+
+	mock.ExpectQuery(`DELETE FROM user_tokens`).WithArgs(model.UserTokenResetPassword, "valid").WillReturnRows(
+		sqlmock.NewRows(columns).AddRow(id.String(), uid.String(), now, exp, true),
+	)
+	mock.ExpectQuery(`DELETE FROM user_tokens`).WithArgs(model.UserTokenResetPassword, "expired").WillReturnRows(
+		sqlmock.NewRows(columns).AddRow(id.String(), uid.String(), now, now, false),
+	)
+	mock.ExpectQuery(`DELETE FROM user_tokens`).WithArgs(model.UserTokenResetPassword, "used").WillReturnRows(
+		sqlmock.NewRows(columns),
+	)
+
+	r, err := NewUserTokenRepository(mdb)
+	require.NoError(t, err)
+
+	got, err := r.Use(context.TODO(), model.UserTokenResetPassword, "valid")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UserToken{
+		ID:        id,
+		UserID:    uid,
+		Purpose:   model.UserTokenResetPassword,
+		TokenHash: "valid",
+		CreatedAt: now,
+		ExpiresAt: exp,
+	}, got)
+
+	_, err = r.Use(context.TODO(), model.UserTokenResetPassword, "expired")
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	_, err = r.Use(context.TODO(), model.UserTokenResetPassword, "used")
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,204 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy of failed attempts per key, zero values disable related limits
+type Policy struct {
+	// FreeAttempts allowed without any delay
+	FreeAttempts int `mapstructure:"free_attempts"`
+	// BaseDelay after the first attempt over free ones, doubled on each next one
+	BaseDelay time.Duration `mapstructure:"base_delay"`
+	// MaxDelay caps the backoff delay
+	MaxDelay time.Duration `mapstructure:"max_delay"`
+	// LockoutAttempts locks the key out for LockoutDuration
+	LockoutAttempts int           `mapstructure:"lockout_attempts"`
+	LockoutDuration time.Duration `mapstructure:"lockout_duration"`
+	// ResetAfter forgets attempts of a key idle for this time
+	ResetAfter time.Duration `mapstructure:"reset_after"`
+}
+
+// Limiter tracks failed attempts in memory, so limits are per server instance
+type Limiter struct {
+	mu        sync.Mutex
+	policy    Policy
+	entries   map[string]*entry
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+type entry struct {
+	failures int
+	last     time.Time
+	until    time.Time
+}
+
+type Option func(*Limiter)
+
+// WithClock sets time source
+func WithClock(now func() time.Time) Option {
+	return func(l *Limiter) {
+		l.now = now
+	}
+}
+
+func New(p Policy, opts ...Option) *Limiter {
+	l := &Limiter{
+		policy:  p,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	l.lastSweep = l.now()
+
+	return l
+}
+
+// Wait returns time left till the next attempt of the key is allowed, zero if it is allowed now
+func (l *Limiter) Wait(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+	if d := e.until.Sub(l.now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Fail records a failed attempt, returns true when the key gets locked out by it
+func (l *Limiter) Fail(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, _, locked := l.count(key, l.now())
+	return locked
+}
+
+// Reservation of an attempt counted before it is made
+type Reservation struct {
+	l   *Limiter
+	key string
+	e   *entry
+	// prev wait end to restore, until is set by the attempt
+	prev  time.Time
+	until time.Time
+}
+
+// Reserve counts an attempt of the key before it is made, so concurrent attempts can't pass the limit together.
+// If the key has to wait nothing is counted and the time left is returned instead of a reservation.
+// locked is true when the key gets locked out by the attempt. Cancel the reservation if the attempt succeeds.
+func (l *Limiter) Reserve(key string) (r *Reservation, wait time.Duration, locked bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if e, ok := l.entries[key]; ok {
+		if d := e.until.Sub(now); d > 0 {
+			return nil, d, false
+		}
+	}
+
+	r = &Reservation{l: l, key: key}
+	r.e, r.prev, locked = l.count(key, now)
+	r.until = r.e.until
+
+	return r, 0, locked
+}
+
+// Cancel the reserved attempt, e.g. a successful one, its delay is undone unless a later attempt changed it.
+// Cancel of a nil reservation does nothing.
+func (r *Reservation) Cancel() {
+	if r == nil {
+		return
+	}
+
+	r.l.mu.Lock()
+	defer r.l.mu.Unlock()
+
+	// the key was reset or forgotten since
+	if r.l.entries[r.key] != r.e {
+		return
+	}
+	if r.e.failures > 0 {
+		r.e.failures--
+	}
+	if r.e.until.Equal(r.until) {
+		r.e.until = r.prev
+	}
+}
+
+// Reset attempts of the key, e.g. after a successful login
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, key)
+}
+
+// delay of the n-th attempt over free ones
+func (l *Limiter) delay(n int) time.Duration {
+	const maxShift = 30 // keeps the duration from overflowing without MaxDelay
+
+	if n > maxShift {
+		n = maxShift
+	}
+	d := l.policy.BaseDelay << (n - 1)
+	if l.policy.MaxDelay > 0 && d > l.policy.MaxDelay {
+		return l.policy.MaxDelay
+	}
+	return d
+}
+
+// count a failed attempt, returns the entry of the key with its wait end before the attempt
+// and true when the key gets locked out by it
+func (l *Limiter) count(key string, now time.Time) (*entry, time.Time, bool) {
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok || l.expired(e, now) {
+		e = &entry{}
+		l.entries[key] = e
+	}
+	prev := e.until
+	e.failures++
+	e.last = now
+
+	p := l.policy
+	switch {
+	case p.LockoutAttempts > 0 && e.failures >= p.LockoutAttempts:
+		e.until = now.Add(p.LockoutDuration)
+		return e, prev, true
+	case e.failures > p.FreeAttempts && p.BaseDelay > 0:
+		e.until = now.Add(l.delay(e.failures - p.FreeAttempts))
+	}
+
+	return e, prev, false
+}
+
+func (l *Limiter) expired(e *entry, now time.Time) bool {
+	return l.policy.ResetAfter > 0 && now.Sub(e.last) > l.policy.ResetAfter && !now.Before(e.until)
+}
+
+// sweep forgets expired entries at most once per reset period, so memory does not grow unbounded
+func (l *Limiter) sweep(now time.Time) {
+	if l.policy.ResetAfter <= 0 || now.Sub(l.lastSweep) < l.policy.ResetAfter {
+		return
+	}
+	l.lastSweep = now
+
+	for k, e := range l.entries {
+		if l.expired(e, now) {
+			delete(l.entries, k)
+		}
+	}
+}
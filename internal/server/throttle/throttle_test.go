@@ -0,0 +1,149 @@
+package throttle
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func (c *clock) add(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func TestLimiter_Backoff(t *testing.T) {
+	c := &clock{t: time.Date(2022, 10, 21, 0, 0, 0, 0, time.UTC)}
+	l := New(Policy{
+		FreeAttempts: 2,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Second,
+		ResetAfter:   time.Hour,
+	}, WithClock(c.now))
+
+	assert.False(t, l.Fail("a"))
+	assert.False(t, l.Fail("a"))
+	assert.Zero(t, l.Wait("a"))
+
+	// exponential over free attempts, capped
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		assert.False(t, l.Fail("a"))
+		assert.Equal(t, want, l.Wait("a"))
+		c.add(want)
+		assert.Zero(t, l.Wait("a"))
+	}
+
+	// other keys are not affected
+	assert.Zero(t, l.Wait("b"))
+
+	l.Reset("a")
+	l.Fail("a")
+	assert.Zero(t, l.Wait("a"))
+}
+
+func TestLimiter_Lockout(t *testing.T) {
+	c := &clock{t: time.Date(2022, 10, 21, 0, 0, 0, 0, time.UTC)}
+	l := New(Policy{
+		FreeAttempts:    10,
+		LockoutAttempts: 3,
+		LockoutDuration: time.Minute,
+		ResetAfter:      time.Hour,
+	}, WithClock(c.now))
+
+	assert.False(t, l.Fail("a"))
+	assert.False(t, l.Fail("a"))
+	assert.True(t, l.Fail("a"))
+	assert.Equal(t, time.Minute, l.Wait("a"))
+
+	c.add(time.Minute)
+	assert.Zero(t, l.Wait("a"))
+
+	// failing again right after the lockout locks again
+	assert.True(t, l.Fail("a"))
+	assert.Equal(t, time.Minute, l.Wait("a"))
+}
+
+func TestLimiter_ResetAfter(t *testing.T) {
+	c := &clock{t: time.Date(2022, 10, 21, 0, 0, 0, 0, time.UTC)}
+	l := New(Policy{
+		FreeAttempts: 1,
+		BaseDelay:    time.Second,
+		ResetAfter:   time.Minute,
+	}, WithClock(c.now))
+
+	l.Fail("a")
+	l.Fail("a")
+	assert.Equal(t, time.Second, l.Wait("a"))
+
+	c.add(2 * time.Minute)
+	l.Fail("b")
+	assert.NotContains(t, l.entries, "a", "idle key is swept")
+
+	l.Fail("a")
+	assert.Zero(t, l.Wait("a"), "attempts are counted from scratch")
+}
+
+func TestLimiter_Reserve(t *testing.T) {
+	c := &clock{t: time.Date(2022, 10, 21, 0, 0, 0, 0, time.UTC)}
+	l := New(Policy{
+		FreeAttempts: 2,
+		BaseDelay:    time.Second,
+		ResetAfter:   time.Hour,
+	}, WithClock(c.now))
+
+	// attempts in flight are counted already
+	r1, wait, _ := l.Reserve("a")
+	assert.Zero(t, wait)
+	r2, _, _ := l.Reserve("a")
+	r3, _, _ := l.Reserve("a")
+	assert.NotNil(t, r3)
+	r4, wait, _ := l.Reserve("a")
+	assert.Nil(t, r4)
+	assert.Equal(t, time.Second, wait)
+
+	// cancelled attempt undoes its delay
+	r3.Cancel()
+	assert.Zero(t, l.Wait("a"))
+	r2.Cancel()
+	r1.Cancel()
+	assert.Zero(t, l.entries["a"].failures)
+
+	// cancel of a reset key does not affect new attempts
+	r, _, _ := l.Reserve("b")
+	l.Reset("b")
+	l.Fail("b")
+	r.Cancel()
+	assert.Equal(t, 1, l.entries["b"].failures)
+
+	var nothing *Reservation
+	nothing.Cancel()
+}
+
+func TestLimiter_ReserveConcurrent(t *testing.T) {
+	l := New(Policy{FreeAttempts: 5, BaseDelay: time.Minute})
+
+	var allowed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r, _, _ := l.Reserve("a"); r != nil {
+				atomic.AddInt64(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// free attempts and the one setting the delay
+	assert.Equal(t, int64(6), allowed)
+}
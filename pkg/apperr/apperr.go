@@ -3,6 +3,7 @@ package apperr
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 var (
@@ -13,4 +14,40 @@ var (
 	ErrNotFound     = fmt.Errorf("not found: %w", ErrInvalidInput)
 	ErrConflict     = fmt.Errorf("conflict: %w", ErrInvalidInput)
 	ErrSoftConflict = fmt.Errorf("soft conflict: %w", ErrInvalidInput)
+	ErrExhausted    = errors.New("resource exhausted")
+	ErrPrecondition = errors.New("failed precondition")
 )
+
+// FieldViolation describes an invalid request field or, for ErrExhausted, an exceeded limit of the subject
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// Error is one of sentinel errors with a message and details for clients
+type Error struct {
+	// Err is a sentinel error classifying the failure
+	Err     error
+	Message string
+	// Reason is a constant machine-readable cause, clients can act on it
+	Reason     string
+	Metadata   map[string]string
+	Violations []FieldViolation
+	// RetryAfter is set when the same request can succeed later
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Message != "":
+		return e.Message
+	case e.Err != nil:
+		return e.Err.Error()
+	default:
+		return "unknown error"
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
@@ -1,23 +1,41 @@
 package grpcserver
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
 	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/v2/auth"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/test/bufconn"
 	"gophkeeper/pkg/logger"
 	"net"
 )
 
+const (
+	// inProcessBufferSize of the in-process listener connections
+	inProcessBufferSize = 1 << 20
+	// inProcessNetwork of bufconn addresses
+	inProcessNetwork = "bufconn"
+	inProcessTarget  = "inprocess"
+)
+
 type Server struct {
 	listenAddr string
 
-	logger             *logger.Logger
-	server             *grpc.Server
+	logger *logger.Logger
+	server *grpc.Server
+	// local serves DialInProcess connections, without TLS as they never leave the process
+	local              *grpc.Server
+	localListener      *bufconn.Listener
 	services           []Service
 	unaryInterceptors  []grpc.UnaryServerInterceptor
 	streamInterceptors []grpc.StreamServerInterceptor
 	authFunc           grpcauth.AuthFunc
+	tlsConfig          *tls.Config
 }
 
 func (s *Server) ListenAddr() string {
@@ -38,6 +56,13 @@ func WithAuthFunc(af grpcauth.AuthFunc) ServerOption {
 	}
 }
 
+// WithTLSConfig serves TLS instead of plaintext connections
+func WithTLSConfig(c *tls.Config) ServerOption {
+	return func(server *Server) {
+		server.tlsConfig = c
+	}
+}
+
 func WithUnaryInterceptors(in ...grpc.UnaryServerInterceptor) ServerOption {
 	return func(server *Server) {
 		server.unaryInterceptors = append(server.unaryInterceptors, in...)
@@ -80,6 +105,7 @@ func New(opts ...ServerOption) *Server {
 func (s *Server) RegisterServices(services ...Service) {
 	for _, svc := range services {
 		svc.RegisterService(s.server)
+		svc.RegisterService(s.local)
 	}
 }
 
@@ -92,7 +118,7 @@ func (s *Server) Start() error {
 	// required for testing
 	s.listenAddr = lis.Addr().String()
 
-	s.server = grpc.NewServer(
+	opts := []grpc.ServerOption{
 		grpc.UnaryInterceptor(
 			grpcmiddleware.ChainUnaryServer(
 				s.unaryInterceptors...,
@@ -103,12 +129,26 @@ func (s *Server) Start() error {
 				s.streamInterceptors...,
 			),
 		),
-	)
+	}
+	// same services and interceptors for in-process calls
+	s.local = grpc.NewServer(opts...)
+	s.localListener = bufconn.Listen(inProcessBufferSize)
+	if s.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
+	}
+
+	s.server = grpc.NewServer(opts...)
 
 	s.RegisterServices(s.services...)
 
 	go func() {
-		s.logger.Info().Str("host", s.listenAddr).Msg("Listening incoming GRPC connections")
+		if err := s.local.Serve(s.localListener); err != nil && err != grpc.ErrServerStopped {
+			s.logger.Fatal().Err(err).Send()
+		}
+	}()
+
+	go func() {
+		s.logger.Info().Str("host", s.listenAddr).Bool("tls", s.tlsConfig != nil).Msg("Listening incoming GRPC connections")
 		if err := s.server.Serve(lis); err != nil && err != grpc.ErrServerStopped {
 			s.logger.Fatal().Err(err).Send()
 		}
@@ -117,6 +157,26 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// DialInProcess connects to the started server without network, e.g. for a gateway in the same process.
+// Calls pass the same interceptors as network ones, see InProcessPeer.
+func (s *Server) DialInProcess(ctx context.Context, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.localListener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, opts...)
+
+	return grpc.DialContext(ctx, inProcessTarget, opts...)
+}
+
+// InProcessPeer reports if the call came through DialInProcess connection
+func InProcessPeer(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	return ok && p.Addr != nil && p.Addr.Network() == inProcessNetwork
+}
+
 func (s *Server) Stop() {
 	s.server.GracefulStop()
+	s.local.GracefulStop()
 }
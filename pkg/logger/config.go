@@ -1,6 +1,10 @@
 package logger
 
+import "io"
+
 type Config struct {
+	// Out of the pretty formatted log, stdout by default
+	Out        io.Writer `mapstructure:"-"`
 	TimeFormat string
 	Verbose    bool `mapstructure:"verbose"`
 	Pretty     bool `mapstructure:"pretty"`
@@ -37,8 +37,11 @@ func New(cfg Config) Logger {
 		cfg.TimeFormat = time.RFC3339
 	}
 	zl := log.Logger.Level(logLevel)
+	if cfg.Out == nil {
+		cfg.Out = os.Stdout
+	}
 	if cfg.Pretty {
-		zl = zl.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: cfg.TimeFormat})
+		zl = zl.Output(zerolog.ConsoleWriter{Out: cfg.Out, TimeFormat: cfg.TimeFormat})
 	}
 
 	return Logger{zl}
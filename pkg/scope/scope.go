@@ -0,0 +1,192 @@
+// Package scope describes permissions of API tokens.
+// A scope is an action with an optional secret name prefix or tag: "read", "write", "read:ci/",
+// "write:ci/deploy-" or "read#ci". Tags follow "#", as prefixes may contain ":".
+package scope
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// Read allows to list and read secrets, and to see usage
+	Read = "read"
+	// Write allows to create and delete secrets
+	Write = "write"
+)
+
+const (
+	// MaxTags of a secret
+	MaxTags = 16
+	// MaxTagLength in bytes
+	MaxTagLength = 64
+)
+
+// Scope is a parsed scope
+type Scope struct {
+	Action string
+	// Prefix of secret names, empty means all secrets unless Tag is set
+	Prefix string
+	// Tag limits the scope to secrets having it
+	Tag string
+}
+
+func (s Scope) String() string {
+	switch {
+	case s.Tag != "":
+		return s.Action + "#" + s.Tag
+	case s.Prefix != "":
+		return s.Action + ":" + s.Prefix
+	default:
+		return s.Action
+	}
+}
+
+// Parse a scope string
+func Parse(s string) (Scope, error) {
+	i := strings.IndexAny(s, ":#")
+	if i < 0 {
+		i = len(s)
+	}
+
+	sc := Scope{Action: s[:i]}
+	switch sc.Action {
+	case Read, Write:
+	default:
+		return Scope{}, fmt.Errorf("invalid scope %q: action must be %s or %s", s, Read, Write)
+	}
+	if i == len(s) {
+		return sc, nil
+	}
+
+	if s[i] == '#' {
+		sc.Tag = s[i+1:]
+		if err := ValidTag(sc.Tag); err != nil {
+			return Scope{}, fmt.Errorf("invalid scope %q: %w", s, err)
+		}
+		return sc, nil
+	}
+
+	sc.Prefix = s[i+1:]
+	if sc.Prefix == "" {
+		return Scope{}, fmt.Errorf("invalid scope %q: empty prefix", s)
+	}
+	return sc, nil
+}
+
+// matches the secret name or one of its tags
+func (s Scope) matches(name string, tags []string) bool {
+	if s.Tag == "" {
+		return strings.HasPrefix(name, s.Prefix)
+	}
+	for _, t := range tags {
+		if t == s.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Set of scopes
+type Set []Scope
+
+// ParseSet of scope strings, duplicates are removed and the result is sorted
+func ParseSet(ss []string) (Set, error) {
+	if len(ss) == 0 {
+		return nil, fmt.Errorf("no scopes")
+	}
+
+	seen := make(map[Scope]struct{}, len(ss))
+	set := make(Set, 0, len(ss))
+	for _, s := range ss {
+		sc, err := Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[sc]; ok {
+			continue
+		}
+		seen[sc] = struct{}{}
+		set = append(set, sc)
+	}
+
+	sort.Slice(set, func(i, j int) bool {
+		return set[i].String() < set[j].String()
+	})
+
+	return set, nil
+}
+
+// Strings of the set
+func (s Set) Strings() []string {
+	ss := make([]string, 0, len(s))
+	for _, sc := range s {
+		ss = append(ss, sc.String())
+	}
+	return ss
+}
+
+// Allows action on the secret with the name and tags
+func (s Set) Allows(action, name string, tags []string) bool {
+	for _, sc := range s {
+		if sc.Action == action && sc.matches(name, tags) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tagged reports if the action is allowed by tag scopes, so tags of a secret have to be known to decide
+func (s Set) Tagged(action string) bool {
+	for _, sc := range s {
+		if sc.Action == action && sc.Tag != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAny name for the action, e.g. to list secrets filtered by name afterwards
+func (s Set) AllowsAny(action string) bool {
+	for _, sc := range s {
+		if sc.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidTag is not empty and has no spaces, commas or "#", so it can be given in lists and scopes
+func ValidTag(tag string) error {
+	switch {
+	case tag == "":
+		return fmt.Errorf("empty tag")
+	case len(tag) > MaxTagLength:
+		return fmt.Errorf("tag %q is longer than %d bytes", tag, MaxTagLength)
+	case strings.ContainsAny(tag, " \t\r\n,#"):
+		return fmt.Errorf("tag %q contains spaces, commas or #", tag)
+	}
+	return nil
+}
+
+// NormalizeTags validates tags, duplicates are removed and the result is sorted
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]struct{}, len(tags))
+	res := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if err := ValidTag(t); err != nil {
+			return nil, err
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		res = append(res, t)
+	}
+	if len(res) > MaxTags {
+		return nil, fmt.Errorf("more than %d tags", MaxTags)
+	}
+	sort.Strings(res)
+	return res, nil
+}
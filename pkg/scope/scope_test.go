@@ -0,0 +1,96 @@
+package scope
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Scope
+		wantErr bool
+	}{
+		{in: "read", want: Scope{Action: Read}},
+		{in: "write", want: Scope{Action: Write}},
+		{in: "read:ci/", want: Scope{Action: Read, Prefix: "ci/"}},
+		{in: "write:ci:deploy", want: Scope{Action: Write, Prefix: "ci:deploy"}},
+		{in: "read#ci", want: Scope{Action: Read, Tag: "ci"}},
+		{in: "write:ci#1", want: Scope{Action: Write, Prefix: "ci#1"}},
+		{in: "read#", wantErr: true},
+		{in: "read#a,b", wantErr: true},
+		{in: "read:", wantErr: true},
+		{in: "admin", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.in, got.String())
+		})
+	}
+}
+
+func TestSet_Allows(t *testing.T) {
+	s, err := ParseSet([]string{"write:ci/", "read", "read", "read:ci/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read", "read:ci/", "write:ci/"}, s.Strings())
+
+	assert.True(t, s.Allows(Read, "anything", nil))
+	assert.True(t, s.Allows(Write, "ci/deploy", nil))
+	assert.False(t, s.Allows(Write, "prod/db", nil))
+	assert.True(t, s.AllowsAny(Write))
+
+	ro, err := ParseSet([]string{"read:ci/"})
+	require.NoError(t, err)
+	assert.False(t, ro.Allows(Read, "prod/db", nil))
+	assert.False(t, ro.AllowsAny(Write))
+
+	_, err = ParseSet(nil)
+	assert.Error(t, err)
+}
+
+func TestSet_AllowsTags(t *testing.T) {
+	s, err := ParseSet([]string{"read#ci", "write:tmp/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read#ci", "write:tmp/"}, s.Strings())
+
+	assert.True(t, s.Allows(Read, "prod/db", []string{"ci", "db"}))
+	assert.False(t, s.Allows(Read, "prod/db", []string{"db"}))
+	assert.False(t, s.Allows(Read, "ci", nil))
+	assert.True(t, s.Allows(Write, "tmp/x", nil))
+	assert.True(t, s.Tagged(Read))
+	assert.False(t, s.Tagged(Write))
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := NormalizeTags([]string{"prod", "ci", "prod"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ci", "prod"}, tags)
+
+	tags, err = NormalizeTags(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, tags)
+
+	for _, bad := range []string{"", "a b", "a,b", "a#b", strings.Repeat("x", MaxTagLength+1)} {
+		_, err = NormalizeTags([]string{bad})
+		assert.Error(t, err, bad)
+	}
+
+	many := make([]string, MaxTags+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("t%d", i)
+	}
+	_, err = NormalizeTags(many)
+	assert.Error(t, err)
+}
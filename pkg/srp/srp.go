@@ -0,0 +1,259 @@
+// Package srp implements SRP-6a password authenticated key exchange (RFC 5054),
+// the server keeps a verifier only and never learns the password.
+// The private key x is derived with Argon2id instead of a single SHA-256,
+// so a leaked verifier is as expensive to brute force as a password hash.
+package srp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"math/big"
+)
+
+const (
+	// SaltSize of generated salts in bytes
+	SaltSize = 16
+
+	keyTime    = 3
+	keyMemory  = 64 * 1024
+	keyThreads = 4
+	keySize    = 32
+
+	ephemeralSize = 32
+)
+
+var (
+	// ErrInvalidPublic is returned for a public value out of (0, N), zero would fix the shared secret
+	ErrInvalidPublic = errors.New("invalid public value")
+	// ErrInvalidProof is returned when the peer does not know the password or the shared secret
+	ErrInvalidProof = errors.New("invalid proof")
+)
+
+// 2048-bit group of RFC 5054 appendix A
+var (
+	groupN, _ = new(big.Int).SetString(""+
+		"AC6BDB41324A9A9BF166DE5E1389582FAF72B6651987EE07FC3192943DB56050"+
+		"A37329CBB4A099ED8193E0757767A13DD52312AB4B03310DCD7F48A9DA04FD50"+
+		"E8083969EDB767B0CF6095179A163AB3661A05FBD5FAAAE82918A9962F0B93B8"+
+		"55F97993EC975EEAA80D740ADBF4FF747359D041D5C33EA71D281E446B14773B"+
+		"CA97B43A23FB801676BD207A436C6481F1D2B9078717461A5B9D32E688F87748"+
+		"544523B524B0D57D5EA77A2775D2ECFA032CFBDBF52FB3786160279004E57AE6"+
+		"AF874E7303CE53299CCC041C7BC308D82A5698F3A8D0C38271AE35F8E9DBFBB6"+
+		"94B5C803D89F7AE435DE236D525F54759B65E372FCD68EF20FA7111F9E4AFF73", 16)
+	groupG = big.NewInt(2)
+	groupK = hashInts(groupN, groupG)
+)
+
+// NewVerifier of the password with a random salt, both are to be stored by the server
+func NewVerifier(password string) ([]byte, []byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, fmt.Errorf("generate salt: %w", err)
+	}
+	return salt, ComputeVerifier(password, salt), nil
+}
+
+// ComputeVerifier v = g^x of the password with the salt
+func ComputeVerifier(password string, salt []byte) []byte {
+	return new(big.Int).Exp(groupG, privateKey(password, salt), groupN).Bytes()
+}
+
+// ValidVerifier checks the salt and verifier received from a client are usable
+func ValidVerifier(salt, verifier []byte) bool {
+	if len(salt) < SaltSize || len(verifier) == 0 || len(verifier) > len(groupN.Bytes()) {
+		return false
+	}
+	v := new(big.Int).SetBytes(verifier)
+	return v.Sign() > 0 && v.Cmp(groupN) < 0
+}
+
+// Client side of the exchange
+type Client struct {
+	identity string
+	password string
+	a        *big.Int
+	pubA     *big.Int
+	key      []byte
+	expected []byte
+}
+
+// NewClient with a random ephemeral key
+func NewClient(identity, password string) (*Client, error) {
+	a, err := randomInt()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		identity: identity,
+		password: password,
+		a:        a,
+		pubA:     new(big.Int).Exp(groupG, a, groupN),
+	}, nil
+}
+
+// Public value A to be sent to the server
+func (c *Client) Public() []byte {
+	return pad(c.pubA)
+}
+
+// Proof M1 of the password for the salt and public value B received from the server
+func (c *Client) Proof(salt, serverPublic []byte) ([]byte, error) {
+	pubB := new(big.Int).SetBytes(serverPublic)
+	if !validPublic(pubB) {
+		return nil, ErrInvalidPublic
+	}
+
+	u := hashInts(c.pubA, pubB)
+	if u.Sign() == 0 {
+		return nil, ErrInvalidPublic
+	}
+
+	x := privateKey(c.password, salt)
+
+	// S = (B - k * g^x) ^ (a + u * x) % N
+	base := new(big.Int).Exp(groupG, x, groupN)
+	base.Mul(base, groupK)
+	base.Sub(pubB, base)
+	base.Mod(base, groupN)
+	exp := new(big.Int).Mul(u, x)
+	exp.Add(exp, c.a)
+	s := new(big.Int).Exp(base, exp, groupN)
+
+	c.key = hash(pad(s))
+	m1 := clientProof(c.identity, salt, c.pubA, pubB, c.key)
+	c.expected = serverProof(c.pubA, m1, c.key)
+
+	return m1, nil
+}
+
+// VerifyServer proof M2, the server proves it knows the verifier and not just replays messages
+func (c *Client) VerifyServer(proof []byte) error {
+	if c.expected == nil || subtle.ConstantTimeCompare(c.expected, proof) != 1 {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// Key is the shared session key, set once the proof is computed
+func (c *Client) Key() []byte {
+	return c.key
+}
+
+// Server side of the exchange
+type Server struct {
+	pubA  *big.Int
+	pubB  *big.Int
+	key   []byte
+	proof []byte
+}
+
+// NewServer for the client public value A with the verifier stored for the identity
+func NewServer(identity string, salt, verifier, clientPublic []byte) (*Server, error) {
+	pubA := new(big.Int).SetBytes(clientPublic)
+	if !validPublic(pubA) {
+		return nil, ErrInvalidPublic
+	}
+
+	b, err := randomInt()
+	if err != nil {
+		return nil, err
+	}
+	v := new(big.Int).SetBytes(verifier)
+
+	// B = k * v + g^b % N
+	pubB := new(big.Int).Mul(groupK, v)
+	pubB.Add(pubB, new(big.Int).Exp(groupG, b, groupN))
+	pubB.Mod(pubB, groupN)
+
+	u := hashInts(pubA, pubB)
+	if u.Sign() == 0 {
+		return nil, ErrInvalidPublic
+	}
+
+	// S = (A * v^u) ^ b % N
+	base := new(big.Int).Exp(v, u, groupN)
+	base.Mul(base, pubA)
+	base.Mod(base, groupN)
+	s := new(big.Int).Exp(base, b, groupN)
+
+	key := hash(pad(s))
+
+	return &Server{
+		pubA:  pubA,
+		pubB:  pubB,
+		key:   key,
+		proof: clientProof(identity, salt, pubA, pubB, key),
+	}, nil
+}
+
+// Public value B to be sent to the client with the salt
+func (s *Server) Public() []byte {
+	return pad(s.pubB)
+}
+
+// Verify client proof M1, returns the server proof M2 to be sent back
+func (s *Server) Verify(proof []byte) ([]byte, error) {
+	if subtle.ConstantTimeCompare(s.proof, proof) != 1 {
+		return nil, ErrInvalidProof
+	}
+	return serverProof(s.pubA, s.proof, s.key), nil
+}
+
+// Key is the shared session key
+func (s *Server) Key() []byte {
+	return s.key
+}
+
+func validPublic(i *big.Int) bool {
+	return i.Sign() > 0 && i.Cmp(groupN) < 0
+}
+
+// privateKey x = H(salt | Argon2id(password, salt))
+func privateKey(password string, salt []byte) *big.Int {
+	k := argon2.IDKey([]byte(password), salt, keyTime, keyMemory, keyThreads, keySize)
+	return new(big.Int).SetBytes(hash(salt, k))
+}
+
+// clientProof M1 = H(H(N) xor H(g) | H(I) | s | A | B | K)
+func clientProof(identity string, salt []byte, pubA, pubB *big.Int, key []byte) []byte {
+	hn, hg := hash(groupN.Bytes()), hash(groupG.Bytes())
+	for i := range hn {
+		hn[i] ^= hg[i]
+	}
+	return hash(hn, hash([]byte(identity)), salt, pad(pubA), pad(pubB), key)
+}
+
+// serverProof M2 = H(A | M1 | K)
+func serverProof(pubA *big.Int, m1, key []byte) []byte {
+	return hash(pad(pubA), m1, key)
+}
+
+func hash(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// hashInts H(PAD(a) | PAD(b)) as an integer
+func hashInts(a, b *big.Int) *big.Int {
+	return new(big.Int).SetBytes(hash(pad(a), pad(b)))
+}
+
+// pad to the length of N
+func pad(i *big.Int) []byte {
+	return i.FillBytes(make([]byte, (groupN.BitLen()+7)/8))
+}
+
+func randomInt() (*big.Int, error) {
+	b := make([]byte, ephemeralSize)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("generate ephemeral key: %w", err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
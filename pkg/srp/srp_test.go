@@ -0,0 +1,84 @@
+package srp
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	assert.Equal(t, 2048, groupN.BitLen())
+	assert.True(t, groupN.ProbablyPrime(20))
+	// safe prime, so g generates a large subgroup
+	q := new(big.Int).Rsh(groupN, 1)
+	assert.True(t, q.ProbablyPrime(20))
+}
+
+func TestExchange(t *testing.T) {
+	salt, verifier, err := NewVerifier("password")
+	require.NoError(t, err)
+	assert.Len(t, salt, SaltSize)
+	assert.True(t, ValidVerifier(salt, verifier))
+	assert.Equal(t, verifier, ComputeVerifier("password", salt))
+
+	c, err := NewClient("alice@example.com", "password")
+	require.NoError(t, err)
+	s, err := NewServer("alice@example.com", salt, verifier, c.Public())
+	require.NoError(t, err)
+
+	m1, err := c.Proof(salt, s.Public())
+	require.NoError(t, err)
+	m2, err := s.Verify(m1)
+	require.NoError(t, err)
+	require.NoError(t, c.VerifyServer(m2))
+	assert.Equal(t, c.Key(), s.Key())
+
+	// wrong password
+	c, err = NewClient("alice@example.com", "guess")
+	require.NoError(t, err)
+	s, err = NewServer("alice@example.com", salt, verifier, c.Public())
+	require.NoError(t, err)
+	m1, err = c.Proof(salt, s.Public())
+	require.NoError(t, err)
+	_, err = s.Verify(m1)
+	assert.ErrorIs(t, err, ErrInvalidProof)
+
+	// wrong identity
+	c, err = NewClient("bob@example.com", "password")
+	require.NoError(t, err)
+	s, err = NewServer("alice@example.com", salt, verifier, c.Public())
+	require.NoError(t, err)
+	m1, err = c.Proof(salt, s.Public())
+	require.NoError(t, err)
+	_, err = s.Verify(m1)
+	assert.ErrorIs(t, err, ErrInvalidProof)
+
+	// server without the verifier can not prove itself
+	assert.ErrorIs(t, c.VerifyServer(m1), ErrInvalidProof)
+}
+
+func TestInvalidPublic(t *testing.T) {
+	salt, verifier, err := NewVerifier("password")
+	require.NoError(t, err)
+
+	for _, pub := range [][]byte{nil, {0}, groupN.Bytes(), new(big.Int).Lsh(groupN, 1).Bytes()} {
+		_, err := NewServer("alice@example.com", salt, verifier, pub)
+		assert.ErrorIs(t, err, ErrInvalidPublic)
+
+		c, err := NewClient("alice@example.com", "password")
+		require.NoError(t, err)
+		_, err = c.Proof(salt, pub)
+		assert.ErrorIs(t, err, ErrInvalidPublic)
+	}
+}
+
+func TestValidVerifier(t *testing.T) {
+	salt, verifier, err := NewVerifier("password")
+	require.NoError(t, err)
+
+	assert.False(t, ValidVerifier(salt[:SaltSize-1], verifier))
+	assert.False(t, ValidVerifier(salt, nil))
+	assert.False(t, ValidVerifier(salt, []byte{0}))
+	assert.False(t, ValidVerifier(salt, groupN.Bytes()))
+}
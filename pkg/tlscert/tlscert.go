@@ -0,0 +1,208 @@
+// Package tlscert manages server certificates: self-signed ones for the first start
+// and reloading of renewed files without restart.
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SelfSignedLifetime of generated certificates
+const SelfSignedLifetime = 365 * 24 * time.Hour
+
+// EnsureSelfSigned generates a self-signed certificate for the hosts unless the cert file exists,
+// reports if the certificate was generated
+func EnsureSelfSigned(certFile, keyFile string, hosts []string) (bool, error) {
+	if _, err := os.Stat(certFile); err == nil {
+		return false, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+
+	certPEM, keyPEM, err := SelfSigned(hosts, SelfSignedLifetime)
+	if err != nil {
+		return false, err
+	}
+
+	// the key goes first, so a certificate never refers to a missing key
+	if err := writeFileAtomic(keyFile, keyPEM, 0600); err != nil {
+		return false, fmt.Errorf("write key: %w", err)
+	}
+	if err := writeFileAtomic(certFile, certPEM, 0644); err != nil {
+		return false, fmt.Errorf("write cert: %w", err)
+	}
+
+	return true, nil
+}
+
+// SelfSigned certificate and ECDSA P-256 key in PEM format, hosts are DNS names or IP addresses
+func SelfSigned(hosts []string, lifetime time.Duration) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate serial: %w", err)
+	}
+
+	now := time.Now()
+	tpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "gophkeeper"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(lifetime),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tpl.IPAddresses = append(tpl.IPAddresses, ip)
+		} else if h != "" {
+			tpl.DNSNames = append(tpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		nil
+}
+
+// Fingerprint of the DER encoded certificate, SHA-256 in hex as shown to users to compare
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return "SHA256:" + hex.EncodeToString(sum[:])
+}
+
+// Reloader serves the key pair from files and loads it again once they are modified,
+// so renewed certificates are used without restart
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewReloader with the key pair loaded
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate to be set in tls.Config, files failing to load keep the previous key pair in use
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if mt, err := r.lastModified(); err == nil && !mt.Equal(r.modTime) {
+		// a half-written pair fails to load, it is retried on the next handshake
+		_ = r.load(mt)
+	}
+
+	return r.cert, nil
+}
+
+// Certificate in use
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.cert
+}
+
+func (r *Reloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	mt, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+	return r.load(mt)
+}
+
+func (r *Reloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// lastModified of the cert and key files
+func (r *Reloader) lastModified() (time.Time, error) {
+	var last time.Time
+	for _, f := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(last) {
+			last = fi.ModTime()
+		}
+	}
+	return last, nil
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer func() {
+		_ = os.Remove(tmp)
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,74 @@
+package tlscert
+
+import (
+	"crypto/x509"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnsureSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem")
+
+	created, err := EnsureSelfSigned(certFile, keyFile, []string{"localhost", "127.0.0.1"})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	fi, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	r, err := NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(r.Certificate().Certificate[0])
+	require.NoError(t, err)
+	assert.NoError(t, leaf.VerifyHostname("localhost"))
+	assert.NoError(t, leaf.VerifyHostname("127.0.0.1"))
+
+	// existing certificates are kept
+	created, err = EnsureSelfSigned(certFile, keyFile, []string{"localhost"})
+	require.NoError(t, err)
+	assert.False(t, created)
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem")
+
+	write := func(cert, key []byte, mt time.Time) {
+		require.NoError(t, os.WriteFile(certFile, cert, 0600))
+		require.NoError(t, os.WriteFile(keyFile, key, 0600))
+		require.NoError(t, os.Chtimes(certFile, mt, mt))
+		require.NoError(t, os.Chtimes(keyFile, mt, mt))
+	}
+
+	cert1, key1, err := SelfSigned([]string{"localhost"}, time.Hour)
+	require.NoError(t, err)
+	cert2, key2, err := SelfSigned([]string{"localhost"}, time.Hour)
+	require.NoError(t, err)
+
+	now := time.Now()
+	write(cert1, key1, now.Add(-time.Minute))
+	r, err := NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+	first, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+
+	// a mismatching pair keeps the previous certificate in use
+	write(cert2, key1, now)
+	c, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, Fingerprint(first.Certificate[0]), Fingerprint(c.Certificate[0]))
+
+	write(cert2, key2, now.Add(time.Second))
+	c, err = r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, Fingerprint(first.Certificate[0]), Fingerprint(c.Certificate[0]))
+
+	_, err = NewReloader(filepath.Join(dir, "missing.pem"), keyFile)
+	assert.Error(t, err)
+}
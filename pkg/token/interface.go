@@ -7,8 +7,22 @@ type Identity interface {
 	Identity() string
 }
 
+// SessionIdentity is an Identity bound to a revocable session
+type SessionIdentity interface {
+	Identity
+	Session() string
+}
+
+// ScopedIdentity is an Identity of a revocable API token limited to scopes
+type ScopedIdentity interface {
+	Identity
+	TokenID() string
+	TokenScopes() []string
+}
+
 type Manager interface {
-	// Issue a new token for a given Identity with exp time
+	// Issue a new token for a given Identity with exp time, SessionIdentity session
+	// and ScopedIdentity token id with scopes are kept in the token
 	Issue(id Identity, exp time.Duration) (string, error)
 	// Decode provided token to the Identity
 	Decode(tk string) (Identity, error)
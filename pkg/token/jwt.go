@@ -13,23 +13,70 @@ var _ Manager = (*JWT)(nil)
 var ErrInvalidToken = errors.New("invalid token")
 
 type JWT struct {
+	// secretKey signs HS256 tokens, with keys it only verifies HS256 tokens issued before keys were set up
 	secretKey []byte
+	// keys in use, nil for HS256 tokens
+	keys func() *KeySet
 }
 
-func NewJWT(secretKey string) (*JWT, error) {
-	return &JWT{
+type JWTOption func(*JWT)
+
+// WithKeySet signs tokens with the active key of the set,
+// HS256 tokens without key id are still accepted if the secret key is not empty, so sessions survive the switch
+func WithKeySet(ks *KeySet) JWTOption {
+	return func(tm *JWT) {
+		tm.keys = func() *KeySet {
+			return ks
+		}
+	}
+}
+
+// WithKeyReloader signs tokens with the active key of the dir reloaded once it is modified, as WithKeySet
+func WithKeyReloader(r *KeyReloader) JWTOption {
+	return func(tm *JWT) {
+		tm.keys = r.KeySet
+	}
+}
+
+func NewJWT(secretKey string, opts ...JWTOption) (*JWT, error) {
+	tm := &JWT{
 		secretKey: []byte(secretKey),
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(tm)
+	}
+
+	if tm.keys != nil && tm.keys().Active() == nil {
+		return nil, errors.New("key set has no active key")
+	}
+
+	return tm, nil
 }
 
 type JWTClaims struct {
 	jwt.StandardClaims
+	SessionID  string   `json:"sid,omitempty"`
+	APITokenID string   `json:"tid,omitempty"`
+	Scopes     []string `json:"scp,omitempty"`
 }
 
 func (c *JWTClaims) Identity() string {
 	return c.StandardClaims.Id
 }
 
+func (c *JWTClaims) Session() string {
+	return c.SessionID
+}
+
+func (c *JWTClaims) TokenID() string {
+	return c.APITokenID
+}
+
+func (c *JWTClaims) TokenScopes() []string {
+	return c.Scopes
+}
+
 // Issue implementation of token.Manager
 func (tm *JWT) Issue(id Identity, lifetime time.Duration) (string, error) {
 	now := time.Now()
@@ -42,6 +89,20 @@ func (tm *JWT) Issue(id Identity, lifetime time.Duration) (string, error) {
 			NotBefore: now.Unix(),
 		},
 	}
+	if sid, ok := id.(SessionIdentity); ok {
+		data.SessionID = sid.Session()
+	}
+	if st, ok := id.(ScopedIdentity); ok {
+		data.APITokenID = st.TokenID()
+		data.Scopes = st.TokenScopes()
+	}
+	if tm.keys != nil {
+		k := tm.keys().Active()
+		token := jwt.NewWithClaims(k.Method, data)
+		token.Header["kid"] = k.ID
+		return token.SignedString(k.private)
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, data)
 	return token.SignedString(tm.secretKey)
 }
@@ -76,8 +137,22 @@ func (tm *JWT) Validate(token string, target Identity) error {
 }
 
 func (tm *JWT) parseSecretGetter(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if tm.keys != nil && (kid != "" || len(tm.secretKey) == 0) {
+		k, ok := tm.keys().Key(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		// the key decides the method, so a public key can not be used as an HMAC secret
+		if token.Method.Alg() != k.Method.Alg() {
+			return nil, fmt.Errorf("bad sign method")
+		}
+		return k.public, nil
+	}
+
+	// legacy HS256 tokens have no key id
 	method, ok := token.Method.(*jwt.SigningMethodHMAC)
-	if !ok || method.Alg() != "HS256" {
+	if !ok || method.Alg() != "HS256" || kid != "" {
 		return nil, fmt.Errorf("bad sign method")
 	}
 	return tm.secretKey, nil
@@ -0,0 +1,52 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testScopedIdentity struct {
+	id, tid string
+	scopes  []string
+}
+
+func (i testScopedIdentity) Identity() string {
+	return i.id
+}
+
+func (i testScopedIdentity) TokenID() string {
+	return i.tid
+}
+
+func (i testScopedIdentity) TokenScopes() []string {
+	return i.scopes
+}
+
+func TestJWT_ScopedIdentity(t *testing.T) {
+	tm, err := NewJWT("secret")
+	require.NoError(t, err)
+
+	tk, err := tm.Issue(testScopedIdentity{"user", "token", []string{"read", "write:ci/"}}, time.Minute)
+	require.NoError(t, err)
+
+	id, err := tm.Decode(tk)
+	require.NoError(t, err)
+	assert.Equal(t, "user", id.Identity())
+
+	st, ok := id.(ScopedIdentity)
+	require.True(t, ok)
+	assert.Equal(t, "token", st.TokenID())
+	assert.Equal(t, []string{"read", "write:ci/"}, st.TokenScopes())
+	assert.Empty(t, id.(SessionIdentity).Session())
+
+	// session tokens carry no scopes
+	tk, err = tm.Issue(testIdentity{"user", "session"}, time.Minute)
+	require.NoError(t, err)
+	id, err = tm.Decode(tk)
+	require.NoError(t, err)
+	assert.Empty(t, id.(ScopedIdentity).TokenID())
+	assert.Nil(t, id.(ScopedIdentity).TokenScopes())
+}
@@ -0,0 +1,540 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	// AlgEdDSA signs tokens with Ed25519 keys
+	AlgEdDSA = "EdDSA"
+	// AlgRS256 signs tokens with RSA keys
+	AlgRS256 = "RS256"
+
+	rsaKeyBits = 2048
+
+	activeFile    = "active"
+	privateSuffix = ".pem"
+	publicSuffix  = ".pub.pem"
+	retiredSuffix = ".retired"
+)
+
+var ErrKeyNotFound = errors.New("key not found")
+
+// Key to sign or verify tokens, retired keys have no private part
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// KeySet has the active signing key and verification keys including retired ones
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+// Active signing key
+func (ks *KeySet) Active() *Key {
+	return ks.active
+}
+
+// Key by id
+func (ks *KeySet) Key(kid string) (*Key, bool) {
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// KeyInfo describes a stored key
+type KeyInfo struct {
+	ID        string
+	Algorithm string
+	Active    bool
+	// Retired keys have the public part only, so they verify tokens but can not sign
+	Retired   bool
+	CreatedAt time.Time
+	// RetiredAt is zero for keys retired before it was recorded
+	RetiredAt time.Time
+}
+
+// KeyDir stores keys as PEM files: <kid>.pem private and <kid>.pub.pem public ones,
+// id of the active key is kept in the "active" file, <kid>.retired marks when the key was retired.
+// Keys that are neither active nor retired are published: they verify tokens and can be activated.
+type KeyDir struct {
+	path string
+}
+
+func NewKeyDir(path string) *KeyDir {
+	return &KeyDir{
+		path: path,
+	}
+}
+
+// Load keys, the active one must have its private part
+func (d *KeyDir) Load() (*KeySet, error) {
+	active, err := d.activeID()
+	if err != nil {
+		return nil, err
+	}
+	if active == "" {
+		return nil, fmt.Errorf("no active key in %s", d.path)
+	}
+
+	infos, err := d.List()
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &KeySet{
+		keys: make(map[string]*Key, len(infos)),
+	}
+	for _, info := range infos {
+		k, err := d.readPublic(info.ID)
+		if err != nil {
+			return nil, err
+		}
+		if info.Active {
+			if k.private, err = d.readPrivate(info.ID); err != nil {
+				return nil, err
+			}
+			ks.active = k
+		}
+		ks.keys[k.ID] = k
+	}
+
+	if ks.active == nil {
+		return nil, fmt.Errorf("active key %s: %w", active, ErrKeyNotFound)
+	}
+
+	return ks, nil
+}
+
+// List keys sorted by creation time
+func (d *KeyDir) List() ([]KeyInfo, error) {
+	active, err := d.activeID()
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := filepath.Glob(filepath.Join(d.path, "*"+publicSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("list keys: %w", err)
+	}
+
+	infos := make([]KeyInfo, 0, len(matches))
+	for _, m := range matches {
+		kid := strings.TrimSuffix(filepath.Base(m), publicSuffix)
+
+		k, err := d.readPublic(kid)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := os.Stat(m)
+		if err != nil {
+			return nil, fmt.Errorf("stat key: %w", err)
+		}
+		_, err = os.Stat(d.privatePath(kid))
+		hasPrivate := err == nil
+		var retiredAt time.Time
+		if rfi, err := os.Stat(d.retiredPath(kid)); err == nil {
+			retiredAt = rfi.ModTime()
+		}
+
+		infos = append(infos, KeyInfo{
+			ID:        kid,
+			Algorithm: k.Method.Alg(),
+			Active:    kid == active,
+			Retired:   !hasPrivate,
+			CreatedAt: fi.ModTime(),
+			RetiredAt: retiredAt,
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].CreatedAt.Equal(infos[j].CreatedAt) {
+			return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+		}
+		return infos[i].ID < infos[j].ID
+	})
+
+	return infos, nil
+}
+
+// Publish generates a new key verifying tokens only, so servers loading it accept tokens it signs
+// before it is activated. The first key of the dir is activated right away.
+func (d *KeyDir) Publish(alg string) (string, error) {
+	private, err := generateKey(alg)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(d.path, 0700); err != nil {
+		return "", fmt.Errorf("create key dir: %w", err)
+	}
+
+	active, err := d.activeID()
+	if err != nil {
+		return "", err
+	}
+
+	kid, err := newKeyID()
+	if err != nil {
+		return "", err
+	}
+
+	if err := d.write(kid, private); err != nil {
+		return "", err
+	}
+	if active == "" {
+		if err := d.setActive(kid); err != nil {
+			return "", err
+		}
+	}
+
+	return kid, nil
+}
+
+// Activate a published key to sign tokens, the previous active key is retired keeping its public part only,
+// so tokens it signed are still valid till their expiration
+func (d *KeyDir) Activate(kid string) error {
+	prev, err := d.activeID()
+	if err != nil {
+		return err
+	}
+	if kid == prev {
+		return fmt.Errorf("key %s is active already", kid)
+	}
+
+	if _, err := d.readPublic(kid); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%s: %w", kid, ErrKeyNotFound)
+		}
+		return err
+	}
+	if _, err := d.readPrivate(kid); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("key %s is retired, publish a new one", kid)
+		}
+		return err
+	}
+
+	if err := d.setActive(kid); err != nil {
+		return err
+	}
+
+	if prev != "" {
+		// marked first, so a retired key always has its time
+		if err := writeFileAtomic(d.retiredPath(prev), nil, 0644); err != nil {
+			return fmt.Errorf("retire key %s: %w", prev, err)
+		}
+		if err := os.Remove(d.privatePath(prev)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("retire key %s: %w", prev, err)
+		}
+	}
+
+	return nil
+}
+
+// Remove a retired or published key, tokens signed by it become invalid.
+// Keys retired less than keep ago are kept, as tokens they signed may be still valid.
+func (d *KeyDir) Remove(kid string, keep time.Duration) error {
+	active, err := d.activeID()
+	if err != nil {
+		return err
+	}
+	if kid == active {
+		return errors.New("active key can not be removed, rotate it first")
+	}
+
+	if fi, err := os.Stat(d.retiredPath(kid)); err == nil {
+		if until := fi.ModTime().Add(keep); time.Now().Before(until) {
+			return fmt.Errorf("key %s signed tokens valid till %s", kid, until.Format(time.RFC3339))
+		}
+	}
+
+	if err := os.Remove(d.publicPath(kid)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%s: %w", kid, ErrKeyNotFound)
+		}
+		return fmt.Errorf("remove key: %w", err)
+	}
+	for _, path := range []string{d.privatePath(kid), d.retiredPath(kid)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove key: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (d *KeyDir) setActive(kid string) error {
+	if err := writeFileAtomic(filepath.Join(d.path, activeFile), []byte(kid+"\n"), 0600); err != nil {
+		return fmt.Errorf("activate key: %w", err)
+	}
+	return nil
+}
+
+// modTime of the dir, it changes once a key file is written or removed as files are replaced by rename
+func (d *KeyDir) modTime() (time.Time, error) {
+	fi, err := os.Stat(d.path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("stat key dir: %w", err)
+	}
+	return fi.ModTime(), nil
+}
+
+func (d *KeyDir) activeID() (string, error) {
+	b, err := os.ReadFile(filepath.Join(d.path, activeFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("read active key: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func (d *KeyDir) write(kid string, private crypto.Signer) error {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return fmt.Errorf("marshal private key: %w", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return fmt.Errorf("marshal public key: %w", err)
+	}
+
+	// public part first, so a key is never listed without it
+	pub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+	if err := writeFileAtomic(d.publicPath(kid), pub, 0644); err != nil {
+		return fmt.Errorf("write public key: %w", err)
+	}
+	priv := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := writeFileAtomic(d.privatePath(kid), priv, 0600); err != nil {
+		return fmt.Errorf("write private key: %w", err)
+	}
+
+	return nil
+}
+
+func (d *KeyDir) readPublic(kid string) (*Key, error) {
+	der, err := readPEM(d.publicPath(kid), "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key %s: %w", kid, err)
+	}
+
+	k := &Key{ID: kid, public: pub}
+	switch pub.(type) {
+	case ed25519.PublicKey:
+		k.Method = jwt.SigningMethodEdDSA
+	case *rsa.PublicKey:
+		k.Method = jwt.SigningMethodRS256
+	default:
+		return nil, fmt.Errorf("key %s: unsupported type %T", kid, pub)
+	}
+
+	return k, nil
+}
+
+func (d *KeyDir) readPrivate(kid string) (crypto.PrivateKey, error) {
+	der, err := readPEM(d.privatePath(kid), "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	private, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key %s: %w", kid, err)
+	}
+	return private, nil
+}
+
+func (d *KeyDir) privatePath(kid string) string {
+	return filepath.Join(d.path, kid+privateSuffix)
+}
+
+func (d *KeyDir) publicPath(kid string) string {
+	return filepath.Join(d.path, kid+publicSuffix)
+}
+
+func (d *KeyDir) retiredPath(kid string) string {
+	return filepath.Join(d.path, kid+retiredSuffix)
+}
+
+// KeyReloader serves keys of the dir and loads them again once the dir is modified,
+// so published and activated keys are used without restart.
+// The dir is checked periodically after Start, issuing and decoding tokens never touch the disk.
+type KeyReloader struct {
+	dir *KeyDir
+
+	// keys hold *KeySet, replaced as a whole on reload
+	keys atomic.Value
+
+	// mu guards modTime of the last loaded dir
+	mu      sync.Mutex
+	modTime time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewKeyReloader with keys loaded
+func NewKeyReloader(d *KeyDir) (*KeyReloader, error) {
+	r := &KeyReloader{
+		dir: d,
+	}
+
+	mt, err := d.modTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(mt); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// KeySet in use
+func (r *KeyReloader) KeySet() *KeySet {
+	return r.keys.Load().(*KeySet)
+}
+
+// Reload keys if the dir is modified since the last load, a dir failing to load keeps the previous set in use
+func (r *KeyReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	mt, err := r.dir.modTime()
+	if err != nil {
+		return err
+	}
+	if mt.Equal(r.modTime) {
+		return nil
+	}
+	// a half-done rotation fails to load, it is retried on the next check
+	return r.load(mt)
+}
+
+// Start checking the dir every interval until Stop
+func (r *KeyReloader) Start(interval time.Duration) {
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-t.C:
+				_ = r.Reload()
+			}
+		}
+	}()
+}
+
+// Stop the checks
+func (r *KeyReloader) Stop() {
+	if r.stop != nil {
+		close(r.stop)
+		<-r.done
+	}
+}
+
+func (r *KeyReloader) load(modTime time.Time) error {
+	ks, err := r.dir.Load()
+	if err != nil {
+		return err
+	}
+	r.keys.Store(ks)
+	r.modTime = modTime
+	return nil
+}
+
+func generateKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case AlgEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate key: %w", err)
+		}
+		return private, nil
+	case AlgRS256:
+		private, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, fmt.Errorf("generate key: %w", err)
+		}
+		return private, nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, use %s or %s", alg, AlgEdDSA, AlgRS256)
+	}
+}
+
+// newKeyID starting with creation time
+func newKeyID() (string, error) {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate key id: %w", err)
+	}
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b), nil
+}
+
+func readPEM(path, blockType string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key: %w", err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s: no %s PEM block", path, blockType)
+	}
+	return block.Bytes, nil
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer func() {
+		_ = os.Remove(tmp)
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
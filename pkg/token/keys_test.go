@@ -0,0 +1,219 @@
+package token
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testIdentity struct {
+	id, sid string
+}
+
+func (i testIdentity) Identity() string {
+	return i.id
+}
+
+func (i testIdentity) Session() string {
+	return i.sid
+}
+
+func loadJWT(t *testing.T, d *KeyDir) *JWT {
+	ks, err := d.Load()
+	require.NoError(t, err)
+	tm, err := NewJWT("", WithKeySet(ks))
+	require.NoError(t, err)
+	return tm
+}
+
+func TestKeyDir_Rotate(t *testing.T) {
+	for _, alg := range []string{AlgEdDSA, AlgRS256} {
+		t.Run(alg, func(t *testing.T) {
+			d := NewKeyDir(filepath.Join(t.TempDir(), "keys"))
+
+			_, err := d.Load()
+			assert.Error(t, err, "no keys yet")
+
+			// the first key is active right away
+			first, err := d.Publish(alg)
+			require.NoError(t, err)
+			assert.Equal(t, first, d.mustActive(t))
+
+			tm := loadJWT(t, d)
+			old, err := tm.Issue(testIdentity{"user", "session"}, time.Minute)
+			require.NoError(t, err)
+
+			second, err := d.Publish(alg)
+			require.NoError(t, err)
+			assert.NotEqual(t, first, second)
+			assert.Equal(t, first, d.mustActive(t), "published key verifies only")
+
+			// servers with the published key accept tokens it signs before any of them activates it
+			published := loadJWT(t, d)
+			require.NoError(t, d.Activate(second))
+			tm = loadJWT(t, d)
+			fresh, err := tm.Issue(testIdentity{"user", "session"}, time.Minute)
+			require.NoError(t, err)
+			_, err = published.Decode(fresh)
+			assert.NoError(t, err)
+
+			// token signed by the retired key is still valid
+			id, err := tm.Decode(old)
+			require.NoError(t, err)
+			assert.Equal(t, "user", id.Identity())
+			assert.Equal(t, "session", id.(SessionIdentity).Session())
+
+			parsed, _, err := new(jwt.Parser).ParseUnverified(fresh, &JWTClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, second, parsed.Header["kid"])
+			assert.Equal(t, alg, parsed.Method.Alg())
+
+			infos, err := d.List()
+			require.NoError(t, err)
+			require.Len(t, infos, 2)
+			assert.Equal(t, first, infos[0].ID)
+			assert.True(t, infos[0].Retired)
+			assert.False(t, infos[0].Active)
+			assert.False(t, infos[0].RetiredAt.IsZero())
+			assert.True(t, infos[1].Active)
+			assert.False(t, infos[1].Retired)
+			assert.NoFileExists(t, filepath.Join(d.path, first+privateSuffix))
+
+			fi, err := os.Stat(filepath.Join(d.path, second+privateSuffix))
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+			assert.Error(t, d.Activate(second), "active already")
+			assert.Error(t, d.Activate(first), "retired")
+			assert.ErrorIs(t, d.Activate("missing"), ErrKeyNotFound)
+
+			assert.Error(t, d.Remove(second, 0), "active key")
+			assert.Error(t, d.Remove(first, time.Hour), "tokens it signed may be valid")
+			require.NoError(t, d.Remove(first, 0))
+			assert.ErrorIs(t, d.Remove(first, 0), ErrKeyNotFound)
+			assert.NoFileExists(t, filepath.Join(d.path, first+retiredSuffix))
+
+			tm = loadJWT(t, d)
+			_, err = tm.Decode(old)
+			assert.Error(t, err, "removed key")
+			_, err = tm.Decode(fresh)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestKeyReloader(t *testing.T) {
+	d := NewKeyDir(filepath.Join(t.TempDir(), "keys"))
+	first, err := d.Publish(AlgEdDSA)
+	require.NoError(t, err)
+
+	r, err := NewKeyReloader(d)
+	require.NoError(t, err)
+	tm, err := NewJWT("", WithKeyReloader(r))
+	require.NoError(t, err)
+	assert.Equal(t, first, r.KeySet().Active().ID)
+
+	second, err := d.Publish(AlgEdDSA)
+	require.NoError(t, err)
+	require.NoError(t, d.Activate(second))
+
+	// keys are not reloaded on use
+	assert.Equal(t, first, r.KeySet().Active().ID)
+
+	// a modified dir is loaded again by the periodic check
+	r.Start(10 * time.Millisecond)
+	defer r.Stop()
+	assert.Eventually(t, func() bool {
+		return r.KeySet().Active().ID == second
+	}, time.Second, 10*time.Millisecond)
+
+	issued, err := tm.Issue(testIdentity{"user", "session"}, time.Minute)
+	require.NoError(t, err)
+	parsed, _, err := new(jwt.Parser).ParseUnverified(issued, &JWTClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, second, parsed.Header["kid"])
+
+	// a broken dir keeps the previous keys in use
+	require.NoError(t, os.Remove(filepath.Join(d.path, second+privateSuffix)))
+	assert.Error(t, r.Reload())
+	assert.Equal(t, second, r.KeySet().Active().ID)
+	_, err = tm.Decode(issued)
+	assert.NoError(t, err)
+}
+
+func TestJWT_KeySetRejectsHMAC(t *testing.T) {
+	d := NewKeyDir(t.TempDir())
+	_, err := d.Publish(AlgEdDSA)
+	require.NoError(t, err)
+
+	hs, err := NewJWT("CHANGE_ME")
+	require.NoError(t, err)
+	forged, err := hs.Issue(testIdentity{"user", "session"}, time.Minute)
+	require.NoError(t, err)
+
+	tm := loadJWT(t, d)
+	_, err = tm.Decode(forged)
+	assert.Error(t, err)
+
+	// HMAC token signed with the public key as a secret
+	pub, err := os.ReadFile(filepath.Join(d.path, d.mustActive(t)+publicSuffix))
+	require.NoError(t, err)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &JWTClaims{StandardClaims: jwt.StandardClaims{Id: "user"}})
+	token.Header["kid"] = d.mustActive(t)
+	confused, err := token.SignedString(pub)
+	require.NoError(t, err)
+	_, err = tm.Decode(confused)
+	assert.Error(t, err)
+}
+
+func TestJWT_KeySetAcceptsLegacyHMAC(t *testing.T) {
+	d := NewKeyDir(t.TempDir())
+	_, err := d.Publish(AlgEdDSA)
+	require.NoError(t, err)
+	ks, err := d.Load()
+	require.NoError(t, err)
+
+	hs, err := NewJWT("legacy")
+	require.NoError(t, err)
+	legacy, err := hs.Issue(testIdentity{"user", "session"}, time.Minute)
+	require.NoError(t, err)
+
+	tm, err := NewJWT("legacy", WithKeySet(ks))
+	require.NoError(t, err)
+	id, err := tm.Decode(legacy)
+	require.NoError(t, err)
+	assert.Equal(t, "user", id.Identity())
+
+	// new tokens are signed with the active key
+	issued, err := tm.Issue(testIdentity{"user", "session"}, time.Minute)
+	require.NoError(t, err)
+	parsed, _, err := new(jwt.Parser).ParseUnverified(issued, &JWTClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, AlgEdDSA, parsed.Method.Alg())
+
+	// HS256 tokens signed with another secret or naming a key id are rejected
+	other, err := NewJWT("other")
+	require.NoError(t, err)
+	forged, err := other.Issue(testIdentity{"user", "session"}, time.Minute)
+	require.NoError(t, err)
+	_, err = tm.Decode(forged)
+	assert.Error(t, err)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &JWTClaims{StandardClaims: jwt.StandardClaims{Id: "user"}})
+	token.Header["kid"] = "unknown"
+	withKid, err := token.SignedString([]byte("legacy"))
+	require.NoError(t, err)
+	_, err = tm.Decode(withKid)
+	assert.Error(t, err)
+}
+
+func (d *KeyDir) mustActive(t *testing.T) string {
+	kid, err := d.activeID()
+	require.NoError(t, err)
+	return kid
+}
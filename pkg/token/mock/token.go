@@ -49,6 +49,122 @@ func (mr *MockIdentityMockRecorder) Identity() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Identity", reflect.TypeOf((*MockIdentity)(nil).Identity))
 }
 
+// MockSessionIdentity is a mock of SessionIdentity interface.
+type MockSessionIdentity struct {
+	ctrl     *gomock.Controller
+	recorder *MockSessionIdentityMockRecorder
+}
+
+// MockSessionIdentityMockRecorder is the mock recorder for MockSessionIdentity.
+type MockSessionIdentityMockRecorder struct {
+	mock *MockSessionIdentity
+}
+
+// NewMockSessionIdentity creates a new mock instance.
+func NewMockSessionIdentity(ctrl *gomock.Controller) *MockSessionIdentity {
+	mock := &MockSessionIdentity{ctrl: ctrl}
+	mock.recorder = &MockSessionIdentityMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSessionIdentity) EXPECT() *MockSessionIdentityMockRecorder {
+	return m.recorder
+}
+
+// Identity mocks base method.
+func (m *MockSessionIdentity) Identity() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Identity")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Identity indicates an expected call of Identity.
+func (mr *MockSessionIdentityMockRecorder) Identity() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Identity", reflect.TypeOf((*MockSessionIdentity)(nil).Identity))
+}
+
+// Session mocks base method.
+func (m *MockSessionIdentity) Session() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Session")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Session indicates an expected call of Session.
+func (mr *MockSessionIdentityMockRecorder) Session() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Session", reflect.TypeOf((*MockSessionIdentity)(nil).Session))
+}
+
+// MockScopedIdentity is a mock of ScopedIdentity interface.
+type MockScopedIdentity struct {
+	ctrl     *gomock.Controller
+	recorder *MockScopedIdentityMockRecorder
+}
+
+// MockScopedIdentityMockRecorder is the mock recorder for MockScopedIdentity.
+type MockScopedIdentityMockRecorder struct {
+	mock *MockScopedIdentity
+}
+
+// NewMockScopedIdentity creates a new mock instance.
+func NewMockScopedIdentity(ctrl *gomock.Controller) *MockScopedIdentity {
+	mock := &MockScopedIdentity{ctrl: ctrl}
+	mock.recorder = &MockScopedIdentityMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockScopedIdentity) EXPECT() *MockScopedIdentityMockRecorder {
+	return m.recorder
+}
+
+// Identity mocks base method.
+func (m *MockScopedIdentity) Identity() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Identity")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Identity indicates an expected call of Identity.
+func (mr *MockScopedIdentityMockRecorder) Identity() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Identity", reflect.TypeOf((*MockScopedIdentity)(nil).Identity))
+}
+
+// TokenID mocks base method.
+func (m *MockScopedIdentity) TokenID() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TokenID")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// TokenID indicates an expected call of TokenID.
+func (mr *MockScopedIdentityMockRecorder) TokenID() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TokenID", reflect.TypeOf((*MockScopedIdentity)(nil).TokenID))
+}
+
+// TokenScopes mocks base method.
+func (m *MockScopedIdentity) TokenScopes() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TokenScopes")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// TokenScopes indicates an expected call of TokenScopes.
+func (mr *MockScopedIdentityMockRecorder) TokenScopes() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TokenScopes", reflect.TypeOf((*MockScopedIdentity)(nil).TokenScopes))
+}
+
 // MockManager is a mock of Manager interface.
 type MockManager struct {
 	ctrl     *gomock.Controller
@@ -0,0 +1,72 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 4226 appendix D test key
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestHOTP(t *testing.T) {
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for i, w := range want {
+		assert.Equal(t, w, hotp([]byte("12345678901234567890"), uint64(i)))
+	}
+}
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B SHA1 vectors, last 6 of 8 digits
+	tests := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for ts, want := range tests {
+		got, err := Code(rfcSecret, time.Unix(ts, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, ts)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+
+	now := time.Unix(1666000000, 0)
+	code, err := Code(secret, now)
+	require.NoError(t, err)
+
+	step, ok := Validate(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	// skew
+	step, ok = Validate(secret, code, now.Add(Period))
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+	_, ok = Validate(secret, code, now.Add(-Period))
+	assert.True(t, ok)
+
+	_, ok = Validate(secret, code, now.Add(3*Period))
+	assert.False(t, ok)
+	_, ok = Validate(secret, "12345", now)
+	assert.False(t, ok)
+	_, ok = Validate("not base32!", code, now)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("gophkeeper", "user@example.org", "ABC")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/gophkeeper:user@example.org?"), uri)
+	assert.Contains(t, uri, "secret=ABC")
+	assert.Contains(t, uri, "issuer=gophkeeper")
+}
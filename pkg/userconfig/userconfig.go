@@ -44,6 +44,11 @@ func (t *UserConfig) Viper(name string) *viper.Viper {
 	return v
 }
 
+// Path of a named file in the config directory
+func (t *UserConfig) Path(name string) string {
+	return filepath.Join(t.cfgDir, name)
+}
+
 // ensureDir at path exists
 func ensureDir(path string) error {
 	if _, err := os.Stat(path); err == nil {
@@ -3,6 +3,7 @@ package usercontext
 import (
 	"context"
 	"github.com/google/uuid"
+	"gophkeeper/pkg/scope"
 )
 
 var EmptyUUID = uuid.NullUUID{
@@ -11,6 +12,54 @@ var EmptyUUID = uuid.NullUUID{
 
 type ContextKeyUID struct{}
 
+type ContextKeySessionID struct{}
+
+type ContextKeyPrincipal struct{}
+
+// Principal is the authenticated caller, either a session with full access or an API token limited to scopes.
+// Service accounts act on behalf of their owner and are limited by the account grants.
+type Principal struct {
+	UserID           uuid.UUID
+	SessionID        uuid.UUID
+	TokenID          uuid.UUID
+	Scopes           scope.Set
+	ServiceAccountID uuid.UUID
+	Grants           scope.Set
+}
+
+// Scoped principal is an API token or a service account
+func (p Principal) Scoped() bool {
+	return p.TokenID != uuid.Nil || p.ServiceAccountID != uuid.Nil
+}
+
+// Allows action on the secret with the name and tags, sessions are allowed everything
+func (p Principal) Allows(action, name string, tags []string) bool {
+	if p.TokenID != uuid.Nil && !p.Scopes.Allows(action, name, tags) {
+		return false
+	}
+	if p.ServiceAccountID != uuid.Nil && !p.Grants.Allows(action, name, tags) {
+		return false
+	}
+	return true
+}
+
+// Tagged reports if tags of a secret may allow the action, so they have to be read to decide
+func (p Principal) Tagged(action string) bool {
+	return (p.TokenID != uuid.Nil && p.Scopes.Tagged(action)) ||
+		(p.ServiceAccountID != uuid.Nil && p.Grants.Tagged(action))
+}
+
+// AllowsAny name for the action, sessions are allowed everything
+func (p Principal) AllowsAny(action string) bool {
+	if p.TokenID != uuid.Nil && !p.Scopes.AllowsAny(action) {
+		return false
+	}
+	if p.ServiceAccountID != uuid.Nil && !p.Grants.AllowsAny(action) {
+		return false
+	}
+	return true
+}
+
 func ReadContextString(ctx context.Context, key interface{}) string {
 	v := ctx.Value(key)
 	if v == nil {
@@ -45,3 +94,26 @@ func ReadUID(ctx context.Context) uuid.NullUUID {
 func WriteUID(ctx context.Context, uid uuid.UUID) context.Context {
 	return context.WithValue(ctx, ContextKeyUID{}, uid)
 }
+
+func ReadSessionID(ctx context.Context) uuid.NullUUID {
+	return ReadContextUUID(ctx, ContextKeySessionID{})
+}
+
+func WriteSessionID(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, ContextKeySessionID{}, id)
+}
+
+func ReadPrincipal(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ContextKeyPrincipal{}).(Principal)
+	return p, ok
+}
+
+// WritePrincipal with its user and session if any
+func WritePrincipal(ctx context.Context, p Principal) context.Context {
+	ctx = context.WithValue(ctx, ContextKeyPrincipal{}, p)
+	ctx = WriteUID(ctx, p.UserID)
+	if p.SessionID != uuid.Nil {
+		ctx = WriteSessionID(ctx, p.SessionID)
+	}
+	return ctx
+}